@@ -0,0 +1,65 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// derive the admin REST base url from the broker service url if the admin url is not configured
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// brokerWebServicePorts are the default broker web service ports of the binary protocol schemes
+var brokerWebServicePorts = map[string]struct {
+	scheme string
+	port   string
+}{
+	"pulsar":     {scheme: "http", port: "8080"},
+	"pulsar+ssl": {scheme: "https", port: "8443"},
+}
+
+// deriveAdminURL returns the broker web service url on the host of the pulsar service url,
+// i.e. http://host:8080 of pulsar://host:6650 and https://host:8443 of pulsar+ssl://host:6651
+func deriveAdminURL(pulsarURL string) (string, error) {
+	u, err := url.Parse(pulsarURL)
+	if err != nil {
+		return "", err
+	}
+	web, ok := brokerWebServicePorts[u.Scheme]
+	if !ok || u.Hostname() == "" {
+		return "", fmt.Errorf("unable to derive the admin url from the pulsar url %s", pulsarURL)
+	}
+	return web.scheme + "://" + net.JoinHostPort(u.Hostname(), web.port), nil
+}
+
+// adminURL returns the configured admin url, or the admin url derived from the pulsar url if not specified
+func adminURL(topicCfg TopicCfg) string {
+	if topicCfg.AdminURL != "" {
+		return topicCfg.AdminURL
+	}
+	derived, err := deriveAdminURL(topicCfg.PulsarURL)
+	if err != nil {
+		return ""
+	}
+	return derived
+}
@@ -0,0 +1,50 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+)
+
+func TestDeriveAdminURL(t *testing.T) {
+	for pulsarURL, expected := range map[string]string{
+		"pulsar://broker.example.com:6650":      "http://broker.example.com:8080",
+		"pulsar+ssl://proxy.example.com:6651":   "https://proxy.example.com:8443",
+		"pulsar+ssl://proxy.example.com":        "https://proxy.example.com:8443",
+		"pulsar://[2001:db8::1]:6650":           "http://[2001:db8::1]:8080",
+		"pulsar://broker.example.com:16650/abc": "http://broker.example.com:8080",
+	} {
+		derived, err := deriveAdminURL(pulsarURL)
+		errNil(t, err)
+		assert(t, derived == expected, "expect %s derived from %s but got %s", expected, pulsarURL, derived)
+	}
+	for _, invalid := range []string{"http://broker.example.com:8080", "pulsar://", "::invalid"} {
+		_, err := deriveAdminURL(invalid)
+		assert(t, err != nil, "expect no admin url derived from %s", invalid)
+	}
+
+	topicCfg := TopicCfg{PulsarURL: "pulsar+ssl://proxy.example.com:6651", AdminURL: "https://admin.example.com"}
+	assert(t, adminURL(topicCfg) == "https://admin.example.com", "expect the configured admin url")
+	topicCfg.AdminURL = ""
+	assert(t, adminURL(topicCfg) == "https://proxy.example.com:8443", "expect the derived admin url but got %s", adminURL(topicCfg))
+	assert(t, adminURL(TopicCfg{}) == "", "expect no admin url without the pulsar url")
+}
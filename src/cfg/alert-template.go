@@ -0,0 +1,95 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// render the incident message with the alert policy template
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// AlertContext is the data of the alert policy message template
+type AlertContext struct {
+	Component string
+	Cluster   string
+	// Message is the default incident message
+	Message string
+	// Error is the failure description
+	Error     string
+	LatencyMs int64
+	BudgetMs  int64
+}
+
+// alertMessage renders the message template with the context,
+// the default message is returned if the template is not specified or fails to render
+func (a *AlertPolicyCfg) alertMessage(ctx AlertContext) string {
+	if a.MessageTemplate == "" {
+		return ctx.Message
+	}
+	msg, err := renderAlertTemplate(a.MessageTemplate, ctx)
+	if err != nil {
+		log.Errorf("failed to render the alert message template of %s, error %v", ctx.Component, err)
+		return ctx.Message
+	}
+	return msg
+}
+
+// renderAlertTemplate renders the Go template text with the context
+func renderAlertTemplate(text string, ctx AlertContext) (string, error) {
+	tmpl, err := template.New("alert").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var rendered strings.Builder
+	if err := tmpl.Execute(&rendered, ctx); err != nil {
+		return "", err
+	}
+	return rendered.String(), nil
+}
+
+// alertContext returns the template context of the incident
+func alertContext(component, alias, msg, desc string, eval *AlertPolicyCfg) AlertContext {
+	return AlertContext{
+		Component: component,
+		Cluster:   util.FirstNonEmptyString(eval.cluster, alias),
+		Message:   msg,
+		Error:     desc,
+	}
+}
+
+// ReportLatencyIncident reports an incident of the latency over the budget,
+// the latency and the budget are available to the message template
+func ReportLatencyIncident(component, alias, msg, desc string, latency, budget time.Duration, eval *AlertPolicyCfg) bool {
+	ctx := alertContext(component, alias, msg, desc, eval)
+	ctx.LatencyMs, ctx.BudgetMs = latency.Milliseconds(), budget.Milliseconds()
+	return reportIncidentWithContext(ctx, alias, eval)
+}
+
+// reportIncidentWithContext reports the incident with the rendered message
+func reportIncidentWithContext(ctx AlertContext, alias string, eval *AlertPolicyCfg) bool {
+	return reportIncident(ctx, alias, eval.alertMessage(ctx), eval)
+}
@@ -0,0 +1,80 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertMessageTemplate(t *testing.T) {
+	policy := AlertPolicyCfg{cluster: "cluster1.example.com"}
+	ctx := alertContext("cluster1.example.com", "alias", "persisted latency test failure", "latency over the budget", &policy)
+	ctx.LatencyMs, ctx.BudgetMs = 1500, 1000
+
+	msg := policy.alertMessage(ctx)
+	assert(t, msg == "persisted latency test failure", "expect the default message without a template but got %s", msg)
+
+	policy.MessageTemplate = "{{.Component}} latency {{.LatencyMs}}ms over {{.BudgetMs}}ms on {{.Cluster}}: {{.Error}}, runbook https://runbooks.example.com/latency"
+	msg = policy.alertMessage(ctx)
+	expected := "cluster1.example.com latency 1500ms over 1000ms on cluster1.example.com: latency over the budget, runbook https://runbooks.example.com/latency"
+	assert(t, msg == expected, "expect the rendered message %s but got %s", expected, msg)
+
+	// the alias is the cluster if it is not assigned from the configuration
+	ctx = alertContext("site1", "site-alias", "site failure", "timeout", &AlertPolicyCfg{})
+	assert(t, ctx.Cluster == "site-alias", "expect the alias as the cluster but got %s", ctx.Cluster)
+
+	// fall back to the default message on a template error
+	for _, invalid := range []string{"{{.Component", "{{.Unknown}}"} {
+		policy.MessageTemplate = invalid
+		msg = policy.alertMessage(ctx)
+		assert(t, msg == "site failure", "expect the default message with the template %s but got %s", invalid, msg)
+	}
+}
+
+func TestReportLatencyIncidentTemplate(t *testing.T) {
+	defer withoutAlertDestinations()()
+	component := "latency-template-component"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+	}()
+	var slackText string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackMessage
+		errNil(t, json.NewDecoder(r.Body).Decode(&msg))
+		slackText = msg.Text
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slack.Close()
+	Config.SlackConfig.AlertURL = slack.URL
+
+	policy := AlertPolicyCfg{Ceiling: 1, MessageTemplate: "{{.Component}} {{.LatencyMs}}/{{.BudgetMs}} ms"}
+	assert(t, ReportLatencyIncident(component, component, "latency failure", "desc", 2*time.Second, time.Second, &policy), "expect an incident created")
+	expected := "message " + component + " 2000/1000 ms, description desc"
+	assert(t, strings.Contains(slackText, expected), "expect the Slack alert with %s but got %s", expected, slackText)
+}
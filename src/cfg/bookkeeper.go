@@ -0,0 +1,137 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// monitor bookkeeper ledger replication and autorecovery over the bookie http admin endpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// GetUnderReplicatedLedgers returns the number of under replicated ledgers reported by the bookie autorecovery
+func GetUnderReplicatedLedgers(bookieURL string) (int, error) {
+	statusCode, body, err := bookieHTTPGet(util.SingleSlashJoin(bookieURL, "api/v1/autorecovery/list_under_replicated_ledger"))
+	if err != nil {
+		return 0, err
+	}
+	return parseUnderReplicatedLedgers(statusCode, body)
+}
+
+// parseUnderReplicatedLedgers parses the response of list_under_replicated_ledger
+// the bookie returns either a list of ledger ids, or a map of ledger ids to the missing replicas,
+// and 404 when no under replicated ledger is found
+func parseUnderReplicatedLedgers(statusCode int, body []byte) (int, error) {
+	if statusCode == http.StatusNotFound {
+		return 0, nil
+	} else if statusCode != http.StatusOK {
+		return 0, fmt.Errorf("list under replicated ledger returns incorrect status code %d", statusCode)
+	}
+
+	var ledgers []int64
+	if err := json.Unmarshal(body, &ledgers); err == nil {
+		return len(ledgers), nil
+	}
+	var ledgersWithMissingReplica map[string][]string
+	if err := json.Unmarshal(body, &ledgersWithMissingReplica); err != nil {
+		return 0, fmt.Errorf("failed to parse under replicated ledger response: %w", err)
+	}
+	return len(ledgersWithMissingReplica), nil
+}
+
+// IsAuditorElected returns whether the autorecovery has an elected auditor
+func IsAuditorElected(bookieURL string) (bool, error) {
+	statusCode, _, err := bookieHTTPGet(util.SingleSlashJoin(bookieURL, "api/v1/autorecovery/who_is_auditor"))
+	if err != nil {
+		return false, err
+	}
+	return statusCode == http.StatusOK, nil
+}
+
+func bookieHTTPGet(url string) (int, []byte, error) {
+	client := newHTTPClient(10 * time.Second)
+	resp, err := client.Get(url)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+// TestBookkeeperLedgers evaluates and reports the under replicated ledgers and autorecovery
+func TestBookkeeperLedgers() {
+	bkCfg := GetConfig().BookkeeperConfig
+	component := GetConfig().Name + "-bookkeeper-ledgers"
+
+	ledgers, err := GetUnderReplicatedLedgers(bkCfg.BookieHTTPURL)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s failed to get under replicated ledgers from %s, error: %v", component, bkCfg.BookieHTTPURL, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "bookkeeper ledger test failure", errMsg, &bkCfg.AlertPolicy)
+		return
+	}
+	PromGaugeInt(UnderReplicatedLedgersGaugeOpt(), GetConfig().Name, ledgers)
+
+	if ledgers > bkCfg.UnderReplicatedLedgersThreshold {
+		errMsg := fmt.Sprintf("%s has %d under replicated ledgers over the threshold %d", component, ledgers, bkCfg.UnderReplicatedLedgersThreshold)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "bookkeeper has under replicated ledgers", errMsg, &bkCfg.AlertPolicy)
+		return
+	}
+
+	if elected, err := IsAuditorElected(bkCfg.BookieHTTPURL); err != nil || !elected {
+		errMsg := fmt.Sprintf("%s autorecovery has no auditor running, error: %v", component, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "bookkeeper autorecovery is not running", errMsg, &bkCfg.AlertPolicy)
+		return
+	}
+
+	log.Infof("%s has %d under replicated ledgers", component, ledgers)
+	ClearIncident(component)
+}
+
+// MonitorBookkeeperLedgers starts the bookkeeper ledger monitoring thread
+func MonitorBookkeeperLedgers() {
+	runProbes(bookkeeperProbes())
+}
+
+// bookkeeperProbes is the under replicated ledgers probe if the bookie http url is configured
+func bookkeeperProbes() []probe {
+	bkCfg := GetConfig().BookkeeperConfig
+	if bkCfg.BookieHTTPURL == "" {
+		return nil
+	}
+	return []probe{{
+		name:     GetConfig().Name + "-bookkeeper-ledgers",
+		interval: IntervalDuration(bkCfg.IntervalSeconds, 300),
+		run:      TestBookkeeperLedgers,
+	}}
+}
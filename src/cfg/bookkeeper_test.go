@@ -0,0 +1,44 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseUnderReplicatedLedgers(t *testing.T) {
+	count, err := parseUnderReplicatedLedgers(http.StatusOK, []byte(`[12, 35, 106]`))
+	errNil(t, err)
+	assert(t, count == 3, "expect 3 under replicated ledgers but got %d", count)
+
+	count, err = parseUnderReplicatedLedgers(http.StatusOK, []byte(`{"12": ["bookie-0:3181"], "35": ["bookie-0:3181", "bookie-2:3181"]}`))
+	errNil(t, err)
+	assert(t, count == 2, "expect 2 under replicated ledgers with missing replicas but got %d", count)
+
+	count, err = parseUnderReplicatedLedgers(http.StatusNotFound, []byte("No under replicated ledgers found"))
+	errNil(t, err)
+	assert(t, count == 0, "expect no under replicated ledger")
+
+	_, err = parseUnderReplicatedLedgers(http.StatusForbidden, []byte{})
+	assert(t, err != nil, "expect error on unexpected status code")
+}
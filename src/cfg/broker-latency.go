@@ -0,0 +1,87 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// tell the broker side from the client side slowness of the latency test
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/apex/log"
+)
+
+const (
+	brokerSideSlowness = "broker"
+	clientSideSlowness = "client"
+)
+
+// brokerLatency returns the larger of the broker reported publish and storage write latency
+func brokerLatency(stats topicStats) time.Duration {
+	ms := math.Max(stats.PublishLatency, stats.StorageWriteLatency)
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// classifySlowness returns where the client latency over the budget is spent, it is broker side if the broker
+// reported latency accounts for at least half of the client latency. It is empty within the budget.
+func classifySlowness(clientLatency, brokerLatency, budget time.Duration) string {
+	if clientLatency <= budget {
+		return ""
+	}
+	if brokerLatency*2 >= clientLatency {
+		return brokerSideSlowness
+	}
+	return clientSideSlowness
+}
+
+// TestBrokerLatency exports the broker reported latency of the test topic and reports the broker side
+// or the client side slowness incident when the client latency is over the budget
+func TestBrokerLatency(clusterName string, tokenSupplier func() (string, error), topicCfg TopicCfg, clientLatency, budget time.Duration) {
+	brokerComponent := clusterName + "-broker-slowness"
+	clientComponent := clusterName + "-client-slowness"
+	admin := restTopicAdmin{baseURL: adminURL(topicCfg), tokenSupplier: tokenSupplier}
+	stats, err := admin.TopicStats(topicCfg.TopicName)
+	if err != nil {
+		log.Errorf("cluster %s failed to get the topic stats of %s for the broker latency, error: %v", clusterName, topicCfg.TopicName, err)
+		return
+	}
+	PromGauge(BrokerPublishLatencyGaugeOpt(), clusterName, stats.PublishLatency)
+	PromGauge(BrokerStorageWriteLatencyGaugeOpt(), clusterName, stats.StorageWriteLatency)
+
+	broker := brokerLatency(stats)
+	switch classifySlowness(clientLatency, broker, budget) {
+	case brokerSideSlowness:
+		errMsg := fmt.Sprintf("cluster %s latency %v over the budget %v is broker side, the broker reports %v", clusterName, clientLatency, budget, broker)
+		log.Errorf(errMsg)
+		ReportLatencyIncident(brokerComponent, clusterName, "broker side latency over the budget", errMsg, clientLatency, budget, &topicCfg.AlertPolicy)
+		ClearIncident(clientComponent)
+	case clientSideSlowness:
+		errMsg := fmt.Sprintf("cluster %s latency %v over the budget %v is client side, the broker only reports %v", clusterName, clientLatency, budget, broker)
+		log.Errorf(errMsg)
+		ReportLatencyIncident(clientComponent, clusterName, "client side latency over the budget", errMsg, clientLatency, budget, &topicCfg.AlertPolicy)
+		ClearIncident(brokerComponent)
+	default:
+		ClearIncident(brokerComponent)
+		ClearIncident(clientComponent)
+	}
+}
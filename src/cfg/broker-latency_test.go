@@ -0,0 +1,53 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBrokerLatencyStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"msgRateIn": 2.0, "publishLatency": 850.5, "storageWriteLatency": 1200.25, "subscriptions": {}}`))
+	}))
+	defer server.Close()
+
+	stats, err := restTopicAdmin{baseURL: server.URL}.TopicStats("persistent://tenant/ns/latency")
+	errNil(t, err)
+	assert(t, stats.PublishLatency == 850.5, "expect publishLatency 850.5 but got %f", stats.PublishLatency)
+	assert(t, stats.StorageWriteLatency == 1200.25, "expect storageWriteLatency 1200.25 but got %f", stats.StorageWriteLatency)
+	assert(t, brokerLatency(stats) == 1200250*time.Microsecond, "expect the larger broker latency but got %v", brokerLatency(stats))
+
+	stats.PublishLatency, stats.StorageWriteLatency = 0, 0
+	assert(t, brokerLatency(stats) == 0, "expect no broker latency if not reported")
+}
+
+func TestClassifySlowness(t *testing.T) {
+	budget := time.Second
+	assert(t, classifySlowness(800*time.Millisecond, 700*time.Millisecond, budget) == "", "expect no slowness within the budget")
+	assert(t, classifySlowness(2*time.Second, 1500*time.Millisecond, budget) == brokerSideSlowness, "expect the broker side slowness")
+	assert(t, classifySlowness(2*time.Second, 100*time.Millisecond, budget) == clientSideSlowness, "expect the client side slowness")
+	assert(t, classifySlowness(2*time.Second, 0, budget) == clientSideSlowness, "expect the client side slowness without the broker latency")
+}
@@ -29,18 +29,59 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/apex/log"
 	"github.com/datastax/pulsar-heartbeat/src/util"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var statsLog = log.WithFields(log.Fields{"app": "broker health monitor"})
 
+// cachedBrokers is the list of brokers fetched at the time
+type cachedBrokers struct {
+	brokers   []string
+	fetchedAt time.Time
+}
+
+var (
+	// key is the admin url and the cluster name
+	brokersCache     = make(map[string]cachedBrokers)
+	brokersCacheLock = &sync.Mutex{}
+)
+
 // GetBrokers gets a list of brokers and ports
+// the list is cached for adminCacheTtlSeconds, it is fetched on every call if the ttl is not specified
 func GetBrokers(restBaseURL, clusterName string, tokenSupplier func() (string, error)) ([]string, error) {
+	ttl := time.Duration(GetConfig().AdminCacheTTLSeconds) * time.Second
+	if ttl <= 0 {
+		return fetchBrokers(restBaseURL, clusterName, tokenSupplier)
+	}
+
+	key := restBaseURL + "|" + clusterName
+	brokersCacheLock.Lock()
+	cached, ok := brokersCache[key]
+	brokersCacheLock.Unlock()
+	if ok && time.Since(cached.fetchedAt) < ttl {
+		return cached.brokers, nil
+	}
+
+	brokers, err := fetchBrokers(restBaseURL, clusterName, tokenSupplier)
+	if err != nil {
+		return nil, err
+	}
+	brokersCacheLock.Lock()
+	brokersCache[key] = cachedBrokers{brokers: brokers, fetchedAt: time.Now()}
+	brokersCacheLock.Unlock()
+	return brokers, nil
+}
+
+// fetchBrokers gets a list of brokers and ports from the admin REST api
+func fetchBrokers(restBaseURL, clusterName string, tokenSupplier func() (string, error)) ([]string, error) {
 	brokersURL := util.SingleSlashJoin(restBaseURL, "admin/v2/brokers/"+clusterName)
 	newRequest, err := http.NewRequest(http.MethodGet, brokersURL, nil)
 	if err != nil {
@@ -54,11 +95,13 @@ func GetBrokers(restBaseURL, clusterName string, tokenSupplier func() (string, e
 		}
 		newRequest.Header.Add("Authorization", "Bearer "+token)
 	}
-	client := &http.Client{
-		CheckRedirect: util.PreserveHeaderForRedirect,
-		Timeout:       10 * time.Second,
+	client, err := adminHTTPClient(10 * time.Second)
+	if err != nil {
+		return nil, err
 	}
+	start := time.Now()
 	resp, err := client.Do(newRequest)
+	PromAdminRequest("brokers", clusterName, responseStatusCode(resp), time.Since(start))
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -97,11 +140,13 @@ func BrokerTopicsQuery(brokerBaseURL, token string) ([]string, error) {
 	}
 	newRequest.Header.Add("user-agent", "pulsar-heartbeat")
 	newRequest.Header.Add("Authorization", "Bearer "+token)
-	client := &http.Client{
-		CheckRedirect: util.PreserveHeaderForRedirect,
-		Timeout:       10 * time.Second,
+	client, err := adminHTTPClient(10 * time.Second)
+	if err != nil {
+		return nil, err
 	}
+	start := time.Now()
 	response, err := client.Do(newRequest)
+	PromAdminRequest("broker-stats-topics", newRequest.URL.Host, responseStatusCode(response), time.Since(start))
 	if response != nil {
 		defer response.Body.Close()
 	}
@@ -137,7 +182,7 @@ func BrokerTopicsQuery(brokerBaseURL, token string) ([]string, error) {
 }
 
 // ConnectBrokerHealthcheckTopic reads the latest messages off broker's healthcheck topic
-func ConnectBrokerHealthcheckTopic(brokerURL, clusterName, pulsarURL string, tokenSupplier func() (string, error), completeChan chan error) {
+func ConnectBrokerHealthcheckTopic(brokerURL, clusterName, pulsarURL string, tokenSupplier func() (string, error), trustStore string, completeChan chan error) {
 	// "persistent://pulsar/{cluster}/10.244.7.85:8080/healthcheck"
 	brokerAddr := util.SingleSlashJoin(strings.ReplaceAll(brokerURL, "http://", ""), "healthcheck")
 	defer func() {
@@ -146,7 +191,7 @@ func ConnectBrokerHealthcheckTopic(brokerURL, clusterName, pulsarURL string, tok
 			log.Errorf("cluster %s individual broker %s test timed out", clusterName, brokerAddr)
 		}
 	}()
-	client, err := GetPulsarClient(pulsarURL, tokenSupplier)
+	client, err := GetPulsarClientWithTrustStore(pulsarURL, tokenSupplier, trustStore)
 	if err != nil {
 		completeChan <- err
 		return
@@ -186,10 +231,12 @@ func ConnectBrokerHealthcheckTopic(brokerURL, clusterName, pulsarURL string, tok
 }
 
 // EvaluateBrokers evaluates all brokers' health
-func EvaluateBrokers(urlPrefix, clusterName, pulsarURL string, tokenSupplier func() (string, error), duration time.Duration) (int, error) {
+// returns the number of failed brokers and the total number of brokers
+// the trust store overrides the global trust store if it is specified
+func EvaluateBrokers(urlPrefix, clusterName, pulsarURL string, tokenSupplier func() (string, error), trustStore string, duration time.Duration) (int, int, error) {
 	brokers, err := GetBrokers(urlPrefix, clusterName, tokenSupplier)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
 	statsLog.Infof("a list of brokers %v", brokers)
@@ -200,7 +247,7 @@ func EvaluateBrokers(urlPrefix, clusterName, pulsarURL string, tokenSupplier fun
 	defer close(completeChan)
 
 	for _, brokerURL := range brokers {
-		go ConnectBrokerHealthcheckTopic(brokerURL, clusterName, pulsarURL, tokenSupplier, completeChan)
+		go ConnectBrokerHealthcheckTopic(brokerURL, clusterName, pulsarURL, tokenSupplier, trustStore, completeChan)
 	}
 
 	receivedCounter := 0
@@ -216,17 +263,48 @@ func EvaluateBrokers(urlPrefix, clusterName, pulsarURL string, tokenSupplier fun
 				errStr = errStr + signal.Error() + ";"
 			}
 		case <-ticker.C:
-			return failedBrokers, fmt.Errorf("received %d msg but timed out to receive all %d messages",
+			// brokers not responded in time are counted as failed
+			return failedBrokers + len(brokers) - receivedCounter, len(brokers), fmt.Errorf("received %d msg but timed out to receive all %d messages",
 				receivedCounter, len(brokers))
 		}
 	}
 
 	statsLog.Infof("cluster %s has %d failed brokers out of total %d brokers", clusterName, failedBrokers, len(brokers))
 	if errStr != "" {
-		return failedBrokers, fmt.Errorf(errStr)
+		return failedBrokers, len(brokers), fmt.Errorf(errStr)
 	}
 
-	return failedBrokers, nil
+	return failedBrokers, len(brokers), nil
+}
+
+// maxFailedBrokersAllowed returns the number of failed brokers tolerated out of the total brokers
+// maxFailed is either an absolute number or a percentage of the total brokers
+func maxFailedBrokersAllowed(maxFailed string, totalBrokers int) (int, error) {
+	maxFailed = strings.TrimSpace(maxFailed)
+	if maxFailed == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(maxFailed, "%") {
+		percentage, err := strconv.ParseFloat(strings.TrimSuffix(maxFailed, "%"), 64)
+		if err != nil || percentage < 0 {
+			return 0, fmt.Errorf("invalid maxFailedBrokers percentage %s", maxFailed)
+		}
+		return int(float64(totalBrokers) * percentage / 100), nil
+	}
+	allowed, err := strconv.Atoi(maxFailed)
+	if err != nil || allowed < 0 {
+		return 0, fmt.Errorf("invalid maxFailedBrokers %s", maxFailed)
+	}
+	return allowed, nil
+}
+
+// brokersUnhealthy decides whether the failed brokers exceed the tolerated threshold
+func brokersUnhealthy(failedBrokers, totalBrokers int, maxFailed string) bool {
+	allowed, err := maxFailedBrokersAllowed(maxFailed, totalBrokers)
+	if err != nil {
+		log.Errorf("%v, any failed broker is considered unhealthy", err)
+	}
+	return failedBrokers > allowed
 }
 
 // TestBrokers evaluates and reports all brokers health
@@ -242,17 +320,33 @@ func TestBrokers(topicCfg TopicCfg) error {
 	if topicCfg.IntervalSeconds > 20 {
 		intervalDuration = time.Duration(topicCfg.IntervalSeconds/2) * time.Second
 	}
-	failedBrokers, err := EvaluateBrokers(topicCfg.AdminURL, topicCfg.ClusterName, topicCfg.PulsarURL, tokenSupplier, intervalDuration)
+	_, span := startSpan(topicCfg.traceCtx, "EvaluateBrokers", attribute.String("pulsar.cluster", topicCfg.ClusterName))
+	failedBrokers, totalBrokers, err := EvaluateBrokers(adminURL(topicCfg), topicCfg.ClusterName, topicCfg.PulsarURL, tokenSupplier, topicCfg.TrustStore, intervalDuration)
+	span.SetAttributes(attribute.Int("pulsar.brokers.failed", failedBrokers), attribute.Int("pulsar.brokers.total", totalBrokers))
+	endSpan(span, err)
+	if totalBrokers > 0 {
+		PromGauge(FailedBrokersRatioGaugeOpt(), topicCfg.ClusterName, float64(failedBrokers)/float64(totalBrokers))
+		recordHealthSignal(clusterHost(topicCfg.PulsarURL), brokersSignal, 1-float64(failedBrokers)/float64(totalBrokers))
+	}
+	// the admin is unreachable if the brokers cannot be listed
+	adminHealth := 0.0
+	if totalBrokers > 0 || err == nil {
+		adminHealth = 1
+	}
+	recordHealthSignal(clusterHost(topicCfg.PulsarURL), adminSignal, adminHealth)
 
-	if failedBrokers > 0 {
-		errMsg := fmt.Sprintf("cluster %s has %d unhealthy brokers, error message: %v", name, failedBrokers, err)
+	if brokersUnhealthy(failedBrokers, totalBrokers, GetConfig().BrokersConfig.MaxFailedBrokers) {
+		errMsg := fmt.Sprintf("cluster %s has %d unhealthy brokers out of %d, error message: %v", name, failedBrokers, totalBrokers, err)
 		log.Errorf(errMsg)
 		ReportIncident(name, name, "brokers are unhealthy reported by pulsar-heartbeat", errMsg, &topicCfg.AlertPolicy)
-	} else if err != nil {
+	} else if err != nil && failedBrokers == 0 {
 		errMsg := fmt.Sprintf("cluster %s Pulsar brokers test failed, error message: %v", name, err)
 		log.Errorf(errMsg)
 		ReportIncident(name, name, "brokers test error reported by pulsar-heartbeat", errMsg, &topicCfg.AlertPolicy)
 	} else {
+		if failedBrokers > 0 {
+			log.Warnf("cluster %s has %d unhealthy brokers out of %d within the tolerated threshold, error message: %v", name, failedBrokers, totalBrokers, err)
+		}
 		statsLog.Infof("%s broker test has successfully passed", name)
 		ClearIncident(name)
 	}
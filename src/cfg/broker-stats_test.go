@@ -0,0 +1,112 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+func TestBrokersUnhealthyThreshold(t *testing.T) {
+	// default alerts on any failed broker
+	assert(t, !brokersUnhealthy(0, 10, ""), "expect no failed broker healthy")
+	assert(t, brokersUnhealthy(1, 10, ""), "expect any failed broker unhealthy by default")
+
+	// absolute threshold
+	assert(t, !brokersUnhealthy(2, 10, "2"), "expect 2 failed brokers tolerated")
+	assert(t, brokersUnhealthy(3, 10, "2"), "expect 3 failed brokers unhealthy")
+
+	// percentage threshold
+	assert(t, !brokersUnhealthy(2, 10, "20%"), "expect 20%% failed brokers tolerated")
+	assert(t, brokersUnhealthy(3, 10, "20%"), "expect 30%% failed brokers unhealthy")
+	assert(t, !brokersUnhealthy(24, 50, "50%"), "expect a minority failed brokers tolerated")
+	assert(t, brokersUnhealthy(26, 50, "50%"), "expect a majority failed brokers unhealthy")
+	assert(t, brokersUnhealthy(1, 3, "20%"), "expect a failed broker out of 3 exceeds 20%%")
+
+	// invalid threshold falls back to the default
+	assert(t, brokersUnhealthy(1, 10, "two"), "expect invalid threshold alert on any failed broker")
+	_, err := maxFailedBrokersAllowed("-10%", 10)
+	assert(t, err != nil, "expect negative percentage error")
+}
+
+func TestBrokerHealthcheckTrustStore(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	defer func(create func(pulsar.ClientOptions) (pulsar.Client, error)) { newPulsarClient = create }(newPulsarClient)
+	trustStores := []string{}
+	newPulsarClient = func(opts pulsar.ClientOptions) (pulsar.Client, error) {
+		trustStores = append(trustStores, opts.TLSTrustCertsFilePath)
+		return nil, errors.New("no client in the test")
+	}
+	Config.TrustStore = "/etc/ssl/global-ca.crt"
+	completeChan := make(chan error, 2)
+
+	ConnectBrokerHealthcheckTopic("http://broker-1:8080", "tls-cluster", "pulsar+ssl://trust-store-test:6651", nil, "/etc/ssl/cluster-ca.crt", completeChan)
+	assert(t, <-completeChan != nil, "expect the client creation error reported")
+	ConnectBrokerHealthcheckTopic("http://broker-1:8080", "tls-cluster", "pulsar+ssl://trust-store-test:6651", nil, "", completeChan)
+	assert(t, <-completeChan != nil, "expect the client creation error reported")
+	assert(t, len(trustStores) == 2 && trustStores[0] == "/etc/ssl/cluster-ca.crt", "expect the override trust store but got %v", trustStores)
+	assert(t, trustStores[1] == "/etc/ssl/global-ca.crt", "expect the global trust store without the override but got %v", trustStores)
+}
+
+func TestBrokersCacheTTL(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Write([]byte(`["10.0.0.1:8080"]`))
+	}))
+	defer server.Close()
+
+	Config.AdminCacheTTLSeconds = 0
+	for i := 0; i < 2; i++ {
+		_, err := GetBrokers(server.URL, "cache-cluster", nil)
+		errNil(t, err)
+	}
+	assert(t, atomic.LoadInt32(&requests) == 2, "expect the brokers fetched on every call without a ttl")
+
+	Config.AdminCacheTTLSeconds = 60
+	for i := 0; i < 2; i++ {
+		brokers, err := GetBrokers(server.URL, "cache-cluster", nil)
+		errNil(t, err)
+		assert(t, len(brokers) == 1, "expect 1 broker but got %d", len(brokers))
+	}
+	assert(t, atomic.LoadInt32(&requests) == 3, "expect the brokers cached within the ttl")
+
+	// age the cached brokers beyond the ttl
+	key := server.URL + "|cache-cluster"
+	brokersCacheLock.Lock()
+	cached := brokersCache[key]
+	cached.fetchedAt = cached.fetchedAt.Add(-time.Minute)
+	brokersCache[key] = cached
+	brokersCacheLock.Unlock()
+	_, err := GetBrokers(server.URL, "cache-cluster", nil)
+	errNil(t, err)
+	assert(t, atomic.LoadInt32(&requests) == 4, "expect the brokers fetched again after the ttl")
+}
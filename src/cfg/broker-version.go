@@ -0,0 +1,118 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// detect the brokers of a cluster running different versions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+var (
+	// versionSkewSince is the time the cluster was first seen with mixed broker versions
+	versionSkewSince     = make(map[string]time.Time)
+	versionSkewSinceLock = &sync.Mutex{}
+)
+
+// distinctVersions returns the sorted distinct versions of the brokers, the unknown version is excluded
+func distinctVersions(brokerVersions map[string]string) []string {
+	seen := make(map[string]bool)
+	versions := []string{}
+	for _, version := range brokerVersions {
+		if version != unknownBrokerVersion && !seen[version] {
+			seen[version] = true
+			versions = append(versions, version)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// versionSkewed tracks how long the cluster has mixed versions, it returns the skew duration
+// and whether the skew lasts beyond the grace window
+func versionSkewed(cluster string, distinct int, grace time.Duration, now time.Time) (time.Duration, bool) {
+	versionSkewSinceLock.Lock()
+	defer versionSkewSinceLock.Unlock()
+	if distinct < 2 {
+		delete(versionSkewSince, cluster)
+		return 0, false
+	}
+	since, ok := versionSkewSince[cluster]
+	if !ok {
+		since = now
+		versionSkewSince[cluster] = now
+	}
+	skewed := now.Sub(since)
+	return skewed, skewed >= grace
+}
+
+// TestBrokerVersionSkew reports an incident when the brokers run different versions beyond the grace window
+func TestBrokerVersionSkew(topicCfg TopicCfg) {
+	component := topicCfg.ClusterName + "-version-skew"
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	brokers, err := GetBrokers(adminURL(topicCfg), topicCfg.ClusterName, tokenSupplier)
+	if err != nil {
+		errMsg := fmt.Sprintf("cluster %s failed to get the brokers for the version skew test, error: %v", topicCfg.ClusterName, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "broker version skew test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+
+	brokerVersions := make(map[string]string)
+	for _, broker := range brokers {
+		brokerURL := broker
+		if !strings.HasPrefix(brokerURL, "http") {
+			brokerURL = "http://" + brokerURL
+		}
+		version, err := restTopicAdmin{baseURL: brokerURL, tokenSupplier: tokenSupplier}.BrokerVersion()
+		if err != nil {
+			// an unreachable broker is reported by the broker health test
+			log.Warnf("cluster %s failed to get broker %s version, error: %v", topicCfg.ClusterName, broker, err)
+			continue
+		}
+		brokerVersions[broker] = version
+	}
+	versions := distinctVersions(brokerVersions)
+	PromGaugeInt(BrokerVersionSkewGaugeOpt(), topicCfg.ClusterName, len(versions))
+
+	grace := util.TimeDuration(topicCfg.BrokerVersionSkew.GraceSeconds, 3600, time.Second)
+	skewed, ok := versionSkewed(topicCfg.ClusterName, len(versions), grace, time.Now())
+	if ok {
+		errMsg := fmt.Sprintf("cluster %s brokers have run %d versions %v for %v beyond the grace window %v",
+			topicCfg.ClusterName, len(versions), versions, skewed.Round(time.Second), grace)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "broker version skew", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	if len(versions) > 1 {
+		log.Warnf("cluster %s brokers run %d versions %v within the grace window %v", topicCfg.ClusterName, len(versions), versions, grace)
+	}
+	ClearIncident(component)
+}
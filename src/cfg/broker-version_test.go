@@ -0,0 +1,54 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrokerVersionSkewDetection(t *testing.T) {
+	versions := distinctVersions(map[string]string{"broker-1:8080": "2.10.1", "broker-2:8080": "2.10.1", "broker-3:8080": "2.10.1"})
+	assert(t, len(versions) == 1, "expect one version but got %v", versions)
+
+	versions = distinctVersions(map[string]string{"broker-1:8080": "2.11.0", "broker-2:8080": "2.10.1", "broker-3:8080": "2.11.0"})
+	assert(t, len(versions) == 2 && versions[0] == "2.10.1" && versions[1] == "2.11.0", "expect two sorted versions but got %v", versions)
+
+	versions = distinctVersions(map[string]string{"broker-1:8080": "2.10.1", "broker-2:8080": unknownBrokerVersion})
+	assert(t, len(versions) == 1, "expect the unknown version excluded but got %v", versions)
+
+	cluster := "version-skew-cluster"
+	grace := 10 * time.Minute
+	now := time.Now()
+	_, skewed := versionSkewed(cluster, 2, grace, now)
+	assert(t, !skewed, "expect the mixed versions tolerated within the grace window")
+	_, skewed = versionSkewed(cluster, 2, grace, now.Add(5*time.Minute))
+	assert(t, !skewed, "expect the mixed versions tolerated within the grace window")
+	duration, skewed := versionSkewed(cluster, 2, grace, now.Add(11*time.Minute))
+	assert(t, skewed && duration == 11*time.Minute, "expect the skew beyond the grace window but got %v", duration)
+
+	// the upgrade completes
+	_, skewed = versionSkewed(cluster, 1, grace, now.Add(12*time.Minute))
+	assert(t, !skewed, "expect no skew with a single version")
+	_, skewed = versionSkewed(cluster, 2, grace, now.Add(13*time.Minute))
+	assert(t, !skewed, "expect the grace window restarted")
+}
@@ -0,0 +1,181 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// detect the excessive namespace bundle ownership changes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// bundleOwners is the owner broker of each bundle, the bundle is in the form of tenant/namespace/0x00000000_0x40000000
+type bundleOwners map[string]string
+
+// churnSample is the number of ownership changes found at the time
+type churnSample struct {
+	at      time.Time
+	changes int
+}
+
+var (
+	// key is the cluster name
+	lastBundleOwners = make(map[string]bundleOwners)
+	churnSamples     = make(map[string][]churnSample)
+	bundleChurnLock  = &sync.Mutex{}
+)
+
+// OwnedNamespaces gets the bundles owned by the broker
+func (a restTopicAdmin) OwnedNamespaces(clusterName, broker string) ([]string, error) {
+	resp, err := a.do(http.MethodGet, "admin/v2/brokers/"+clusterName+"/"+broker+"/ownedNamespaces")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get the owned namespaces of broker %s, returns incorrect status code %d", broker, resp.StatusCode)
+	}
+
+	var owned map[string]json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&owned); err != nil {
+		return nil, err
+	}
+	bundles := make([]string, 0, len(owned))
+	for bundle := range owned {
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+// namespaceBundle returns whether the bundle belongs to one of the namespaces
+func namespaceBundle(bundle string, namespaces []string) bool {
+	for _, ns := range namespaces {
+		if strings.HasPrefix(bundle, ns+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// bundleChanges returns the number of bundles moved to another broker or no longer owned, i.e. unloaded or split,
+// since the previous sample
+func bundleChanges(previous, current bundleOwners) int {
+	changes := 0
+	for bundle, owner := range previous {
+		if current[bundle] != owner {
+			changes++
+		}
+	}
+	return changes
+}
+
+// recordBundleChurn records the bundle owners of the cluster and returns the ownership changes since the last sample
+// and the total changes within the window
+func recordBundleChurn(cluster string, owners bundleOwners, window time.Duration, now time.Time) (int, int) {
+	bundleChurnLock.Lock()
+	defer bundleChurnLock.Unlock()
+	previous, ok := lastBundleOwners[cluster]
+	lastBundleOwners[cluster] = owners
+	if !ok {
+		return 0, 0
+	}
+	changes := bundleChanges(previous, owners)
+	samples := append(churnSamples[cluster], churnSample{at: now, changes: changes})
+	total := 0
+	kept := samples[:0]
+	for _, sample := range samples {
+		if now.Sub(sample.at) <= window {
+			kept = append(kept, sample)
+			total += sample.changes
+		}
+	}
+	churnSamples[cluster] = kept
+	return changes, total
+}
+
+// fetchBundleOwners gets the owner broker of the bundles of the namespaces
+func fetchBundleOwners(admin restTopicAdmin, clusterName string, tokenSupplier func() (string, error), namespaces []string) (bundleOwners, error) {
+	brokers, err := GetBrokers(admin.baseURL, clusterName, tokenSupplier)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(bundleOwners)
+	for _, broker := range brokers {
+		bundles, err := admin.OwnedNamespaces(clusterName, broker)
+		if err != nil {
+			return nil, err
+		}
+		for _, bundle := range bundles {
+			if namespaceBundle(bundle, namespaces) {
+				owners[bundle] = broker
+			}
+		}
+	}
+	return owners, nil
+}
+
+// PulsarBundleChurn verifies the bundles of the namespaces do not change ownership excessively on each cluster
+func PulsarBundleChurn() {
+	adminCfg := GetConfig().PulsarAdminConfig
+	churnCfg := adminCfg.BundleChurn
+	if len(churnCfg.Namespaces) == 0 {
+		return
+	}
+	tokenSupplier := util.TokenSupplierWithOverride(adminCfg.Token, GetConfig().TokenSupplier())
+	window := util.TimeDuration(churnCfg.WindowSeconds, 600, time.Second)
+
+	for _, cluster := range adminCfg.Clusters {
+		adminURL, err := url.ParseRequestURI(cluster.URL)
+		if err != nil {
+			panic(err) //panic because this is a showstopper
+		}
+		component := cluster.Name + "-bundle-churn"
+		admin := restTopicAdmin{baseURL: cluster.URL, tokenSupplier: tokenSupplier}
+		owners, err := fetchBundleOwners(admin, util.FirstNonEmptyString(cluster.ClusterName, cluster.Name), tokenSupplier, churnCfg.Namespaces)
+		if err != nil {
+			errMsg := fmt.Sprintf("cluster %s bundle churn test failed, error: %v", cluster.Name, err)
+			log.Errorf(errMsg)
+			ReportIncident(component, adminURL.Hostname(), "bundle churn test failure", errMsg, &cluster.AlertPolicy)
+			continue
+		}
+		changes, total := recordBundleChurn(cluster.Name, owners, window, time.Now())
+		PromCounterAdd(BundleChurnCounterOpt(), cluster.Name, float64(changes))
+		if total > churnCfg.MaxChanges {
+			errMsg := fmt.Sprintf("cluster %s has %d bundle ownership changes over the maximum %d within %v",
+				cluster.Name, total, churnCfg.MaxChanges, window)
+			log.Errorf(errMsg)
+			ReportIncident(component, adminURL.Hostname(), "excessive bundle ownership churn", errMsg, &cluster.AlertPolicy)
+		} else {
+			log.Infof("cluster %s has %d bundle ownership changes within %v", cluster.Name, total, window)
+			ClearIncident(component)
+		}
+	}
+}
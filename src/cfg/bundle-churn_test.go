@@ -0,0 +1,78 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestOwnedNamespaces(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert(t, r.URL.Path == "/admin/v2/brokers/cluster1/broker-1:8080/ownedNamespaces", "unexpected path %s", r.URL.Path)
+		w.Write([]byte(`{"tenant/ns/0x00000000_0x80000000": {"broker_assignment": "shared", "is_controlled": false, "is_active": true},
+			"tenant/other/0x00000000_0xffffffff": {"broker_assignment": "shared"}}`))
+	}))
+	defer server.Close()
+
+	bundles, err := restTopicAdmin{baseURL: server.URL}.OwnedNamespaces("cluster1", "broker-1:8080")
+	errNil(t, err)
+	sort.Strings(bundles)
+	assert(t, len(bundles) == 2 && bundles[0] == "tenant/ns/0x00000000_0x80000000", "unexpected bundles %v", bundles)
+	assert(t, namespaceBundle(bundles[0], []string{"tenant/ns"}), "expect the bundle of the namespace")
+	assert(t, !namespaceBundle(bundles[1], []string{"tenant/ns"}), "expect the bundle of another namespace excluded")
+}
+
+func TestBundleChurn(t *testing.T) {
+	cluster := "bundle-churn-cluster"
+	defer func() {
+		bundleChurnLock.Lock()
+		delete(lastBundleOwners, cluster)
+		delete(churnSamples, cluster)
+		bundleChurnLock.Unlock()
+	}()
+	window := 10 * time.Minute
+	now := time.Now()
+	first := bundleOwners{"tenant/ns/0x00000000_0x80000000": "broker-1", "tenant/ns/0x80000000_0xffffffff": "broker-2"}
+	changes, total := recordBundleChurn(cluster, first, window, now)
+	assert(t, changes == 0 && total == 0, "expect no churn of the first sample but got %d %d", changes, total)
+
+	// one bundle moved to another broker
+	moved := bundleOwners{"tenant/ns/0x00000000_0x80000000": "broker-3", "tenant/ns/0x80000000_0xffffffff": "broker-2"}
+	changes, total = recordBundleChurn(cluster, moved, window, now.Add(time.Minute))
+	assert(t, changes == 1 && total == 1, "expect one ownership change but got %d %d", changes, total)
+
+	// one bundle split into two bundles on the same broker
+	split := bundleOwners{
+		"tenant/ns/0x00000000_0x40000000": "broker-3", "tenant/ns/0x40000000_0x80000000": "broker-3",
+		"tenant/ns/0x80000000_0xffffffff": "broker-2",
+	}
+	changes, total = recordBundleChurn(cluster, split, window, now.Add(2*time.Minute))
+	assert(t, changes == 1 && total == 2, "expect the split bundle counted once but got %d %d", changes, total)
+
+	// a stable sample out of the window of the earlier changes
+	changes, total = recordBundleChurn(cluster, split, window, now.Add(13*time.Minute))
+	assert(t, changes == 0 && total == 0, "expect the changes rolled out of the window but got %d %d", changes, total)
+}
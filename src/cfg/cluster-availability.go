@@ -0,0 +1,76 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// roll up test outcomes into a cluster availability ratio and a smoothed latency
+
+import (
+	"sync"
+	"time"
+
+	"github.com/datastax/pulsar-heartbeat/src/stats"
+)
+
+// availabilityWindowSize is the number of the latest test outcomes in the availability ratio
+const availabilityWindowSize = 100
+
+var (
+	// key is the cluster name
+	availabilityRatios     = make(map[string]*stats.RollingRatio)
+	availabilityRatiosLock = &sync.Mutex{}
+)
+
+// RecordAvailability records a test outcome of the cluster and exports the rolling availability ratio
+func RecordAvailability(cluster string, success bool) float64 {
+	availabilityRatiosLock.Lock()
+	ratio, ok := availabilityRatios[cluster]
+	if !ok {
+		ratio = stats.NewRollingRatio(availabilityWindowSize)
+		availabilityRatios[cluster] = ratio
+	}
+	availability := ratio.Push(success)
+	availabilityRatiosLock.Unlock()
+
+	PromGauge(ClusterAvailabilityGaugeOpt(), cluster, availability)
+	return availability
+}
+
+var (
+	// key is the cluster name
+	latencyEMAs     = make(map[string]*stats.EMA)
+	latencyEMAsLock = &sync.Mutex{}
+)
+
+// RecordLatencyEMA records a latency of the cluster and exports the exponential moving average in ms
+func RecordLatencyEMA(cluster string, latency time.Duration) float64 {
+	latencyEMAsLock.Lock()
+	ema, ok := latencyEMAs[cluster]
+	if !ok {
+		ema = stats.NewEMA(GetConfig().StatsConfig.EMASmoothingFactor)
+		latencyEMAs[cluster] = ema
+	}
+	average := ema.Push(float64(latency) / float64(time.Millisecond))
+	latencyEMAsLock.Unlock()
+
+	PromGauge(PubSubLatencyEMAGaugeOpt(), cluster, average)
+	return average
+}
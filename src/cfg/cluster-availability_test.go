@@ -0,0 +1,63 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestClusterAvailability(t *testing.T) {
+	cluster := "availability-cluster"
+	for i := 0; i < 10; i++ {
+		RecordAvailability(cluster, i%4 != 0)
+	}
+	// 3 failures at 0, 4, and 8 out of 10 tests
+	gauge := metrics[getMetricKey(ClusterAvailabilityGaugeOpt())].WithLabelValues(cluster)
+	assert(t, testutil.ToFloat64(gauge) == 0.7, "expect availability ratio 0.7 but got %f", testutil.ToFloat64(gauge))
+
+	// the failures roll out of the window
+	for i := 0; i < availabilityWindowSize; i++ {
+		RecordAvailability(cluster, true)
+	}
+	assert(t, testutil.ToFloat64(gauge) == 1, "expect availability ratio 1 but got %f", testutil.ToFloat64(gauge))
+	assert(t, RecordAvailability(cluster, false) == 0.99, "expect availability ratio 0.99")
+}
+
+func TestLatencyEMA(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	Config.StatsConfig.EMASmoothingFactor = 0.25
+	cluster := "latency-ema-cluster"
+	defer func() {
+		latencyEMAsLock.Lock()
+		delete(latencyEMAs, cluster)
+		latencyEMAsLock.Unlock()
+	}()
+
+	assert(t, RecordLatencyEMA(cluster, 100*time.Millisecond) == 100, "expect the first latency as the average")
+	assert(t, RecordLatencyEMA(cluster, 500*time.Millisecond) == 200, "expect a smoothed latency spike")
+	gauge := metrics[getMetricKey(PubSubLatencyEMAGaugeOpt())].WithLabelValues(cluster)
+	assert(t, testutil.ToFloat64(gauge) == 200, "expect the average exported but got %f", testutil.ToFloat64(gauge))
+}
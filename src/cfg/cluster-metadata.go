@@ -0,0 +1,153 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// export the Pulsar cluster name and broker version reported by the admin REST API as metric labels
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// unknownBrokerVersion is the version label of brokers without the version endpoint
+const unknownBrokerVersion = "unknown"
+
+// clusterMetadata is the cached metadata of a monitored cluster
+type clusterMetadata struct {
+	pulsarCluster string
+	brokerVersion string
+	fetchedAt     time.Time
+}
+
+var (
+	// key is the monitored cluster name
+	clusterMetadataCache     = make(map[string]clusterMetadata)
+	clusterMetadataCacheLock = &sync.Mutex{}
+)
+
+// BrokerVersion gets the broker version, it is unknown on older brokers lacking the endpoint
+func (a restTopicAdmin) BrokerVersion() (string, error) {
+	resp, err := a.do(http.MethodGet, "admin/v2/brokers/version")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode == http.StatusNotFound {
+		return unknownBrokerVersion, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get the broker version, returns incorrect status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return parseBrokerVersion(body), nil
+}
+
+// parseBrokerVersion parses the version in either plain text or a json string
+func parseBrokerVersion(body []byte) string {
+	version := strings.TrimSpace(string(body))
+	var quoted string
+	if err := json.Unmarshal([]byte(version), &quoted); err == nil {
+		version = strings.TrimSpace(quoted)
+	}
+	return util.FirstNonEmptyString(version, unknownBrokerVersion)
+}
+
+// PulsarClusters gets the names of the Pulsar clusters, including the replication clusters
+func (a restTopicAdmin) PulsarClusters() ([]string, error) {
+	resp, err := a.do(http.MethodGet, "admin/v2/clusters")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list clusters, returns incorrect status code %d", resp.StatusCode)
+	}
+
+	var clusters []string
+	if err = json.NewDecoder(resp.Body).Decode(&clusters); err != nil {
+		return nil, err
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
+// fetchClusterMetadata gets the Pulsar cluster name and the broker version
+func fetchClusterMetadata(adminURL string, tokenSupplier func() (string, error)) (clusterMetadata, error) {
+	admin := restTopicAdmin{baseURL: adminURL, tokenSupplier: tokenSupplier}
+	clusters, err := admin.PulsarClusters()
+	if err != nil {
+		return clusterMetadata{}, err
+	}
+	version, err := admin.BrokerVersion()
+	if err != nil {
+		return clusterMetadata{}, err
+	}
+	return clusterMetadata{
+		pulsarCluster: strings.Join(clusters, ","),
+		brokerVersion: version,
+		fetchedAt:     time.Now(),
+	}, nil
+}
+
+// refreshClusterMetadata fetches the metadata if the cache is older than the refresh interval and exports it
+func refreshClusterMetadata(cluster OpsClusterCfg, tokenSupplier func() (string, error)) {
+	defaultRefresh := GetConfig().AdminCacheTTLSeconds
+	if defaultRefresh <= 0 {
+		defaultRefresh = 3600
+	}
+	refresh := util.TimeDuration(GetConfig().PulsarAdminConfig.MetadataRefreshSeconds, defaultRefresh, time.Second)
+	clusterMetadataCacheLock.Lock()
+	cached, ok := clusterMetadataCache[cluster.Name]
+	clusterMetadataCacheLock.Unlock()
+	if ok && time.Since(cached.fetchedAt) < refresh {
+		return
+	}
+
+	metadata, err := fetchClusterMetadata(cluster.URL, tokenSupplier)
+	if err != nil {
+		// keep the cached labels until the next refresh
+		log.Errorf("failed to get the metadata of cluster %s, error: %v", cluster.Name, err)
+		return
+	}
+	clusterMetadataCacheLock.Lock()
+	clusterMetadataCache[cluster.Name] = metadata
+	clusterMetadataCacheLock.Unlock()
+	if ok && (cached.pulsarCluster != metadata.pulsarCluster || cached.brokerVersion != metadata.brokerVersion) {
+		log.Infof("cluster %s metadata changed from %s %s to %s %s", cluster.Name,
+			cached.pulsarCluster, cached.brokerVersion, metadata.pulsarCluster, metadata.brokerVersion)
+	}
+	PromClusterInfo(cluster.Name, metadata.pulsarCluster, metadata.brokerVersion)
+}
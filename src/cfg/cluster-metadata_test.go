@@ -0,0 +1,90 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseBrokerVersion(t *testing.T) {
+	assert(t, "2.10.1" == parseBrokerVersion([]byte("2.10.1\n")), "expect the plain text version")
+	assert(t, "2.10.1.5" == parseBrokerVersion([]byte(`"2.10.1.5"`)), "expect the json string version")
+	assert(t, unknownBrokerVersion == parseBrokerVersion([]byte("")), "expect an unknown version of an empty response")
+}
+
+func TestClusterMetadata(t *testing.T) {
+	versionFound := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/v2/clusters":
+			w.Write([]byte(`["us-east-pulsar","global"]`))
+		case "/admin/v2/brokers/version":
+			if !versionFound {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("2.10.1"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	metadata, err := fetchClusterMetadata(server.URL, nil)
+	errNil(t, err)
+	assert(t, "global,us-east-pulsar" == metadata.pulsarCluster, "expect sorted cluster names but got %s", metadata.pulsarCluster)
+	assert(t, "2.10.1" == metadata.brokerVersion, "expect the broker version but got %s", metadata.brokerVersion)
+
+	// older brokers lack the version endpoint
+	versionFound = false
+	metadata, err = fetchClusterMetadata(server.URL, nil)
+	errNil(t, err)
+	assert(t, unknownBrokerVersion == metadata.brokerVersion, "expect an unknown broker version but got %s", metadata.brokerVersion)
+
+	cluster := OpsClusterCfg{Name: "metadata-cluster", URL: server.URL}
+	defer func() {
+		clusterMetadataCacheLock.Lock()
+		delete(clusterMetadataCache, cluster.Name)
+		clusterMetadataCacheLock.Unlock()
+	}()
+	refreshClusterMetadata(cluster, nil)
+	versionFound = true
+	// the cached metadata is not refreshed within the refresh interval
+	refreshClusterMetadata(cluster, nil)
+	found := 0
+	for _, labels := range gatheredLabels(t, "pulsar_cluster_info") {
+		if labels["device"] == cluster.Name {
+			found++
+			assert(t, unknownBrokerVersion == labels["broker_version"], "expect the cached broker version but got %s", labels["broker_version"])
+		}
+	}
+	assert(t, found == 1, "expect one cluster info series but got %d", found)
+
+	PromClusterInfo(cluster.Name, "us-east-pulsar", "2.10.2")
+	for _, labels := range gatheredLabels(t, "pulsar_cluster_info") {
+		if labels["device"] == cluster.Name {
+			assert(t, "2.10.2" == labels["broker_version"], "expect the previous labels removed but got %s", labels["broker_version"])
+		}
+	}
+}
@@ -38,6 +38,8 @@ type ClusterHealth struct {
 	sync.RWMutex
 	Status         k8s.ClusterStatusCode
 	MissingBrokers int
+	// UpdatedAt is the time of the last evaluation, the status is unknown before the first evaluation
+	UpdatedAt time.Time
 }
 
 var clusterHealth = ClusterHealth{}
@@ -54,9 +56,24 @@ func (h *ClusterHealth) Set(status k8s.ClusterStatusCode, offlineBrokers int) {
 	h.Lock()
 	h.Status = status
 	h.MissingBrokers = offlineBrokers
+	h.UpdatedAt = time.Now()
 	h.Unlock()
 }
 
+// TotalDown returns whether the cluster was evaluated total down recently
+func (h *ClusterHealth) TotalDown(now time.Time) bool {
+	h.RLock()
+	defer h.RUnlock()
+	return !h.UpdatedAt.IsZero() && now.Sub(h.UpdatedAt) < 3*clusterMonInterval && h.Status == k8s.TotalDown
+}
+
+// suppressedByClusterDown returns whether the data plane incidents are suppressed since the in-cluster
+// monitoring already reports the k8s cluster total down
+func suppressedByClusterDown() bool {
+	k8sCfg := GetConfig().K8sConfig
+	return k8sCfg.Enabled && !k8sCfg.DataPlaneAlertsOnTotalDown && clusterHealth.TotalDown(time.Now())
+}
+
 // EvaluateClusterHealth evaluates and reports the k8s cluster health
 func EvaluateClusterHealth(client *k8s.Client) error {
 	k8sCfg := GetConfig().K8sConfig
@@ -77,6 +94,8 @@ func EvaluateClusterHealth(client *k8s.Client) error {
 	PromGaugeInt(GetOfflinePodsCounter(k8sBookkeeperSubsystem), cluster, status.BookkeeperOfflineInstances)
 	PromGaugeInt(GetOfflinePodsCounter(k8sBrokerSubsystem), cluster, status.BrokerOfflineInstances)
 	PromGaugeInt(GetOfflinePodsCounter(k8sProxySubsystem), cluster, status.ProxyOfflineInstances)
+	PromGaugeInt(GetOfflinePodsCounter(k8sFunctionWorkerSubsystem), cluster, status.FunctionWorkerOfflineInstances)
+	evalFunctionWorkers(cluster, status.FunctionWorkerOfflineInstances, &k8sCfg.AlertPolicy)
 
 	if status.Status != k8s.OK {
 		errMsg := fmt.Sprintf("cluster %s, k8s pulsar cluster status is unhealthy, error message %s", cluster, desc)
@@ -91,33 +110,66 @@ func EvaluateClusterHealth(client *k8s.Client) error {
 	return nil
 }
 
+// evalFunctionWorkers reports an incident of the function workers down apart from the cluster status,
+// the functions are unavailable while the data plane is intact
+func evalFunctionWorkers(cluster string, offlineInstances int, alertPolicy *AlertPolicyCfg) {
+	component := cluster + "-function-worker"
+	if offlineInstances > 0 {
+		errMsg := fmt.Sprintf("cluster %s, %d k8s function worker instances are offline", cluster, offlineInstances)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "Kubernetes function workers are down, reported by pulsar-heartbeat", errMsg, alertPolicy)
+		return
+	}
+	ClearIncident(component)
+}
+
 // MonitorK8sPulsarCluster start K8sPulsarClusterMonitor thread
 func MonitorK8sPulsarCluster() error {
+	probes, err := k8sProbes()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range probes {
+		go func(p probe) {
+			log.Infof("start k8s cluster monitoring ...")
+			ticker := time.NewTicker(p.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					p.run()
+				}
+			}
+		}(p)
+	}
+	return nil
+}
+
+// k8sProbes is the in-cluster pods probe if the k8s monitoring is enabled
+func k8sProbes() ([]probe, error) {
 	k8sCfg := GetConfig().K8sConfig
 	if !k8sCfg.Enabled {
-		return nil
+		return nil, nil
 	}
 
 	ns := util.FirstNonEmptyString(k8sCfg.PulsarNamespace, k8s.DefaultPulsarNamespace)
 	clientset, err := k8s.GetK8sClient(ns)
 	if err != nil {
 		log.Errorf("failed to get k8s clientset %v or get pods under pulsar namespace", err)
-		return err
+		return nil, err
 	}
-
-	go func(client *k8s.Client) {
-		log.Infof("start k8s cluster monitoring ...")
-		ticker := time.NewTicker(clusterMonInterval)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				if err := EvaluateClusterHealth(clientset); err != nil {
-					log.Errorf("k8s monitoring failed to watch pods error: %v", err)
-				}
+	clientset.ExpectedReplicas = k8sCfg.ExpectedReplicas
+
+	name := GetConfig().Name + "-in-cluster"
+	return []probe{{
+		name:     name,
+		interval: clusterMonInterval,
+		run: func() {
+			if err := EvaluateClusterHealth(clientset); err != nil {
+				log.Errorf("k8s monitoring failed to watch pods error: %v", err)
+				recordFailureReport(name, time.Now())
 			}
-		}
-
-	}(clientset)
-	return nil
+		},
+	}}, nil
 }
@@ -0,0 +1,80 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datastax/pulsar-heartbeat/src/k8s"
+)
+
+func TestTotalDownSuppressesLatencyIncident(t *testing.T) {
+	defer withoutAlertDestinations()()
+	resetHealth := func() {
+		clusterHealth.Lock()
+		clusterHealth.Status, clusterHealth.MissingBrokers, clusterHealth.UpdatedAt = k8s.TotalDown, 0, time.Time{}
+		clusterHealth.Unlock()
+	}
+	defer resetHealth()
+	topicCfg := TopicCfg{
+		PulsarURL:       "pulsar://total-down-test:6650",
+		TopicName:       "persistent://tenant/ns/total-down-test",
+		PayloadSizes:    []string{"10B"},
+		LatencyBudgetMs: 1,
+		AlertPolicy:     AlertPolicyCfg{Ceiling: 1},
+	}
+	clusterName := "total-down-cluster"
+	tracked := func() bool {
+		incidentTrackersLock.RLock()
+		defer incidentTrackersLock.RUnlock()
+		_, ok := incidentTrackers[clusterName]
+		return ok
+	}
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, clusterName)
+		incidentTrackersLock.Unlock()
+	}()
+
+	// the initial status is unknown rather than total down
+	Config.K8sConfig.Enabled = true
+	resetHealth()
+	assert(t, !suppressedByClusterDown(), "expect no suppression before the cluster is evaluated")
+
+	clusterHealth.Set(k8s.TotalDown, 3)
+	newFakePulsarClient(t, topicCfg.PulsarURL, 20*time.Millisecond)
+	testTopicLatency(clusterName, nil, topicCfg)
+	assert(t, !tracked(), "expect the redundant latency incident suppressed while the cluster is total down")
+
+	Config.K8sConfig.DataPlaneAlertsOnTotalDown = true
+	newFakePulsarClient(t, topicCfg.PulsarURL, 20*time.Millisecond)
+	testTopicLatency(clusterName, nil, topicCfg)
+	assert(t, tracked(), "expect the latency incident reported with dataPlaneAlertsOnTotalDown")
+
+	// a stale total down status does not suppress the incident
+	Config.K8sConfig.DataPlaneAlertsOnTotalDown = false
+	clusterHealth.Lock()
+	clusterHealth.UpdatedAt = time.Now().Add(-time.Minute)
+	clusterHealth.Unlock()
+	assert(t, !suppressedByClusterDown(), "expect no suppression with a stale cluster status")
+}
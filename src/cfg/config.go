@@ -25,6 +25,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -32,6 +33,7 @@ import (
 
 	"golang.org/x/oauth2/clientcredentials"
 
+	"github.com/datastax/pulsar-heartbeat/src/k8s"
 	"github.com/datastax/pulsar-heartbeat/src/util"
 
 	"github.com/apex/log"
@@ -44,12 +46,32 @@ type PrometheusCfg struct {
 	ExposeMetrics         bool   `json:"exposeMetrics"`
 	PrometheusProxyURL    string `json:"prometheusProxyURL"`
 	PrometheusProxyAPIKey string `json:"prometheusProxyAPIKey"`
+	// WarnOnBindError keeps the process running without metrics if the port cannot be bound, otherwise the process exits
+	WarnOnBindError bool `json:"warnOnBindError"`
+	// StatusToken is the bearer token required by the status endpoint, the endpoint is open if not specified
+	StatusToken string `json:"statusToken"`
+	// PushIncludePrefixes are the metric name prefixes pushed to the Prometheus proxy, metrics matching pulsar are pushed if not specified
+	PushIncludePrefixes []string `json:"pushIncludePrefixes"`
+	// PushExcludePrefixes are the metric name prefixes never pushed to the Prometheus proxy, it takes precedence of PushIncludePrefixes
+	PushExcludePrefixes []string `json:"pushExcludePrefixes"`
+	// RetainRemovedSeries keeps the series of the devices removed from the configuration on reload, they are deleted by default
+	RetainRemovedSeries bool `json:"retainRemovedSeries"`
 }
 
 // SlackCfg is slack configuration
 type SlackCfg struct {
 	AlertURL string `json:"alertUrl"` // AlertURL can be overridden with SLACK_ALERT_URL env var
 	Verbose  bool   `json:"verbose"`
+	// Attachments posts the alerts as color coded attachments of the severity and posts the incident recoveries,
+	// the alerts are plain text if not specified
+	Attachments bool `json:"attachments"`
+	// Colors overrides the attachment color of the critical, incident, warning and recovery severities,
+	// the defaults are danger, danger, warning and good
+	Colors map[string]string `json:"colors"`
+	// CriticalMention is the mention of the critical incidents, i.e. <!here> or <!subteam^ID>, it requires attachments
+	CriticalMention string `json:"criticalMention"`
+	// CriticalPriorities are the incident priorities of the critical severity, the default is P1
+	CriticalPriorities []string `json:"criticalPriorities"`
 }
 
 // OpsGenieCfg is opsGenie configuration
@@ -84,6 +106,18 @@ type SiteCfg struct {
 	StatusCodeExpr  string            `json:"statusCodeExpr"`
 	Retries         int               `json:"retries"`
 	AlertPolicy     AlertPolicyCfg    `json:"alertPolicy"`
+	// RetryWaitMinMs and RetryWaitMaxMs bound the exponential retry backoff, the defaults are 4 and 64 seconds
+	RetryWaitMinMs int `json:"retryWaitMinMs"`
+	RetryWaitMaxMs int `json:"retryWaitMaxMs"`
+	// RetryJitter randomizes the retry backoff between the min wait and the exponential wait
+	RetryJitter bool `json:"retryJitter"`
+	// OwnerTeam and Service are included as incident tags for team based routing
+	OwnerTeam string `json:"ownerTeam"`
+	Service   string `json:"service"`
+	// TrustStore is the CA file to verify a site signed by a private CA, the default is the system roots
+	TrustStore string `json:"trustStore"`
+	// ResponseMetrics exposes the response body size labeled by the content type, the body is read up to 10 MiB
+	ResponseMetrics bool `json:"responseMetrics"`
 }
 
 // SitesCfg configures a list of website`
@@ -95,26 +129,82 @@ type SitesCfg struct {
 type OpsClusterCfg struct {
 	Name        string         `json:"name"`
 	URL         string         `json:"url"`
+	Region      string         `json:"region"` // failures are aggregated into a region incident if specified
 	AlertPolicy AlertPolicyCfg `json:"alertPolicy"`
+	// OwnerTeam and Service are included as incident tags for team based routing
+	OwnerTeam string `json:"ownerTeam"`
+	Service   string `json:"service"`
+	// ClusterName is the Pulsar cluster name to list the brokers, the name is used if not specified
+	ClusterName string `json:"clusterName"`
 }
 
 // PulsarAdminRESTCfg is for monitor a list of Pulsar cluster
 type PulsarAdminRESTCfg struct {
-	Token           string          `json:"Token"`
-	Clusters        []OpsClusterCfg `json:"clusters"`
-	IntervalSeconds int             `json:"intervalSeconds"`
+	Token             string               `json:"Token"`
+	Clusters          []OpsClusterCfg      `json:"clusters"`
+	IntervalSeconds   int                  `json:"intervalSeconds"`
+	NamespacePolicies []NamespacePolicyCfg `json:"namespacePolicies"`
+	// MetadataLabels exports the Pulsar cluster name and broker version of each cluster as pulsar_cluster_info labels
+	MetadataLabels bool `json:"metadataLabels"`
+	// MetadataRefreshSeconds is the interval to refresh the cached cluster metadata,
+	// the default is adminCacheTtlSeconds or 1 hour if neither is specified
+	MetadataRefreshSeconds int `json:"metadataRefreshSeconds"`
+	// Subscriptions are the critical subscriptions to verify the number of connected consumers on every cluster
+	Subscriptions []SubscriptionCfg `json:"subscriptions"`
+	// MessageRates are the topics expected to keep a minimum publish rate on every cluster
+	MessageRates []MessageRateCfg `json:"messageRates"`
+	// RequiredTopics are the fully qualified topic names expected to exist on every cluster
+	RequiredTopics []string `json:"requiredTopics"`
+	// DeadLetterTopics are the fully qualified dead letter topic names expected to stay empty on every cluster
+	DeadLetterTopics []string `json:"deadLetterTopics"`
+	// DeadLetterThreshold is the dead letter topic backlog tolerated without an alert, the default is 0
+	DeadLetterThreshold int64 `json:"deadLetterThreshold"`
+	// BundleChurn alerts on the excessive bundle ownership changes of the namespaces on every cluster
+	BundleChurn BundleChurnCfg `json:"bundleChurn"`
+}
+
+// BundleChurnCfg reports an incident when the bundles of the namespaces change ownership more than MaxChanges within the window
+type BundleChurnCfg struct {
+	// Namespaces are in the form of tenant/namespace
+	Namespaces []string `json:"namespaces"`
+	MaxChanges int      `json:"maxChanges"`
+	// WindowSeconds is the sliding window to count the ownership changes, the default is 10 minutes
+	WindowSeconds int `json:"windowSeconds"`
+}
+
+// MessageRateCfg is a topic expected to have a minimum msgRateIn
+type MessageRateCfg struct {
+	TopicName     string  `json:"topicName"`     // fully qualified topic name, i.e. persistent://tenant/ns/topic
+	MinRateIn     float64 `json:"minRateIn"`     // messages per second
+	WindowSeconds int     `json:"windowSeconds"` // the rate has to stay below the minimum for the window to alert, the default is 300 seconds
+}
+
+// SubscriptionCfg is a subscription expected to have connected consumers
+type SubscriptionCfg struct {
+	TopicName    string `json:"topicName"` // fully qualified topic name, i.e. persistent://tenant/ns/topic
+	Subscription string `json:"subscription"`
+	MinConsumers int    `json:"minConsumers"` // the default is 1
+}
+
+// NamespacePolicyCfg is the expected namespace policy to detect drift on every cluster
+type NamespacePolicyCfg struct {
+	Namespace string `json:"namespace"` // tenant/namespace
+	Policy    string `json:"policy"`    // the policy path under admin/v2/namespaces/{tenant}/{ns}/, i.e. retention
+	// Expected maps a policy field to its expected value, a nested field is separated by dot, i.e. destination_storage.limit
+	Expected map[string]interface{} `json:"expected"`
 }
 
 // TopicCfg is topic configuration
 type TopicCfg struct {
 	Name                    string         `json:"name"`
 	ClusterName             string         `json:"clusterName"` // used for broker monitoring if specified
+	Region                  string         `json:"region"`      // failures are aggregated into a region incident if specified
 	Token                   string         `json:"token"`
 	TrustStore              string         `json:"trustStore"`
 	NumberOfPartitions      int            `json:"numberOfPartitions"`
 	LatencyBudgetMs         int            `json:"latencyBudgetMs"`
 	PulsarURL               string         `json:"pulsarUrl"`
-	AdminURL                string         `json:"adminUrl"`
+	AdminURL                string         `json:"adminUrl"` // derived from pulsarUrl if not specified
 	TopicName               string         `json:"topicName"`
 	OutputTopic             string         `json:"outputTopic"`
 	IntervalSeconds         int            `json:"intervalSeconds"`
@@ -123,6 +213,114 @@ type TopicCfg struct {
 	NumOfMessages           int            `json:"numberOfMessages"`
 	AlertPolicy             AlertPolicyCfg `json:"AlertPolicy"`
 	DowntimeTrackerDisabled bool           `json:"downtimeTrackerDisabled"`
+	// Retries is the number of times to re-run a failed pub sub probe before declaring failure
+	Retries int `json:"retries"`
+	// AutoCreateCheck verifies topic auto creation by producing to a new topic under AutoCreateNamespace
+	AutoCreateCheck bool `json:"autoCreateCheck"`
+	// AutoCreateNamespace is in the form of tenant/namespace, the namespace of TopicName is used if absent
+	AutoCreateNamespace string `json:"autoCreateNamespace"`
+	// MaxInFlightMessages limits the number of messages sent but not yet acknowledged, it is unbounded if not specified
+	MaxInFlightMessages int `json:"maxInFlightMessages"`
+	// ResetSubscriptionBeforeTest skips any backlog of the latency-measure subscription before each test
+	ResetSubscriptionBeforeTest bool `json:"resetSubscriptionBeforeTest"`
+	// UseTransaction sends the messages in a produce transaction and acks them in an ack transaction,
+	// the commit latency of both is exposed, it requires the transaction coordinator enabled on the cluster
+	UseTransaction bool `json:"useTransaction"`
+	// OutputSchema is the expected schema of the OutputTopic messages, it is not validated if the type is not specified
+	OutputSchema SchemaCfg `json:"outputSchema"`
+	// NackRedeliveryCheck negatively acknowledges the first message and verifies it is redelivered after the delay
+	NackRedeliveryCheck bool `json:"nackRedeliveryCheck"`
+	// NackRedeliveryDelayMs is the consumer nack redelivery delay, the default is 1 second
+	NackRedeliveryDelayMs int `json:"nackRedeliveryDelayMs"`
+	// CompactionCheck verifies a compacted read only returns the latest value per key on CompactionTopic
+	CompactionCheck bool `json:"compactionCheck"`
+	// CompactionTopic is a persistent topic, the default is the TopicName with a -compaction suffix
+	CompactionTopic string `json:"compactionTopic"`
+	// MessagesPerPartition is the number of messages sent to each partition to verify per partition ordering
+	MessagesPerPartition int `json:"messagesPerPartition"`
+	// PayloadDistribution samples each message payload size by weight, it takes precedence of PayloadSizes
+	PayloadDistribution []PayloadWeightCfg `json:"payloadDistribution"`
+	// SubscriptionType is exclusive, failover, shared or key_shared, the default is exclusive
+	// out of order delivery is only a warning on shared and key_shared subscriptions since the order is not guaranteed
+	SubscriptionType string `json:"subscriptionType"`
+	// ProbeTimeoutSeconds bounds the runtime of a single probe regardless of the number of messages
+	// the default is 5 seconds per message up to 60 seconds or twice the receive timeout of large payloads
+	ProbeTimeoutSeconds int `json:"probeTimeoutSeconds"`
+	// ReceiveDeadlineSeconds bounds the receive of all the messages in addition to the timeout of every receive, the default is the probe timeout
+	ReceiveDeadlineSeconds int `json:"receiveDeadlineSeconds"`
+	// DirectBrokerProbe runs a single message pub sub on each broker of ClusterName discovered by AdminURL, bypassing the proxy
+	DirectBrokerProbe bool `json:"directBrokerProbe"`
+	// DirectBrokerPort is the broker service port, the default is 6651 for pulsar+ssl and 6650 for pulsar
+	DirectBrokerPort int `json:"directBrokerPort"`
+	// DowntimeBudgetSeconds raises an incident when the downtime accumulated in the rolling window exceeds the budget, it is disabled if not specified
+	DowntimeBudgetSeconds int `json:"downtimeBudgetSeconds"`
+	// DowntimeBudgetWindowDays is the rolling window of the downtime budget, i.e. 7 for weekly, the default is 30 days
+	DowntimeBudgetWindowDays int `json:"downtimeBudgetWindowDays"`
+	// SeekByTimeCheck publishes a message and verifies a reader seeking to a timestamp just before it reads the message back
+	SeekByTimeCheck bool `json:"seekByTimeCheck"`
+	// OwnerTeam and Service are included as incident tags for team based routing
+	OwnerTeam string `json:"ownerTeam"`
+	Service   string `json:"service"`
+	// DedupCheck sends a message twice with the same sequence id and verifies it is consumed once, it requires deduplication enabled on the topic
+	DedupCheck bool `json:"dedupCheck"`
+	// MinPlausibleLatencyMs flags a latency below the realistic round trip floor as suspicious, i.e. stale or skipped messages
+	MinPlausibleLatencyMs int `json:"minPlausibleLatencyMs"`
+	// VerifyPersistence confirms with the topic internal stats of AdminURL that the last message of the test is durable
+	VerifyPersistence bool `json:"verifyPersistence"`
+	// TestActualPartitions keeps testing the actual partitions of a partitioned topic when they differ from numberOfPartitions,
+	// the partition topic test is skipped on a mismatch if not specified
+	TestActualPartitions bool `json:"testActualPartitions"`
+	// SchemaCheck publishes a message with the declared schema to verify the schema registration, it is disabled if the type is not specified
+	SchemaCheck SchemaCheckCfg `json:"schemaCheck"`
+	// ReceiveErrorRate only reports consumer receive errors sustained over the window, every error is reported if not specified
+	ReceiveErrorRate ReceiveErrorRateCfg `json:"receiveErrorRate"`
+	// PayloadEncoding encodes the message body in base64 or hex and verifies the encoding is preserved exactly on receive,
+	// the default is raw bytes
+	PayloadEncoding string `json:"payloadEncoding"`
+	// BrokerVersionSkew alerts when the brokers of the cluster report more than one version, it requires ClusterName and AdminURL
+	BrokerVersionSkew BrokerVersionSkewCfg `json:"brokerVersionSkew"`
+	// BrokerLatencyCheck compares the client latency over the budget with the broker reported publish and storage write
+	// latency of the topic stats to tell the broker side from the client side slowness, it requires AdminURL
+	BrokerLatencyCheck bool `json:"brokerLatencyCheck"`
+	// PlaneProbe probes the topic lookup over the binary protocol and over the HTTP lookup of AdminURL independently
+	PlaneProbe bool `json:"planeProbe"`
+	// PropagatedProperties are the message property keys set on the input messages and verified on the OutputTopic
+	// messages to ensure the function propagates them
+	PropagatedProperties []string `json:"propagatedProperties"`
+	// ReconnectStorm alerts when the Pulsar client of PulsarURL is re-created after failures too often, it is disabled if maxReconnects is not specified
+	ReconnectStorm ReconnectStormCfg `json:"reconnectStorm"`
+	// PerMessageHistogram observes the latency of every message of the test in the latency histogram to reveal the tail latency,
+	// only the average latency of the test is observed if not specified, the gauge is always the average
+	PerMessageHistogram bool `json:"perMessageHistogram"`
+	// StrictOrdering verifies the received sequence of the messages matches the sent sequence exactly and reports the first deviation,
+	// otherwise a message is in order as long as its index is greater than the last received index
+	StrictOrdering bool `json:"strictOrdering"`
+	// ProbeTenant and ProbeNamespace isolate the probe topic from the production namespaces, the topic name is built
+	// under the tenant and namespace with the local name of TopicName, or heartbeat-probe if TopicName is not specified
+	ProbeTenant    string `json:"probeTenant"`
+	ProbeNamespace string `json:"probeNamespace"`
+	// CreateProbeNamespace creates the probe namespace with AdminURL if it does not exist, the namespace is only verified otherwise
+	CreateProbeNamespace bool `json:"createProbeNamespace"`
+
+	// subscriptionName is the latency test subscription, the default is latency-measure
+	subscriptionName string
+	// expanded indicates the topic is expanded from pulsarTopicGroups
+	expanded bool
+	// traceCtx carries the probe span of the test run, the spans of the test are children of it
+	traceCtx context.Context
+}
+
+// ReconnectStormCfg reports an incident when the client reconnects more than MaxReconnects within the window
+type ReconnectStormCfg struct {
+	MaxReconnects int `json:"maxReconnects"`
+	// WindowSeconds is the sliding window to count the reconnects, the default is 10 minutes
+	WindowSeconds int `json:"windowSeconds"`
+}
+
+// PayloadWeightCfg is a payload size and its relative weight in the payload distribution
+type PayloadWeightCfg struct {
+	SizeBytes int     `json:"sizeBytes"`
+	Weight    float64 `json:"weight"`
 }
 
 // WsConfig is configuration to monitor WebSocket pub sub latency
@@ -142,6 +340,49 @@ type WsConfig struct {
 	AlertPolicy     AlertPolicyCfg `json:"AlertPolicy"`
 }
 
+// KafkaCfg is configuration to monitor pub sub latency over the Kafka protocol handler (KoP)
+type KafkaCfg struct {
+	Name             string         `json:"name"`
+	BootstrapServers []string       `json:"bootstrapServers"`
+	TopicName        string         `json:"topicName"`
+	Token            string         `json:"token"` // SASL token, the global token is used if absent
+	LatencyBudgetMs  int            `json:"latencyBudgetMs"`
+	IntervalSeconds  int            `json:"intervalSeconds"`
+	AlertPolicy      AlertPolicyCfg `json:"AlertPolicy"`
+	// Username is the SASL PLAIN username that KoP authorizes as the tenant/namespace, public/default if absent
+	Username string `json:"username"`
+	// UseTLS connects to the bootstrap servers over TLS with the trust store, the global trust store is used if absent
+	UseTLS     bool   `json:"useTls"`
+	TrustStore string `json:"trustStore"`
+}
+
+// MqttCfg is configuration to monitor pub sub latency over the MQTT protocol handler (MoP)
+type MqttCfg struct {
+	Name            string         `json:"name"`
+	BrokerURL       string         `json:"brokerUrl"` // tcp://host:1883 or ssl://host:8883
+	TopicName       string         `json:"topicName"`
+	Username        string         `json:"username"`
+	Password        string         `json:"password"` // a Pulsar token is used as the password if absent
+	Token           string         `json:"token"`
+	TrustStore      string         `json:"trustStore"`
+	QoS             byte           `json:"qos"`
+	LatencyBudgetMs int            `json:"latencyBudgetMs"`
+	IntervalSeconds int            `json:"intervalSeconds"`
+	AlertPolicy     AlertPolicyCfg `json:"AlertPolicy"`
+}
+
+// FailoverDrillCfg measures the time to fail over to the secondary proxy when the primary proxy is marked down
+type FailoverDrillCfg struct {
+	Name            string         `json:"name"`
+	PrimaryURL      string         `json:"primaryUrl"`
+	SecondaryURL    string         `json:"secondaryUrl"`
+	TopicName       string         `json:"topicName"`
+	Token           string         `json:"token"`
+	SLASeconds      int            `json:"slaSeconds"`      // the default is 60 seconds
+	IntervalSeconds int            `json:"intervalSeconds"` // the default is an hour
+	AlertPolicy     AlertPolicyCfg `json:"AlertPolicy"`
+}
+
 // K8sClusterCfg is configuration to monitor kubernete cluster
 // only to be enabled in-cluster monitoring
 type K8sClusterCfg struct {
@@ -149,6 +390,14 @@ type K8sClusterCfg struct {
 	PulsarNamespace string         `json:"pulsarNamespace"`
 	KubeConfigDir   string         `json:"kubeConfigDir"`
 	AlertPolicy     AlertPolicyCfg `json:"AlertPolicy"`
+	// ExpectedReplicas overrides the replicas derived from the k8s spec if it is greater
+	ExpectedReplicas k8s.ExpectedReplicas `json:"expectedReplicas"`
+	// DataPlaneAlertsOnTotalDown reports the latency test incidents while the k8s cluster is total down,
+	// they are suppressed by default since the cluster down incident has been paged
+	DataPlaneAlertsOnTotalDown bool `json:"dataPlaneAlertsOnTotalDown"`
+	// Cluster is the host of the pulsarUrl of the monitored k8s cluster, the pod health only applies to its health score,
+	// the cluster of the latency test topics is assumed if they are all on one cluster
+	Cluster string `json:"cluster"`
 }
 
 // BrokersCfg monitors all brokers in the cluster
@@ -157,6 +406,40 @@ type BrokersCfg struct {
 	InClusterRESTURL   string         `json:"inclusterRestURL"`
 	IntervalSeconds    int            `json:"intervalSeconds"`
 	AlertPolicy        AlertPolicyCfg `json:"AlertPolicy"`
+	// MaxFailedBrokers is the number of failed brokers tolerated before alerting, either absolute i.e. 2 or percentage i.e. 20%
+	// any failed broker is alerted if it is not specified
+	MaxFailedBrokers string `json:"maxFailedBrokers"`
+}
+
+// BookkeeperCfg monitors bookkeeper ledger replication over the bookie http admin endpoint
+type BookkeeperCfg struct {
+	BookieHTTPURL                   string         `json:"bookieHttpUrl"`
+	UnderReplicatedLedgersThreshold int            `json:"underReplicatedLedgersThreshold"`
+	IntervalSeconds                 int            `json:"intervalSeconds"`
+	AlertPolicy                     AlertPolicyCfg `json:"AlertPolicy"`
+}
+
+// ZookeeperCfg monitors zookeeper latency on each server in the ensemble
+type ZookeeperCfg struct {
+	Hosts           []string       `json:"hosts"` // a list of host:port
+	LatencyBudgetMs int            `json:"latencyBudgetMs"`
+	TimeoutSeconds  int            `json:"timeoutSeconds"`
+	IntervalSeconds int            `json:"intervalSeconds"`
+	AlertPolicy     AlertPolicyCfg `json:"AlertPolicy"`
+}
+
+// TLSClientCertCfg is the client certificate for mTLS authentication, it can be combined with the token authentication
+type TLSClientCertCfg struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// TokenSecretCfg is the k8s secret of the Pulsar JWT
+type TokenSecretCfg struct {
+	Namespace      string `json:"namespace"`
+	Name           string `json:"name"`
+	Key            string `json:"key"`            // the default is token
+	RefreshSeconds int    `json:"refreshSeconds"` // the default is 300 seconds
 }
 
 // TenantUsageCfg tenant usage reporting and monitoring
@@ -170,14 +453,21 @@ type Configuration struct {
 	// Name is the Pulsar cluster name, it is mandatory
 	Name string `json:"name"`
 	// ClusterName is the Pulsar cluster name if the Name cannot be used as the Pulsar cluster name, optional
-	ClusterName      string                    `json:"clusterName"`
+	ClusterName string `json:"clusterName"`
+	// Env is the deployment environment label on metrics, it overrides DeployEnv env var and the default is testing
+	Env              string                    `json:"env"`
 	TokenOAuthConfig *clientcredentials.Config `json:"tokenOAuthConfig"`
 	// TokenFilePath is the file path to Pulsar JWT. It takes precedence of the token attribute.
 	TokenFilePath string `json:"tokenFilePath"`
+	// TokenSecret reads the Pulsar JWT from a k8s secret, it takes precedence of the token file path and the token attribute.
+	TokenSecret *TokenSecretCfg `json:"tokenSecret"`
 	// Token is a Pulsar JWT can be used for both client or http admin client
 	Token             string             `json:"token"`
 	BrokersConfig     BrokersCfg         `json:"brokersConfig"`
+	BookkeeperConfig  BookkeeperCfg      `json:"bookkeeperConfig"`
+	ZookeeperConfig   ZookeeperCfg       `json:"zookeeperConfig"`
 	TrustStore        string             `json:"trustStore"`
+	ClientCertConfig  TLSClientCertCfg   `json:"clientCertConfig"`
 	K8sConfig         K8sClusterCfg      `json:"k8sConfig"`
 	AnalyticsConfig   AnalyticsCfg       `json:"analyticsConfig"`
 	PrometheusConfig  PrometheusCfg      `json:"prometheusConfig"`
@@ -188,7 +478,78 @@ type Configuration struct {
 	PulsarTopicConfig []TopicCfg         `json:"pulsarTopicConfig"`
 	SitesConfig       SitesCfg           `json:"sitesConfig"`
 	WebSocketConfig   []WsConfig         `json:"webSocketConfig"`
+	KafkaConfig       []KafkaCfg         `json:"kafkaConfig"`
+	MqttConfig        []MqttCfg          `json:"mqttConfig"`
 	TenantUsageConfig TenantUsageCfg     `json:"tenantUsageConfig"`
+	// LogLevel is one of debug, info, warn, error, and fatal, the default is info
+	LogLevel string `json:"logLevel"`
+	// LogSampleRate emits one in every N per message logs at info level,
+	// per message logs are only emitted at debug level if it is not specified
+	LogSampleRate int `json:"logSampleRate"`
+	// MaxConnectionsPerBroker is the connection pool size of every cached Pulsar client, the client default is 1
+	MaxConnectionsPerBroker int `json:"maxConnectionsPerBroker"`
+	// MaxClientAgeSeconds recycles a cached Pulsar client older than the age, a client is only recycled on connection failures if not specified
+	MaxClientAgeSeconds int `json:"maxClientAgeSeconds"`
+	// HeartbeatWatchdogMultiple alerts when the uptime heartbeat has not run within the multiple of its interval,
+	// the watchdog is disabled if not specified
+	HeartbeatWatchdogMultiple int `json:"heartbeatWatchdogMultiple"`
+	// StartupGracePeriodSeconds is the period after startup when failures are logged but do not create incidents
+	StartupGracePeriodSeconds int `json:"startupGracePeriodSeconds"`
+	// RegionFailureThreshold is the fraction of a region's failing clusters, counted by the cluster host, over which the region
+	// incident is evaluated with the alert policy of the failing component, the default is 0.5
+	RegionFailureThreshold float64 `json:"regionFailureThreshold"`
+	// StatsConfig configures the latency standard deviation evaluation and moving average
+	StatsConfig StatsCfg `json:"statsConfig"`
+	// FailoverDrillConfig is a list of primary and secondary proxies to drill the failover
+	FailoverDrillConfig []FailoverDrillCfg `json:"failoverDrillConfig"`
+	// AlertsEnabled is the master switch of Slack alerts, OpsGenie and PagerDuty incidents, the default is true.
+	// alerts and incidents are only logged if it is false, metrics are still exposed
+	AlertsEnabled *bool `json:"alertsEnabled"`
+	// WarmupOnStart creates the Pulsar client and a throwaway producer of every cluster at startup,
+	// so that the first scheduled probe is not measured over cold connections
+	WarmupOnStart bool `json:"warmupOnStart"`
+	// ProbeNamePrefix is the prefix of the probe producer and consumer names to identify them in the broker stats,
+	// the default is pulsar-heartbeat
+	ProbeNamePrefix string `json:"probeNamePrefix"`
+	// WidespreadFailureThreshold is the fraction of the configured clusters failing at once to create a single
+	// widespread failure incident, indicating a monitor side or shared infrastructure problem, disabled if not specified
+	WidespreadFailureThreshold float64 `json:"widespreadFailureThreshold"`
+	// ListenerName is the advertised listener of the brokers for the Pulsar clients to connect to,
+	// the broker default listener is used if not specified
+	ListenerName string `json:"listenerName"`
+	// AdminCacheTTLSeconds is how long the broker lists are cached before re-fetch, they are fetched on every test if not specified,
+	// it is also the default refresh of the cluster metadata if metadataRefreshSeconds is not specified
+	AdminCacheTTLSeconds int `json:"adminCacheTtlSeconds"`
+	// MinIntervalSeconds is the floor of every configured test interval to guard the clusters against a tiny interval,
+	// there is no floor if not specified
+	MinIntervalSeconds int `json:"minIntervalSeconds"`
+	// StatusHistorySize is the number of the latest latency test results of every cluster kept for the status endpoint,
+	// the default is 20
+	StatusHistorySize int `json:"statusHistorySize"`
+	// HTTPProxy is the http, https or socks5 proxy URL of the outbound HTTP requests,
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are honored if not specified
+	HTTPProxy string `json:"httpProxy"`
+	// ClientIdleSeconds closes a cached Pulsar client unused for longer than the duration to free the broker connections,
+	// the idle clients are kept if not specified
+	ClientIdleSeconds int `json:"clientIdleSeconds"`
+	// EnableFailureExec allows the onFailureExec commands of the alert policies to run
+	EnableFailureExec bool `json:"enableFailureExec"`
+	// FailureExecTimeoutSeconds is the timeout of an onFailureExec command that delays the incident creation, the default is 30 seconds
+	FailureExecTimeoutSeconds int `json:"failureExecTimeoutSeconds"`
+	// IncidentHistorySize is the number of the latest incidents kept for the incidents endpoint, the default is 100
+	IncidentHistorySize int `json:"incidentHistorySize"`
+	// PulsarTopicGroups list multiple topics with shared settings under one cluster entry,
+	// every topic is expanded into a probe of pulsarTopicConfig
+	PulsarTopicGroups []TopicGroupCfg `json:"pulsarTopicGroups"`
+	// MaxPagerConcurrency bounds the concurrent OpsGenie and PagerDuty API calls to stay within the vendor limits during
+	// a large correlated failure, the calls are unbounded if not specified
+	MaxPagerConcurrency int `json:"maxPagerConcurrency"`
+	// HealthScoreWeights are the weights of the latency, brokers, pods and admin signals of the cluster health score,
+	// a signal not specified has the weight 1 and a signal of the weight 0 is excluded
+	HealthScoreWeights map[string]float64 `json:"healthScoreWeights"`
+	// OtelTracing exports the spans of the latency, admin and broker probes to an OpenTelemetry collector,
+	// tracing is disabled if not specified
+	OtelTracing OtelTracingCfg `json:"otelTracing"`
 
 	tokenFunc func() (string, error)
 }
@@ -201,6 +562,20 @@ func (c *Configuration) Init() {
 	// env overrides for certain config fields
 	c.PagerDutyConfig.IntegrationKey = util.FirstNonEmptyString(os.Getenv("PAGER_DUTY_INTEGRATION_KEY"), c.PagerDutyConfig.IntegrationKey)
 	c.SlackConfig.AlertURL = util.FirstNonEmptyString(os.Getenv("SLACK_ALERT_URL"), c.SlackConfig.AlertURL)
+	c.Env = util.FirstNonEmptyString(c.Env, os.Getenv("DeployEnv"), "testing")
+	c.expandTopicGroups()
+	c.setProbeTopics()
+	c.setRegions()
+	c.setOwners()
+	c.setClusters()
+
+	if c.LogLevel != "" {
+		if level, err := log.ParseLevel(c.LogLevel); err != nil {
+			log.Errorf("invalid logLevel %s, error: %v", c.LogLevel, err)
+		} else {
+			log.SetLevel(level)
+		}
+	}
 
 	if c.TokenOAuthConfig != nil {
 		tokenSrc := c.TokenOAuthConfig.TokenSource(context.Background())
@@ -211,6 +586,8 @@ func (c *Configuration) Init() {
 			}
 			return ot.AccessToken, nil
 		}
+	} else if c.TokenSecret != nil {
+		c.tokenFunc = secretTokenFunc(*c.TokenSecret)
 	} else if len(c.TokenFilePath) > 1 {
 		// In the case of Kubernetes, the token file can be updated, so this reads it from the file every time.
 		c.tokenFunc = func() (string, error) {
@@ -228,10 +605,66 @@ func (c *Configuration) Init() {
 	}
 }
 
+// alertsEnabled returns whether alerts and incidents are sent to the external destinations
+func (c *Configuration) alertsEnabled() bool {
+	return c.AlertsEnabled == nil || *c.AlertsEnabled
+}
+
+// secretTokenFunc returns the token supplier reading the k8s secret with the k8s clientset
+func secretTokenFunc(secret TokenSecretCfg) func() (string, error) {
+	clientset, err := k8s.GetClientset(secret.Namespace)
+	if err != nil {
+		log.Errorf("failed to get k8s clientset to read the token secret %s/%s, error: %v", secret.Namespace, secret.Name, err)
+		return func() (string, error) {
+			return "", fmt.Errorf("failed to get k8s clientset to read the token secret: %w", err)
+		}
+	}
+	return k8s.SecretTokenSupplier(clientset, secret.Namespace, secret.Name, util.FirstNonEmptyString(secret.Key, "token"),
+		util.TimeDuration(secret.RefreshSeconds, 300, time.Second))
+}
+
 func (c *Configuration) TokenSupplier() func() (string, error) {
 	return c.tokenFunc
 }
 
+// SchemaCfg is a Pulsar schema
+type SchemaCfg struct {
+	Type       string `json:"type"`       // i.e. AVRO, JSON, STRING, or BYTES
+	Definition string `json:"definition"` // the optional schema definition
+}
+
+// ReceiveErrorRateCfg reports an incident when the receive errors of a cluster exceed the maximum within the window
+type ReceiveErrorRateCfg struct {
+	MaxErrors int `json:"maxErrors"`
+	// WindowSeconds is the sliding window to count the receive errors, the default is 10 minutes
+	WindowSeconds int `json:"windowSeconds"`
+}
+
+// BrokerVersionSkewCfg reports an incident when the brokers run different versions beyond the grace window
+type BrokerVersionSkewCfg struct {
+	Enabled bool `json:"enabled"`
+	// GraceSeconds tolerates the mixed versions of a rolling upgrade, the default is 1 hour
+	GraceSeconds int `json:"graceSeconds"`
+}
+
+// SchemaCheckCfg is the declared schema and a sample message published by the schema check
+type SchemaCheckCfg struct {
+	Type       string `json:"type"`       // i.e. AVRO or JSON
+	Definition string `json:"definition"` // the Avro schema definition of both AVRO and JSON schemas
+	// TopicName is the topic registered with the schema, the default is the test topic name with the -schema suffix
+	TopicName string `json:"topicName"`
+	// Payload is the json message conforming to the schema, the default is an empty json object
+	Payload string `json:"payload"`
+}
+
+// StatsCfg configures the latency standard deviation model
+type StatsCfg struct {
+	// WarmupSamples is the number of the first successful latency samples per cluster excluded from the model
+	WarmupSamples int `json:"warmupSamples"`
+	// EMASmoothingFactor is the weight in (0, 1] of the latest latency in the exponential moving average, the default is 0.2
+	EMASmoothingFactor float64 `json:"emaSmoothingFactor"`
+}
+
 // AlertPolicyCfg is a set of criteria to evaluation triggers for incident alert
 type AlertPolicyCfg struct {
 	// first evaluation to count continuous failure
@@ -239,6 +672,28 @@ type AlertPolicyCfg struct {
 	// Second evaluation for moving window
 	MovingWindowSeconds   int `json:"movingWindowSeconds"`
 	CeilingInMovingWindow int `json:"ceilingInMovingWindow"`
+	// incident starts at P3 and escalates through the ladder while the component stays failing
+	// incident is reported at P2 without escalation if not specified
+	EscalationLadder []EscalationStepCfg `json:"escalationLadder"`
+	// MessageTemplate is a Go template of the incident message, i.e. to embed the runbook link, rendered with
+	// .Component, .Cluster, .Message, .Error, .LatencyMs and .BudgetMs, the default message is used if not specified
+	MessageTemplate string `json:"messageTemplate"`
+	// OnFailureExec is the command and the arguments run once before an incident is created, each is a Go template rendered
+	// as MessageTemplate, the output is appended to the incident description of every provider. It requires enableFailureExec.
+	OnFailureExec []string `json:"onFailureExec"`
+
+	// region is assigned from the topic or cluster configuration
+	region string
+	// owner is assigned from the topic, site, or cluster configuration
+	owner incidentOwner
+	// cluster is assigned from the topic or cluster configuration
+	cluster string
+}
+
+// EscalationStepCfg re-pages an incident at the priority after the component has been failing for the duration
+type EscalationStepCfg struct {
+	Priority     string `json:"priority"`
+	AfterSeconds int    `json:"afterSeconds"`
 }
 
 // Config - this server's configuration instance
@@ -247,12 +702,15 @@ var Config Configuration
 // ReadConfigFile reads configuration file.
 func ReadConfigFile(configFile string) {
 
+	previousDevices := configuredDevices(Config)
 	fileBytes, err := os.ReadFile(configFile)
 	if err != nil {
 		log.Errorf("failed to load configuration file %s", configFile)
 		panic(err)
 	}
 
+	// the expanded topics are not decoded over, they are expanded again from the topic groups
+	Config.PulsarTopicConfig = flatTopics(Config.PulsarTopicConfig)
 	if hasJSONPrefix(fileBytes) {
 		if err = json.Unmarshal(fileBytes, &Config); err != nil {
 			panic(err)
@@ -264,6 +722,23 @@ func ReadConfigFile(configFile string) {
 	}
 	Config.Init()
 	logConfig(Config)
+	if !Config.PrometheusConfig.RetainRemovedSeries {
+		deleteRemovedSeries(previousDevices, configuredDevices(Config))
+	}
+
+	PromGauge(ConfigLoadedGaugeOpt(), Config.Name, float64(time.Now().Unix()))
+	PromCounter(ConfigReloadCounterOpt(), Config.Name)
+	PromComponents(Config.Name, componentCounts(Config))
+}
+
+// componentCounts returns the number of monitored components by kind, every configured component runs its own test thread
+func componentCounts(c Configuration) map[string]int {
+	return map[string]int{
+		"topics":     len(c.PulsarTopicConfig),
+		"sites":      len(c.SitesConfig.Sites),
+		"websockets": len(c.WebSocketConfig),
+		"clusters":   len(c.PulsarAdminConfig.Clusters),
+	}
 }
 
 // logConfig prints the config at the 'debug' level after removing sensitive fields
@@ -293,6 +768,9 @@ func logConfig(c Configuration) {
 	if c.PulsarAdminConfig.Token != "" {
 		c.PulsarAdminConfig.Token = hideSecret
 	}
+	if c.PrometheusConfig.StatusToken != "" {
+		c.PrometheusConfig.StatusToken = hideSecret
+	}
 	log.Debugf("config: \n%v", c)
 }
 
@@ -314,6 +792,18 @@ func GetConfig() *Configuration {
 
 type monitorFunc func()
 
+// IntervalDuration resolves the configured interval in seconds or the default,
+// an interval below minIntervalSeconds is raised to the floor
+func IntervalDuration(configV, defaultV int) time.Duration {
+	interval := util.TimeDuration(configV, defaultV, time.Second)
+	floor := time.Duration(GetConfig().MinIntervalSeconds) * time.Second
+	if interval < floor {
+		log.Warnf("interval %v is raised to the minimum interval %v", interval, floor)
+		return floor
+	}
+	return interval
+}
+
 // RunInterval runs interval
 func RunInterval(fn monitorFunc, interval time.Duration) {
 	go func() {
@@ -0,0 +1,85 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// alert when messages land in the dead letter topics
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// deadLetterBacklog returns the largest subscription backlog of the dead letter topic,
+// or the number of messages published since the topic was loaded if it has no subscription
+func deadLetterBacklog(stats topicStats) int64 {
+	if len(stats.Subscriptions) == 0 {
+		return stats.MsgInCounter
+	}
+	var backlog int64
+	for _, subscription := range stats.Subscriptions {
+		if subscription.MsgBacklog > backlog {
+			backlog = subscription.MsgBacklog
+		}
+	}
+	return backlog
+}
+
+// PulsarDeadLetterTopics verifies the backlog of the dead letter topics stays within the threshold on each cluster
+func PulsarDeadLetterTopics() {
+	adminCfg := GetConfig().PulsarAdminConfig
+	if len(adminCfg.DeadLetterTopics) == 0 {
+		return
+	}
+	tokenSupplier := util.TokenSupplierWithOverride(adminCfg.Token, GetConfig().TokenSupplier())
+
+	for _, cluster := range adminCfg.Clusters {
+		adminURL, err := url.ParseRequestURI(cluster.URL)
+		if err != nil {
+			panic(err) //panic because this is a showstopper
+		}
+		admin := restTopicAdmin{baseURL: cluster.URL, tokenSupplier: tokenSupplier}
+		for _, topicName := range adminCfg.DeadLetterTopics {
+			component := cluster.Name + "-" + topicName + "-dlq"
+			stats, err := admin.TopicStats(topicName)
+			if err != nil {
+				errMsg := fmt.Sprintf("cluster %s dead letter topic test failed, error: %v", cluster.Name, err)
+				log.Errorf(errMsg)
+				ReportIncident(component, adminURL.Hostname(), "dead letter topic test failure", errMsg, &cluster.AlertPolicy)
+				continue
+			}
+			backlog := deadLetterBacklog(stats)
+			PromDLQBacklog(cluster.Name, topicName, backlog)
+			if backlog > adminCfg.DeadLetterThreshold {
+				errMsg := fmt.Sprintf("cluster %s dead letter topic %s has %d messages over the threshold %d",
+					cluster.Name, topicName, backlog, adminCfg.DeadLetterThreshold)
+				log.Errorf(errMsg)
+				ReportIncident(component, adminURL.Hostname(), "messages landed in the dead letter topic", errMsg, &cluster.AlertPolicy)
+			} else {
+				log.Infof("cluster %s dead letter topic %s has %d messages", cluster.Name, topicName, backlog)
+				ClearIncident(component)
+			}
+		}
+	}
+}
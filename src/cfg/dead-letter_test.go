@@ -0,0 +1,65 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeadLetterBacklog(t *testing.T) {
+	samples := map[string]int64{
+		`{"msgInCounter": 42, "subscriptions": {}}`: 42,
+		`{"msgInCounter": 42, "subscriptions": {"replay": {"msgBacklog": 7, "consumers": []}, "audit": {"msgBacklog": 3}}}`: 7,
+		`{"msgInCounter": 0, "subscriptions": {"replay": {"msgBacklog": 0}}}`:                                               0,
+	}
+	for sample, expected := range samples {
+		body := sample
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		stats, err := restTopicAdmin{baseURL: server.URL}.TopicStats("persistent://tenant/ns/orders-DLQ")
+		server.Close()
+		errNil(t, err)
+		backlog := deadLetterBacklog(stats)
+		assert(t, backlog == expected, "expect the backlog %d of %s but got %d", expected, sample, backlog)
+	}
+}
+
+func TestPulsarDeadLetterTopics(t *testing.T) {
+	defer withoutAlertDestinations()()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert(t, r.URL.Path == "/admin/v2/persistent/tenant/ns/orders-DLQ/stats", "unexpected path %s", r.URL.Path)
+		w.Write([]byte(`{"subscriptions": {"replay": {"msgBacklog": 5}}}`))
+	}))
+	defer server.Close()
+
+	Config.PulsarAdminConfig.Clusters = []OpsClusterCfg{{Name: "dlq-cluster", URL: server.URL}}
+	Config.PulsarAdminConfig.DeadLetterTopics = []string{"persistent://tenant/ns/orders-DLQ"}
+	Config.PulsarAdminConfig.DeadLetterThreshold = 10
+	PulsarDeadLetterTopics()
+	backlog := testutil.ToFloat64(dlqBacklog.WithLabelValues("dlq-cluster", "persistent://tenant/ns/orders-DLQ"))
+	assert(t, backlog == 5, "expect pulsar_dlq_backlog 5 but got %v", backlog)
+}
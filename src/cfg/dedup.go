@@ -0,0 +1,137 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify a message sent twice with the same sequence id is deduplicated by the broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// dedupGracePeriod is how long to wait for a duplicate after the first delivery
+const dedupGracePeriod = 3 * time.Second
+
+// dedupReceiver is the consumer capability required by the dedup check
+type dedupReceiver interface {
+	Receive(context.Context) (pulsar.Message, error)
+	Ack(pulsar.Message) error
+}
+
+// verifySingleDelivery receives until the payload is delivered and waits for the grace period
+// to ensure the payload is not delivered again
+func verifySingleDelivery(consumer dedupReceiver, payload string, timeout, grace time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delivered := 0
+	for {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		msg, err := consumer.Receive(ctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to receive message: %w", err)
+		}
+		consumer.Ack(msg)
+		if string(msg.Payload()) != payload {
+			continue
+		}
+		delivered++
+		if delivered == 1 {
+			deadline = time.Now().Add(grace)
+		}
+	}
+
+	switch {
+	case delivered == 0:
+		return fmt.Errorf("the message is not received after %v", timeout)
+	case delivered > 1:
+		return fmt.Errorf("the message sent with the same sequence id is delivered %d times", delivered)
+	}
+	return nil
+}
+
+// TestDedup evaluates and reports the topic deduplication
+func TestDedup(topicCfg TopicCfg) {
+	pulsarURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
+	if err != nil {
+		log.Errorf("dedup check is skipped, invalid pulsar url %s error: %v", topicCfg.PulsarURL, err)
+		return
+	}
+	component := pulsarURL.Hostname() + "-dedup"
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	if err := dedupCheck(topicCfg, tokenSupplier); err != nil {
+		errMsg := fmt.Sprintf("%s dedup test failed on %s, error: %v", component, topicCfg.TopicName, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "dedup test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	log.Infof("%s dedup test has successfully passed on %s", component, topicCfg.TopicName)
+	ClearIncident(component)
+}
+
+// dedupCheck sends a message twice with the same sequence id and verifies it is consumed once
+func dedupCheck(topicCfg TopicCfg, tokenSupplier func() (string, error)) error {
+	client, err := GetPulsarClient(topicCfg.PulsarURL, tokenSupplier)
+	if err != nil {
+		return fmt.Errorf("failed to create Pulsar client: %w", err)
+	}
+
+	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
+		Topic:                       topicCfg.TopicName,
+		SubscriptionName:            "heartbeat-dedup",
+		SubscriptionInitialPosition: pulsar.SubscriptionPositionLatest,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	// the producer name is assigned by the broker, so the sequence id is the first one of the producer
+	producer, err := client.CreateProducer(pulsar.ProducerOptions{
+		Topic: topicCfg.TopicName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create producer: %w", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	sequenceID := time.Now().UnixNano()
+	payload := fmt.Sprintf("heartbeat-dedup-%d", sequenceID)
+	for i := 0; i < 2; i++ {
+		msg := &pulsar.ProducerMessage{Payload: []byte(payload), SequenceID: &sequenceID}
+		if _, err = producer.Send(ctx, msg); err != nil {
+			return fmt.Errorf("failed to send message %d: %w", i+1, err)
+		}
+	}
+	return verifySingleDelivery(consumer, payload, 30*time.Second, dedupGracePeriod)
+}
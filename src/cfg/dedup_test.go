@@ -0,0 +1,60 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+func TestVerifySingleDelivery(t *testing.T) {
+	deliver := func(payloads ...string) *fakeConsumer {
+		consumer := &fakeConsumer{messages: make(chan pulsar.Message, len(payloads))}
+		for _, payload := range payloads {
+			consumer.messages <- &fakeMessage{payload: []byte(payload)}
+		}
+		return consumer
+	}
+
+	errNil(t, verifySingleDelivery(deliver("other", "dedup", "other"), "dedup", time.Second, 50*time.Millisecond))
+
+	err := verifySingleDelivery(deliver("dedup", "other", "dedup"), "dedup", time.Second, 50*time.Millisecond)
+	assert(t, err != nil, "expect an error on the duplicate delivery")
+
+	err = verifySingleDelivery(deliver("other"), "dedup", 50*time.Millisecond, 50*time.Millisecond)
+	assert(t, err != nil, "expect an error if the message is not received")
+
+	err = verifySingleDelivery(&failedReceiver{err: errors.New("consumer closed")}, "dedup", time.Second, time.Second)
+	assert(t, err != nil && errors.Unwrap(err).Error() == "consumer closed", "expect the receive error but got %v", err)
+}
+
+// failedReceiver fails every receive
+type failedReceiver struct {
+	err error
+}
+
+func (r *failedReceiver) Receive(context.Context) (pulsar.Message, error) { return nil, r.err }
+func (r *failedReceiver) Ack(pulsar.Message) error                        { return nil }
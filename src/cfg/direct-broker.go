@@ -0,0 +1,152 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// probe each broker service url directly to tell proxy issues from broker issues
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// directBrokerResult is the pub sub result on a broker service url
+type directBrokerResult struct {
+	broker  string
+	latency time.Duration
+	err     error
+}
+
+// brokerServiceURLs converts the broker addresses returned by the admin REST API, in the form of host:port,
+// to the broker service urls with the scheme of the pulsar url
+func brokerServiceURLs(brokers []string, pulsarURL string, port int) map[string]string {
+	scheme := "pulsar://"
+	defaultPort := 6650
+	if strings.HasPrefix(pulsarURL, "pulsar+ssl://") {
+		scheme = "pulsar+ssl://"
+		defaultPort = 6651
+	}
+	if port <= 0 {
+		port = defaultPort
+	}
+
+	urls := make(map[string]string, len(brokers))
+	for _, broker := range brokers {
+		host := strings.TrimPrefix(strings.TrimPrefix(broker, "http://"), "https://")
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		urls[broker] = scheme + net.JoinHostPort(host, strconv.Itoa(port))
+	}
+	return urls
+}
+
+// probeBrokers runs the probe on every broker service url concurrently, the results are sorted by broker
+func probeBrokers(serviceURLs map[string]string, probe func(serviceURL string) (MsgResult, error)) []directBrokerResult {
+	results := make([]directBrokerResult, 0, len(serviceURLs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for broker, serviceURL := range serviceURLs {
+		wg.Add(1)
+		go func(broker, serviceURL string) {
+			defer wg.Done()
+			result, err := probe(serviceURL)
+			mu.Lock()
+			results = append(results, directBrokerResult{broker: broker, latency: result.Latency, err: err})
+			mu.Unlock()
+		}(broker, serviceURL)
+	}
+	wg.Wait()
+	sort.Slice(results, func(i, j int) bool { return results[i].broker < results[j].broker })
+	return results
+}
+
+// aggregateBrokerResults returns the number of failed brokers and the error of every failed broker
+func aggregateBrokerResults(results []directBrokerResult) (int, error) {
+	errs := []string{}
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, fmt.Sprintf("broker %s: %v", result.broker, result.err))
+		}
+	}
+	if len(errs) == 0 {
+		return 0, nil
+	}
+	return len(errs), fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// TestDirectBrokers runs a single message pub sub on each broker service url bypassing the proxy
+// the broker serves the topic lookup and the client connects to the owner broker without the proxy
+func TestDirectBrokers(topicCfg TopicCfg) {
+	if topicCfg.ClusterName == "" || adminURL(topicCfg) == "" {
+		log.Errorf("direct broker probe on topic %s requires clusterName and adminUrl", topicCfg.TopicName)
+		return
+	}
+	name := topicCfg.ClusterName + "-direct-brokers"
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	brokers, err := GetBrokers(adminURL(topicCfg), topicCfg.ClusterName, tokenSupplier)
+	if err != nil {
+		errMsg := fmt.Sprintf("cluster %s direct broker probe failed to list brokers, error: %v", name, err)
+		log.Errorf(errMsg)
+		ReportIncident(name, name, "direct broker test error reported by pulsar-heartbeat", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+
+	prefix := "messageid"
+	payloads, maxPayloadSize := AllMsgPayloads(prefix, nil, 1)
+	results := probeBrokers(brokerServiceURLs(brokers, topicCfg.PulsarURL, topicCfg.DirectBrokerPort), func(serviceURL string) (MsgResult, error) {
+		// a lightweight pub sub on the input topic with a subscription per broker not to conflict with the latency test
+		brokerCfg := topicCfg
+		brokerCfg.PulsarURL = serviceURL
+		brokerCfg.OutputTopic, brokerCfg.ExpectedMsg = "", ""
+		brokerCfg.NackRedeliveryCheck = false
+		brokerCfg.ResetSubscriptionBeforeTest = true
+		brokerCfg.subscriptionName = "latency-measure-" + serviceURL[strings.Index(serviceURL, "://")+3:]
+		return PubSubLatency(topicCfg.ClusterName, tokenSupplier, brokerCfg, prefix, payloads, maxPayloadSize)
+	})
+	for _, result := range results {
+		if result.err == nil {
+			PromDirectBrokerLatency(topicCfg.ClusterName, result.broker, result.latency)
+			log.Infof("cluster %s broker %s direct message latency %v", topicCfg.ClusterName, result.broker, result.latency)
+		}
+	}
+
+	failedBrokers, err := aggregateBrokerResults(results)
+	if brokersUnhealthy(failedBrokers, len(results), GetConfig().BrokersConfig.MaxFailedBrokers) {
+		errMsg := fmt.Sprintf("cluster %s has %d brokers failed the direct pub sub test out of %d, error message: %v", name, failedBrokers, len(results), err)
+		log.Errorf(errMsg)
+		ReportIncident(name, name, "direct broker pub sub test failure reported by pulsar-heartbeat", errMsg, &topicCfg.AlertPolicy)
+	} else {
+		if failedBrokers > 0 {
+			log.Warnf("cluster %s has %d brokers failed the direct pub sub test out of %d within the tolerated threshold, error message: %v", name, failedBrokers, len(results), err)
+		}
+		ClearIncident(name)
+	}
+}
@@ -0,0 +1,99 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBrokerServiceURLs(t *testing.T) {
+	brokers := []string{"10.244.1.5:8080", "http://broker-1.pulsar:8080"}
+	urls := brokerServiceURLs(brokers, "pulsar+ssl://pulsar.example.com:6651", 0)
+	assert(t, "pulsar+ssl://10.244.1.5:6651" == urls["10.244.1.5:8080"], "expect the tls service url but got %s", urls["10.244.1.5:8080"])
+	assert(t, "pulsar+ssl://broker-1.pulsar:6651" == urls["http://broker-1.pulsar:8080"], "expect the scheme stripped but got %s", urls["http://broker-1.pulsar:8080"])
+
+	urls = brokerServiceURLs(brokers, "pulsar://pulsar.example.com:6650", 16650)
+	assert(t, "pulsar://10.244.1.5:16650" == urls["10.244.1.5:8080"], "expect the configured port but got %s", urls["10.244.1.5:8080"])
+}
+
+func TestProbeBrokersAggregation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["10.0.0.3:8080","10.0.0.1:8080","10.0.0.2:8080"]`))
+	}))
+	defer server.Close()
+	brokers, err := GetBrokers(server.URL, "direct-cluster", nil)
+	errNil(t, err)
+
+	results := probeBrokers(brokerServiceURLs(brokers, "pulsar://proxy:6650", 0), func(serviceURL string) (MsgResult, error) {
+		if strings.HasPrefix(serviceURL, "pulsar://10.0.0.2:") {
+			return MsgResult{Latency: failedLatency}, errors.New("connection refused")
+		}
+		return MsgResult{Latency: 15 * time.Millisecond}, nil
+	})
+	assert(t, len(results) == 3, "expect a result per broker but got %d", len(results))
+	assert(t, "10.0.0.1:8080" == results[0].broker && "10.0.0.3:8080" == results[2].broker, "expect results sorted by broker")
+	assert(t, results[0].latency == 15*time.Millisecond, "expect the broker latency")
+
+	failed, err := aggregateBrokerResults(results)
+	assert(t, failed == 1, "expect 1 failed broker but got %d", failed)
+	assert(t, err != nil && strings.Contains(err.Error(), "broker 10.0.0.2:8080: connection refused"), "expect the failed broker error but got %v", err)
+
+	failed, err = aggregateBrokerResults(results[:1])
+	errNil(t, err)
+	assert(t, failed == 0, "expect no failed broker")
+}
+
+func TestDirectBrokerProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["direct-broker-1:8080","direct-broker-2:8080"]`))
+	}))
+	defer server.Close()
+	topicCfg := TopicCfg{
+		ClusterName: "direct-test",
+		AdminURL:    server.URL,
+		PulsarURL:   "pulsar://direct-proxy:6650",
+		TopicName:   "persistent://tenant/ns/direct-test",
+	}
+	clients := []*fakePulsarClient{
+		newFakePulsarClient(t, "pulsar://direct-broker-1:6650", time.Millisecond),
+		newFakePulsarClient(t, "pulsar://direct-broker-2:6650", time.Millisecond),
+	}
+
+	TestDirectBrokers(topicCfg)
+	for _, client := range clients {
+		assert(t, client.consumerOptions.Topic == topicCfg.TopicName, "expect a pub sub on the broker")
+		assert(t, strings.HasPrefix(client.consumerOptions.SubscriptionName, "latency-measure-direct-broker-"),
+			"expect a subscription per broker but got %s", client.consumerOptions.SubscriptionName)
+	}
+	found := 0
+	for _, labels := range gatheredLabels(t, "pulsar_broker_direct_latency_ms") {
+		if labels["device"] == "direct-test" {
+			found++
+		}
+	}
+	assert(t, found == 2, "expect the latency of 2 brokers but got %d", found)
+}
@@ -0,0 +1,113 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// track the accumulated pub sub downtime against a downtime budget in a rolling window
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// downtimeSample is the downtime reported by a test at the time
+type downtimeSample struct {
+	at       time.Time
+	downtime time.Duration
+}
+
+// downtimeBudget accumulates the downtime in a rolling window
+// the downtime is kept in memory so that the window restarts with the process
+type downtimeBudget struct {
+	budget   time.Duration
+	window   time.Duration
+	samples  []downtimeSample
+	breached bool
+}
+
+// add records the downtime and returns the budget remaining in the window ending at the time
+func (b *downtimeBudget) add(at time.Time, downtime time.Duration) time.Duration {
+	if downtime > 0 {
+		b.samples = append(b.samples, downtimeSample{at: at, downtime: downtime})
+	}
+	windowStart := at.Add(-b.window)
+	i := 0
+	for i < len(b.samples) && !b.samples[i].at.After(windowStart) {
+		i++
+	}
+	b.samples = b.samples[i:]
+
+	remaining := b.budget
+	for _, sample := range b.samples {
+		remaining -= sample.downtime
+	}
+	return remaining
+}
+
+var (
+	// key is the cluster name
+	downtimeBudgets     = make(map[string]*downtimeBudget)
+	downtimeBudgetsLock = &sync.Mutex{}
+)
+
+// reportDowntime exposes the downtime of the latest test and accounts it against the topic's downtime budget
+func reportDowntime(clusterName string, topicCfg TopicCfg, downtime time.Duration) {
+	PromGauge(PubSubDowntimeGaugeOpt(), clusterName, downtime.Seconds())
+	if topicCfg.DowntimeBudgetSeconds <= 0 {
+		return
+	}
+	evalDowntimeBudget(clusterName, topicCfg, time.Now(), downtime)
+}
+
+// evalDowntimeBudget raises an incident once when the budget is exhausted and clears it when the window has budget again
+func evalDowntimeBudget(clusterName string, topicCfg TopicCfg, at time.Time, downtime time.Duration) time.Duration {
+	budget := time.Duration(topicCfg.DowntimeBudgetSeconds) * time.Second
+	window := util.TimeDuration(topicCfg.DowntimeBudgetWindowDays, 30, 24*time.Hour)
+
+	downtimeBudgetsLock.Lock()
+	b, ok := downtimeBudgets[clusterName]
+	if !ok || b.budget != budget || b.window != window {
+		b = &downtimeBudget{budget: budget, window: window}
+		downtimeBudgets[clusterName] = b
+	}
+	remaining := b.add(at, downtime)
+	breached := remaining < 0
+	changed := breached != b.breached
+	b.breached = breached
+	downtimeBudgetsLock.Unlock()
+
+	PromGauge(DowntimeBudgetRemainingGaugeOpt(), clusterName, remaining.Seconds())
+	component := clusterName + "-downtime-budget"
+	if changed && breached {
+		errMsg := fmt.Sprintf("cluster %s downtime %v exceeds the budget %v in the last %v",
+			clusterName, budget-remaining, budget, window)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "downtime budget exhausted", errMsg, &AlertPolicyCfg{Ceiling: 1})
+	} else if changed {
+		log.Infof("cluster %s downtime is within the budget %v, remaining %v", clusterName, budget, remaining)
+		ClearIncident(component)
+	}
+	return remaining
+}
@@ -0,0 +1,117 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDowntimeBudgetAccounting(t *testing.T) {
+	b := downtimeBudget{budget: 5 * time.Minute, window: 24 * time.Hour}
+	start := time.Now()
+	assert(t, 5*time.Minute == b.add(start, 0), "expect the full budget without downtime")
+	assert(t, 4*time.Minute == b.add(start.Add(time.Hour), time.Minute), "expect 4 minutes remaining")
+	assert(t, time.Minute == b.add(start.Add(2*time.Hour), 3*time.Minute), "expect 1 minute remaining")
+	assert(t, -time.Minute == b.add(start.Add(3*time.Hour), 2*time.Minute), "expect the budget overspent by 1 minute")
+
+	// the first downtime rolls out of the window
+	assert(t, 0 == b.add(start.Add(25*time.Hour+time.Second), 0), "expect the 1st minute rolled out of the window")
+	assert(t, 2 == len(b.samples), "expect 2 samples in the window but got %d", len(b.samples))
+	assert(t, 5*time.Minute == b.add(start.Add(28*time.Hour), 0), "expect the full budget after the window")
+}
+
+func TestDowntimeBudgetBreach(t *testing.T) {
+	defer withoutAlertDestinations()()
+	clusterName := "downtime-budget-cluster"
+	component := clusterName + "-downtime-budget"
+	defer func() {
+		downtimeBudgetsLock.Lock()
+		delete(downtimeBudgets, clusterName)
+		downtimeBudgetsLock.Unlock()
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+	}()
+	paged := func() bool {
+		incidentTrackersLock.RLock()
+		defer incidentTrackersLock.RUnlock()
+		tracker, ok := incidentTrackers[component]
+		return ok && tracker.Priority != ""
+	}
+	topicCfg := TopicCfg{DowntimeBudgetSeconds: 120, DowntimeBudgetWindowDays: 7}
+	start := time.Now()
+
+	remaining := evalDowntimeBudget(clusterName, topicCfg, start, time.Minute)
+	assert(t, time.Minute == remaining, "expect 1 minute remaining but got %v", remaining)
+	assert(t, !paged(), "expect no incident within the budget")
+
+	remaining = evalDowntimeBudget(clusterName, topicCfg, start.Add(time.Minute), 90*time.Second)
+	assert(t, -30*time.Second == remaining, "expect the budget overspent but got %v", remaining)
+	assert(t, paged(), "expect a budget incident")
+
+	// the budget recovers after the downtime rolls out of the weekly window
+	remaining = evalDowntimeBudget(clusterName, topicCfg, start.Add(8*24*time.Hour), 0)
+	assert(t, 2*time.Minute == remaining, "expect the full budget but got %v", remaining)
+	assert(t, !paged(), "expect the budget incident cleared")
+}
+
+func TestDowntimeBudgetDefaultInterval(t *testing.T) {
+	defer withoutAlertDestinations()()
+	clusterName := "downtime-default-interval-cluster"
+	component := clusterName + "-downtime-budget"
+	defer func() {
+		downtimeBudgetsLock.Lock()
+		delete(downtimeBudgets, clusterName)
+		downtimeBudgetsLock.Unlock()
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, clusterName)
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+		componentStatusesLock.Lock()
+		delete(componentStatuses, clusterName)
+		componentStatusesLock.Unlock()
+	}()
+	// the interval is unset, every failed test accounts the 60 seconds default interval as downtime
+	topicCfg := TopicCfg{
+		ClusterName:           clusterName,
+		PulsarURL:             "pulsar://downtime-default-interval-test:6650",
+		TopicName:             "persistent://tenant/ns/downtime-default-interval",
+		PayloadSizes:          []string{"10B"},
+		NumOfMessages:         1,
+		NumberOfPartitions:    1,
+		LatencyBudgetMs:       1,
+		DowntimeBudgetSeconds: 30,
+		AlertPolicy:           AlertPolicyCfg{Ceiling: 1},
+	}
+	newFakePulsarClient(t, topicCfg.PulsarURL, 5*time.Millisecond)
+
+	testTopicLatency(clusterName, nil, topicCfg)
+	downtimeBudgetsLock.Lock()
+	b := downtimeBudgets[clusterName]
+	downtimeBudgetsLock.Unlock()
+	assert(t, b != nil && b.breached, "expect the downtime budget breached by the default interval")
+	incidentTrackersLock.RLock()
+	tracker, ok := incidentTrackers[component]
+	incidentTrackersLock.RUnlock()
+	assert(t, ok && tracker.Priority != "", "expect a budget incident")
+}
@@ -0,0 +1,110 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"strings"
+	"syscall"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// the underlying error classes of a failed pub sub test
+const (
+	errClassAuth              = "auth"
+	errClassTLS               = "tls"
+	errClassConnectionRefused = "connection_refused"
+	errClassTimeout           = "timeout"
+	errClassNotFound          = "not_found"
+	errClassUnknown           = "unknown"
+)
+
+// errorClasses are all the classes reported by the error class gauge
+var errorClasses = []string{errClassAuth, errClassTLS, errClassConnectionRefused, errClassTimeout, errClassNotFound, errClassUnknown}
+
+// classifyError returns the class of a pub sub test error so that on-call can tell auth from network and broker failures
+// the Pulsar client wraps most errors as text, so the error message is matched if the error type is not conclusive
+func classifyError(err error) string {
+	var pulsarErr *pulsar.Error
+	if errors.As(err, &pulsarErr) {
+		switch pulsarErr.Result() {
+		case pulsar.AuthenticationError, pulsar.AuthorizationError, pulsar.ErrorGettingAuthenticationData:
+			return errClassAuth
+		case pulsar.TimeoutError:
+			return errClassTimeout
+		case pulsar.TopicNotFound, pulsar.SubscriptionNotFound, pulsar.ConsumerNotFound:
+			return errClassNotFound
+		}
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &invalidCert):
+		return errClassTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return errClassConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded):
+		return errClassTimeout
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, "authenticationerror", "authorizationerror", "unauthorized", "forbidden", "token"):
+		return errClassAuth
+	case containsAny(msg, "x509", "tls:", "certificate"):
+		return errClassTLS
+	case containsAny(msg, "connection refused"):
+		return errClassConnectionRefused
+	case containsAny(msg, "timeout", "timed out", "deadline exceeded"):
+		return errClassTimeout
+	case containsAny(msg, "not found", "notfound", "404"):
+		return errClassNotFound
+	}
+	return errClassUnknown
+}
+
+// connectionErrorClass returns whether the error class could be caused by stale connections of the cached client,
+// an application level failure such as an out of order or altered message does not recycle the shared client
+func connectionErrorClass(class string) bool {
+	return class == errClassConnectionRefused || class == errClassTimeout
+}
+
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorClassIncidentMsg returns the incident message of an error class
+func errorClassIncidentMsg(class string) string {
+	if class == errClassUnknown {
+		return "persisted latency test failure"
+	}
+	return "persisted latency test " + strings.ReplaceAll(class, "_", " ") + " failure"
+}
@@ -0,0 +1,62 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := map[string]error{
+		errClassAuth:              errors.New("failed to create producer: server error: AuthenticationError: Failed to authentication token"),
+		errClassTLS:               fmt.Errorf("failed to subscribe to topic: %w", x509.UnknownAuthorityError{}),
+		errClassConnectionRefused: fmt.Errorf("failed to create producer: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}),
+		errClassTimeout:           fmt.Errorf("consumer Receive() error: %w", context.DeadlineExceeded),
+		errClassNotFound:          errors.New("failed to subscribe to topic: server error: TopicNotFound: Topic does not exist"),
+	}
+	for class, err := range cases {
+		assert(t, class == classifyError(err), "expect %s class for error %v but got %s", class, err, classifyError(err))
+	}
+	assert(t, errClassTimeout == classifyError(errors.New("latency measure not received after timeout")), "expect the probe timeout classified as timeout")
+	assert(t, errClassUnknown == classifyError(errors.New("producer closed")), "expect the unknown class")
+	assert(t, errClassTLS == classifyError(errors.New("remote error: tls: bad certificate")), "expect the tls class from the message")
+	assert(t, errClassConnectionRefused == classifyError(errors.New("dial tcp 10.0.0.1:6651: connect: connection refused")), "expect the connection refused class from the message")
+
+	assert(t, "persisted latency test auth failure" == errorClassIncidentMsg(errClassAuth), "expect the auth incident message")
+	assert(t, "persisted latency test connection refused failure" == errorClassIncidentMsg(errClassConnectionRefused), "expect the connection refused incident message")
+	assert(t, "persisted latency test failure" == errorClassIncidentMsg(errClassUnknown), "expect the generic incident message for unknown errors")
+}
+
+func TestConnectionErrorClass(t *testing.T) {
+	assert(t, connectionErrorClass(errClassConnectionRefused), "expect a refused connection recycles the client")
+	assert(t, connectionErrorClass(errClassTimeout), "expect a timeout recycles the client")
+	for _, class := range []string{errClassAuth, errClassTLS, errClassNotFound, errClassUnknown} {
+		assert(t, !connectionErrorClass(class), "expect the %s class keeps the client", class)
+	}
+}
@@ -0,0 +1,48 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify the exact received order of the messages against the sent order
+
+import (
+	"errors"
+	"fmt"
+)
+
+// errOrderDeviation is the received order different from the sent order
+var errOrderDeviation = errors.New("received order deviates from the sent order")
+
+// orderDeviation returns the first position the received message indexes deviate from the sent message indexes
+func orderDeviation(sent, received []int) error {
+	for i, index := range received {
+		if i >= len(sent) {
+			return fmt.Errorf("%w: position %d received extra message index %d after all %d messages", errOrderDeviation, i, index, len(sent))
+		}
+		if index != sent[i] {
+			return fmt.Errorf("%w: position %d received message index %d, expected %d", errOrderDeviation, i, index, sent[i])
+		}
+	}
+	if len(received) < len(sent) {
+		return fmt.Errorf("%w: position %d expected message index %d not received", errOrderDeviation, len(received), sent[len(received)])
+	}
+	return nil
+}
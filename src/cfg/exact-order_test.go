@@ -0,0 +1,78 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrderDeviation(t *testing.T) {
+	sent := []int{0, 1, 2, 3}
+	errNil(t, orderDeviation(sent, []int{0, 1, 2, 3}))
+
+	// the max index advances on every message but the message index 1 is lost
+	err := orderDeviation(sent, []int{0, 2, 3})
+	assert(t, errors.Is(err, errOrderDeviation), "expect the gap as a deviation but got %v", err)
+	assert(t, strings.Contains(err.Error(), "position 1 received message index 2, expected 1"), "expect the first deviation but got %v", err)
+
+	err = orderDeviation(sent, []int{0, 1, 1, 2})
+	assert(t, strings.Contains(err.Error(), "position 2 received message index 1, expected 2"), "expect the duplicate as a deviation but got %v", err)
+	err = orderDeviation(sent, []int{0, 1, 2})
+	assert(t, strings.Contains(err.Error(), "position 3 expected message index 3 not received"), "expect the missing message but got %v", err)
+	err = orderDeviation(sent, []int{0, 1, 2, 3, 3})
+	assert(t, strings.Contains(err.Error(), "position 4 received extra message index 3"), "expect the extra message but got %v", err)
+}
+
+func TestPubSubLatencyStrictOrdering(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:      "pulsar://strict-ordering-test:6650",
+		TopicName:      "persistent://tenant/ns/strict-ordering-test",
+		StrictOrdering: true,
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 4)
+
+	// the messages are delivered one after another in the sent order
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 2*time.Millisecond)
+	client.producer.stagger = true
+	result, err := PubSubLatency("strict-ordering-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, result.InOrderDelivery && result.orderDeviation == nil, "expect the exact order but got %v", result.orderDeviation)
+
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 2*time.Millisecond)
+	client.producer.stagger, client.producer.outOfOrder = true, true
+	result, err = PubSubLatency("strict-ordering-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, !result.InOrderDelivery, "expect the out of order delivery")
+	assert(t, errors.Is(result.orderDeviation, errOrderDeviation) && strings.Contains(result.orderDeviation.Error(), "position 0 received message index 1, expected 0"),
+		"expect the first deviation reported but got %v", result.orderDeviation)
+
+	// the deviation is not tracked without the strict ordering mode
+	topicCfg.StrictOrdering = false
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 2*time.Millisecond)
+	client.producer.stagger, client.producer.outOfOrder = true, true
+	result, err = PubSubLatency("strict-ordering-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, !result.InOrderDelivery && result.orderDeviation == nil, "expect no deviation tracked but got %v", result.orderDeviation)
+}
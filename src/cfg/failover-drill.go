@@ -0,0 +1,114 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// drill the failover from the primary proxy, simulated unreachable, to the secondary proxy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// errMarkedDown fails the attempt on a url simulated unreachable
+var errMarkedDown = errors.New("marked down for the failover drill")
+
+// failoverAttempt is a probe attempt on one of the proxy urls
+type failoverAttempt struct {
+	url string
+	at  time.Time // when the attempt finished
+	err error
+}
+
+// failoverTime returns the time from the start to the end of the first successful attempt
+func failoverTime(start time.Time, attempts []failoverAttempt) (time.Duration, bool) {
+	for _, attempt := range attempts {
+		if attempt.err == nil {
+			return attempt.at.Sub(start), true
+		}
+	}
+	return 0, false
+}
+
+// runFailoverDrill probes the urls in order until the first success, or until the sla is exceeded,
+// the urls marked down fail without being probed
+func runFailoverDrill(urls []string, down map[string]bool, sla, retryInterval time.Duration, probe func(string) error) (time.Time, []failoverAttempt) {
+	start := time.Now()
+	attempts := []failoverAttempt{}
+	for time.Since(start) < sla {
+		for _, u := range urls {
+			err := errMarkedDown
+			if !down[u] {
+				err = probe(u)
+			}
+			attempts = append(attempts, failoverAttempt{url: u, at: time.Now(), err: err})
+			if err == nil {
+				return start, attempts
+			}
+		}
+		time.Sleep(retryInterval)
+	}
+	return start, attempts
+}
+
+// TestFailoverDrill marks the primary proxy down and measures the time to the first pub sub success on the secondary proxy
+func TestFailoverDrill(drill FailoverDrillCfg) {
+	component := drill.Name + "-failover"
+	sla := util.TimeDuration(drill.SLASeconds, 60, time.Second)
+	tokenSupplier := util.TokenSupplierWithOverride(drill.Token, GetConfig().TokenSupplier())
+	prefix := "messageid"
+	payloads, maxPayloadSize := AllMsgPayloads(prefix, nil, 1)
+
+	start, attempts := runFailoverDrill([]string{drill.PrimaryURL, drill.SecondaryURL}, map[string]bool{drill.PrimaryURL: true},
+		sla, time.Second, func(pulsarURL string) error {
+			topicCfg := TopicCfg{PulsarURL: pulsarURL, TopicName: drill.TopicName, subscriptionName: "failover-drill"}
+			_, err := PubSubLatency(drill.Name, tokenSupplier, topicCfg, prefix, payloads, maxPayloadSize)
+			return err
+		})
+
+	elapsed, ok := failoverTime(start, attempts)
+	if !ok || elapsed > sla {
+		errMsg := fmt.Sprintf("%s failover from %s to %s did not complete within %v", component, drill.PrimaryURL, drill.SecondaryURL, sla)
+		if last := attempts[len(attempts)-1]; last.err != nil {
+			errMsg = fmt.Sprintf("%s, the last error on %s: %v", errMsg, last.url, last.err)
+		}
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "failover drill exceeds the sla", errMsg, &drill.AlertPolicy)
+		return
+	}
+	PromGauge(FailoverTimeGaugeOpt(), drill.Name, float64(elapsed.Milliseconds()))
+	log.Infof("%s failover to %s completed in %v", component, drill.SecondaryURL, elapsed)
+	ClearIncident(component)
+}
+
+// FailoverDrillThread runs the failover drills
+func FailoverDrillThread() {
+	for _, drill := range GetConfig().FailoverDrillConfig {
+		log.Infof("drill failover from %s to %s", drill.PrimaryURL, drill.SecondaryURL)
+		RunInterval(func(d FailoverDrillCfg) monitorFunc {
+			return func() { TestFailoverDrill(d) }
+		}(drill), IntervalDuration(drill.IntervalSeconds, 3600))
+	}
+}
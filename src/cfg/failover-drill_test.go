@@ -0,0 +1,64 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFailoverTime(t *testing.T) {
+	start := time.Now()
+	attempts := []failoverAttempt{
+		{url: "primary", at: start, err: errMarkedDown},
+		{url: "secondary", at: start.Add(200 * time.Millisecond), err: errors.New("connection refused")},
+		{url: "primary", at: start.Add(time.Second), err: errMarkedDown},
+		{url: "secondary", at: start.Add(1500 * time.Millisecond)},
+	}
+	elapsed, ok := failoverTime(start, attempts)
+	assert(t, ok, "expect the failover completed")
+	assert(t, elapsed == 1500*time.Millisecond, "expect the failover time to the first success but got %v", elapsed)
+
+	_, ok = failoverTime(start, attempts[:3])
+	assert(t, !ok, "expect the failover incomplete without a success")
+}
+
+func TestRunFailoverDrill(t *testing.T) {
+	probed := []string{}
+	probe := func(u string) error {
+		probed = append(probed, u)
+		if len(probed) == 1 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	start, attempts := runFailoverDrill([]string{"primary", "secondary"}, map[string]bool{"primary": true}, time.Second, 10*time.Millisecond, probe)
+	assert(t, len(probed) == 2 && probed[0] == "secondary", "expect only the secondary probed but got %v", probed)
+	assert(t, len(attempts) == 4, "expect 4 attempts but got %d", len(attempts))
+	elapsed, ok := failoverTime(start, attempts)
+	assert(t, ok && elapsed >= 10*time.Millisecond, "expect the failover after a retry but got %v", elapsed)
+
+	_, attempts = runFailoverDrill([]string{"primary"}, map[string]bool{"primary": true}, 30*time.Millisecond, 10*time.Millisecond, probe)
+	_, ok = failoverTime(start, attempts)
+	assert(t, !ok, "expect no success when every url is marked down")
+}
@@ -0,0 +1,148 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// run the custom command of the alert policy when a probe fails
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// maxFailureExecOutput is the maximum number of bytes of the command output added to the incident
+const maxFailureExecOutput = 2048
+
+var (
+	// failureExecs are the components of the correlation aliases whose on failure command has been started,
+	// the command runs again only after the incident is removed
+	failureExecs     = make(map[string]string)
+	failureExecsLock = &sync.Mutex{}
+)
+
+// runFailureExec runs the command and returns the combined output, it is replaced in tests
+var runFailureExec = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// sanitizeExecValue removes the control characters of a context value rendered into the command arguments,
+// the command is never run by a shell so the value cannot inject another command
+func sanitizeExecValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// failureExecArgs renders the command and the arguments with the sanitized context
+func (a *AlertPolicyCfg) failureExecArgs(ctx AlertContext) ([]string, error) {
+	ctx.Component = sanitizeExecValue(ctx.Component)
+	ctx.Cluster = sanitizeExecValue(ctx.Cluster)
+	ctx.Message = sanitizeExecValue(ctx.Message)
+	ctx.Error = sanitizeExecValue(ctx.Error)
+	args := make([]string, 0, len(a.OnFailureExec))
+	for _, text := range a.OnFailureExec {
+		arg, err := renderAlertTemplate(text, ctx)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// failureExecDescription runs the on failure command of an incident about to be created and appends the output to the
+// incident description, the incident waits for the command within the timeout so that every provider receives the output.
+// The command runs at most once per open incident of the alias.
+func (a *AlertPolicyCfg) failureExecDescription(ctx AlertContext, alias string) string {
+	if len(a.OnFailureExec) == 0 || a.OnFailureExec[0] == "" {
+		return ctx.Error
+	}
+	alias = correlationAlias(ctx.Component, alias)
+	failureExecsLock.Lock()
+	if _, started := failureExecs[alias]; started {
+		failureExecsLock.Unlock()
+		return ctx.Error
+	}
+	failureExecs[alias] = ctx.Component
+	failureExecsLock.Unlock()
+
+	output := a.onFailureExec(ctx)
+	if output == "" {
+		return ctx.Error
+	}
+	return fmt.Sprintf("%s, on failure exec output: %s", ctx.Error, output)
+}
+
+// clearFailureExec allows the on failure command to run on the next incident of the removed component
+func clearFailureExec(component string) {
+	failureExecsLock.Lock()
+	defer failureExecsLock.Unlock()
+	for alias, c := range failureExecs {
+		if c == component {
+			delete(failureExecs, alias)
+		}
+	}
+}
+
+// onFailureExec runs the on failure command if it is enabled with the timeout, it returns the output or the error of the command
+func (a *AlertPolicyCfg) onFailureExec(ctx AlertContext) string {
+	if len(a.OnFailureExec) == 0 || a.OnFailureExec[0] == "" {
+		return ""
+	}
+	if inStartupGracePeriod(time.Now()) {
+		return ""
+	}
+	if !GetConfig().EnableFailureExec {
+		log.Warnf("onFailureExec of %s is not run without enableFailureExec", ctx.Component)
+		return ""
+	}
+	args, err := a.failureExecArgs(ctx)
+	if err != nil {
+		log.Errorf("failed to render the onFailureExec of %s, error %v", ctx.Component, err)
+		return ""
+	}
+
+	timeout := util.TimeDuration(GetConfig().FailureExecTimeoutSeconds, 30, time.Second)
+	execCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	output, err := runFailureExec(execCtx, args[0], args[1:]...)
+	result := strings.TrimSpace(string(output))
+	if len(result) > maxFailureExecOutput {
+		result = result[:maxFailureExecOutput]
+	}
+	if err != nil {
+		log.Errorf("onFailureExec %s of %s failed, error %v, output %s", args[0], ctx.Component, err, result)
+		return strings.TrimSpace("error " + err.Error() + " " + result)
+	}
+	log.Infof("onFailureExec %s of %s output %s", args[0], ctx.Component, result)
+	return result
+}
@@ -0,0 +1,184 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// failureExecStub records the invocations of the on failure command
+type failureExecStub struct {
+	mu   sync.Mutex
+	args []string
+	runs int
+}
+
+func (s *failureExecStub) invoked() ([]string, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.args, s.runs
+}
+
+func stubFailureExec(t *testing.T, output string, err error) *failureExecStub {
+	stub := &failureExecStub{}
+	saved := runFailureExec
+	runFailureExec = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		_, hasDeadline := ctx.Deadline()
+		assert(t, hasDeadline, "expect the command run with a timeout")
+		stub.mu.Lock()
+		defer stub.mu.Unlock()
+		stub.args = append([]string{name}, args...)
+		stub.runs++
+		return []byte(output), err
+	}
+	t.Cleanup(func() { runFailureExec = saved })
+	return stub
+}
+
+func TestOnFailureExecArgs(t *testing.T) {
+	defer withoutAlertDestinations()()
+	stub := stubFailureExec(t, "heap dumped\n", nil)
+	policy := AlertPolicyCfg{OnFailureExec: []string{"/opt/hooks/heap-dump.sh", "--cluster={{.Cluster}}", "{{.Component}}", "{{.LatencyMs}}"}}
+	ctx := AlertContext{Component: "broker-1\n; rm -rf /", Cluster: "cluster1.example.com", LatencyMs: 1500}
+
+	// disabled by default
+	Config.EnableFailureExec = false
+	assert(t, policy.onFailureExec(ctx) == "", "expect no output without enableFailureExec")
+	_, runs := stub.invoked()
+	assert(t, runs == 0, "expect the command not invoked without enableFailureExec")
+
+	Config.EnableFailureExec = true
+	output := policy.onFailureExec(ctx)
+	assert(t, output == "heap dumped", "expect the captured output but got %s", output)
+	expected := []string{"/opt/hooks/heap-dump.sh", "--cluster=cluster1.example.com", "broker-1; rm -rf /", "1500"}
+	invoked, _ := stub.invoked()
+	assert(t, strings.Join(invoked, "|") == strings.Join(expected, "|"), "expect the templated args %v but got %v", expected, invoked)
+
+	// a failed command reports the error
+	stubFailureExec(t, "no such pod", errors.New("exit status 1"))
+	output = policy.onFailureExec(ctx)
+	assert(t, output == "error exit status 1 no such pod", "expect the command error but got %s", output)
+}
+
+func TestOnFailureExecOnIncidentCreated(t *testing.T) {
+	defer withoutAlertDestinations()()
+	stub := stubFailureExec(t, "heap dumped", nil)
+	component := "failure-exec-component"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+		clearFailureExec(component)
+	}()
+	slackTexts := make(chan string, 10)
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg SlackMessage
+		errNil(t, json.NewDecoder(r.Body).Decode(&msg))
+		slackTexts <- msg.Text
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer slack.Close()
+	pdDescriptions := make(chan string, 10)
+	pagerDuty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event struct {
+			Payload struct {
+				Details map[string]string `json:"custom_details"`
+			} `json:"payload"`
+		}
+		errNil(t, json.NewDecoder(r.Body).Decode(&event))
+		pdDescriptions <- event.Payload.Details["description"]
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"success","dedup_key":"failure-exec-component"}`))
+	}))
+	defer pagerDuty.Close()
+	eventURL := pagerDutyEventURL
+	pagerDutyEventURL = pagerDuty.URL
+	defer func() {
+		pagerDutyEventURL = eventURL
+		incidentsLock.Lock()
+		delete(incidents, component)
+		incidentsLock.Unlock()
+	}()
+	Config.SlackConfig.AlertURL = slack.URL
+	Config.PagerDutyConfig.IntegrationKey = "routing-key"
+	Config.EnableFailureExec = true
+
+	// the failures below the threshold do not run the command
+	policy := AlertPolicyCfg{Ceiling: 2, OnFailureExec: []string{"/opt/hooks/heap-dump.sh"}}
+	assert(t, !ReportIncident(component, component, "probe failure", "desc", &policy), "expect no incident below the threshold")
+	time.Sleep(50 * time.Millisecond)
+	_, runs := stub.invoked()
+	assert(t, runs == 0, "expect the command not invoked below the threshold but got %d runs", runs)
+
+	// the incident is created with the command output in the description
+	assert(t, ReportIncident(component, component, "probe failure", "desc", &policy), "expect an incident created")
+	_, runs = stub.invoked()
+	assert(t, runs == 1, "expect the command completed before the incident is created")
+	waitSlackText(t, slackTexts, "report incident as pager escalation, component failure-exec-component, alias failure-exec-component, message probe failure, description desc, on failure exec output: heap dumped")
+	select {
+	case desc := <-pdDescriptions:
+		assert(t, desc == "desc, on failure exec output: heap dumped", "expect the command output in the PagerDuty description but got %s", desc)
+	case <-time.After(time.Second):
+		t.Fatal("expect the PagerDuty incident")
+	}
+
+	// the command runs once per open incident
+	for i := 0; i < 3; i++ {
+		ReportIncident(component, component, "probe failure", "desc", &policy)
+	}
+	time.Sleep(50 * time.Millisecond)
+	_, runs = stub.invoked()
+	assert(t, runs == 1, "expect the command run once per open incident but got %d runs", runs)
+
+	// the removed incident allows the command to run on the next incident
+	RemoveIncident(component)
+	assert(t, !failureExecStarted(component), "expect the on failure exec cleared with the incident")
+}
+
+// waitSlackText waits for the Slack message containing the text
+func waitSlackText(t *testing.T, texts chan string, expected string) {
+	for {
+		select {
+		case text := <-texts:
+			if strings.Contains(text, expected) {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expect the Slack message %s", expected)
+		}
+	}
+}
+
+func failureExecStarted(alias string) bool {
+	failureExecsLock.Lock()
+	defer failureExecsLock.Unlock()
+	_, started := failureExecs[alias]
+	return started
+}
@@ -0,0 +1,141 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// combine the latency budget adherence, broker health, k8s pod health and admin reachability into a cluster health score
+
+import (
+	"sync"
+	"time"
+
+	"github.com/datastax/pulsar-heartbeat/src/k8s"
+)
+
+// the health signals of a cluster, every signal is between 0 and 1
+const (
+	latencySignal = "latency"
+	brokersSignal = "brokers"
+	podsSignal    = "pods"
+	adminSignal   = "admin"
+)
+
+// defaultHealthWeights weigh every signal equally
+var defaultHealthWeights = map[string]float64{
+	latencySignal: 1,
+	brokersSignal: 1,
+	podsSignal:    1,
+	adminSignal:   1,
+}
+
+var (
+	// key is the cluster name, value is the latest value of every signal
+	healthSignals     = make(map[string]map[string]float64)
+	healthSignalsLock = &sync.Mutex{}
+)
+
+// healthScore returns the weighted average of the available signals between 0 and 100, a signal without a weight
+// has the default weight, a cluster without any weighted signal is healthy
+func healthScore(signals map[string]float64, weights map[string]float64) float64 {
+	var sum, totalWeight float64
+	for signal, value := range signals {
+		weight, ok := weights[signal]
+		if !ok {
+			weight = defaultHealthWeights[signal]
+		}
+		if weight <= 0 {
+			continue
+		}
+		sum += weight * value
+		totalWeight += weight
+	}
+	if totalWeight == 0 {
+		return 100
+	}
+	return 100 * sum / totalWeight
+}
+
+// recordHealthSignal records the latest value of the signal of the cluster
+func recordHealthSignal(cluster, signal string, value float64) {
+	healthSignalsLock.Lock()
+	defer healthSignalsLock.Unlock()
+	signals, ok := healthSignals[cluster]
+	if !ok {
+		signals = make(map[string]float64)
+		healthSignals[cluster] = signals
+	}
+	signals[signal] = value
+}
+
+// podHealth returns the pod health of the in-cluster k8s monitoring, it is not available if the cluster is not evaluated recently
+func (h *ClusterHealth) podHealth(now time.Time) (float64, bool) {
+	h.RLock()
+	defer h.RUnlock()
+	if h.UpdatedAt.IsZero() || now.Sub(h.UpdatedAt) >= 3*clusterMonInterval {
+		return 0, false
+	}
+	switch h.Status {
+	case k8s.OK:
+		return 1, true
+	case k8s.PartialReady:
+		return 0.5, true
+	default:
+		return 0, true
+	}
+}
+
+// inCluster returns the cluster monitored by the in-cluster k8s monitoring, the only cluster of the latency test topics
+// is assumed if it is not specified, it is empty if the topics are on multiple clusters
+func inCluster() string {
+	k8sCfg := GetConfig().K8sConfig
+	if k8sCfg.Cluster != "" {
+		return k8sCfg.Cluster
+	}
+	cluster := ""
+	for _, topicCfg := range GetConfig().PulsarTopicConfig {
+		host := clusterHost(topicCfg.PulsarURL)
+		if cluster != "" && host != cluster {
+			return ""
+		}
+		cluster = host
+	}
+	return cluster
+}
+
+// RecordHealthScore exports the health score of the cluster combining the latest signals,
+// the pod health of the in-cluster k8s monitoring only applies to the monitored cluster
+func RecordHealthScore(cluster string) float64 {
+	healthSignalsLock.Lock()
+	signals := make(map[string]float64, len(healthSignals[cluster])+1)
+	for signal, value := range healthSignals[cluster] {
+		signals[signal] = value
+	}
+	healthSignalsLock.Unlock()
+	if GetConfig().K8sConfig.Enabled && cluster == inCluster() {
+		if pods, ok := clusterHealth.podHealth(time.Now()); ok {
+			signals[podsSignal] = pods
+		}
+	}
+
+	score := healthScore(signals, GetConfig().HealthScoreWeights)
+	PromGauge(ClusterHealthScoreGaugeOpt(), cluster, score)
+	return score
+}
@@ -0,0 +1,113 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/datastax/pulsar-heartbeat/src/k8s"
+)
+
+func TestHealthScore(t *testing.T) {
+	signals := map[string]float64{latencySignal: 0.9, brokersSignal: 0.5, podsSignal: 1, adminSignal: 1}
+	score := healthScore(signals, nil)
+	assert(t, math.Abs(score-85) < 0.001, "expect the equally weighted score 85 but got %f", score)
+
+	score = healthScore(signals, map[string]float64{latencySignal: 2, brokersSignal: 0, podsSignal: 1})
+	// (2*0.9 + 1 + 1) / 4, the brokers are excluded and the admin has the default weight
+	assert(t, math.Abs(score-95) < 0.001, "expect the weighted score 95 but got %f", score)
+
+	score = healthScore(map[string]float64{latencySignal: 0}, nil)
+	assert(t, score == 0, "expect the score 0 of a failed cluster but got %f", score)
+	score = healthScore(map[string]float64{}, nil)
+	assert(t, score == 100, "expect a healthy score without signals but got %f", score)
+}
+
+func TestRecordHealthScore(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	cluster := "health-score-cluster"
+	defer func() {
+		healthSignalsLock.Lock()
+		delete(healthSignals, cluster)
+		healthSignalsLock.Unlock()
+		clusterHealth.Lock()
+		clusterHealth.Status, clusterHealth.MissingBrokers, clusterHealth.UpdatedAt = k8s.TotalDown, 0, time.Time{}
+		clusterHealth.Unlock()
+	}()
+
+	Config.K8sConfig.Enabled = false
+	Config.HealthScoreWeights = nil
+	recordHealthSignal(cluster, latencySignal, 1)
+	recordHealthSignal(cluster, brokersSignal, 0.5)
+	score := RecordHealthScore(cluster)
+	assert(t, math.Abs(score-75) < 0.001, "expect the score 75 without the pod health but got %f", score)
+
+	// the in-cluster pod health is included once evaluated
+	Config.K8sConfig.Enabled = true
+	Config.K8sConfig.Cluster = cluster
+	score = RecordHealthScore(cluster)
+	assert(t, math.Abs(score-75) < 0.001, "expect the pod health excluded before the evaluation but got %f", score)
+	clusterHealth.Set(k8s.PartialReady, 1)
+	score = RecordHealthScore(cluster)
+	assert(t, math.Abs(score-(1+0.5+0.5)/3*100) < 0.001, "expect the partial ready pod health included but got %f", score)
+}
+
+func TestRecordHealthScoreTwoClusters(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	inClusterName, remote := "pulsar.in-cluster.example.com", "pulsar.remote.example.com"
+	defer func() {
+		healthSignalsLock.Lock()
+		delete(healthSignals, inClusterName)
+		delete(healthSignals, remote)
+		healthSignalsLock.Unlock()
+		clusterHealth.Lock()
+		clusterHealth.Status, clusterHealth.MissingBrokers, clusterHealth.UpdatedAt = k8s.TotalDown, 0, time.Time{}
+		clusterHealth.Unlock()
+	}()
+
+	Config.HealthScoreWeights = nil
+	Config.K8sConfig = K8sClusterCfg{Enabled: true}
+	Config.PulsarTopicConfig = []TopicCfg{{PulsarURL: "pulsar+ssl://" + inClusterName + ":6651"}, {PulsarURL: "pulsar+ssl://" + remote + ":6651"}}
+	recordHealthSignal(inClusterName, latencySignal, 1)
+	recordHealthSignal(remote, latencySignal, 1)
+	clusterHealth.Set(k8s.TotalDown, 3)
+
+	// the pod health is not applied to any cluster if the monitored cluster is ambiguous
+	score := RecordHealthScore(inClusterName)
+	assert(t, math.Abs(score-100) < 0.001, "expect the pod health excluded from the ambiguous cluster but got %f", score)
+
+	Config.K8sConfig.Cluster = inClusterName
+	score = RecordHealthScore(inClusterName)
+	assert(t, math.Abs(score-50) < 0.001, "expect the total down pods on the in-cluster cluster but got %f", score)
+	score = RecordHealthScore(remote)
+	assert(t, math.Abs(score-100) < 0.001, "expect the remote cluster unaffected by the in-cluster pods but got %f", score)
+
+	// the only cluster of the topics is the monitored cluster
+	Config.K8sConfig.Cluster = ""
+	Config.PulsarTopicConfig = Config.PulsarTopicConfig[:1]
+	score = RecordHealthScore(inClusterName)
+	assert(t, math.Abs(score-50) < 0.001, "expect the pods applied to the only cluster but got %f", score)
+}
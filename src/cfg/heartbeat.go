@@ -25,6 +25,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/apex/log"
@@ -44,17 +45,60 @@ func StartHeartBeat() {
 	}
 }
 
+// UptimeHeartbeatInterval is the fixed interval of the uptime heartbeat
+const UptimeHeartbeatInterval = 30 * time.Second
+
+// lastUptimeHeartbeat is the unix nano time of the last uptime heartbeat, it must be accessed atomically
+var lastUptimeHeartbeat int64
+
 // UptimeHeartBeat sends heartbeat to uptime counter
 func UptimeHeartBeat() {
+	atomic.StoreInt64(&lastUptimeHeartbeat, time.Now().UnixNano())
 	PromCounter(HeartbeatCounterOpt(), GetConfig().Name)
 }
 
+// heartbeatWatchdog alerts once when the uptime heartbeat has stalled beyond the max stale duration
+type heartbeatWatchdog struct {
+	maxStale  time.Duration
+	startedAt time.Time
+	alerted   bool
+	alert     func(msg string)
+}
+
+// check returns whether the uptime heartbeat is stale, the watchdog start time is used before the first heartbeat
+func (w *heartbeatWatchdog) check(now time.Time) bool {
+	last := time.Unix(0, atomic.LoadInt64(&lastUptimeHeartbeat))
+	if last.Before(w.startedAt) {
+		last = w.startedAt
+	}
+	stale := now.Sub(last) > w.maxStale
+	if stale && !w.alerted {
+		w.alert(fmt.Sprintf("%s uptime heartbeat has stalled since %v, the monitor process could be frozen", GetConfig().Name, last))
+	}
+	w.alerted = stale
+	return stale
+}
+
+// MonitorHeartbeatWatchdog alerts when the uptime heartbeat has not run within a multiple of its interval
+func MonitorHeartbeatWatchdog() {
+	multiple := GetConfig().HeartbeatWatchdogMultiple
+	if multiple <= 0 {
+		return
+	}
+	w := heartbeatWatchdog{
+		maxStale:  time.Duration(multiple) * UptimeHeartbeatInterval,
+		startedAt: time.Now(),
+		alert:     Alert,
+	}
+	RunInterval(func() { w.check(time.Now()) }, UptimeHeartbeatInterval)
+}
+
 // HeartBeatToOpsGenie send heart beat to ops genie
 func HeartBeatToOpsGenie(genieURL, genieKey string) error {
 
 	name := GetConfig().Name
 
-	client := retryablehttp.NewClient()
+	client := newRetryableClient()
 	client.HTTPClient.Timeout = time.Duration(5) * time.Second
 	client.RetryWaitMin = 4 * time.Second
 	client.RetryWaitMax = 64 * time.Second
@@ -0,0 +1,53 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHeartbeatWatchdog(t *testing.T) {
+	alerts := []string{}
+	start := time.Now()
+	w := heartbeatWatchdog{
+		maxStale:  time.Minute,
+		startedAt: start,
+		alert:     func(msg string) { alerts = append(alerts, msg) },
+	}
+	atomic.StoreInt64(&lastUptimeHeartbeat, 0)
+
+	// the watchdog start time is used before the first heartbeat
+	assert(t, !w.check(start.Add(30*time.Second)), "expect no stale heartbeat within the grace of the start")
+	assert(t, w.check(start.Add(2*time.Minute)), "expect a stale heartbeat without any heartbeat")
+	assert(t, 1 == len(alerts), "expect one alert but got %d", len(alerts))
+
+	// the alert is only sent once for a stall
+	assert(t, w.check(start.Add(3*time.Minute)), "expect a stale heartbeat")
+	assert(t, 1 == len(alerts), "expect no repeated alert but got %d", len(alerts))
+
+	UptimeHeartBeat()
+	assert(t, !w.check(time.Now()), "expect the heartbeat resumed")
+	assert(t, w.check(time.Now().Add(2*time.Minute)), "expect the heartbeat stalled again")
+	assert(t, 2 == len(alerts), "expect an alert for the new stall but got %d", len(alerts))
+}
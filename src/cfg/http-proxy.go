@@ -0,0 +1,74 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// outbound HTTP clients honoring the configured or the environment proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// httpProxy returns the proxy of the outbound requests, the explicit httpProxy config takes precedence of
+// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func httpProxy() func(*http.Request) (*url.URL, error) {
+	proxy := GetConfig().HTTPProxy
+	if proxy == "" {
+		return http.ProxyFromEnvironment
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		log.Errorf("invalid httpProxy %s, fall back to the environment proxy, error %v", proxy, err)
+		return http.ProxyFromEnvironment
+	}
+	return http.ProxyURL(proxyURL)
+}
+
+// newHTTPTransport returns a transport with the default settings, the proxy and the optional tls config
+func newHTTPTransport(tlsConfig *tls.Config) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = httpProxy()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport
+}
+
+// newHTTPClient returns a http client with the timeout and the proxy
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: newHTTPTransport(nil),
+	}
+}
+
+// newRetryableClient returns a retryable http client with the proxy
+func newRetryableClient() *retryablehttp.Client {
+	client := retryablehttp.NewClient()
+	client.HTTPClient.Transport.(*http.Transport).Proxy = httpProxy()
+	return client
+}
@@ -0,0 +1,67 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConfiguredHTTPProxy(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+
+	proxied := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// a proxy receives the absolute URL of the target
+		proxied <- r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+	Config.HTTPProxy = proxy.URL
+
+	target := "http://pulsar-admin.example.invalid/admin/v2/clusters"
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	errNil(t, err)
+
+	admin, err := adminHTTPClient(5 * time.Second)
+	errNil(t, err)
+	proxyURL, err := admin.Transport.(*http.Transport).Proxy(req)
+	errNil(t, err)
+	assert(t, proxyURL != nil && proxyURL.String() == proxy.URL, "expect the admin client proxy %s but got %v", proxy.URL, proxyURL)
+
+	retryable := newRetryableClient()
+	proxyURL, err = retryable.HTTPClient.Transport.(*http.Transport).Proxy(req)
+	errNil(t, err)
+	assert(t, proxyURL != nil && proxyURL.String() == proxy.URL, "expect the retryable client proxy %s but got %v", proxy.URL, proxyURL)
+
+	resp, err := newHTTPClient(5 * time.Second).Do(req)
+	errNil(t, err)
+	resp.Body.Close()
+	assert(t, <-proxied == target, "expect the request sent through the proxy")
+
+	// an invalid proxy falls back to the environment proxy
+	Config.HTTPProxy = "://invalid"
+	assert(t, newHTTPTransport(nil).Proxy != nil, "expect the environment proxy")
+}
@@ -0,0 +1,131 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// keep the latest incidents in a bounded in-memory log for the incidents endpoint
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// defaultIncidentHistorySize is the number of the latest incidents kept
+const defaultIncidentHistorySize = 100
+
+// IncidentHistoryEntry is an incident created and possibly cleared
+type IncidentHistoryEntry struct {
+	Component string     `json:"component"`
+	Priority  string     `json:"priority"`
+	Created   time.Time  `json:"created"`
+	Cleared   *time.Time `json:"cleared,omitempty"`
+	// DowntimeSeconds is from the creation to the clear, or to now if the incident is still open
+	DowntimeSeconds float64 `json:"downtimeSeconds"`
+}
+
+var (
+	// incidentHistory is ordered with the oldest first
+	incidentHistory     []IncidentHistoryEntry
+	incidentHistoryLock = &sync.Mutex{}
+)
+
+// recordIncidentCreated appends the incident unless the component already has an open incident in the history
+func recordIncidentCreated(component, priority string) {
+	size := GetConfig().IncidentHistorySize
+	if size <= 0 {
+		size = defaultIncidentHistorySize
+	}
+	incidentHistoryLock.Lock()
+	defer incidentHistoryLock.Unlock()
+	if i := openHistoryEntry(component); i >= 0 {
+		incidentHistory[i].Priority = priority
+		return
+	}
+	incidentHistory = append(incidentHistory, IncidentHistoryEntry{
+		Component: component,
+		Priority:  priority,
+		Created:   time.Now(),
+	})
+	if len(incidentHistory) > size {
+		incidentHistory = append([]IncidentHistoryEntry{}, incidentHistory[len(incidentHistory)-size:]...)
+	}
+}
+
+// recordIncidentCleared marks the open incident of the component cleared, it returns whether there is an open incident
+func recordIncidentCleared(component string) bool {
+	incidentHistoryLock.Lock()
+	defer incidentHistoryLock.Unlock()
+	if i := openHistoryEntry(component); i >= 0 {
+		now := time.Now()
+		incidentHistory[i].Cleared = &now
+		return true
+	}
+	return false
+}
+
+// openHistoryEntry returns the index of the latest incident of the component not cleared yet, or -1
+// the caller must hold the incidentHistoryLock
+func openHistoryEntry(component string) int {
+	for i := len(incidentHistory) - 1; i >= 0; i-- {
+		if incidentHistory[i].Component == component {
+			if incidentHistory[i].Cleared == nil {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// IncidentHistory returns a copy of the incident history with the oldest first
+func IncidentHistory() []IncidentHistoryEntry {
+	now := time.Now()
+	incidentHistoryLock.Lock()
+	defer incidentHistoryLock.Unlock()
+	entries := make([]IncidentHistoryEntry, len(incidentHistory))
+	for i, entry := range incidentHistory {
+		end := now
+		if entry.Cleared != nil {
+			end = *entry.Cleared
+		}
+		entry.DowntimeSeconds = end.Sub(entry.Created).Seconds()
+		entries[i] = entry
+	}
+	return entries
+}
+
+// IncidentsHandler serves the incident history, a bearer token is required if the token is specified
+func IncidentsHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizedGet(w, r, token) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(IncidentHistory()); err != nil {
+			log.Errorf("failed to write incident history %v", err)
+		}
+	}
+}
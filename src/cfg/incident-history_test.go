@@ -0,0 +1,77 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIncidentHistory(t *testing.T) {
+	defer withoutAlertDestinations()()
+	saved := incidentHistory
+	defer func() {
+		incidentHistoryLock.Lock()
+		incidentHistory = saved
+		incidentHistoryLock.Unlock()
+	}()
+	incidentHistoryLock.Lock()
+	incidentHistory = nil
+	incidentHistoryLock.Unlock()
+
+	CreateIncident("history-cleared", "", "cluster down", "latency test error", "P2")
+	// a repeated incident of an open component is not another history entry
+	CreateIncident("history-cleared", "", "cluster down", "latency test error", "P1")
+	CreateIncident("history-open", "", "cluster down", "latency test error", "P3")
+	RemoveIncident("history-cleared")
+
+	rec := httptest.NewRecorder()
+	IncidentsHandler("")(rec, httptest.NewRequest(http.MethodGet, "/incidents", nil))
+	assert(t, http.StatusOK == rec.Code, "expect status code 200 but got %d", rec.Code)
+	entries := []IncidentHistoryEntry{}
+	errNil(t, json.NewDecoder(rec.Body).Decode(&entries))
+	assert(t, len(entries) == 2, "expect 2 incidents but got %v", entries)
+
+	cleared := entries[0]
+	assert(t, cleared.Component == "history-cleared" && cleared.Priority == "P1", "unexpected incident %v", cleared)
+	assert(t, cleared.Cleared != nil && !cleared.Cleared.Before(cleared.Created), "expect the clear time but got %v", cleared)
+	assert(t, cleared.DowntimeSeconds >= 0, "expect the downtime but got %f", cleared.DowntimeSeconds)
+	open := entries[1]
+	assert(t, open.Component == "history-open" && open.Priority == "P3" && open.Cleared == nil, "unexpected incident %v", open)
+
+	// a new incident after the clear is another entry
+	CreateIncident("history-cleared", "", "cluster down", "latency test error", "P2")
+	entries = IncidentHistory()
+	assert(t, len(entries) == 3 && entries[2].Cleared == nil, "expect a new open incident but got %v", entries)
+
+	// the history is bounded
+	Config.IncidentHistorySize = 2
+	CreateIncident("history-bounded", "", "cluster down", "latency test error", "P2")
+	entries = IncidentHistory()
+	assert(t, len(entries) == 2 && entries[1].Component == "history-bounded", "expect the latest 2 incidents but got %v", entries)
+
+	rec = httptest.NewRecorder()
+	IncidentsHandler("secret")(rec, httptest.NewRequest(http.MethodGet, "/incidents", nil))
+	assert(t, http.StatusUnauthorized == rec.Code, "expect status code 401 without a token but got %d", rec.Code)
+}
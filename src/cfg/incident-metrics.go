@@ -0,0 +1,42 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// exposes the incident tracker internals as metrics
+
+// recordIncidentMetrics updates the open incident and incident tracker gauges
+func recordIncidentMetrics() {
+	name := GetConfig().Name
+	PromGaugeInt(OpenIncidentsGaugeOpt(), name, len(openIncidents()))
+
+	counters := make(map[string]int)
+	windowAlerts := make(map[string]int)
+	incidentTrackersLock.RLock()
+	for component, tracker := range incidentTrackers {
+		counters[component] = tracker.Counters
+		windowAlerts[component] = len(tracker.Alerts)
+	}
+	incidentTrackersLock.RUnlock()
+
+	PromGaugeInt(IncidentTrackersGaugeOpt(), name, len(counters))
+	PromIncidentTrackers(name, counters, windowAlerts)
+}
@@ -0,0 +1,76 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestIncidentMetrics(t *testing.T) {
+	defer withoutAlertDestinations()()
+	component := "incident-metrics-component"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+		recordIncidentMetrics()
+	}()
+	name := GetConfig().Name
+	policy := AlertPolicyCfg{Ceiling: 2, MovingWindowSeconds: 60, CeilingInMovingWindow: 5}
+	recordIncidentMetrics()
+	openGauge := func() float64 {
+		return testutil.ToFloat64(metrics[getMetricKey(OpenIncidentsGaugeOpt())].WithLabelValues(name))
+	}
+	trackers := func() float64 {
+		return testutil.ToFloat64(metrics[getMetricKey(IncidentTrackersGaugeOpt())].WithLabelValues(name))
+	}
+	trackedSeries := func() int {
+		count := 0
+		for _, labels := range gatheredLabels(t, "pulsar_monitor_incident_tracker_counter") {
+			if labels["component"] == component {
+				count++
+			}
+		}
+		return count
+	}
+	open, tracked := openGauge(), trackers()
+
+	assert(t, !ReportIncident(component, component, "time out message", "desc", &policy), "expect no incident under the ceiling")
+	assert(t, trackers() == tracked+1, "expect one more tracker but got %f", trackers())
+	assert(t, openGauge() == open, "expect no more open incident but got %f", openGauge())
+	assert(t, testutil.ToFloat64(incidentTrackerCounter.WithLabelValues(name, component)) == 1, "expect the tracker counter 1")
+	assert(t, testutil.ToFloat64(incidentTrackerWindowAlerts.WithLabelValues(name, component)) == 1, "expect 1 failure in the moving window")
+
+	ClearIncident(component)
+	assert(t, trackers() == tracked, "expect the tracker removed but got %f", trackers())
+	assert(t, trackedSeries() == 0, "expect the tracker series removed")
+
+	ReportIncident(component, component, "time out message", "desc", &policy)
+	assert(t, ReportIncident(component, component, "time out message", "desc", &policy), "expect an incident at the ceiling")
+	assert(t, openGauge() == open+1, "expect one more open incident but got %f", openGauge())
+	assert(t, testutil.ToFloat64(incidentTrackerCounter.WithLabelValues(name, component)) == 0, "expect the tracker counter reset after paging")
+
+	ClearIncident(component)
+	assert(t, openGauge() == open, "expect the open incident cleared but got %f", openGauge())
+}
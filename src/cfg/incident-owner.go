@@ -0,0 +1,85 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// tag incidents with the team and service owning the component
+
+import (
+	"sync"
+)
+
+// incidentOwner is the team and service responsible for a component
+type incidentOwner struct {
+	team    string
+	service string
+}
+
+// tags returns the incident tags of the owner
+func (o incidentOwner) tags() []string {
+	tags := []string{}
+	if o.team != "" {
+		tags = append(tags, "team:"+o.team)
+	}
+	if o.service != "" {
+		tags = append(tags, "service:"+o.service)
+	}
+	return tags
+}
+
+var (
+	// key is the component, the owner is recorded when the component reports an incident
+	incidentOwners     = make(map[string]incidentOwner)
+	incidentOwnersLock = &sync.RWMutex{}
+)
+
+// setOwners assigns the owner team and service to the alert policies
+func (c *Configuration) setOwners() {
+	for i := range c.PulsarTopicConfig {
+		t := &c.PulsarTopicConfig[i]
+		t.AlertPolicy.owner = incidentOwner{team: t.OwnerTeam, service: t.Service}
+	}
+	for i := range c.SitesConfig.Sites {
+		s := &c.SitesConfig.Sites[i]
+		s.AlertPolicy.owner = incidentOwner{team: s.OwnerTeam, service: s.Service}
+	}
+	for i := range c.PulsarAdminConfig.Clusters {
+		cluster := &c.PulsarAdminConfig.Clusters[i]
+		cluster.AlertPolicy.owner = incidentOwner{team: cluster.OwnerTeam, service: cluster.Service}
+	}
+}
+
+// recordIncidentOwner records the owner of the component reporting an incident
+func recordIncidentOwner(component string, owner incidentOwner) {
+	if owner == (incidentOwner{}) {
+		return
+	}
+	incidentOwnersLock.Lock()
+	defer incidentOwnersLock.Unlock()
+	incidentOwners[component] = owner
+}
+
+// componentOwner returns the owner of the component, it is empty if the component has no owner
+func componentOwner(component string) incidentOwner {
+	incidentOwnersLock.RLock()
+	defer incidentOwnersLock.RUnlock()
+	return incidentOwners[component]
+}
@@ -0,0 +1,83 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+func TestIncidentOwnerTags(t *testing.T) {
+	defer withoutAlertDestinations()()
+	c := Configuration{
+		PulsarTopicConfig: []TopicCfg{{OwnerTeam: "payments", Service: "billing"}},
+		SitesConfig:       SitesCfg{Sites: []SiteCfg{{OwnerTeam: "web"}}},
+		PulsarAdminConfig: PulsarAdminRESTCfg{Clusters: []OpsClusterCfg{{Service: "streaming"}}},
+	}
+	c.setOwners()
+	assert(t, "payments" == c.PulsarTopicConfig[0].AlertPolicy.owner.team, "expect the owner team assigned to the topic alert policy")
+	assert(t, "web" == c.SitesConfig.Sites[0].AlertPolicy.owner.team, "expect the owner team assigned to the site alert policy")
+	assert(t, "streaming" == c.PulsarAdminConfig.Clusters[0].AlertPolicy.owner.service, "expect the service assigned to the cluster alert policy")
+
+	component := "owner-tag-component"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+		incidentOwnersLock.Lock()
+		delete(incidentOwners, component)
+		incidentOwnersLock.Unlock()
+	}()
+	policy := c.PulsarTopicConfig[0].AlertPolicy
+	policy.Ceiling = 1
+	assert(t, ReportIncident(component, component, "latency test failure", "desc", &policy), "expect an incident created")
+
+	incident := NewIncident(component, component, "latency test failure", "desc", "P2")
+	assert(t, util.StrContains(incident.Tags, "team:payments"), "expect the owner team tag but got %v", incident.Tags)
+	assert(t, util.StrContains(incident.Tags, "service:billing"), "expect the service tag but got %v", incident.Tags)
+	assert(t, 2 == len(NewIncident("no-owner-component", "alias", "msg", "desc", "P2").Tags), "expect no owner tag without an owner")
+
+	var event map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&event)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"success","dedup_key":"owner-tag-component"}`))
+	}))
+	defer server.Close()
+	eventURL := pagerDutyEventURL
+	pagerDutyEventURL = server.URL
+	defer func() {
+		pagerDutyEventURL = eventURL
+		incidentsLock.Lock()
+		delete(incidents, component)
+		incidentsLock.Unlock()
+	}()
+	errNil(t, CreatePDIncident(component, component, "latency test failure", "desc", "routing-key"))
+	payload, _ := event["payload"].(map[string]interface{})
+	details, _ := payload["custom_details"].(map[string]interface{})
+	assert(t, "payments" == details["ownerTeam"], "expect the owner team in the PagerDuty details but got %v", payload)
+	assert(t, "billing" == payload["group"], "expect the service as the PagerDuty group but got %v", payload)
+}
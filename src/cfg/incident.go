@@ -25,7 +25,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,11 +42,31 @@ import (
 // incident tracker and policy are NOT thread safe struct and map.
 
 type incidentRecord struct {
+	// requestID and alertID identify the OpsGenie alert
 	requestID string
 	alertID   string
+	// alias is the correlation id shared by the OpsGenie alias and the PagerDuty dedup key
+	alias     string
 	createdAt time.Time
 }
 
+// correlationAlias is the alias shared by every alert channel of the component incident, it is the component if not specified
+func correlationAlias(component, alias string) string {
+	return util.FirstNonEmptyString(alias, component)
+}
+
+// recordIncident updates the incident record of the component created by an alert channel
+func recordIncident(component string, update func(*incidentRecord)) {
+	incidentsLock.Lock()
+	defer incidentsLock.Unlock()
+	record, ok := incidents[component]
+	if !ok {
+		record.createdAt = time.Now()
+	}
+	update(&record)
+	incidents[component] = record
+}
+
 var (
 	// AllowedPriorities a list of allowed priorities
 	AllowedPriorities = []string{"P1", "P2", "P3", "P4", "P5"}
@@ -59,9 +83,16 @@ var (
 	incidentTrackersLock = &sync.RWMutex{}
 )
 
-const (
-	opsGenieAlertURL = "https://api.opsgenie.com/v2/alerts"
-)
+var opsGenieAlertURL = "https://api.opsgenie.com/v2/alerts"
+
+// processStartedAt is the beginning of the startup grace period
+var processStartedAt = time.Now()
+
+// inStartupGracePeriod returns whether incidents are suppressed during the startup grace period
+func inStartupGracePeriod(now time.Time) bool {
+	grace := time.Duration(GetConfig().StartupGracePeriodSeconds) * time.Second
+	return now.Sub(processStartedAt) < grace
+}
 
 // Incident is the struct for incident reporting
 type Incident struct {
@@ -116,6 +147,11 @@ type IncidentAlertPolicy struct {
 	LimitInWindow     int
 	Limit             int
 	LastUpdatedAt     time.Time
+	EscalationLadder  []EscalationStepCfg
+	// FailingSince is the first failure since the component was last cleared
+	FailingSince time.Time
+	// Priority is the priority of the paged incident, empty when no incident has been paged
+	Priority string
 }
 
 // return if alert is triggered
@@ -124,10 +160,14 @@ func (t *IncidentAlertPolicy) report(component, msg, desc string) bool {
 	t.Entity = component
 	t.Counters = t.Counters + 1
 	t.Alerts[time.Now()] = true
+	if t.FailingSince.IsZero() {
+		t.FailingSince = t.LastUpdatedAt
+	}
 	if t.Limit > 0 && t.Counters >= t.Limit {
 		// TODO: to be discussed if resetting to 0 is too relaxed
 		t.Counters = 0
 		t.Alerts = make(map[time.Time]bool)
+		t.page()
 		return true
 	}
 	if t.Limit > 0 && t.Counters+1 >= t.Limit {
@@ -148,16 +188,60 @@ func (t *IncidentAlertPolicy) report(component, msg, desc string) bool {
 	if t.LimitInWindow > 0 && windowCounts >= t.LimitInWindow {
 		t.Counters = 0
 		t.Alerts = make(map[time.Time]bool)
+		t.page()
 		return true
 	}
 	return false
 }
 
+// page records the priority of a paged incident, a re-paged incident keeps its escalated priority
+func (t *IncidentAlertPolicy) page() {
+	if t.Priority != "" {
+		return
+	}
+	if len(t.EscalationLadder) > 0 {
+		t.Priority = "P3"
+	} else {
+		t.Priority = "P2"
+	}
+}
+
+// escalate returns a higher priority when the paged incident has been failing beyond an escalation step
+func (t *IncidentAlertPolicy) escalate() (string, bool) {
+	if t.Priority == "" {
+		return "", false
+	}
+	failing := time.Since(t.FailingSince)
+	priority := t.Priority
+	for _, step := range t.EscalationLadder {
+		if failing >= time.Duration(step.AfterSeconds)*time.Second && priorityRank(step.Priority) < priorityRank(priority) {
+			priority = step.Priority
+		}
+	}
+	if priority == t.Priority {
+		return "", false
+	}
+	t.Priority = priority
+	return priority, true
+}
+
 func (t *IncidentAlertPolicy) clear() int {
 	t.Counters--
+	t.FailingSince = time.Time{}
+	t.Priority = ""
 	return t.Counters
 }
 
+// priorityRank returns the rank of priority, P1 is the highest with the lowest rank
+func priorityRank(priority string) int {
+	for i, p := range AllowedPriorities {
+		if p == priority {
+			return i
+		}
+	}
+	return len(AllowedPriorities)
+}
+
 func newPolicy(component, msg, desc string, eval *AlertPolicyCfg) IncidentAlertPolicy {
 	newTracker := IncidentAlertPolicy{}
 	newTracker.EvalWindowSeconds = util.TimeDuration(eval.MovingWindowSeconds, 1, time.Second)
@@ -165,6 +249,7 @@ func newPolicy(component, msg, desc string, eval *AlertPolicyCfg) IncidentAlertP
 	newTracker.LimitInWindow = eval.CeilingInMovingWindow
 	newTracker.Limit = eval.Ceiling
 	newTracker.LastUpdatedAt = time.Now()
+	newTracker.EscalationLadder = eval.EscalationLadder
 	return newTracker
 }
 
@@ -180,10 +265,58 @@ func trackIncident(component, msg, desc string, eval *AlertPolicyCfg) bool {
 	return rc
 }
 
+// pagedPriority returns the priority of the component's paged incident
+func pagedPriority(component string) string {
+	incidentTrackersLock.RLock()
+	defer incidentTrackersLock.RUnlock()
+	if tracker, ok := incidentTrackers[component]; ok && tracker.Priority != "" {
+		return tracker.Priority
+	}
+	return "P2"
+}
+
+// escalateIncident returns the escalated priority if the component's paged incident reaches the next escalation step
+func escalateIncident(component string) (string, time.Duration, bool) {
+	incidentTrackersLock.Lock()
+	defer incidentTrackersLock.Unlock()
+	if tracker, ok := incidentTrackers[component]; ok {
+		priority, escalated := tracker.escalate()
+		return priority, time.Since(tracker.FailingSince), escalated
+	}
+	return "", 0, false
+}
+
 // ReportIncident reports an incident return bool indicate an incident is created or not.
+// The message is rendered with the message template of the alert policy if it is specified.
 func ReportIncident(component, alias, msg, desc string, eval *AlertPolicyCfg) bool {
+	return reportIncidentWithContext(alertContext(component, alias, msg, desc, eval), alias, eval)
+}
+
+// reportIncident evaluates the alert policy with the rendered message and returns whether the incident is reported,
+// the on failure command output is added to the description of a created incident
+func reportIncident(ctx AlertContext, alias, msg string, eval *AlertPolicyCfg) bool {
+	component, desc := ctx.Component, ctx.Error
+	recordFailureReport(component, time.Now())
+	if inStartupGracePeriod(time.Now()) {
+		log.Warnf("%s incident is not reported within the startup grace period, %s: %s", component, msg, desc)
+		return false
+	}
+	defer recordIncidentMetrics()
+	recordIncidentOwner(component, eval.owner)
+	if eval.cluster != "" {
+		reportWidespreadFailure(eval.cluster, component, desc)
+	}
+	if eval.region != "" {
+		if created, degraded := reportRegionIncident(eval.region, component, desc, eval); degraded {
+			return created
+		}
+	}
 	if eval.Ceiling > 0 && trackIncident(component, msg, desc, eval) {
-		CreateIncident(component, alias, msg, desc, "P2")
+		CreateIncident(component, alias, msg, eval.failureExecDescription(ctx, alias), pagedPriority(component))
+		return true
+	}
+	if priority, failing, ok := escalateIncident(component); ok {
+		EscalateIncident(component, alias, msg, fmt.Sprintf("%s, failing for %v", desc, failing.Round(time.Second)), priority)
 		return true
 	}
 
@@ -201,7 +334,7 @@ func ReportIncident(component, alias, msg, desc string, eval *AlertPolicyCfg) bo
 	incidentTrackersLock.RUnlock()
 
 	if count > 2 {
-		CreateIncident(component, alias, msg, desc, "P2")
+		CreateIncident(component, alias, msg, eval.failureExecDescription(ctx, alias), "P2")
 		return true
 	}
 	return false
@@ -209,7 +342,17 @@ func ReportIncident(component, alias, msg, desc string, eval *AlertPolicyCfg) bo
 
 // ClearIncident clears an incident
 func ClearIncident(component string) {
+	defer recordIncidentMetrics()
 	RemoveIncident(component)
+	if region, recovered := clearRegionFailure(component); recovered {
+		RemoveIncident(regionComponent(region))
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, regionComponent(region))
+		incidentTrackersLock.Unlock()
+	}
+	if clearWidespreadFailure(component) {
+		RemoveIncident(widespreadFailureComponent)
+	}
 
 	incidentTrackersLock.Lock()
 	defer incidentTrackersLock.Unlock()
@@ -232,15 +375,22 @@ func NewIncident(component, alias, msg, desc, priority string) Incident {
 		Priority:    p,
 		Entity:      component,
 		Alias:       alias,
-		Tags:        []string{"ops-monitor", component},
+		Tags:        append([]string{"ops-monitor", component}, componentOwner(component).tags()...),
 		Timestamp:   time.Now(),
 	}
 }
 
 // CreateIncident creates incident
 func CreateIncident(component, alias, msg, desc, priority string) {
-	Alert(fmt.Sprintf("report incident as pager escalation, component %s, alias %s, message %s, description %s",
-		component, alias, msg, desc))
+	alias = correlationAlias(component, alias)
+	if !GetConfig().alertsEnabled() {
+		log.Errorf("alerts are disabled, incident is not reported, component %s, alias %s, message %s, description %s",
+			component, alias, msg, desc)
+		return
+	}
+	recordIncidentCreated(component, priority)
+	AlertWithSeverity(fmt.Sprintf("report incident as pager escalation, component %s, alias %s, message %s, description %s",
+		component, alias, msg, desc), GetConfig().SlackConfig.incidentSeverity(priority))
 	genieKey := GetConfig().OpsGenieConfig.AlertKey
 	if genieKey != "" {
 		err := CreateOpsGenieAlert(NewIncident(component, alias, msg, desc, priority), genieKey)
@@ -250,35 +400,65 @@ func CreateIncident(component, alias, msg, desc, priority string) {
 	}
 
 	if GetConfig().PagerDutyConfig.IntegrationKey != "" {
-		err := CreatePDIncident(component, alias, msg, GetConfig().PagerDutyConfig.IntegrationKey)
+		err := CreatePDIncident(component, alias, msg, desc, GetConfig().PagerDutyConfig.IntegrationKey)
 		if err != nil {
 			Alert(fmt.Sprintf("from %s PagerDuty report incident error %v", component, err))
 		}
 	}
 }
 
+// EscalateIncident updates the priority of an existing incident
+func EscalateIncident(component, alias, msg, desc, priority string) {
+	alias = correlationAlias(component, alias)
+	if !GetConfig().alertsEnabled() {
+		log.Errorf("alerts are disabled, incident is not escalated to %s, component %s, alias %s, message %s, description %s",
+			priority, component, alias, msg, desc)
+		return
+	}
+	AlertWithSeverity(fmt.Sprintf("escalate incident to %s, component %s, alias %s, message %s, description %s",
+		priority, component, alias, msg, desc), GetConfig().SlackConfig.incidentSeverity(priority))
+	genieKey := GetConfig().OpsGenieConfig.AlertKey
+	if genieKey != "" {
+		err := UpdateOpsGenieAlertPriority(alias, priority, genieKey)
+		if err != nil {
+			Alert(fmt.Sprintf("from %s Opsgenie escalate incident error %v", component, err))
+		}
+	}
+
+	if GetConfig().PagerDutyConfig.IntegrationKey != "" {
+		// trigger with the same dedup key updates the existing PagerDuty incident
+		err := CreatePDIncident(component, alias, fmt.Sprintf("%s escalated to %s", msg, priority), desc, GetConfig().PagerDutyConfig.IntegrationKey)
+		if err != nil {
+			Alert(fmt.Sprintf("from %s PagerDuty escalate incident error %v", component, err))
+		}
+	}
+}
+
 // RemoveIncident removes an existing incident
 func RemoveIncident(component string) {
 	incidentsLock.Lock()
 	record, ok := incidents[component]
 	delete(incidents, component)
 	incidentsLock.Unlock()
+	clearFailureExec(component)
+	if recordIncidentCleared(component) && GetConfig().SlackConfig.Attachments {
+		AlertWithSeverity(fmt.Sprintf("incident cleared, component %s", component), SeverityRecovery)
+	}
 
 	if ok {
-		if record.alertID == "" {
-			log.Errorf("%s unable to identify alert with request id %s for auto clear operation", component, record.requestID)
-			return
-		}
-		log.Infof("auto record alertID %v", record)
+		log.Infof("auto clear incident %s with alias %s, record %v", component, record.alias, record)
 		genieKey := GetConfig().OpsGenieConfig.AlertKey
-		if genieKey != "" {
-			err := CloseOpsGenieAlert(component, record.alertID, genieKey)
-			if err != nil {
+		if genieKey != "" && record.requestID != "" {
+			if record.alertID == "" {
+				log.Errorf("%s unable to identify alert with request id %s for auto clear operation", component, record.requestID)
+			} else if err := CloseOpsGenieAlert(component, record.alertID, genieKey); err != nil {
 				Alert(fmt.Sprintf("from %s Opsgenie remove incident error %v", component, err))
 			}
 		}
 
-		ResolvePDIncident(component, record.alertID, GetConfig().PagerDutyConfig.IntegrationKey)
+		if record.alias != "" {
+			ResolvePDIncident(component, record.alias, GetConfig().PagerDutyConfig.IntegrationKey)
+		}
 	}
 }
 
@@ -289,11 +469,12 @@ func opsGenieHTTP(method, endpoint, genieKey string, payload *bytes.Buffer) (*ht
 		return nil, fmt.Errorf(errStr)
 	}
 
-	client := retryablehttp.NewClient()
+	client := newRetryableClient()
 	client.HTTPClient.Timeout = time.Duration(5) * time.Second
 	client.RetryWaitMin = 4 * time.Second
 	client.RetryWaitMax = 64 * time.Second
 	client.RetryMax = 2
+	client.Backoff = rateLimitBackoff
 
 	log.Infof("method %v request URL %v", method, opsGenieAlertURL+endpoint)
 	req, err := retryablehttp.NewRequest(method, opsGenieAlertURL+endpoint, payload)
@@ -304,9 +485,44 @@ func opsGenieHTTP(method, endpoint, genieKey string, payload *bytes.Buffer) (*ht
 	req.Header.Set("Authorization", genieKey)
 	req.Header.Set("Content-Type", "application/json")
 
+	release := acquirePagerSlot()
+	defer release()
 	return client.Do(req)
 }
 
+// rateLimitBackoff honors the Retry-After header of a rate limited or unavailable response,
+// the wait is capped by the max wait so that a long Retry-After does not block alerting
+func rateLimitBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			if wait > max {
+				wait = max
+			}
+			log.Warnf("rate limited by %s with status code %d, retry after %v", resp.Request.URL.Host, resp.StatusCode, wait)
+			return wait
+		}
+		log.Warnf("rate limited by %s with status code %d", resp.Request.URL.Host, resp.StatusCode)
+	}
+	return retryablehttp.DefaultBackoff(min, max, attemptNum, nil)
+}
+
+// retryAfter parses the Retry-After header value in either seconds or http date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
 // CreateOpsGenieAlert creates an OpsGenie alert
 func CreateOpsGenieAlert(msg Incident, genieKey string) error {
 	buf, err := json.Marshal(msg)
@@ -321,6 +537,12 @@ func CreateOpsGenieAlert(msg Incident, genieKey string) error {
 	if err != nil {
 		return err
 	} else if resp.StatusCode > 300 {
+		body, _ := io.ReadAll(resp.Body)
+		if isDuplicateAlias(resp.StatusCode, body) {
+			// the alert of the same alias is still open during an alert storm, it is noted rather than reported as an error
+			log.Warnf("Opsgenie alert alias %s already exists, status code %d, %s", msg.Alias, resp.StatusCode, string(body))
+			return AddOpsGenieAlertNote(msg.Entity, msg.Alias, msg.Description, genieKey)
+		}
 		return fmt.Errorf("Create Opsgenie alert returns incorrect status code %d", resp.StatusCode)
 	}
 
@@ -330,18 +552,14 @@ func CreateOpsGenieAlert(msg Incident, genieKey string) error {
 		return err
 	}
 
-	incident := incidentRecord{
-		requestID: alertResp.RequestID,
-		createdAt: time.Now(),
-	}
-
-	incidentsLock.Lock()
-	defer incidentsLock.Unlock()
-	incidents[msg.Entity] = incident
+	log.Infof("Opsgenie alert of %s created with alias %s, request id %s", msg.Entity, msg.Alias, alertResp.RequestID)
+	recordIncident(msg.Entity, func(record *incidentRecord) {
+		record.requestID, record.alertID, record.alias = alertResp.RequestID, "", msg.Alias
+	})
 
 	// there is a delay when the alert is created by opsgenie, so we use retry
 	// time out has to be less than the latency time interval
-	go getOpsGenieAlertIDRetry(msg.Entity, incident.requestID, genieKey, 4*time.Second)
+	go getOpsGenieAlertIDRetry(msg.Entity, alertResp.RequestID, genieKey, 4*time.Second)
 	return nil
 }
 
@@ -391,6 +609,65 @@ func getOpsGenieAlertID(requestID, genieKey string) (alertID string, err error)
 	return alertResp.Data.AlertID, nil
 }
 
+// UpdateOpsGenieAlertPriority updates the priority of an OpsGenie alert identified by the alias
+// https://docs.opsgenie.com/docs/alert-api-continued#update-alert-priority
+func UpdateOpsGenieAlertPriority(alias, priority, genieKey string) error {
+	buf, err := json.Marshal(map[string]string{"priority": priority})
+	if err != nil {
+		return err
+	}
+
+	resp, err := opsGenieHTTP(http.MethodPut, fmt.Sprintf("/%s/priority?identifierType=alias", url.PathEscape(alias)), genieKey, bytes.NewBuffer(buf))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode > 300 {
+		return fmt.Errorf("Update Opsgenie alert priority returns incorrect status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// isDuplicateAlias returns whether the create alert response rejects the alias of an existing alert
+func isDuplicateAlias(statusCode int, body []byte) bool {
+	if statusCode == http.StatusConflict {
+		return true
+	}
+	if statusCode < 400 || statusCode >= 500 {
+		return false
+	}
+	message := strings.ToLower(string(body))
+	return strings.Contains(message, "alias") && (strings.Contains(message, "exist") || strings.Contains(message, "duplicate"))
+}
+
+// AddOpsGenieAlertNote adds a note to an existing OpsGenie alert identified by the alias
+func AddOpsGenieAlertNote(component, alias, note, genieKey string) error {
+	buf, err := json.Marshal(OpsGenieAlertCloseRequest{
+		User:   "pulsar monitor",
+		Source: component,
+		Note:   note,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := opsGenieHTTP(http.MethodPost, fmt.Sprintf("/%s/notes?identifierType=alias", url.PathEscape(alias)), genieKey, bytes.NewBuffer(buf))
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode > 300 {
+		return fmt.Errorf("Add Opsgenie alert note returns incorrect status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
 // CloseOpsGenieAlert deletes an OpsGenie alert
 func CloseOpsGenieAlert(component, alertID string, genieKey string) error {
 	buf, err := json.Marshal(OpsGenieAlertCloseRequest{
@@ -22,15 +22,22 @@
 package cfg
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	pd "github.com/PagerDuty/go-pagerduty"
 	"github.com/datastax/pulsar-heartbeat/src/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestUnmarshConfigFile(t *testing.T) {
@@ -41,6 +48,20 @@ func TestUnmarshConfigFile(t *testing.T) {
 
 }
 
+func TestConfigReloadMetrics(t *testing.T) {
+	ReadConfigFile("../../config/runtime-template.yml")
+	name := GetConfig().Name
+	reloadCounter := counters["pulsar-monitor-config_reload_total"].WithLabelValues(name)
+	loadedGauge := metrics[getMetricKey(ConfigLoadedGaugeOpt())].WithLabelValues(name)
+	reloads := testutil.ToFloat64(reloadCounter)
+
+	PromGauge(ConfigLoadedGaugeOpt(), name, 0)
+	ReadConfigFile("../../config/runtime-template.yml")
+	assert(t, testutil.ToFloat64(reloadCounter) == reloads+1, "expect the reload counter incremented")
+	loaded := testutil.ToFloat64(loadedGauge)
+	assert(t, time.Since(time.Unix(int64(loaded), 0)) < time.Minute, "expect the loaded timestamp updated but got %f", loaded)
+}
+
 func TestRandBytes(t *testing.T) {
 	p := Payload{
 		Ceiling: 8,
@@ -95,6 +116,26 @@ func TestGenMulitplDifferentPayloads(t *testing.T) {
 	}
 }
 
+func TestPayloadEncodings(t *testing.T) {
+	for _, encoding := range []string{"", "base64", "hex"} {
+		msgs, _ := AllEncodedMsgPayloads("messageid", []string{"100B"}, 3, encoding)
+		assert(t, 3 == len(msgs), "total messages")
+		for i := 0; i < len(msgs); i++ {
+			assert(t, len(msgs[i]) <= 100, "expect the %s message within the payload size but got %d", encoding, len(msgs[i]))
+			assert(t, i == GetMessageID("messageid", string(msgs[i])), "check message index")
+			errNil(t, verifyPayloadEncoding(string(msgs[i]), encoding))
+		}
+	}
+
+	assert(t, verifyPayloadEncoding("messageid-0-aGVhcnRiZWF0", "base64") == nil, "expect a valid base64 body")
+	assert(t, verifyPayloadEncoding("messageid-0-aGVhcnRiZWF0!", "base64") != nil, "expect an altered base64 body rejected")
+	assert(t, verifyPayloadEncoding("messageid-0-6865617274", "hex") == nil, "expect a valid hex body")
+	assert(t, verifyPayloadEncoding("messageid-0-6865617274", "base64") != nil, "expect a hex body rejected as base64")
+	assert(t, verifyPayloadEncoding("messageid-0-68656172744Z", "hex") != nil, "expect an altered hex body rejected")
+	assert(t, verifyPayloadEncoding("messageid-0-686561727A", "hex") != nil, "expect a non canonical hex body rejected")
+	assert(t, verifyPayloadEncoding("messageid-0-any bytes", "") == nil, "expect any raw body")
+}
+
 func TestGenSinglePayloads(t *testing.T) {
 
 	// with single payload size specified with 3 messages
@@ -136,6 +177,29 @@ func TestGenMultipleDefaultPayloadSize(t *testing.T) {
 	}
 }
 
+func TestGenPayloadDistribution(t *testing.T) {
+
+	// 80% small and 20% large messages
+	distribution := []PayloadWeightCfg{{SizeBytes: 100, Weight: 80}, {SizeBytes: 2048, Weight: 20}}
+	sizes := samplePayloadSizes(distribution, 2000, rand.New(rand.NewSource(1)))
+	msgs, _ := AllMsgPayloads("dist", sizes, 2000)
+	assert(t, 2000 == len(msgs), "total messages")
+	small := 0
+	for i := 0; i < len(msgs); i++ {
+		assert(t, 100 == len(msgs[i]) || 2048 == len(msgs[i]), "individual message size %d", len(msgs[i]))
+		if len(msgs[i]) == 100 {
+			small++
+		}
+	}
+	ratio := float64(small) / float64(len(msgs))
+	assert(t, ratio > 0.75 && ratio < 0.85, "expect roughly 80%% small messages but got %f", ratio)
+
+	// zero weights are ignored
+	sizes = samplePayloadSizes([]PayloadWeightCfg{{SizeBytes: 10, Weight: 0}, {SizeBytes: 20, Weight: 1}}, 0, rand.New(rand.NewSource(1)))
+	assert(t, len(sizes) == 1 && sizes[0] == "20", "expect only one sampled 20 bytes size %v", sizes)
+	assert(t, len(SamplePayloadSizes([]PayloadWeightCfg{{SizeBytes: 10}}, 10)) == 0, "expect no sizes without weight")
+}
+
 func TestIncidentAlertPolicy(t *testing.T) {
 
 	assert(t, util.StrContains([]string{"test", "foo"}, "foo"), "fail to eval container string")
@@ -221,3 +285,256 @@ func errNil(tb testing.TB, err error) {
 		tb.FailNow()
 	}
 }
+
+func TestIncidentEscalationLadder(t *testing.T) {
+	policy := AlertPolicyCfg{
+		Ceiling: 2,
+		EscalationLadder: []EscalationStepCfg{
+			{Priority: "P2", AfterSeconds: 60},
+			{Priority: "P1", AfterSeconds: 120},
+		},
+	}
+	component := "escalation-component"
+	failingFor := func(d time.Duration) {
+		incidentTrackersLock.Lock()
+		incidentTrackers[component].FailingSince = time.Now().Add(-d)
+		incidentTrackersLock.Unlock()
+	}
+	_, _, escalated := escalateIncident(component)
+	assert(t, !escalated, "expect no escalation without a tracked incident")
+
+	assert(t, !trackIncident(component, "time out message", "save me description", &policy), "")
+	_, _, escalated = escalateIncident(component)
+	assert(t, !escalated, "expect no escalation before an incident is paged")
+	assert(t, trackIncident(component, "time out message", "save me description", &policy), "")
+	assert(t, "P3" == pagedPriority(component), "expect the incident paged at P3 but got %s", pagedPriority(component))
+
+	_, _, escalated = escalateIncident(component)
+	assert(t, !escalated, "expect no escalation before the first step")
+
+	failingFor(61 * time.Second)
+	priority, failing, escalated := escalateIncident(component)
+	assert(t, escalated && "P2" == priority, "expect escalation to P2 but got %s", priority)
+	assert(t, failing >= 61*time.Second, "expect failing duration over 61s but got %v", failing)
+	_, _, escalated = escalateIncident(component)
+	assert(t, !escalated, "expect a single escalation per step")
+
+	// a re-paged incident keeps the escalated priority
+	assert(t, !trackIncident(component, "time out message", "save me description", &policy), "")
+	assert(t, trackIncident(component, "time out message", "save me description", &policy), "")
+	assert(t, "P2" == pagedPriority(component), "expect the re-paged incident at P2 but got %s", pagedPriority(component))
+
+	failingFor(121 * time.Second)
+	priority, _, escalated = escalateIncident(component)
+	assert(t, escalated && "P1" == priority, "expect escalation to P1 but got %s", priority)
+	_, _, escalated = escalateIncident(component)
+	assert(t, !escalated, "expect no escalation beyond P1")
+
+	// clear resets the ladder
+	trackIncident(component, "time out message", "save me description", &policy)
+	ClearIncident(component)
+	incidentTrackersLock.RLock()
+	tracker, ok := incidentTrackers[component]
+	incidentTrackersLock.RUnlock()
+	assert(t, !ok || ("" == tracker.Priority && tracker.FailingSince.IsZero()), "expect clear to reset the escalation")
+
+	incidentTrackersLock.Lock()
+	delete(incidentTrackers, component)
+	incidentTrackersLock.Unlock()
+}
+
+func TestIncidentDefaultPriority(t *testing.T) {
+	policy := AlertPolicyCfg{Ceiling: 1}
+	component := "default-priority-component"
+	assert(t, trackIncident(component, "time out message", "save me description", &policy), "")
+	assert(t, "P2" == pagedPriority(component), "expect the incident paged at P2 but got %s", pagedPriority(component))
+	_, _, escalated := escalateIncident(component)
+	assert(t, !escalated, "expect no escalation without a ladder")
+
+	incidentTrackersLock.Lock()
+	delete(incidentTrackers, component)
+	incidentTrackersLock.Unlock()
+}
+
+// withoutAlertDestinations keeps incident tests off the OpsGenie, PagerDuty and Slack APIs
+// configured by the config file loaded in earlier tests.
+func withoutAlertDestinations() func() {
+	saved := Config
+	Config.OpsGenieConfig.AlertKey = ""
+	Config.PagerDutyConfig.IntegrationKey = ""
+	Config.SlackConfig.AlertURL = ""
+	return func() { Config = saved }
+}
+
+func TestStartupGracePeriod(t *testing.T) {
+	defer withoutAlertDestinations()()
+	policy := AlertPolicyCfg{Ceiling: 1}
+	component := "grace-period-component"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+	}()
+
+	Config.StartupGracePeriodSeconds = 3600
+	assert(t, inStartupGracePeriod(time.Now()), "expect within the startup grace period")
+	assert(t, !ReportIncident(component, component, "time out message", "save me description", &policy), "expect no incident within the startup grace period")
+	incidentTrackersLock.RLock()
+	_, tracked := incidentTrackers[component]
+	incidentTrackersLock.RUnlock()
+	assert(t, !tracked, "expect the failure not tracked within the startup grace period")
+	assert(t, !inStartupGracePeriod(processStartedAt.Add(time.Hour)), "expect the grace period ends")
+
+	Config.StartupGracePeriodSeconds = 0
+	assert(t, ReportIncident(component, component, "time out message", "save me description", &policy), "expect an incident without the startup grace period")
+}
+
+func TestOpsGenieDuplicateAlias(t *testing.T) {
+	notes := []string{}
+	status, body := http.StatusConflict, `{"message":"Alert with the alias already exists"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/duplicate-alias/notes" {
+			assert(t, r.URL.Query().Get("identifierType") == "alias", "expect the alert identified by the alias")
+			req := OpsGenieAlertCloseRequest{}
+			errNil(t, json.NewDecoder(r.Body).Decode(&req))
+			notes = append(notes, req.Note)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	alertURL := opsGenieAlertURL
+	opsGenieAlertURL = server.URL
+	defer func() { opsGenieAlertURL = alertURL }()
+
+	incident := NewIncident("duplicate-component", "duplicate-alias", "latency test failure", "still failing", "P2")
+	errNil(t, CreateOpsGenieAlert(incident, "genie-key"))
+	assert(t, len(notes) == 1 && notes[0] == "still failing", "expect the description noted on the existing alert but got %v", notes)
+
+	status, body = http.StatusUnprocessableEntity, `{"message":"Alias already exists"}`
+	errNil(t, CreateOpsGenieAlert(incident, "genie-key"))
+	assert(t, len(notes) == 2, "expect the alias exists response noted")
+
+	status, body = http.StatusBadRequest, `{"message":"Request body is not processable"}`
+	assert(t, CreateOpsGenieAlert(incident, "genie-key") != nil, "expect a genuine error")
+	assert(t, len(notes) == 2, "expect no note on a genuine error")
+
+	assert(t, !isDuplicateAlias(http.StatusUnauthorized, []byte(`{"message":"Key format is not valid"}`)), "expect an auth error not a duplicate")
+}
+
+func TestAlertsDisabled(t *testing.T) {
+	defer withoutAlertDestinations()()
+	component := "alerts-disabled-component"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+		recordIncidentMetrics()
+	}()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	alertURL, eventURL := opsGenieAlertURL, pagerDutyEventURL
+	opsGenieAlertURL, pagerDutyEventURL = server.URL, server.URL
+	defer func() { opsGenieAlertURL, pagerDutyEventURL = alertURL, eventURL }()
+	Config.SlackConfig.AlertURL = server.URL
+	Config.OpsGenieConfig.AlertKey = "genie-key"
+	Config.PagerDutyConfig.IntegrationKey = "integration-key"
+	disabled := false
+	Config.AlertsEnabled = &disabled
+
+	recordIncidentMetrics()
+	trackers := func() float64 {
+		return testutil.ToFloat64(metrics[getMetricKey(IncidentTrackersGaugeOpt())].WithLabelValues(GetConfig().Name))
+	}
+	tracked := trackers()
+	assert(t, ReportIncident(component, component, "latency test failure", "desc", &AlertPolicyCfg{Ceiling: 1}), "expect the incident evaluated")
+	EscalateIncident(component, component, "latency test failure", "desc", "P1")
+	Alert("alerts disabled test")
+	assert(t, requests == 0, "expect no sender invoked with alerts disabled but got %d requests", requests)
+	assert(t, trackers() == tracked+1, "expect the incident metrics updated with alerts disabled")
+
+	Config.AlertsEnabled = nil
+	Alert("alerts enabled test")
+	assert(t, requests == 1, "expect the Slack alert sent by default but got %d requests", requests)
+}
+
+func TestIncidentAliasAcrossChannels(t *testing.T) {
+	defer withoutAlertDestinations()()
+	component := "correlated-component"
+	var mu sync.Mutex
+	genieAliases, closedAlerts, pdEvents := []string{}, []string{}, []pd.V2Event{}
+	genie := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/":
+			incident := Incident{}
+			errNil(t, json.NewDecoder(r.Body).Decode(&incident))
+			genieAliases = append(genieAliases, incident.Alias)
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"result":"Request will be processed","requestId":"correlated-request"}`))
+		case r.URL.Path == "/requests/correlated-request":
+			w.Write([]byte(`{"data":{"success":true,"alertId":"correlated-alert"}}`))
+		case r.URL.Path == "/correlated-alert/close":
+			closedAlerts = append(closedAlerts, r.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer genie.Close()
+	pagerDuty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event := pd.V2Event{}
+		errNil(t, json.NewDecoder(r.Body).Decode(&event))
+		mu.Lock()
+		pdEvents = append(pdEvents, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"success","dedup_key":"` + event.DedupKey + `"}`))
+	}))
+	defer pagerDuty.Close()
+	alertURL, eventURL := opsGenieAlertURL, pagerDutyEventURL
+	opsGenieAlertURL, pagerDutyEventURL = genie.URL, pagerDuty.URL
+	defer func() { opsGenieAlertURL, pagerDutyEventURL = alertURL, eventURL }()
+	Config.OpsGenieConfig.AlertKey = "genie-key"
+	Config.PagerDutyConfig.IntegrationKey = "integration-key"
+
+	// the component is the alias shared by both channels if it is not specified
+	CreateIncident(component, "", "latency test failure", "desc", "P2")
+	mu.Lock()
+	assert(t, len(genieAliases) == 1 && genieAliases[0] == component, "expect the component as the Opsgenie alias but got %v", genieAliases)
+	assert(t, len(pdEvents) == 1 && pdEvents[0].DedupKey == component, "expect the component as the PagerDuty dedup key but got %v", pdEvents)
+	mu.Unlock()
+
+	// wait for the Opsgenie alert id kept in the record along with the alias
+	deadline := time.Now().Add(3 * time.Second)
+	record := incidentRecord{}
+	for time.Now().Before(deadline) {
+		incidentsLock.RLock()
+		record = incidents[component]
+		incidentsLock.RUnlock()
+		if record.alertID != "" {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	assert(t, record.alertID == "correlated-alert", "expect the Opsgenie alert id kept after the PagerDuty incident but got %v", record)
+	assert(t, record.alias == component, "expect the correlation alias recorded but got %s", record.alias)
+
+	EscalateIncident(component, "", "latency test failure", "desc", "P1")
+	RemoveIncident(component)
+	mu.Lock()
+	defer mu.Unlock()
+	assert(t, len(closedAlerts) == 1, "expect the Opsgenie alert closed but got %v", closedAlerts)
+	assert(t, len(pdEvents) == 3, "expect the escalation and the resolve PagerDuty events but got %d", len(pdEvents))
+	for _, event := range pdEvents {
+		assert(t, event.DedupKey == component, "expect the same dedup key on every event but got %s", event.DedupKey)
+	}
+	assert(t, pdEvents[2].Action == resolve, "expect the PagerDuty incident resolved but got %s", pdEvents[2].Action)
+}
@@ -0,0 +1,189 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+)
+
+// monitor pub sub latency over the Kafka protocol handler (KoP)
+
+// kafkaClient is the minimum Kafka producer and consumer capability required by the KoP latency probe
+type kafkaClient interface {
+	// Produce sends a single message to the topic
+	Produce(ctx context.Context, topic string, value []byte) error
+	// Consume returns the next message received on the topic
+	Consume(ctx context.Context, topic string) ([]byte, error)
+	Close() error
+}
+
+// newKafkaClient creates a Kafka client for the KoP probe, it is replaced in tests
+var newKafkaClient = func(cfg KafkaCfg, tokenSupplier func() (string, error)) (kafkaClient, error) {
+	password := ""
+	if tokenSupplier != nil {
+		// KoP authenticates the token as the SASL PLAIN password with the token: prefix
+		token, err := tokenSupplier()
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			password = "token:" + token
+		}
+	}
+
+	var tlsConfig *tls.Config
+	if cfg.UseTLS {
+		tlsConfig = &tls.Config{}
+		if trustStore := util.FirstNonEmptyString(cfg.TrustStore, GetConfig().TrustStore); trustStore != "" {
+			var err error
+			if tlsConfig, err = trustStoreTLSConfig(trustStore); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.BootstrapServers...),
+		kgo.ClientID("pulsar-heartbeat"),
+		kgo.ConsumeTopics(cfg.TopicName),
+		// start after the creation time so a probe message produced before the partition offsets are listed is still consumed
+		kgo.ConsumeResetOffset(kgo.NewOffset().AfterMilli(time.Now().UnixMilli())),
+	}
+	if password != "" {
+		opts = append(opts, kgo.SASL(plain.Auth{
+			User: util.FirstNonEmptyString(cfg.Username, "public/default"),
+			Pass: password,
+		}.AsMechanism()))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &franzKafkaClient{client: client}, nil
+}
+
+// franzKafkaClient implements kafkaClient with the franz-go client
+type franzKafkaClient struct {
+	client  *kgo.Client
+	records []*kgo.Record
+}
+
+// Produce sends a single message to the topic and waits for the broker acknowledgement
+func (c *franzKafkaClient) Produce(ctx context.Context, topic string, value []byte) error {
+	return c.client.ProduceSync(ctx, &kgo.Record{Topic: topic, Value: value}).FirstErr()
+}
+
+// Consume returns the next message received on the topic, it polls the brokers once the fetched records are drained
+func (c *franzKafkaClient) Consume(ctx context.Context, topic string) ([]byte, error) {
+	for {
+		for len(c.records) > 0 {
+			record := c.records[0]
+			c.records = c.records[1:]
+			if record.Topic == topic {
+				return record.Value, nil
+			}
+		}
+
+		fetches := c.client.PollFetches(ctx)
+		if err := fetches.Err(); err != nil {
+			return nil, err
+		}
+		c.records = fetches.Records()
+	}
+}
+
+// Close closes the client connections
+func (c *franzKafkaClient) Close() error {
+	c.client.Close()
+	return nil
+}
+
+// KafkaLatencyTest produces a message over the Kafka protocol and measures the latency until it is consumed
+func KafkaLatencyTest(client kafkaClient, topic string, timeout time.Duration) (MsgResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	messageText := fmt.Sprintf("test kop latency %s", time.Now())
+	sentTime := time.Now()
+	if err := client.Produce(ctx, topic, []byte(messageText)); err != nil {
+		return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to produce to kafka topic %s: %w", topic, err)
+	}
+
+	for {
+		msg, err := client.Consume(ctx, topic)
+		if err != nil {
+			return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to consume from kafka topic %s: %w", topic, err)
+		}
+		if string(msg) == messageText {
+			return MsgResult{Latency: time.Since(sentTime), InOrderDelivery: true}, nil
+		}
+	}
+}
+
+// TestKafkaLatency tests Kafka protocol pub sub latency and reports the result
+func TestKafkaLatency(config KafkaCfg) {
+	tokenSupplier := util.TokenSupplierWithOverride(config.Token, GetConfig().TokenSupplier())
+	client, err := newKafkaClient(config, tokenSupplier)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s kop latency test failed to create kafka client: %v", config.Name, err)
+		log.Errorf(errMsg)
+		ReportIncident(config.Name, config.Name, kopSubsystem+" persisted latency test failure", errMsg, &config.AlertPolicy)
+		return
+	}
+	defer client.Close()
+
+	result, err := KafkaLatencyTest(client, config.TopicName, util.TimeDuration(config.IntervalSeconds/2, 30, time.Second))
+	evalProtocolLatency(config.Name, kopSubsystem, config.LatencyBudgetMs, &config.AlertPolicy, result, err)
+}
+
+// KafkaLatencyTestThread tests message delivery over the Kafka protocol and measures the latency
+func KafkaLatencyTestThread() {
+	runProbes(kafkaProbes())
+}
+
+// kafkaProbes are the Kafka protocol latency tests
+func kafkaProbes() []probe {
+	probes := []probe{}
+	for _, cfg := range GetConfig().KafkaConfig {
+		log.Infof("monitor kop latency on %v topic %s", cfg.BootstrapServers, cfg.TopicName)
+		probes = append(probes, probe{
+			name:     cfg.Name,
+			interval: IntervalDuration(cfg.IntervalSeconds, 60),
+			run: func(c KafkaCfg) monitorFunc {
+				return func() { TestKafkaLatency(c) }
+			}(cfg),
+		})
+	}
+	return probes
+}
@@ -0,0 +1,109 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeKafkaClient struct {
+	produced [][]byte
+	backlog  [][]byte
+	err      error
+}
+
+func (c *fakeKafkaClient) Produce(ctx context.Context, topic string, value []byte) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.produced = append(c.produced, value)
+	c.backlog = append(c.backlog, value)
+	return nil
+}
+
+func (c *fakeKafkaClient) Consume(ctx context.Context, topic string) ([]byte, error) {
+	if len(c.backlog) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	msg := c.backlog[0]
+	c.backlog = c.backlog[1:]
+	return msg, nil
+}
+
+func (c *fakeKafkaClient) Close() error {
+	return nil
+}
+
+// timeoutKafkaClient drops every produced message
+type timeoutKafkaClient struct {
+	*fakeKafkaClient
+}
+
+func (c *timeoutKafkaClient) Produce(ctx context.Context, topic string, value []byte) error {
+	return nil
+}
+
+func TestKafkaLatencyProbe(t *testing.T) {
+	// a stale message sits ahead of the probe message
+	client := &fakeKafkaClient{backlog: [][]byte{[]byte("stale message")}}
+	result, err := KafkaLatencyTest(client, "kop-topic", time.Second)
+	errNil(t, err)
+	assert(t, len(client.produced) == 1, "expect a single produced message")
+	assert(t, result.Latency < time.Second, "expect the latency of the probe message")
+
+	client = &fakeKafkaClient{err: errors.New("SASL authentication failed")}
+	result, err = KafkaLatencyTest(client, "kop-topic", time.Second)
+	assert(t, err != nil, "expect produce error")
+	assert(t, result.Latency == failedLatency, "expect failed latency")
+
+	// the probe message is never consumed
+	client = &fakeKafkaClient{}
+	client.backlog = nil
+	_, err = KafkaLatencyTest(&timeoutKafkaClient{client}, "kop-topic", 10*time.Millisecond)
+	assert(t, errors.Is(err, context.DeadlineExceeded), "expect consume timeout")
+}
+
+func TestNewKafkaClient(t *testing.T) {
+	_, err := newKafkaClient(KafkaCfg{BootstrapServers: []string{"127.0.0.1:1"}, TopicName: "kop-topic"}, func() (string, error) {
+		return "", errors.New("token supplier failure")
+	})
+	assert(t, err != nil, "expect the token supplier error")
+
+	client, err := newKafkaClient(KafkaCfg{BootstrapServers: []string{"127.0.0.1:1"}, TopicName: "kop-topic"}, func() (string, error) {
+		return "jwt", nil
+	})
+	errNil(t, err)
+	defer client.Close()
+	_, ok := client.(*franzKafkaClient)
+	assert(t, ok, "expect the franz-go client")
+
+	// the broker is unreachable
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert(t, client.Produce(ctx, "kop-topic", []byte("message")) != nil, "expect produce error")
+	_, err = client.Consume(ctx, "kop-topic")
+	assert(t, err != nil, "expect consume error")
+}
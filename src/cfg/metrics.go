@@ -26,34 +26,81 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/datastax/pulsar-heartbeat/src/metering"
 	"github.com/datastax/pulsar-heartbeat/src/util"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
-	metrics   = make(map[string]*prometheus.GaugeVec)
-	summaries = make(map[string]*prometheus.SummaryVec)
-	counters  = make(map[string]*prometheus.CounterVec)
+	metrics    = make(map[string]*prometheus.GaugeVec)
+	summaries  = make(map[string]*prometheus.SummaryVec)
+	histograms = make(map[string]*prometheus.HistogramVec)
+	counters   = make(map[string]*prometheus.CounterVec)
+	// metricsLock guards the metrics, summaries, histograms and counters maps
+	metricsLock = &sync.Mutex{}
+
+	adminRequestLatency  *prometheus.GaugeVec
+	adminRequestRegister sync.Once
+
+	monitorComponents         *prometheus.GaugeVec
+	monitorComponentsRegister sync.Once
+
+	pubSubErrorClass         *prometheus.GaugeVec
+	pubSubErrorClassRegister sync.Once
+
+	clusterInfo         *prometheus.GaugeVec
+	clusterInfoRegister sync.Once
+
+	subscriptionConsumerCount         *prometheus.GaugeVec
+	subscriptionConsumerCountRegister sync.Once
+
+	directBrokerLatency         *prometheus.GaugeVec
+	directBrokerLatencyRegister sync.Once
+
+	topicMsgRateIn         *prometheus.GaugeVec
+	topicMsgRateInRegister sync.Once
+
+	siteResponseBytes         *prometheus.GaugeVec
+	siteResponseBytesRegister sync.Once
+
+	partitionCount         *prometheus.GaugeVec
+	partitionCountRegister sync.Once
+
+	dlqBacklog         *prometheus.GaugeVec
+	dlqBacklogRegister sync.Once
+
+	planeHealth         *prometheus.GaugeVec
+	planeHealthRegister sync.Once
+
+	incidentTrackerCounter         *prometheus.GaugeVec
+	incidentTrackerWindowAlerts    *prometheus.GaugeVec
+	incidentTrackerMetricsRegister sync.Once
 )
 
 const (
-	funcTopicSubsystem     = "func_topic"
-	pubSubSubsystem        = "pubsub"
-	websocketSubsystem     = "websocket"
-	heartbeatSubsystem     = "heartbeat"
-	downtimeSubsystem      = "downtime"
-	k8sBrokerSubsystem     = "k8s_broker"
-	k8sBookkeeperSubsystem = "k8s_bookkeeper"
-	k8sZookeeperSubsystem  = "k8s_zookeeper"
-	k8sProxySubsystem      = "k8s_proxy"
-	k8sUndefinedSubsystem  = "k8s_undefined"
+	funcTopicSubsystem         = "func_topic"
+	pubSubSubsystem            = "pubsub"
+	websocketSubsystem         = "websocket"
+	kopSubsystem               = "kop"
+	mopSubsystem               = "mop"
+	heartbeatSubsystem         = "heartbeat"
+	downtimeSubsystem          = "downtime"
+	k8sBrokerSubsystem         = "k8s_broker"
+	k8sBookkeeperSubsystem     = "k8s_bookkeeper"
+	k8sZookeeperSubsystem      = "k8s_zookeeper"
+	k8sProxySubsystem          = "k8s_proxy"
+	k8sFunctionWorkerSubsystem = "k8s_function_worker"
+	k8sUndefinedSubsystem      = "k8s_undefined"
 )
 
 // This is Premetheus data modelling and naming convention
@@ -81,6 +128,56 @@ func OfflinePodGaugeOpt(subsystem, desc string) prometheus.GaugeOpts {
 	}
 }
 
+// PubSubLatencyEMAGaugeOpt is the exponential moving average of the pub sub latency
+func PubSubLatencyEMAGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: pubSubSubsystem,
+		Name:      "latency_ema_ms",
+		Help:      "Pulsar pub sub message latency exponential moving average in ms",
+	}
+}
+
+// ClusterAvailabilityGaugeOpt is the ratio of successful tests over the latest tests of a cluster
+func ClusterAvailabilityGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "cluster",
+		Name:      "availability_ratio",
+		Help:      "Pulsar cluster availability ratio over the latest tests",
+	}
+}
+
+// FailedBrokersRatioGaugeOpt is the ratio of failed brokers to the total brokers
+func FailedBrokersRatioGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "brokers",
+		Name:      "failed_ratio",
+		Help:      "Pulsar failed brokers to the total brokers ratio",
+	}
+}
+
+// AdminRequestGaugeOpt is the description for Pulsar admin REST API request latency
+func AdminRequestGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "admin",
+		Name:      "request_ms",
+		Help:      "Pulsar admin REST API request latency in ms",
+	}
+}
+
+// UnderReplicatedLedgersGaugeOpt is the number of under replicated ledgers reported by bookkeeper autorecovery
+func UnderReplicatedLedgersGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: k8sBookkeeperSubsystem,
+		Name:      "underreplicated_ledgers",
+		Help:      "Pulsar bookkeeper under replicated ledgers",
+	}
+}
+
 // SiteLatencyGaugeOpt is the description for hosting site latency gauge
 func SiteLatencyGaugeOpt() prometheus.GaugeOpts {
 	return prometheus.GaugeOpts{
@@ -91,6 +188,16 @@ func SiteLatencyGaugeOpt() prometheus.GaugeOpts {
 	}
 }
 
+// SiteResponseBytesGaugeOpt is the website endpoint response body size
+func SiteResponseBytesGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "website",
+		Subsystem: "webendpoint",
+		Name:      "response_bytes",
+		Help:      "website endpoint response body size in bytes",
+	}
+}
+
 // MsgLatencyGaugeOpt is the description for Pulsar message latency gauge
 func MsgLatencyGaugeOpt(typeName, desc string) prometheus.GaugeOpts {
 	return prometheus.GaugeOpts{
@@ -121,6 +228,283 @@ func PubSubDowntimeGaugeOpt() prometheus.GaugeOpts {
 	}
 }
 
+// DowntimeBudgetRemainingGaugeOpt is the downtime budget left in the rolling window
+func DowntimeBudgetRemainingGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "downtime",
+		Name:      "budget_remaining_seconds",
+		Help:      "Pulsar downtime budget remaining in seconds in the rolling window, negative if the budget is exhausted",
+	}
+}
+
+// ConfigReloadCounterOpt is the number of times the configuration file is loaded
+func ConfigReloadCounterOpt() prometheus.CounterOpts {
+	return prometheus.CounterOpts{
+		Namespace: "pulsar",
+		Subsystem: "monitor",
+		Name:      "config_reload_total",
+		Help:      "Pulsar heartbeat configuration file load counter",
+	}
+}
+
+// ConfigLoadedGaugeOpt is the unix timestamp when the configuration file is last loaded
+func ConfigLoadedGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "monitor",
+		Name:      "config_loaded_timestamp",
+		Help:      "Pulsar heartbeat configuration file last loaded unix timestamp in seconds",
+	}
+}
+
+// ComponentsGaugeOpt is the number of monitored components by kind
+func ComponentsGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "monitor",
+		Name:      "components",
+		Help:      "Pulsar heartbeat number of monitored components by kind",
+	}
+}
+
+// FailoverTimeGaugeOpt is the time to the first success on the secondary proxy in the failover drill
+func FailoverTimeGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Name:      "failover_time_ms",
+		Help:      "Pulsar time in ms to the first successful pub sub after the primary proxy is marked down",
+	}
+}
+
+// OpenIncidentsGaugeOpt is the number of open incidents
+func OpenIncidentsGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "monitor",
+		Name:      "open_incidents",
+		Help:      "Pulsar heartbeat number of open incidents",
+	}
+}
+
+// IncidentTrackersGaugeOpt is the number of incident trackers
+func IncidentTrackersGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "monitor",
+		Name:      "incident_trackers",
+		Help:      "Pulsar heartbeat number of components tracked by the incident alert policy",
+	}
+}
+
+// IncidentTrackerCounterGaugeOpt is the continuous failure counter of a tracked component
+func IncidentTrackerCounterGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "monitor",
+		Name:      "incident_tracker_counter",
+		Help:      "Pulsar heartbeat continuous failure counter of a component tracked by the incident alert policy",
+	}
+}
+
+// IncidentTrackerWindowAlertsGaugeOpt is the number of failures in the moving window of a tracked component
+func IncidentTrackerWindowAlertsGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "monitor",
+		Name:      "incident_tracker_window_alerts",
+		Help:      "Pulsar heartbeat number of failures in the moving window of a component tracked by the incident alert policy",
+	}
+}
+
+// ClusterInfoGaugeOpt is the Pulsar cluster metadata as labels
+func ClusterInfoGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "cluster",
+		Name:      "info",
+		Help:      "Pulsar cluster name and broker version reported by the admin REST API, the value is always 1",
+	}
+}
+
+// SubscriptionConsumersGaugeOpt is the number of consumers connected to a subscription
+func SubscriptionConsumersGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "subscription",
+		Name:      "consumer_count",
+		Help:      "Pulsar number of consumers connected to the subscription",
+	}
+}
+
+// DirectBrokerLatencyGaugeOpt is the pub sub latency on a broker bypassing the proxy
+func DirectBrokerLatencyGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "broker",
+		Name:      "direct_latency_ms",
+		Help:      "Pulsar message latency in ms on a broker service url bypassing the proxy",
+	}
+}
+
+// TransactionCommitLatencyGaugeOpt is the time to commit the transactions of a transactional latency test
+func TransactionCommitLatencyGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "transaction",
+		Name:      "commit_latency_ms",
+		Help:      "Pulsar transaction commit latency in ms of the produce and ack transactions of the latency test",
+	}
+}
+
+// TopicMsgRateInGaugeOpt is the publish rate of a topic
+func TopicMsgRateInGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "topic",
+		Name:      "msg_rate_in",
+		Help:      "Pulsar topic publish rate in messages per second reported by the topic stats",
+	}
+}
+
+// BrokerVersionSkewGaugeOpt is the number of distinct versions reported by the brokers of a cluster
+func BrokerVersionSkewGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "broker",
+		Name:      "version_skew",
+		Help:      "Pulsar number of distinct broker versions in the cluster",
+	}
+}
+
+// DLQBacklogGaugeOpt is the backlog of a dead letter topic
+func DLQBacklogGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "dlq",
+		Name:      "backlog",
+		Help:      "Pulsar dead letter topic backlog in number of messages",
+	}
+}
+
+// BrokerPublishLatencyGaugeOpt is the broker reported publish latency of the latency test topic
+func BrokerPublishLatencyGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "broker",
+		Name:      "publish_latency_ms",
+		Help:      "Pulsar broker reported publish latency of the latency test topic in milliseconds",
+	}
+}
+
+// BrokerStorageWriteLatencyGaugeOpt is the broker reported storage write latency of the latency test topic
+func BrokerStorageWriteLatencyGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "broker",
+		Name:      "storage_write_latency_ms",
+		Help:      "Pulsar broker reported storage write latency of the latency test topic in milliseconds",
+	}
+}
+
+// PlaneHealthGaugeOpt is the health of the binary protocol and the HTTP plane of a cluster
+func PlaneHealthGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "plane",
+		Name:      "up",
+		Help:      "Pulsar binary protocol or HTTP plane is up 1 or down 0",
+	}
+}
+
+// ClusterHealthScoreGaugeOpt is the description of the composite cluster health score
+func ClusterHealthScoreGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: "cluster",
+		Name:      "health_score",
+		Help:      "Pulsar cluster health score from 0 to 100, weighted over the latency budget adherence, broker, k8s pod health and admin reachability",
+	}
+}
+
+// PartitionCountGaugeOpt is the actual and the expected number of partitions of a partitioned topic
+func PartitionCountGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Name:      "partition_count",
+		Help:      "the actual and the configured number of partitions of a partitioned topic",
+	}
+}
+
+// MissingTopicsGaugeOpt is the number of required topics missing on a cluster
+func MissingTopicsGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Name:      "missing_topics",
+		Help:      "Pulsar number of required topics missing on the cluster",
+	}
+}
+
+// PubSubErrorClassGaugeOpt is the class of the last pub sub test error
+func PubSubErrorClassGaugeOpt() prometheus.GaugeOpts {
+	return prometheus.GaugeOpts{
+		Namespace: "pulsar",
+		Subsystem: pubSubSubsystem,
+		Name:      "error_class",
+		Help:      "Pulsar pub sub test error class of the last test, 1 for the class of the failure",
+	}
+}
+
+// PubSubFailedAttemptCounterOpt is the description for failed pub sub probe attempts
+func PubSubFailedAttemptCounterOpt() prometheus.CounterOpts {
+	return prometheus.CounterOpts{
+		Namespace: "pulsar",
+		Subsystem: "pubsub",
+		Name:      "failed_attempt_counter",
+		Help:      "Pulsar pubsub failed probe attempts including retries",
+	}
+}
+
+// ProbeFailureCounterOpt is the description for failed tests of the subsystem
+func ProbeFailureCounterOpt(subsystem string) prometheus.CounterOpts {
+	return prometheus.CounterOpts{
+		Namespace: "pulsar",
+		Subsystem: subsystem,
+		Name:      "failure_counter",
+		Help:      fmt.Sprintf("Pulsar %s failed tests", subsystem),
+	}
+}
+
+// ReceiveErrorCounterOpt is the description for consumer receive errors of the pub sub test
+func ReceiveErrorCounterOpt() prometheus.CounterOpts {
+	return prometheus.CounterOpts{
+		Namespace: "pulsar",
+		Subsystem: "pubsub",
+		Name:      "receive_error_total",
+		Help:      "Pulsar pub sub test consumer receive errors",
+	}
+}
+
+// BundleChurnCounterOpt is the description for the namespace bundle ownership changes
+func BundleChurnCounterOpt() prometheus.CounterOpts {
+	return prometheus.CounterOpts{
+		Namespace: "pulsar",
+		Subsystem: "bundle",
+		Name:      "churn_total",
+		Help:      "Pulsar namespace bundles unloaded or moved to another broker",
+	}
+}
+
+// ClientReconnectCounterOpt is the description for the Pulsar clients re-created after failures
+func ClientReconnectCounterOpt() prometheus.CounterOpts {
+	return prometheus.CounterOpts{
+		Namespace: "pulsar",
+		Subsystem: "client",
+		Name:      "reconnect_total",
+		Help:      "Pulsar clients dropped on a failure and connected again",
+	}
+}
+
 // FuncLatencyGaugeOpt is the description of Pulsar Function latency gauge
 func FuncLatencyGaugeOpt() prometheus.GaugeOpts {
 	return prometheus.GaugeOpts{
@@ -139,10 +523,12 @@ func PromGaugeInt(opt prometheus.GaugeOpts, cluster string, num int) {
 // PromGauge registers gauge reading
 func PromGauge(opt prometheus.GaugeOpts, cluster string, num float64) {
 	key := getMetricKey(opt)
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
 	if promMetric, ok := metrics[key]; ok {
 		promMetric.WithLabelValues(cluster).Set(num)
 	} else {
-		newMetric := prometheus.NewGaugeVec(opt, []string{"device"})
+		newMetric := prometheus.NewGaugeVec(withEnvLabel(opt), []string{"device"})
 		prometheus.Register(newMetric)
 		newMetric.WithLabelValues(cluster).Set(num)
 		metrics[key] = newMetric
@@ -151,25 +537,51 @@ func PromGauge(opt prometheus.GaugeOpts, cluster string, num float64) {
 
 // PromCounter registers counter and increment
 func PromCounter(opt prometheus.CounterOpts, cluster string) {
+	PromCounterAdd(opt, cluster, 1)
+}
+
+// PromCounterAdd registers counter and adds the value
+func PromCounterAdd(opt prometheus.CounterOpts, cluster string, value float64) {
 	key := fmt.Sprintf("%s-%s-%s", opt.Namespace, opt.Subsystem, opt.Name)
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
 	if promMetric, ok := counters[key]; ok {
-		promMetric.WithLabelValues(cluster).Inc()
+		promMetric.WithLabelValues(cluster).Add(value)
 	} else {
+		opt.ConstLabels = envLabels(opt.ConstLabels)
 		newMetric := prometheus.NewCounterVec(opt, []string{"device"})
 		prometheus.Register(newMetric)
-		newMetric.WithLabelValues(cluster).Inc()
+		newMetric.WithLabelValues(cluster).Add(value)
 		counters[key] = newMetric
 	}
 }
 
 // PromLatencySum expose monitoring metrics to Prometheus
 func PromLatencySum(opt prometheus.GaugeOpts, cluster string, latency time.Duration) {
+	PromLatencySumWithExemplar(opt, cluster, latency, "")
+}
+
+// PromLatencySumWithExemplar exposes the latency as PromLatencySum and a histogram,
+// the histogram sample has the probe id exemplar if it is specified
+func PromLatencySumWithExemplar(opt prometheus.GaugeOpts, cluster string, latency time.Duration, probeID string) {
+	PromMessageLatencies(opt, cluster, latency, []time.Duration{latency}, probeID)
+}
+
+// PromMessageLatencies exposes the average latency of a test as PromLatencySum and observes the latency of every message
+// of the test in the histogram, the histogram samples have the probe id exemplar if it is specified
+func PromMessageLatencies(opt prometheus.GaugeOpts, cluster string, latency time.Duration, messageLatencies []time.Duration, probeID string) {
+	if latency >= failedLatency {
+		// the failure sentinel is not a latency, failures are exported by the failure counters
+		return
+	}
 	key := getMetricKey(opt)
 	ms := float64(latency / time.Millisecond)
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
 	if promMetric, ok := metrics[key]; ok {
 		promMetric.WithLabelValues(cluster).Set(ms)
 	} else {
-		newMetric := prometheus.NewGaugeVec(opt, []string{"device"})
+		newMetric := prometheus.NewGaugeVec(withEnvLabel(opt), []string{"device"})
 		prometheus.Register(newMetric)
 		newMetric.WithLabelValues(cluster).Set(ms)
 		metrics[key] = newMetric
@@ -179,20 +591,224 @@ func PromLatencySum(opt prometheus.GaugeOpts, cluster string, latency time.Durat
 		summary.WithLabelValues(cluster).Observe(ms)
 	} else {
 		newSummary := prometheus.NewSummaryVec(prometheus.SummaryOpts{
-			Namespace:  opt.Namespace,
-			Subsystem:  opt.Subsystem,
-			Name:       fmt.Sprintf("%s_hst", opt.Name),
-			Help:       opt.Help,
-			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
-			MaxAge:     30 * time.Minute,
-			AgeBuckets: 3,
-			BufCap:     500,
+			Namespace:   opt.Namespace,
+			Subsystem:   opt.Subsystem,
+			Name:        fmt.Sprintf("%s_hst", opt.Name),
+			Help:        opt.Help,
+			Objectives:  map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+			MaxAge:      30 * time.Minute,
+			AgeBuckets:  3,
+			BufCap:      500,
+			ConstLabels: envLabels(opt.ConstLabels),
 		}, []string{"device"})
 		prometheus.MustRegister(newSummary)
 		newSummary.WithLabelValues(cluster).Observe(ms)
 		summaries[key] = newSummary
 	}
 
+	histogram, ok := histograms[key]
+	if !ok {
+		histogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   opt.Namespace,
+			Subsystem:   opt.Subsystem,
+			Name:        fmt.Sprintf("%s_histogram", opt.Name),
+			Help:        opt.Help,
+			Buckets:     prometheus.ExponentialBuckets(5, 2, 12),
+			ConstLabels: envLabels(opt.ConstLabels),
+		}, []string{"device"})
+		prometheus.MustRegister(histogram)
+		histograms[key] = histogram
+	}
+	for _, messageLatency := range messageLatencies {
+		if messageLatency >= failedLatency {
+			continue
+		}
+		messageMs := float64(messageLatency / time.Millisecond)
+		if probeID != "" {
+			histogram.WithLabelValues(cluster).(prometheus.ExemplarObserver).ObserveWithExemplar(messageMs, prometheus.Labels{"probe_id": probeID})
+		} else {
+			histogram.WithLabelValues(cluster).Observe(messageMs)
+		}
+	}
+}
+
+// PromAdminRequest exposes the admin REST API request latency labeled by endpoint and status code
+// the status is `error` if the request failed without a response
+func PromAdminRequest(endpoint, cluster string, statusCode int, latency time.Duration) {
+	adminRequestRegister.Do(func() {
+		adminRequestLatency = prometheus.NewGaugeVec(withEnvLabel(AdminRequestGaugeOpt()), []string{"device", "endpoint", "status"})
+		prometheus.MustRegister(adminRequestLatency)
+	})
+	status := "error"
+	if statusCode > 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	adminRequestLatency.WithLabelValues(cluster, endpoint, status).Set(float64(latency / time.Millisecond))
+}
+
+// PromComponents exposes the number of monitored components labeled by kind
+func PromComponents(cluster string, counts map[string]int) {
+	monitorComponentsRegister.Do(func() {
+		monitorComponents = prometheus.NewGaugeVec(withEnvLabel(ComponentsGaugeOpt()), []string{"device", "kind"})
+		prometheus.MustRegister(monitorComponents)
+	})
+	for kind, count := range counts {
+		monitorComponents.WithLabelValues(cluster, kind).Set(float64(count))
+	}
+}
+
+// PromPubSubErrorClass sets the gauge of the error class to 1 and the other classes to 0,
+// all classes are 0 if the class is empty after a successful test
+func PromPubSubErrorClass(cluster, class string) {
+	pubSubErrorClassRegister.Do(func() {
+		pubSubErrorClass = prometheus.NewGaugeVec(withEnvLabel(PubSubErrorClassGaugeOpt()), []string{"device", "class"})
+		prometheus.MustRegister(pubSubErrorClass)
+	})
+	for _, c := range errorClasses {
+		v := 0.0
+		if c == class {
+			v = 1
+		}
+		pubSubErrorClass.WithLabelValues(cluster, c).Set(v)
+	}
+}
+
+// PromClusterInfo exposes the cluster metadata labels, the labels of a previous metadata are removed
+func PromClusterInfo(cluster, pulsarCluster, brokerVersion string) {
+	clusterInfoRegister.Do(func() {
+		clusterInfo = prometheus.NewGaugeVec(withEnvLabel(ClusterInfoGaugeOpt()), []string{"device", "pulsar_cluster", "broker_version"})
+		prometheus.MustRegister(clusterInfo)
+	})
+	clusterInfo.DeletePartialMatch(prometheus.Labels{"device": cluster})
+	clusterInfo.WithLabelValues(cluster, pulsarCluster, brokerVersion).Set(1)
+}
+
+// PromSiteResponseBytes exposes the response body size labeled by the content type of the latest response
+func PromSiteResponseBytes(site, contentType string, size int64) {
+	siteResponseBytesRegister.Do(func() {
+		siteResponseBytes = prometheus.NewGaugeVec(withEnvLabel(SiteResponseBytesGaugeOpt()), []string{"device", "content_type"})
+		prometheus.MustRegister(siteResponseBytes)
+	})
+	siteResponseBytes.DeletePartialMatch(prometheus.Labels{"device": site})
+	siteResponseBytes.WithLabelValues(site, contentType).Set(float64(size))
+}
+
+// PromSubscriptionConsumers exposes the number of consumers labeled by topic and subscription
+func PromSubscriptionConsumers(cluster, topic, subscription string, count int) {
+	subscriptionConsumerCountRegister.Do(func() {
+		subscriptionConsumerCount = prometheus.NewGaugeVec(withEnvLabel(SubscriptionConsumersGaugeOpt()), []string{"device", "topic", "subscription"})
+		prometheus.MustRegister(subscriptionConsumerCount)
+	})
+	subscriptionConsumerCount.WithLabelValues(cluster, topic, subscription).Set(float64(count))
+}
+
+// PromDirectBrokerLatency exposes the direct broker pub sub latency labeled by broker
+func PromDirectBrokerLatency(cluster, broker string, latency time.Duration) {
+	directBrokerLatencyRegister.Do(func() {
+		directBrokerLatency = prometheus.NewGaugeVec(withEnvLabel(DirectBrokerLatencyGaugeOpt()), []string{"device", "broker"})
+		prometheus.MustRegister(directBrokerLatency)
+	})
+	directBrokerLatency.WithLabelValues(cluster, broker).Set(float64(latency / time.Millisecond))
+}
+
+// PromTopicMsgRateIn exposes the topic publish rate labeled by topic
+func PromTopicMsgRateIn(cluster, topic string, rate float64) {
+	topicMsgRateInRegister.Do(func() {
+		topicMsgRateIn = prometheus.NewGaugeVec(withEnvLabel(TopicMsgRateInGaugeOpt()), []string{"device", "topic"})
+		prometheus.MustRegister(topicMsgRateIn)
+	})
+	topicMsgRateIn.WithLabelValues(cluster, topic).Set(rate)
+}
+
+// PromPartitionCount exposes the actual and the expected number of partitions labeled by topic and kind
+func PromPartitionCount(cluster, topic string, expected, actual int) {
+	partitionCountRegister.Do(func() {
+		partitionCount = prometheus.NewGaugeVec(withEnvLabel(PartitionCountGaugeOpt()), []string{"device", "topic", "kind"})
+		prometheus.MustRegister(partitionCount)
+	})
+	partitionCount.WithLabelValues(cluster, topic, "expected").Set(float64(expected))
+	partitionCount.WithLabelValues(cluster, topic, "actual").Set(float64(actual))
+}
+
+// PromDLQBacklog exposes the dead letter topic backlog labeled by topic
+func PromDLQBacklog(cluster, topic string, backlog int64) {
+	dlqBacklogRegister.Do(func() {
+		dlqBacklog = prometheus.NewGaugeVec(withEnvLabel(DLQBacklogGaugeOpt()), []string{"device", "topic"})
+		prometheus.MustRegister(dlqBacklog)
+	})
+	dlqBacklog.WithLabelValues(cluster, topic).Set(float64(backlog))
+}
+
+// PromPlaneHealth exposes the plane health labeled by plane, either binary or http
+func PromPlaneHealth(cluster, plane string, up bool) {
+	planeHealthRegister.Do(func() {
+		planeHealth = prometheus.NewGaugeVec(withEnvLabel(PlaneHealthGaugeOpt()), []string{"device", "plane"})
+		prometheus.MustRegister(planeHealth)
+	})
+	value := 0.0
+	if up {
+		value = 1
+	}
+	planeHealth.WithLabelValues(cluster, plane).Set(value)
+}
+
+// PromIncidentTrackers exposes the counter and moving window failures of each tracked component,
+// the series of the components no longer tracked are removed
+func PromIncidentTrackers(cluster string, counters, windowAlerts map[string]int) {
+	incidentTrackerMetricsRegister.Do(func() {
+		incidentTrackerCounter = prometheus.NewGaugeVec(withEnvLabel(IncidentTrackerCounterGaugeOpt()), []string{"device", "component"})
+		incidentTrackerWindowAlerts = prometheus.NewGaugeVec(withEnvLabel(IncidentTrackerWindowAlertsGaugeOpt()), []string{"device", "component"})
+		prometheus.MustRegister(incidentTrackerCounter, incidentTrackerWindowAlerts)
+	})
+	incidentTrackerCounter.Reset()
+	incidentTrackerWindowAlerts.Reset()
+	for component, count := range counters {
+		incidentTrackerCounter.WithLabelValues(cluster, component).Set(float64(count))
+	}
+	for component, count := range windowAlerts {
+		incidentTrackerWindowAlerts.WithLabelValues(cluster, component).Set(float64(count))
+	}
+}
+
+// withEnvLabel adds the deployment environment label to the gauge
+func withEnvLabel(opt prometheus.GaugeOpts) prometheus.GaugeOpts {
+	opt.ConstLabels = envLabels(opt.ConstLabels)
+	return opt
+}
+
+// envLabels returns a copy of the constant labels with the deployment environment
+func envLabels(labels prometheus.Labels) prometheus.Labels {
+	env := GetConfig().Env
+	if env == "" {
+		return labels
+	}
+	merged := prometheus.Labels{"env": env}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
+
+// MetricsHandler serves the default registry in the Prometheus text format,
+// or in the OpenMetrics format with exemplars if it is requested by the Accept header
+func MetricsHandler() http.Handler {
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+}
+
+// ServeMetrics binds the port and serves the handler in the background
+// the bind error is returned so that a port clash is not silently ignored
+func ServeMetrics(port string, handler http.Handler) error {
+	listener, err := net.Listen("tcp", port)
+	if err != nil {
+		return fmt.Errorf("failed to bind metrics port %s, error: %w", port, err)
+	}
+	go func() {
+		if err := http.Serve(listener, handler); err != nil {
+			log.Errorf("metrics server on port %s stopped, error: %v", port, err)
+		}
+	}()
+	return nil
 }
 
 func getMetricKey(opt prometheus.GaugeOpts) string {
@@ -209,6 +825,18 @@ func GetGaugeType(nameType string) prometheus.GaugeOpts {
 		return MsgLatencyGaugeOpt(websocketSubsystem, "Plusar websocket pubsub topic latency in ms")
 	}
 
+	if nameType == k8sZookeeperSubsystem {
+		return MsgLatencyGaugeOpt(k8sZookeeperSubsystem, "Pulsar zookeeper exists read latency in ms")
+	}
+
+	if nameType == kopSubsystem {
+		return MsgLatencyGaugeOpt(kopSubsystem, "Pulsar Kafka protocol handler pubsub topic latency in ms")
+	}
+
+	if nameType == mopSubsystem {
+		return MsgLatencyGaugeOpt(mopSubsystem, "Pulsar MQTT protocol handler pubsub topic latency in ms")
+	}
+
 	return MsgLatencyGaugeOpt(pubSubSubsystem, "Plusar pubsub message latency in ms")
 }
 
@@ -223,6 +851,8 @@ func GetOfflinePodsCounter(subsystem string) prometheus.GaugeOpts {
 		return OfflinePodGaugeOpt(k8sProxySubsystem, "Pulsar k8s clueter proxy pods offline counter")
 	case k8sZookeeperSubsystem:
 		return OfflinePodGaugeOpt(k8sZookeeperSubsystem, "Pulsar k8s clueter zookeeper pods offline counter")
+	case k8sFunctionWorkerSubsystem:
+		return OfflinePodGaugeOpt(k8sFunctionWorkerSubsystem, "Pulsar k8s clueter function worker pods offline counter")
 	default:
 		return OfflinePodGaugeOpt(k8sUndefinedSubsystem, "Pulsar k8s clueter undefined pods offline counter")
 	}
@@ -261,17 +891,53 @@ func scrapeLocal() ([]byte, error) {
 		return []byte{}, err
 	}
 
-	var rc string
-	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	promCfg := GetConfig().PrometheusConfig
+	return filterMetrics(string(body), promCfg.PushIncludePrefixes, promCfg.PushExcludePrefixes), nil
+}
+
+// filterMetrics returns the metric lines in the text exposition format whose metric name has one of the include prefixes
+// and none of the exclude prefixes, the lines matching pulsarMetricsPattern are included if no include prefix is specified
+func filterMetrics(body string, includePrefixes, excludePrefixes []string) []byte {
+	var rc strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(body))
 
 	for scanner.Scan() {
 		text := scanner.Text()
+		name := metricName(text)
 		matched := pulsarMetricsRegexp.MatchString(text)
-		if matched && err == nil {
-			rc = fmt.Sprintf("%s%s\n", rc, text)
+		if len(includePrefixes) > 0 {
+			matched = hasAnyPrefix(name, includePrefixes)
+		}
+		if matched && !hasAnyPrefix(name, excludePrefixes) {
+			rc.WriteString(text)
+			rc.WriteString("\n")
+		}
+	}
+	return []byte(strings.TrimSuffix(rc.String(), "\n"))
+}
+
+// metricName returns the metric name of a sample line or a HELP and TYPE comment line
+func metricName(line string) string {
+	if strings.HasPrefix(line, "#") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+			return ""
+		}
+		return fields[2]
+	}
+	if end := strings.IndexAny(line, "{ "); end >= 0 {
+		return line[:end]
+	}
+	return line
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
 		}
 	}
-	return []byte(strings.TrimSuffix(rc, "\n")), nil
+	return false
 }
 
 // PushToPrometheusProxy pushes exp data to PrometheusProxy
@@ -289,7 +955,7 @@ func PushToPrometheusProxy(proxyURL, authKey string) error {
 
 	req.Header.Set("Authorization", authKey)
 
-	client := &http.Client{Timeout: time.Second * 50}
+	client := newHTTPClient(time.Second * 50)
 
 	// Send request
 	resp, err := client.Do(req)
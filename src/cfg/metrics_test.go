@@ -0,0 +1,257 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// gatheredLabels returns the labels of every series of the metric in the default registry
+func gatheredLabels(t *testing.T, name string) []map[string]string {
+	families, err := prometheus.DefaultGatherer.Gather()
+	errNil(t, err)
+	series := []map[string]string{}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			series = append(series, labels)
+		}
+	}
+	return series
+}
+
+func TestEnvConfig(t *testing.T) {
+	c := Configuration{Name: "env-test"}
+	c.Init()
+	assert(t, c.Env == "testing", "expect the default env testing but got %s", c.Env)
+
+	t.Setenv("DeployEnv", "staging")
+	c = Configuration{Name: "env-test"}
+	c.Init()
+	assert(t, c.Env == "staging", "expect the env from DeployEnv but got %s", c.Env)
+
+	c = Configuration{Name: "env-test", Env: "production"}
+	c.Init()
+	assert(t, c.Env == "production", "expect the configured env overrides DeployEnv but got %s", c.Env)
+}
+
+func TestEnvMetricLabel(t *testing.T) {
+	env := GetConfig().Env
+	GetConfig().Env = "production"
+	defer func() {
+		GetConfig().Env = env
+	}()
+
+	PromLatencySum(MsgLatencyGaugeOpt("env_test", "env label test latency in ms"), "env-cluster", 5*time.Millisecond)
+	for _, name := range []string{"pulsar_env_test_latency_ms", "pulsar_env_test_latency_ms_hst"} {
+		series := gatheredLabels(t, name)
+		assert(t, len(series) == 1, "expect one %s series but got %d", name, len(series))
+		assert(t, series[0]["env"] == "production" && series[0]["device"] == "env-cluster", "unexpected %s labels %v", name, series[0])
+	}
+}
+
+func TestServeMetricsBindError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	errNil(t, err)
+	defer listener.Close()
+
+	err = ServeMetrics(listener.Addr().String(), http.NotFoundHandler())
+	assert(t, err != nil, "expect the bind error on a port in use surfaced")
+
+	port := freePort(t)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("metrics"))
+	})
+	errNil(t, ServeMetrics(port, handler))
+	resp, err := http.Get("http://" + port + "/metrics")
+	errNil(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	errNil(t, err)
+	assert(t, string(body) == "metrics", "expect the handler served but got %s", string(body))
+}
+
+// freePort returns a local address with a port that is not in use
+func TestComponentsMetric(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+
+	configFile := filepath.Join(t.TempDir(), "components.yml")
+	sample := `
+name: components-test
+pulsarTopicConfig:
+  - topicName: persistent://tenant/ns/topic1
+  - topicName: persistent://tenant/ns/topic2
+sitesConfig:
+  sites:
+    - url: https://example.com
+webSocketConfig:
+  - topicName: persistent://tenant/ns/ws1
+  - topicName: persistent://tenant/ns/ws2
+  - topicName: persistent://tenant/ns/ws3
+pulsarAdminRestConfig:
+  clusters:
+    - name: cluster1
+      url: https://cluster1.example.com
+`
+	errNil(t, os.WriteFile(configFile, []byte(sample), 0600))
+	ReadConfigFile(configFile)
+
+	expected := map[string]float64{"topics": 2, "sites": 1, "websockets": 3, "clusters": 1}
+	for kind, count := range expected {
+		value := testutil.ToFloat64(monitorComponents.WithLabelValues("components-test", kind))
+		assert(t, count == value, "expect %v %s but got %v", count, kind, value)
+	}
+
+	// reload updates the counts
+	sample = `
+name: components-test
+pulsarTopicConfig:
+  - topicName: persistent://tenant/ns/topic1
+webSocketConfig: []
+`
+	errNil(t, os.WriteFile(configFile, []byte(sample), 0600))
+	ReadConfigFile(configFile)
+	expected["topics"], expected["websockets"] = 1, 0
+	for kind, count := range expected {
+		value := testutil.ToFloat64(monitorComponents.WithLabelValues("components-test", kind))
+		assert(t, count == value, "expect %v %s after reload but got %v", count, kind, value)
+	}
+}
+
+func TestMetricsHandlerOpenMetrics(t *testing.T) {
+	opt := MsgLatencyGaugeOpt("exemplar_test", "exemplar test latency in ms")
+	PromLatencySumWithExemplar(opt, "exemplar-cluster", 20*time.Millisecond, "probe123")
+	server := httptest.NewServer(MetricsHandler())
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	errNil(t, err)
+	req.Header.Set("Accept", "application/openmetrics-text; version=0.0.1")
+	resp, err := http.DefaultClient.Do(req)
+	errNil(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	errNil(t, err)
+	assert(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "application/openmetrics-text"), "expect OpenMetrics but got %s", resp.Header.Get("Content-Type"))
+	assert(t, strings.Contains(string(body), `# {probe_id="probe123"} 20`), "expect the probe id exemplar in\n%s", body)
+	assert(t, strings.HasSuffix(string(body), "# EOF\n"), "expect the OpenMetrics EOF marker")
+
+	// the Prometheus text format is the default
+	resp, err = http.Get(server.URL)
+	errNil(t, err)
+	defer resp.Body.Close()
+	body, err = io.ReadAll(resp.Body)
+	errNil(t, err)
+	assert(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain"), "expect the text format but got %s", resp.Header.Get("Content-Type"))
+	assert(t, strings.Contains(string(body), "pulsar_exemplar_test_latency_ms_histogram_bucket"), "expect the latency histogram")
+	assert(t, !strings.Contains(string(body), "probe_id"), "expect no exemplar in the text format")
+}
+
+func freePort(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	errNil(t, err)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestFilterMetrics(t *testing.T) {
+	body := strings.Join([]string{
+		"# HELP go_goroutines Number of goroutines that currently exist.",
+		"# TYPE go_goroutines gauge",
+		`go_goroutines{device="pulsar-east"} 12`,
+		"# HELP pulsar_pubsub_latency_ms Plusar message latency in ms",
+		"# TYPE pulsar_pubsub_latency_ms gauge",
+		`pulsar_pubsub_latency_ms{device="useast"} 35`,
+		`pulsar_admin_request_ms{device="useast",endpoint="tenants",status="200"} 12`,
+		`website_webendpoint_latency_ms{device="docs"} 120`,
+	}, "\n")
+
+	// the default includes any line matching pulsar
+	filtered := string(filterMetrics(body, nil, nil))
+	assert(t, strings.Contains(filtered, "go_goroutines{"), "expect the incidental pulsar match included by default")
+	assert(t, !strings.Contains(filtered, "website_"), "expect website metrics excluded by default")
+
+	filtered = string(filterMetrics(body, []string{"pulsar_", "website_"}, []string{"pulsar_admin_"}))
+	assert(t, !strings.Contains(filtered, "go_goroutines"), "expect go runtime metrics excluded")
+	assert(t, strings.Contains(filtered, "# TYPE pulsar_pubsub_latency_ms gauge"), "expect the included metric TYPE line")
+	assert(t, strings.Contains(filtered, `pulsar_pubsub_latency_ms{device="useast"} 35`), "expect the included metric sample")
+	assert(t, strings.Contains(filtered, "website_webendpoint_latency_ms"), "expect the included website metric")
+	assert(t, !strings.Contains(filtered, "pulsar_admin_request_ms"), "expect the excluded prefix dropped")
+	assert(t, 4 == len(strings.Split(filtered, "\n")), "expect 4 lines but got %q", filtered)
+
+	filtered = string(filterMetrics(body, nil, []string{"go_"}))
+	assert(t, !strings.Contains(filtered, "go_goroutines"), "expect the excluded prefix dropped from the default")
+	assert(t, strings.Contains(filtered, "pulsar_admin_request_ms"), "expect the default pulsar metrics")
+}
+
+func TestFailedLatencyNotObserved(t *testing.T) {
+	defer withoutAlertDestinations()()
+	name := "failed-latency-component"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, name)
+		incidentTrackersLock.Unlock()
+	}()
+	observed := func() uint64 {
+		families, err := prometheus.DefaultGatherer.Gather()
+		errNil(t, err)
+		for _, family := range families {
+			if family.GetName() != "pulsar_mop_latency_ms_hst" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				for _, label := range metric.GetLabel() {
+					if label.GetName() == "device" && label.GetValue() == name {
+						return metric.GetSummary().GetSampleCount()
+					}
+				}
+			}
+		}
+		return 0
+	}
+
+	evalProtocolLatency(name, mopSubsystem, 0, &AlertPolicyCfg{Ceiling: 10}, MsgResult{Latency: failedLatency}, errors.New("failed to publish"))
+	assert(t, observed() == 0, "expect the failed latency not observed in the summary")
+	assert(t, testutil.ToFloat64(counters["pulsar-mop-failure_counter"].WithLabelValues(name)) == 1, "expect the failure counted")
+
+	evalProtocolLatency(name, mopSubsystem, 0, &AlertPolicyCfg{Ceiling: 10}, MsgResult{Latency: 20 * time.Millisecond}, nil)
+	assert(t, observed() == 1, "expect the latency observed in the summary")
+}
@@ -0,0 +1,227 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// monitor pub sub latency over the MQTT protocol handler (MoP)
+
+// mqttConnectTimeout bounds the MQTT connect and publish acknowledgement
+const mqttConnectTimeout = 10 * time.Second
+
+// mqttMessage is a received MQTT application message
+type mqttMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+// mqttClient is the MQTT capability required by the MoP latency probe
+type mqttClient interface {
+	Subscribe(ctx context.Context, topic string, qos byte) error
+	Publish(topic string, qos byte, payload []byte) error
+	Receive(ctx context.Context) (mqttMessage, error)
+	Close() error
+}
+
+func newMqttClient(cfg MqttCfg, tokenSupplier func() (string, error)) (mqttClient, error) {
+	password := cfg.Password
+	if password == "" && tokenSupplier != nil {
+		// MoP authenticates the token as the password
+		token, err := tokenSupplier()
+		if err != nil {
+			return nil, err
+		}
+		password = token
+	}
+
+	var tlsConfig *tls.Config
+	if trustStore := util.FirstNonEmptyString(cfg.TrustStore, GetConfig().TrustStore); trustStore != "" {
+		var err error
+		if tlsConfig, err = trustStoreTLSConfig(trustStore); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.BrokerURL).
+		SetClientID(fmt.Sprintf("pulsar-heartbeat-%d", time.Now().UnixNano())).
+		SetUsername(util.FirstNonEmptyString(cfg.Username, "pulsar-heartbeat")).
+		SetPassword(password).
+		// MoP speaks MQTT 3.1.1, do not fall back to 3.1 on a refused connection
+		SetProtocolVersion(4).
+		SetKeepAlive(60 * time.Second).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetConnectTimeout(mqttConnectTimeout)
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	client := &pahoMqttClient{
+		client:   paho.NewClient(opts),
+		messages: make(chan mqttMessage, 100),
+	}
+	if err := waitMqttToken(client.client.Connect(), mqttConnectTimeout); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// pahoMqttClient implements mqttClient with the Eclipse Paho client
+type pahoMqttClient struct {
+	client   paho.Client
+	messages chan mqttMessage
+}
+
+// Subscribe subscribes to the topic, the received messages are buffered for Receive
+func (c *pahoMqttClient) Subscribe(ctx context.Context, topic string, qos byte) error {
+	token := c.client.Subscribe(topic, qos, func(_ paho.Client, msg paho.Message) {
+		select {
+		case c.messages <- mqttMessage{Topic: msg.Topic(), Payload: msg.Payload()}:
+		default:
+			// the probe only looks for its own message, drop the rest rather than blocking the client
+		}
+	})
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Publish publishes the payload and waits for the broker acknowledgement of QoS 1
+func (c *pahoMqttClient) Publish(topic string, qos byte, payload []byte) error {
+	return waitMqttToken(c.client.Publish(topic, qos, false, payload), mqttConnectTimeout)
+}
+
+// Receive returns the next message received by the subscription
+func (c *pahoMqttClient) Receive(ctx context.Context) (mqttMessage, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return mqttMessage{}, ctx.Err()
+	}
+}
+
+// Close disconnects from the broker
+func (c *pahoMqttClient) Close() error {
+	c.client.Disconnect(250)
+	return nil
+}
+
+func waitMqttToken(token paho.Token, timeout time.Duration) error {
+	if !token.WaitTimeout(timeout) {
+		return fmt.Errorf("mqtt operation timed out after %v", timeout)
+	}
+	return token.Error()
+}
+
+// trustStoreTLSConfig returns the TLS config trusting the CA certificates of the trust store file
+func trustStoreTLSConfig(trustStore string) (*tls.Config, error) {
+	caCert, err := os.ReadFile(trustStore)
+	if err != nil {
+		return nil, fmt.Errorf("error opening cert file %s, Error: %v", trustStore, err)
+	}
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+	return &tls.Config{RootCAs: caCertPool}, nil
+}
+
+// MqttLatencyTest publishes a message over MQTT and measures the latency until it is received by the subscription
+func MqttLatencyTest(client mqttClient, topic string, qos byte, timeout time.Duration) (MsgResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := client.Subscribe(ctx, topic, qos); err != nil {
+		return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to subscribe to mqtt topic %s: %w", topic, err)
+	}
+
+	messageText := fmt.Sprintf("test mop latency %s", time.Now())
+	sentTime := time.Now()
+	if err := client.Publish(topic, qos, []byte(messageText)); err != nil {
+		return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to publish to mqtt topic %s: %w", topic, err)
+	}
+
+	for {
+		msg, err := client.Receive(ctx)
+		if err != nil {
+			return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to receive from mqtt topic %s: %w", topic, err)
+		}
+		if string(msg.Payload) == messageText {
+			return MsgResult{Latency: time.Since(sentTime), InOrderDelivery: true}, nil
+		}
+	}
+}
+
+// TestMqttLatency tests MQTT protocol pub sub latency and reports the result
+func TestMqttLatency(config MqttCfg) {
+	tokenSupplier := util.TokenSupplierWithOverride(config.Token, GetConfig().TokenSupplier())
+	client, err := newMqttClient(config, tokenSupplier)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s mop latency test failed to connect to mqtt broker %s: %v", config.Name, config.BrokerURL, err)
+		log.Errorf(errMsg)
+		ReportIncident(config.Name, config.Name, mopSubsystem+" persisted latency test failure", errMsg, &config.AlertPolicy)
+		return
+	}
+	defer client.Close()
+
+	result, err := MqttLatencyTest(client, config.TopicName, config.QoS, util.TimeDuration(config.IntervalSeconds/2, 30, time.Second))
+	evalProtocolLatency(config.Name, mopSubsystem, config.LatencyBudgetMs, &config.AlertPolicy, result, err)
+}
+
+// MqttLatencyTestThread tests message delivery over the MQTT protocol and measures the latency
+func MqttLatencyTestThread() {
+	runProbes(mqttProbes())
+}
+
+// mqttProbes are the MQTT protocol latency tests
+func mqttProbes() []probe {
+	probes := []probe{}
+	for _, cfg := range GetConfig().MqttConfig {
+		if cfg.QoS > 1 {
+			log.Warnf("mop latency test %s only supports QoS 0 and 1, QoS %d is lowered to 1", cfg.Name, cfg.QoS)
+			cfg.QoS = 1
+		}
+		log.Infof("monitor mop latency on %s topic %s", cfg.BrokerURL, cfg.TopicName)
+		probes = append(probes, probe{
+			name:     cfg.Name,
+			interval: IntervalDuration(cfg.IntervalSeconds, 60),
+			run: func(c MqttCfg) monitorFunc {
+				return func() { TestMqttLatency(c) }
+			}(cfg),
+		})
+	}
+	return probes
+}
@@ -0,0 +1,146 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+type fakeMqttClient struct {
+	subscribeErr error
+	messages     []mqttMessage
+}
+
+func (c *fakeMqttClient) Subscribe(ctx context.Context, topic string, qos byte) error {
+	return c.subscribeErr
+}
+
+func (c *fakeMqttClient) Publish(topic string, qos byte, payload []byte) error {
+	c.messages = append(c.messages, mqttMessage{Topic: topic, Payload: payload})
+	return nil
+}
+
+func (c *fakeMqttClient) Receive(ctx context.Context) (mqttMessage, error) {
+	if len(c.messages) == 0 {
+		<-ctx.Done()
+		return mqttMessage{}, ctx.Err()
+	}
+	msg := c.messages[0]
+	c.messages = c.messages[1:]
+	return msg, nil
+}
+
+func (c *fakeMqttClient) Close() error {
+	return nil
+}
+
+func TestMqttLatencyProbe(t *testing.T) {
+	client := &fakeMqttClient{messages: []mqttMessage{{Topic: "mop-topic", Payload: []byte("retained message")}}}
+	result, err := MqttLatencyTest(client, "mop-topic", 1, time.Second)
+	errNil(t, err)
+	assert(t, result.Latency < time.Second, "expect the latency of the probe message")
+
+	client = &fakeMqttClient{subscribeErr: errors.New("not authorized")}
+	result, err = MqttLatencyTest(client, "mop-topic", 1, time.Second)
+	assert(t, err != nil, "expect subscription error")
+	assert(t, result.Latency == failedLatency, "expect failed latency")
+}
+
+// echoMqttBroker accepts a connection, acknowledges connect, subscribe and publish, and echos publish packets back
+func echoMqttBroker(listener net.Listener, password string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	for {
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		switch p := packet.(type) {
+		case *packets.ConnectPacket:
+			connack := packets.NewControlPacket(packets.Connack).(*packets.ConnackPacket)
+			if string(p.Password) != password {
+				connack.ReturnCode = packets.ErrRefusedNotAuthorised
+			}
+			connack.Write(conn)
+		case *packets.SubscribePacket:
+			suback := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			suback.MessageID = p.MessageID
+			suback.ReturnCodes = p.Qoss
+			suback.Write(conn)
+		case *packets.PublishPacket:
+			if p.Qos > 0 {
+				puback := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				puback.MessageID = p.MessageID
+				puback.Write(conn)
+			}
+			p.Write(conn)
+		case *packets.PingreqPacket:
+			packets.NewControlPacket(packets.Pingresp).Write(conn)
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+func TestPahoMqttClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	errNil(t, err)
+	defer listener.Close()
+	go echoMqttBroker(listener, "jwt")
+
+	cfg := MqttCfg{BrokerURL: "tcp://" + listener.Addr().String()}
+	client, err := newMqttClient(cfg, func() (string, error) { return "jwt", nil })
+	errNil(t, err)
+	defer client.Close()
+
+	result, err := MqttLatencyTest(client, "mop-topic", 1, 2*time.Second)
+	errNil(t, err)
+	assert(t, result.InOrderDelivery, "expect the probe message to be received")
+
+	go echoMqttBroker(listener, "jwt")
+	_, err = newMqttClient(cfg, func() (string, error) { return "expired", nil })
+	assert(t, err != nil, "expect the connection to be refused")
+}
+
+func TestEvalProtocolLatency(t *testing.T) {
+	name := "mop-eval"
+	policy := AlertPolicyCfg{Ceiling: 5}
+
+	evalProtocolLatency(name, mopSubsystem, 100, &policy, MsgResult{Latency: failedLatency}, errors.New("receive timed out"))
+	assert(t, incidentTrackers[name] != nil, "expect the failure to be tracked")
+	assert(t, incidentTrackers[name].Counters == 1, "expect one failure counted")
+
+	evalProtocolLatency(name, mopSubsystem, 100, &policy, MsgResult{Latency: 200 * time.Millisecond}, nil)
+	assert(t, incidentTrackers[name].Counters == 2, "expect over budget latency counted as failure")
+
+	evalProtocolLatency(name, mopSubsystem, 100, &policy, MsgResult{Latency: 20 * time.Millisecond}, nil)
+	assert(t, incidentTrackers[name].Counters == 1, "expect a successful probe to clear a failure")
+}
@@ -0,0 +1,93 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify a negatively acknowledged message is redelivered after the nack redelivery delay
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultNackRedeliveryDelay is shorter than the client default of one minute to fit in the receive timeout
+const defaultNackRedeliveryDelay = time.Second
+
+// nackRedeliveryTolerance is the allowed time over the nack redelivery delay, it is shortened in tests
+var nackRedeliveryTolerance = 5 * time.Second
+
+// nack check states of a received message
+const (
+	nackNone        = iota // not the nack check message
+	nackFirst              // the first delivery to negatively acknowledge
+	nackRedelivered        // the redelivery of the negatively acknowledged message
+)
+
+// nackRedelivery tracks the negatively acknowledged message of the nack redelivery check, a nil check is disabled
+type nackRedelivery struct {
+	payload     string
+	window      time.Duration
+	nackedAt    time.Time
+	redelivered bool
+}
+
+func newNackRedelivery(topicCfg TopicCfg, payload string) *nackRedelivery {
+	if !topicCfg.NackRedeliveryCheck {
+		return nil
+	}
+	return &nackRedelivery{
+		payload: payload,
+		window:  nackRedeliveryDelay(topicCfg) + nackRedeliveryTolerance,
+	}
+}
+
+// nackRedeliveryDelay is the consumer nack redelivery delay
+func nackRedeliveryDelay(topicCfg TopicCfg) time.Duration {
+	if topicCfg.NackRedeliveryDelayMs > 0 {
+		return time.Duration(topicCfg.NackRedeliveryDelayMs) * time.Millisecond
+	}
+	return defaultNackRedeliveryDelay
+}
+
+// receive returns the nack check state of the received message,
+// an error is returned if the nacked message is redelivered outside of the window
+func (n *nackRedelivery) receive(payload string, now time.Time) (int, error) {
+	if n == nil || payload != n.payload || n.redelivered {
+		return nackNone, nil
+	}
+	if n.nackedAt.IsZero() {
+		n.nackedAt = now
+		return nackFirst, nil
+	}
+	n.redelivered = true
+	if elapsed := now.Sub(n.nackedAt); elapsed > n.window {
+		return nackRedelivered, fmt.Errorf("nacked message redelivered after %v over the window %v", elapsed, n.window)
+	}
+	return nackRedelivered, nil
+}
+
+// pending returns an error if the nacked message has not been redelivered
+func (n *nackRedelivery) pending() error {
+	if n == nil || n.nackedAt.IsZero() || n.redelivered {
+		return nil
+	}
+	return fmt.Errorf("nacked message has not been redelivered since %v", n.nackedAt)
+}
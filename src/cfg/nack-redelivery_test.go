@@ -0,0 +1,98 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNackRedelivery(t *testing.T) {
+	assert(t, nil == newNackRedelivery(TopicCfg{}, "payload"), "expect the nack check disabled by default")
+	var disabled *nackRedelivery
+	state, err := disabled.receive("payload", time.Now())
+	assert(t, nackNone == state && err == nil, "expect no nack on a disabled check")
+	errNil(t, disabled.pending())
+
+	check := newNackRedelivery(TopicCfg{NackRedeliveryCheck: true, NackRedeliveryDelayMs: 100}, "payload")
+	assert(t, 100*time.Millisecond+nackRedeliveryTolerance == check.window, "unexpected window %v", check.window)
+	start := time.Now()
+	state, _ = check.receive("other", start)
+	assert(t, nackNone == state, "expect no nack on other messages")
+	state, _ = check.receive("payload", start)
+	assert(t, nackFirst == state, "expect the first delivery nacked")
+	assert(t, check.pending() != nil, "expect the redelivery pending")
+	state, err = check.receive("payload", start.Add(time.Second))
+	assert(t, nackRedelivered == state, "expect the redelivery")
+	errNil(t, err)
+	errNil(t, check.pending())
+	state, _ = check.receive("payload", start.Add(2*time.Second))
+	assert(t, nackNone == state, "expect a single nack check")
+
+	// redelivered out of the window
+	check = newNackRedelivery(TopicCfg{NackRedeliveryCheck: true}, "payload")
+	check.receive("payload", start)
+	_, err = check.receive("payload", start.Add(defaultNackRedeliveryDelay+nackRedeliveryTolerance+time.Second))
+	assert(t, err != nil && strings.Contains(err.Error(), "over the window"), "expect redelivery out of window error but got %v", err)
+}
+
+func TestPubSubLatencyNackRedelivery(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:             "pulsar://nack-test:6650",
+		TopicName:             "persistent://tenant/ns/nack-test",
+		NackRedeliveryCheck:   true,
+		NackRedeliveryDelayMs: 50,
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 5)
+
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	client.producer.consumer.nackDelay = 50 * time.Millisecond
+	result, err := PubSubLatency("nack-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, 1 == client.producer.consumer.nacked, "expect a single nack but got %d", client.producer.consumer.nacked)
+	assert(t, 50*time.Millisecond == client.consumerOptions.NackRedeliveryDelay, "expect the consumer nack redelivery delay")
+	assert(t, result.Latency < 50*time.Millisecond, "expect the latency measured on the first delivery but got %v", result.Latency)
+}
+
+func TestPubSubLatencyLateNackRedelivery(t *testing.T) {
+	saved := nackRedeliveryTolerance
+	defer func() { nackRedeliveryTolerance = saved }()
+	nackRedeliveryTolerance = 0
+	topicCfg := TopicCfg{
+		PulsarURL:             "pulsar://late-nack-test:6650",
+		TopicName:             "persistent://tenant/ns/late-nack-test",
+		NackRedeliveryCheck:   true,
+		NackRedeliveryDelayMs: 10,
+		MaxInFlightMessages:   1,
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 10)
+
+	// the late redelivery is received while the sender is still in flight, the error must win over a result
+	for i := 0; i < 5; i++ {
+		client := newFakePulsarClient(t, topicCfg.PulsarURL, 20*time.Millisecond)
+		client.producer.consumer.nackDelay = 100 * time.Millisecond
+		result, err := PubSubLatency("late-nack-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+		assert(t, err != nil && strings.Contains(err.Error(), "over the window"), "expect the late redelivery error but got %v", err)
+		assert(t, failedLatency == result.Latency, "expect the failed latency")
+	}
+}
@@ -0,0 +1,152 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// detect namespace policy drift against the expected policy values
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// GetNamespacePolicy gets the namespace policy as a generic json object
+func GetNamespacePolicy(adminURL, namespace, policy string, tokenSupplier func() (string, error)) (interface{}, error) {
+	admin := restTopicAdmin{baseURL: adminURL, tokenSupplier: tokenSupplier}
+	resp, err := admin.do(http.MethodGet, "admin/v2/namespaces/"+namespace+"/"+policy)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %s policy of namespace %s, returns incorrect status code %d", policy, namespace, resp.StatusCode)
+	}
+
+	var policyValue interface{}
+	if err = json.NewDecoder(resp.Body).Decode(&policyValue); err != nil {
+		return nil, err
+	}
+	return policyValue, nil
+}
+
+// comparePolicy returns a list of drifted fields between the policy and the expected values
+func comparePolicy(policy interface{}, expected map[string]interface{}) []string {
+	fields := make([]string, 0, len(expected))
+	for field := range expected {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	drifts := []string{}
+	for _, field := range fields {
+		actual, ok := policyField(policy, field)
+		if !ok {
+			drifts = append(drifts, fmt.Sprintf("%s is missing, expected %s", field, policyValueString(expected[field])))
+		} else if !policyValueEqual(actual, expected[field]) {
+			drifts = append(drifts, fmt.Sprintf("%s is %s, expected %s", field, policyValueString(actual), policyValueString(expected[field])))
+		}
+	}
+	return drifts
+}
+
+// policyField looks up a dot separated field in the json object
+func policyField(policy interface{}, field string) (interface{}, bool) {
+	value := policy
+	for _, key := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// policyValueEqual compares json values, the expected value can be decoded from either json or yaml
+func policyValueEqual(actual, expected interface{}) bool {
+	expectedBytes, err := json.Marshal(expected)
+	if err != nil {
+		return false
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(expectedBytes, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(actual, normalized)
+}
+
+// policyValueString formats the value in json for reporting
+func policyValueString(value interface{}) string {
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(valueBytes)
+}
+
+// PulsarNamespacePolicies evaluates the configured namespace policies on each cluster
+func PulsarNamespacePolicies() {
+	adminCfg := GetConfig().PulsarAdminConfig
+	if len(adminCfg.NamespacePolicies) == 0 {
+		return
+	}
+	tokenSupplier := util.TokenSupplierWithOverride(adminCfg.Token, GetConfig().TokenSupplier())
+
+	for _, cluster := range adminCfg.Clusters {
+		adminURL, err := url.ParseRequestURI(cluster.URL)
+		if err != nil {
+			panic(err) //panic because this is a showstopper
+		}
+		component := cluster.Name + "-namespace-policy"
+		drifts := []string{}
+		for _, nsPolicy := range adminCfg.NamespacePolicies {
+			policy, err := GetNamespacePolicy(cluster.URL, nsPolicy.Namespace, nsPolicy.Policy, tokenSupplier)
+			if err != nil {
+				log.Errorf("cluster %s namespace policy test failed, error: %v", adminURL.Hostname(), err)
+				drifts = append(drifts, err.Error())
+				continue
+			}
+			for _, drift := range comparePolicy(policy, nsPolicy.Expected) {
+				drifts = append(drifts, fmt.Sprintf("%s %s %s", nsPolicy.Namespace, nsPolicy.Policy, drift))
+			}
+		}
+
+		if len(drifts) > 0 {
+			errMsg := fmt.Sprintf("cluster %s namespace policy drift detected: %s", cluster.Name, strings.Join(drifts, "; "))
+			log.Errorf(errMsg)
+			ReportIncident(component, adminURL.Hostname(), "namespace policy drift", errMsg, &cluster.AlertPolicy)
+		} else {
+			log.Infof("cluster %s namespace policies match the expected values", cluster.Name)
+			ClearIncident(component)
+		}
+	}
+}
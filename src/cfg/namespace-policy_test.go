@@ -0,0 +1,76 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const sampleBacklogQuotaPolicy = `{"destination_storage":{"limit":10737418240,"limitSize":10737418240,"limitTime":-1,"policy":"producer_request_hold"}}`
+
+func TestComparePolicy(t *testing.T) {
+	var policy interface{}
+	errNil(t, json.Unmarshal([]byte(sampleBacklogQuotaPolicy), &policy))
+
+	drifts := comparePolicy(policy, map[string]interface{}{
+		"destination_storage.limitSize": 10737418240,
+		"destination_storage.policy":    "producer_request_hold",
+	})
+	assert(t, len(drifts) == 0, "expect no drift but got %v", drifts)
+
+	drifts = comparePolicy(policy, map[string]interface{}{
+		"destination_storage.limitSize": 1073741824,
+		"destination_storage.policy":    "consumer_backlog_eviction",
+		"destination_storage.missing":   true,
+	})
+	assert(t, len(drifts) == 3, "expect 3 drifted fields but got %v", drifts)
+	assert(t, drifts[0] == "destination_storage.limitSize is 10737418240, expected 1073741824", "unexpected drift %s", drifts[0])
+	assert(t, drifts[1] == "destination_storage.missing is missing, expected true", "unexpected drift %s", drifts[1])
+	assert(t, drifts[2] == "destination_storage.policy is \"producer_request_hold\", expected \"consumer_backlog_eviction\"", "unexpected drift %s", drifts[2])
+}
+
+func TestGetNamespacePolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/v2/namespaces/tenant/ns/retention" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"retentionTimeInMinutes":10080,"retentionSizeInMB":-1}`))
+	}))
+	defer server.Close()
+
+	tokenSupplier := func() (string, error) { return "token", nil }
+	policy, err := GetNamespacePolicy(server.URL, "tenant/ns", "retention", tokenSupplier)
+	errNil(t, err)
+	drifts := comparePolicy(policy, map[string]interface{}{"retentionTimeInMinutes": 10080, "retentionSizeInMB": -1})
+	assert(t, len(drifts) == 0, "expect no drift but got %v", drifts)
+
+	_, err = GetNamespacePolicy(server.URL, "tenant/ns", "backlogQuotaMap", tokenSupplier)
+	assert(t, err != nil, "expect an error on incorrect status code")
+}
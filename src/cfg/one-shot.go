@@ -0,0 +1,138 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// run the probes on their intervals or every probe exactly once
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// probe is a single pass of a test, it is run on the interval by the test threads or once in the one-shot mode
+type probe struct {
+	name     string
+	interval time.Duration
+	run      monitorFunc
+}
+
+// runProbes runs every probe on its interval
+func runProbes(probes []probe) {
+	for _, p := range probes {
+		RunInterval(p.run, p.interval)
+	}
+}
+
+// oneShotProbes are the configured probes run by the one-shot mode,
+// failover drills are excluded because they mark the primary proxy down,
+// the bundle churn is excluded because it is measured between two runs,
+// and the OpsGenie heartbeats are excluded because they are not tests
+func oneShotProbes() []probe {
+	probes := topicProbes(true)
+	probes = append(probes, webSocketProbes()...)
+	probes = append(probes, kafkaProbes()...)
+	probes = append(probes, mqttProbes()...)
+	probes = append(probes, siteProbes()...)
+	probes = append(probes, adminProbes()...)
+	probes = append(probes, bookkeeperProbes()...)
+	probes = append(probes, zookeeperProbes()...)
+	k8sCluster, err := k8sProbes()
+	if err != nil {
+		// the run fails if the in-cluster monitoring cannot start
+		name := GetConfig().Name + "-in-cluster"
+		k8sCluster = []probe{{name: name, run: func() { recordFailureReport(name, time.Now()) }}}
+	}
+	return append(probes, k8sCluster...)
+}
+
+// RunOnce runs every configured probe exactly once and returns the process exit code,
+// which is 1 if any probe has failed
+func RunOnce() int {
+	return runProbesOnce(oneShotProbes())
+}
+
+// runProbesOnce runs the probes concurrently and waits for all of them,
+// it returns 1 if any component status recorded during the run is a failure
+func runProbesOnce(probes []probe) int {
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, p := range probes {
+		wg.Add(1)
+		go func(p probe) {
+			defer wg.Done()
+			log.Infof("one-shot probe %s", p.name)
+			p.run()
+		}(p)
+	}
+	wg.Wait()
+
+	failed := failedComponentsSince(start)
+	if len(failed) > 0 {
+		log.Errorf("one-shot run of %d probes failed on components %v", len(probes), failed)
+		return 1
+	}
+	log.Infof("one-shot run of %d probes has successfully passed", len(probes))
+	return 0
+}
+
+var (
+	// failureReports is the time of the last failure reported to the alert policy, key is the component name
+	failureReports     = make(map[string]time.Time)
+	failureReportsLock = &sync.Mutex{}
+)
+
+// recordFailureReport records a failure of the component, it covers the probes without a component status
+func recordFailureReport(component string, at time.Time) {
+	failureReportsLock.Lock()
+	defer failureReportsLock.Unlock()
+	failureReports[component] = at
+}
+
+// failedComponentsSince returns the sorted components whose last test since the time has failed
+// or whose failure has been reported since the time
+func failedComponentsSince(since time.Time) []string {
+	failedSet := map[string]bool{}
+	componentStatusesLock.RLock()
+	for component, status := range componentStatuses {
+		if !status.Success && !status.LastRun.Before(since) {
+			failedSet[component] = true
+		}
+	}
+	componentStatusesLock.RUnlock()
+	failureReportsLock.Lock()
+	for component, at := range failureReports {
+		if !at.Before(since) {
+			failedSet[component] = true
+		}
+	}
+	failureReportsLock.Unlock()
+
+	failed := []string{}
+	for component := range failedSet {
+		failed = append(failed, component)
+	}
+	sort.Strings(failed)
+	return failed
+}
@@ -0,0 +1,125 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunProbesOnce(t *testing.T) {
+	var passRuns, failRuns int32
+	passing := probe{name: "one-shot-pass", interval: time.Hour, run: func() {
+		atomic.AddInt32(&passRuns, 1)
+		RecordStatus("one-shot-pass", time.Millisecond, "")
+	}}
+	failing := probe{name: "one-shot-fail", interval: time.Hour, run: func() {
+		atomic.AddInt32(&failRuns, 1)
+		RecordStatus("one-shot-fail", time.Millisecond, "latency test failure")
+	}}
+	defer func() {
+		componentStatusesLock.Lock()
+		delete(componentStatuses, "one-shot-pass")
+		delete(componentStatuses, "one-shot-fail")
+		componentStatusesLock.Unlock()
+	}()
+
+	code := runProbesOnce([]probe{passing})
+	assert(t, code == 0, "expect exit code 0 but got %d", code)
+	assert(t, atomic.LoadInt32(&passRuns) == 1, "expect the probe run once but got %d", passRuns)
+
+	code = runProbesOnce([]probe{passing, failing})
+	assert(t, code == 1, "expect exit code 1 but got %d", code)
+	assert(t, atomic.LoadInt32(&passRuns) == 2, "expect the probe run once more but got %d", passRuns)
+	assert(t, atomic.LoadInt32(&failRuns) == 1, "expect the failing probe run once but got %d", failRuns)
+
+	// a failure recorded before the run is not counted
+	code = runProbesOnce([]probe{passing})
+	assert(t, code == 0, "expect exit code 0 but got %d", code)
+}
+
+func TestRunProbesOnceFailureReport(t *testing.T) {
+	defer withoutAlertDestinations()()
+	component := "one-shot-report"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, component)
+		incidentTrackersLock.Unlock()
+		failureReportsLock.Lock()
+		delete(failureReports, component)
+		failureReportsLock.Unlock()
+	}()
+	// a probe reporting the failure to its alert policy fails the run without a component status
+	reporting := probe{name: component, interval: time.Hour, run: func() {
+		ReportIncident(component, component, "one-shot test failure", "bookkeeper unreachable", &AlertPolicyCfg{Ceiling: 5})
+	}}
+	start := time.Now()
+	code := runProbesOnce([]probe{reporting})
+	assert(t, code == 1, "expect exit code 1 but got %d", code)
+	failed := failedComponentsSince(start)
+	assert(t, len(failed) == 1 && failed[0] == component, "expect the reported component failed but got %v", failed)
+}
+
+func TestOneShotProbes(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	Config = Configuration{
+		Name:              "one-shot",
+		PulsarTopicConfig: []TopicCfg{{TopicName: "persistent://tenant/ns/one"}, {TopicName: "persistent://tenant/ns/two", IntervalSeconds: 30}},
+		SitesConfig:       SitesCfg{Sites: []SiteCfg{{Name: "site", URL: "https://example.com"}}},
+	}
+	probes := oneShotProbes()
+	assert(t, len(probes) == 9, "expect a probe per topic and site and the admin probes but got %d", len(probes))
+	assert(t, probes[1].interval == 30*time.Second, "expect the topic interval but got %v", probes[1].interval)
+	assert(t, probes[2].interval == 120*time.Second, "expect the default site interval but got %v", probes[2].interval)
+	assert(t, probes[3].name == "pulsar-admin-tenants", "expect the tenants probe but got %s", probes[3].name)
+
+	Config.BookkeeperConfig.BookieHTTPURL = "http://bookie:8000"
+	Config.ZookeeperConfig.Hosts = []string{"zookeeper:2181"}
+	probes = oneShotProbes()
+	names := map[string]bool{}
+	for _, p := range probes {
+		names[p.name] = true
+	}
+	assert(t, len(probes) == 11, "expect the bookkeeper and zookeeper probes but got %d probes", len(probes))
+	assert(t, names["one-shot-bookkeeper-ledgers"] && names["one-shot-zookeeper"], "expect the bookkeeper and zookeeper probes in %v", names)
+}
+
+func TestIntervalDuration(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+
+	Config.MinIntervalSeconds = 0
+	assert(t, IntervalDuration(1, 60) == time.Second, "expect no floor if not specified")
+	assert(t, IntervalDuration(0, 60) == time.Minute, "expect the default interval")
+
+	Config.MinIntervalSeconds = 10
+	interval := IntervalDuration(1, 60)
+	assert(t, interval == 10*time.Second, "expect the interval raised to the floor but got %v", interval)
+	interval = IntervalDuration(30, 60)
+	assert(t, interval == 30*time.Second, "expect the interval above the floor kept but got %v", interval)
+
+	Config.PulsarTopicConfig = []TopicCfg{{TopicName: "persistent://tenant/ns/tiny", IntervalSeconds: 1}}
+	probes := topicProbes(false)
+	assert(t, probes[0].interval == 10*time.Second, "expect the topic interval raised to the floor but got %v", probes[0].interval)
+}
@@ -0,0 +1,106 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// validate the schema of the output topic messages of a function pipeline test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// schemaInfo is the schema returned by the admin REST API
+type schemaInfo struct {
+	Version int64  `json:"version"`
+	Type    string `json:"type"`
+	Data    string `json:"data"`
+}
+
+// GetSchema returns the schema of the version, the latest schema is returned without a version
+// a topic without a schema is a BYTES schema
+func (a restTopicAdmin) GetSchema(tenant, namespace, topic string, version []byte) (schemaInfo, error) {
+	route := "admin/v2/schemas/" + tenant + "/" + namespace + "/" + url.PathEscape(topic) + "/schema"
+	if len(version) == 8 {
+		route += "/" + strconv.FormatInt(int64(binary.BigEndian.Uint64(version)), 10)
+	}
+	resp, err := a.do(http.MethodGet, route)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return schemaInfo{}, err
+	} else if resp.StatusCode == http.StatusNotFound {
+		return schemaInfo{Type: "BYTES"}, nil
+	} else if resp.StatusCode != http.StatusOK {
+		return schemaInfo{}, fmt.Errorf("failed to get schema of topic %s, returns incorrect status code %d", topic, resp.StatusCode)
+	}
+
+	schema := schemaInfo{}
+	if err = json.NewDecoder(resp.Body).Decode(&schema); err != nil {
+		return schemaInfo{}, err
+	}
+	return schema, nil
+}
+
+// compareSchema compares the schema type and the optional schema definition, a json definition is compared semantically
+func compareSchema(expected SchemaCfg, actual schemaInfo) error {
+	if !strings.EqualFold(expected.Type, actual.Type) {
+		return fmt.Errorf("output schema type %s does not match the expected type %s", actual.Type, expected.Type)
+	}
+	if expected.Definition == "" {
+		return nil
+	}
+
+	var expectedDef, actualDef interface{}
+	if json.Unmarshal([]byte(expected.Definition), &expectedDef) == nil && json.Unmarshal([]byte(actual.Data), &actualDef) == nil {
+		if !reflect.DeepEqual(expectedDef, actualDef) {
+			return fmt.Errorf("output schema definition %s does not match the expected definition %s", actual.Data, expected.Definition)
+		}
+		return nil
+	}
+	if expected.Definition != actual.Data {
+		return fmt.Errorf("output schema definition %s does not match the expected definition %s", actual.Data, expected.Definition)
+	}
+	return nil
+}
+
+// validateOutputSchema validates the schema of the received output topic message against the expected schema
+func validateOutputSchema(topicCfg TopicCfg, tokenSupplier func() (string, error), schemaVersion []byte) error {
+	_, tenant, namespace, topic, err := util.TokenizeTopicFullName(topicCfg.OutputTopic)
+	if err != nil {
+		return fmt.Errorf("invalid output topic %s: %w", topicCfg.OutputTopic, err)
+	}
+	admin := restTopicAdmin{baseURL: adminURL(topicCfg), tokenSupplier: tokenSupplier}
+	schema, err := admin.GetSchema(tenant, namespace, topic, schemaVersion)
+	if err != nil {
+		return err
+	}
+	return compareSchema(topicCfg.OutputSchema, schema)
+}
@@ -0,0 +1,76 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareSchema(t *testing.T) {
+	errNil(t, compareSchema(SchemaCfg{Type: "STRING"}, schemaInfo{Type: "STRING"}))
+	errNil(t, compareSchema(SchemaCfg{Type: "json"}, schemaInfo{Type: "JSON", Data: `{"type":"record"}`}))
+
+	err := compareSchema(SchemaCfg{Type: "JSON"}, schemaInfo{Type: "BYTES"})
+	assert(t, err != nil && strings.Contains(err.Error(), "type BYTES"), "expect schema type mismatch but got %v", err)
+
+	// json definitions are compared regardless of the formatting
+	expected := SchemaCfg{Type: "AVRO", Definition: `{"type": "record", "name": "Out", "fields": [{"name": "id", "type": "long"}]}`}
+	errNil(t, compareSchema(expected, schemaInfo{Type: "AVRO", Data: `{"name":"Out","type":"record","fields":[{"type":"long","name":"id"}]}`}))
+	err = compareSchema(expected, schemaInfo{Type: "AVRO", Data: `{"name":"Out","type":"record","fields":[{"type":"string","name":"id"}]}`})
+	assert(t, err != nil && strings.Contains(err.Error(), "definition"), "expect schema definition mismatch but got %v", err)
+
+	// a non json definition is compared as is
+	errNil(t, compareSchema(SchemaCfg{Type: "PROTOBUF", Definition: "message Out {}"}, schemaInfo{Type: "PROTOBUF", Data: "message Out {}"}))
+	err = compareSchema(SchemaCfg{Type: "PROTOBUF", Definition: "message Out {}"}, schemaInfo{Type: "PROTOBUF", Data: "message In {}"})
+	assert(t, err != nil, "expect schema definition mismatch")
+}
+
+func TestValidateOutputSchema(t *testing.T) {
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		if strings.Contains(r.URL.Path, "no-schema") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(schemaInfo{Version: 2, Type: "JSON", Data: `{"type":"record"}`})
+	}))
+	defer server.Close()
+
+	topicCfg := TopicCfg{
+		AdminURL:     server.URL,
+		OutputTopic:  "persistent://tenant/ns/output",
+		OutputSchema: SchemaCfg{Type: "JSON", Definition: `{"type": "record"}`},
+	}
+	errNil(t, validateOutputSchema(topicCfg, nil, []byte{0, 0, 0, 0, 0, 0, 0, 2}))
+	assert(t, "/admin/v2/schemas/tenant/ns/output/schema/2" == requested, "unexpected schema request %s", requested)
+	errNil(t, validateOutputSchema(topicCfg, nil, nil))
+	assert(t, "/admin/v2/schemas/tenant/ns/output/schema" == requested, "expect the latest schema requested but got %s", requested)
+
+	topicCfg.OutputTopic = "persistent://tenant/ns/no-schema"
+	err := validateOutputSchema(topicCfg, nil, nil)
+	assert(t, err != nil && strings.Contains(err.Error(), "type BYTES"), "expect a topic without schema as BYTES but got %v", err)
+}
@@ -0,0 +1,52 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// bound the concurrent OpsGenie and PagerDuty API calls during a large correlated failure
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	// pagerSlots is shared by the OpsGenie and PagerDuty API calls, it is sized by maxPagerConcurrency
+	pagerSlots     inFlightLimiter
+	pagerSlotsSize int
+	pagerSlotsLock = &sync.Mutex{}
+)
+
+// acquirePagerSlot blocks until the pager API call is within the maxPagerConcurrency bound, the blocked calls proceed
+// in the order they are blocked. It returns the release of the slot.
+func acquirePagerSlot() func() {
+	limit := GetConfig().MaxPagerConcurrency
+	pagerSlotsLock.Lock()
+	if pagerSlotsSize != limit {
+		// the slots acquired before the resize are released to the previous limiter
+		pagerSlots, pagerSlotsSize = newInFlightLimiter(limit), limit
+	}
+	slots := pagerSlots
+	pagerSlotsLock.Unlock()
+
+	slots.acquire(context.Background())
+	return slots.release
+}
@@ -0,0 +1,90 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPagerConcurrency(t *testing.T) {
+	defer withoutAlertDestinations()()
+	var lock sync.Mutex
+	inFlight, maxInFlight, served := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		inFlight--
+		served++
+		lock.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer server.Close()
+	eventURL := pagerDutyEventURL
+	pagerDutyEventURL = server.URL
+	components := []string{}
+	for i := 0; i < 8; i++ {
+		components = append(components, "pager-concurrency-"+strconv.Itoa(i))
+	}
+	defer func() {
+		pagerDutyEventURL = eventURL
+		incidentsLock.Lock()
+		for _, component := range components {
+			delete(incidents, component)
+		}
+		incidentsLock.Unlock()
+	}()
+
+	createAll := func() {
+		var wg sync.WaitGroup
+		for _, component := range components {
+			wg.Add(1)
+			go func(component string) {
+				defer wg.Done()
+				errNil(t, CreatePDIncident(component, component, "cluster down", "desc", "routing-key"))
+			}(component)
+		}
+		wg.Wait()
+	}
+
+	Config.MaxPagerConcurrency = 2
+	createAll()
+	assert(t, served == 8, "expect every incident created but got %d", served)
+	assert(t, maxInFlight <= 2, "expect at most 2 concurrent pager calls but got %d", maxInFlight)
+
+	// unbounded if not specified
+	Config.MaxPagerConcurrency = 0
+	maxInFlight = 0
+	createAll()
+	assert(t, maxInFlight > 2, "expect unbounded concurrent pager calls but got %d", maxInFlight)
+}
@@ -22,11 +22,16 @@
 package cfg
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	pd "github.com/PagerDuty/go-pagerduty"
 	log "github.com/apex/log"
+	"github.com/hashicorp/go-retryablehttp"
 )
 
 const (
@@ -35,14 +40,28 @@ const (
 	resolve     = "resolve"
 )
 
+// pagerDutyEventURL is the PagerDuty Events API v2 endpoint
+var pagerDutyEventURL = "https://events.pagerduty.com/v2/enqueue"
+
 // CreatePDIncident creates PagerDuty incident
-func CreatePDIncident(component, alias, msg, pdIntegrationKey string) error {
+func CreatePDIncident(component, alias, msg, desc, pdIntegrationKey string) error {
 	payload := pd.V2Payload{
 		Summary:   component + ":" + msg,
 		Source:    "pulsar-heartbeat",
 		Severity:  "critical",
 		Component: component,
 	}
+	details := map[string]string{}
+	if desc != "" {
+		details["description"] = desc
+	}
+	if owner := componentOwner(component); owner != (incidentOwner{}) {
+		payload.Group = owner.service
+		details["ownerTeam"], details["service"] = owner.team, owner.service
+	}
+	if len(details) > 0 {
+		payload.Details = details
+	}
 	pdResp, err := PdV2Event(trigger, alias, pdIntegrationKey, &payload)
 	if err != nil {
 		return err
@@ -50,15 +69,11 @@ func CreatePDIncident(component, alias, msg, pdIntegrationKey string) error {
 	if pdResp == nil {
 		return errors.New("empty pagerduty event update response")
 	}
-	incident := incidentRecord{
-		requestID: pdResp.DedupKey, // use dedupKey as a place holder
-		alertID:   alias,
-		createdAt: time.Now(),
-	}
-
-	incidentsLock.Lock()
-	defer incidentsLock.Unlock()
-	incidents[component] = incident
+	log.Infof("PagerDuty incident of %s triggered with dedup key %s", component, alias)
+	// the OpsGenie alert of the same incident is kept in the record
+	recordIncident(component, func(record *incidentRecord) {
+		record.alias = alias
+	})
 	return nil
 }
 
@@ -85,7 +100,7 @@ func PdV2Event(action, dedupKey, routingKey string, payload *pd.V2Payload) (*pd.
 		Action:     action,
 		Payload:    payload,
 	}
-	resp, err := pd.ManageEvent(v2Event)
+	resp, err := sendPdV2Event(v2Event)
 	if err != nil {
 		log.Errorf("failed V2Event to PagerDuty error - %v", err)
 	} else {
@@ -93,3 +108,43 @@ func PdV2Event(action, dedupKey, routingKey string, payload *pd.V2Payload) (*pd.
 	}
 	return resp, err
 }
+
+// sendPdV2Event sends the event to PagerDuty, the rate limited request is retried after the Retry-After wait
+func sendPdV2Event(event pd.V2Event) (*pd.V2EventResponse, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newRetryableClient()
+	client.HTTPClient.Timeout = time.Duration(5) * time.Second
+	client.RetryWaitMin = 4 * time.Second
+	client.RetryWaitMax = 64 * time.Second
+	client.RetryMax = 2
+	client.Backoff = rateLimitBackoff
+
+	req, err := retryablehttp.NewRequest(http.MethodPost, pagerDutyEventURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "pulsar-heartbeat")
+	req.Header.Set("Content-Type", "application/json")
+
+	release := acquirePagerSlot()
+	resp, err := client.Do(req)
+	release()
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusAccepted {
+		return nil, fmt.Errorf("PagerDuty event returns incorrect status code %d", resp.StatusCode)
+	}
+
+	eventResp := &pd.V2EventResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(eventResp); err != nil {
+		return nil, err
+	}
+	return eventResp, nil
+}
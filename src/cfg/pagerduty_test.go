@@ -0,0 +1,84 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pd "github.com/PagerDuty/go-pagerduty"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Now()
+	wait, ok := retryAfter("3", now)
+	assert(t, ok && wait == 3*time.Second, "expect 3 seconds retry after but got %v", wait)
+	wait, ok = retryAfter(now.Add(10*time.Second).UTC().Format(http.TimeFormat), now)
+	assert(t, ok && wait > 8*time.Second && wait <= 10*time.Second, "expect about 10 seconds retry after but got %v", wait)
+	_, ok = retryAfter("soon", now)
+	assert(t, !ok, "expect invalid retry after")
+
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"120"}},
+		Request:    &http.Request{URL: &url.URL{Host: "api.opsgenie.com"}},
+	}
+	wait = rateLimitBackoff(time.Second, 64*time.Second, 0, resp)
+	assert(t, wait == 64*time.Second, "expect the retry after capped by the max wait but got %v", wait)
+	resp.StatusCode = http.StatusInternalServerError
+	wait = rateLimitBackoff(time.Second, 64*time.Second, 1, resp)
+	assert(t, wait == 2*time.Second, "expect the exponential backoff on a non rate limited error but got %v", wait)
+}
+
+func TestPagerDutyRateLimit(t *testing.T) {
+	var attempts int32
+	var firstAttempt, secondAttempt time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			firstAttempt = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		secondAttempt = time.Now()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"success","dedup_key":"pd-dedup-key"}`))
+	}))
+	defer server.Close()
+
+	eventURL := pagerDutyEventURL
+	pagerDutyEventURL = server.URL
+	defer func() {
+		pagerDutyEventURL = eventURL
+	}()
+
+	resp, err := PdV2Event(trigger, "pd-dedup-key", "routing-key", &pd.V2Payload{Summary: "rate limit test"})
+	errNil(t, err)
+	assert(t, resp.DedupKey == "pd-dedup-key", "unexpected dedup key %s", resp.DedupKey)
+	assert(t, atomic.LoadInt32(&attempts) == 2, "expect a retry after the rate limited request")
+	wait := secondAttempt.Sub(firstAttempt)
+	assert(t, wait >= time.Second && wait < 4*time.Second, "expect the 1 second Retry-After honored but waited %v", wait)
+}
@@ -22,6 +22,8 @@
 package cfg
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"math/rand"
@@ -38,6 +40,10 @@ import (
 const (
 	// PrefixDelimiter for message prefix
 	PrefixDelimiter = "-"
+
+	// the payload encodings of the message body after the prefix, the default is raw bytes
+	base64Encoding = "base64"
+	hexEncoding    = "hex"
 )
 
 // Payload defines the payload size
@@ -125,6 +131,12 @@ func NumOfBytes(size string) int {
 // AllMsgPayloads generates a series of payloads based on
 // specified payload sizes or the number of messages
 func AllMsgPayloads(prefix string, payloadSizes []string, numOfMsg int) ([][]byte, int) {
+	return AllEncodedMsgPayloads(prefix, payloadSizes, numOfMsg, "")
+}
+
+// AllEncodedMsgPayloads generates the payloads of AllMsgPayloads with the message body after the prefix
+// in the payload encoding, the encoded body is no longer than the body of the specified payload size
+func AllEncodedMsgPayloads(prefix string, payloadSizes []string, numOfMsg int, encoding string) ([][]byte, int) {
 	maxPayloadSize := len(prefix)
 	actualNumOfMsg := 1 //default minimun one message
 	specifiedSizes := len(payloadSizes)
@@ -153,6 +165,7 @@ func AllMsgPayloads(prefix string, payloadSizes []string, numOfMsg int) ([][]byt
 		pre := fmt.Sprintf("%s-%d-", prefix, i)
 		size := 0
 		payloads[i], size = GenPayload(pre, payloadSizes[specifiedIndex])
+		payloads[i] = append([]byte(pre), encodePayloadBody(payloads[i][len(pre):], encoding)...)
 		maxPayloadSize = int(math.Max(float64(maxPayloadSize), float64(size)))
 
 	}
@@ -160,6 +173,75 @@ func AllMsgPayloads(prefix string, payloadSizes []string, numOfMsg int) ([][]byt
 	return payloads, maxPayloadSize
 }
 
+// encodePayloadBody encodes the leading bytes of the body so that the encoded body fits in the body size
+func encodePayloadBody(body []byte, encoding string) []byte {
+	switch strings.ToLower(encoding) {
+	case base64Encoding:
+		return []byte(base64.StdEncoding.EncodeToString(body[:len(body)/4*3]))
+	case hexEncoding:
+		return []byte(hex.EncodeToString(body[:len(body)/2]))
+	default:
+		return body
+	}
+}
+
+// verifyPayloadEncoding returns an error if the message body after the prefix is not exactly in the payload encoding
+func verifyPayloadEncoding(payload, encoding string) error {
+	parts := strings.SplitN(payload, PrefixDelimiter, 3)
+	if len(parts) < 3 {
+		return nil
+	}
+	body := parts[2]
+	switch strings.ToLower(encoding) {
+	case base64Encoding:
+		if decoded, err := base64.StdEncoding.DecodeString(body); err != nil || base64.StdEncoding.EncodeToString(decoded) != body {
+			return fmt.Errorf("message body of %d bytes is not preserved in the base64 encoding", len(body))
+		}
+	case hexEncoding:
+		if decoded, err := hex.DecodeString(body); err != nil || hex.EncodeToString(decoded) != body {
+			return fmt.Errorf("message body of %d bytes is not preserved in the hex encoding", len(body))
+		}
+	}
+	return nil
+}
+
+// SamplePayloadSizes returns a list of payload sizes for the number of messages,
+// each size is sampled from the distribution by its weight
+func SamplePayloadSizes(distribution []PayloadWeightCfg, numOfMsg int) []string {
+	return samplePayloadSizes(distribution, numOfMsg, rand.New(rand.NewSource(time.Now().UnixNano())))
+}
+
+func samplePayloadSizes(distribution []PayloadWeightCfg, numOfMsg int, rnd *rand.Rand) []string {
+	totalWeight := 0.0
+	for _, w := range distribution {
+		if w.Weight > 0 {
+			totalWeight += w.Weight
+		}
+	}
+	if totalWeight == 0 {
+		return []string{}
+	}
+	if numOfMsg < 1 {
+		numOfMsg = 1
+	}
+
+	sizes := make([]string, numOfMsg)
+	for i := range sizes {
+		sample := rnd.Float64() * totalWeight
+		for _, w := range distribution {
+			if w.Weight <= 0 {
+				continue
+			}
+			sizes[i] = strconv.Itoa(w.SizeBytes)
+			if sample < w.Weight {
+				break
+			}
+			sample -= w.Weight
+		}
+	}
+	return sizes
+}
+
 // GetMessageID returns the message index by parsing the template payload string with a prefix.
 func GetMessageID(prefix, str string) int {
 	parts := strings.Split(string(str), PrefixDelimiter)
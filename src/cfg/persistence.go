@@ -0,0 +1,136 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify the latency test message is persisted in the managed ledger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// internalStats is the subset of the topic internal stats of the managed ledger
+type internalStats struct {
+	State              string `json:"state"`
+	LastConfirmedEntry string `json:"lastConfirmedEntry"`
+}
+
+// failedLedgerStates are the managed ledger states that can no longer persist writes
+var failedLedgerStates = map[string]bool{
+	"WriteFailed": true,
+	"Fenced":      true,
+	"Closed":      true,
+}
+
+// InternalStats gets the topic internal stats
+func (a restTopicAdmin) InternalStats(topicFn string) (internalStats, error) {
+	route, err := util.TopicFnToURL(topicFn)
+	if err != nil {
+		return internalStats{}, err
+	}
+	resp, err := a.do(http.MethodGet, "admin/v2/"+route+"/internalStats")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return internalStats{}, err
+	} else if resp.StatusCode != http.StatusOK {
+		return internalStats{}, fmt.Errorf("failed to get the internal stats of topic %s, returns incorrect status code %d", topicFn, resp.StatusCode)
+	}
+
+	var stats internalStats
+	if err = json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return internalStats{}, err
+	}
+	return stats, nil
+}
+
+// parsePosition parses a managed ledger position in the format of ledgerId:entryId
+func parsePosition(position string) (int64, int64, error) {
+	parts := strings.Split(position, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid position %q", position)
+	}
+	ledgerID, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid ledger id in position %q", position)
+	}
+	entryID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid entry id in position %q", position)
+	}
+	return ledgerID, entryID, nil
+}
+
+// verifyPersistence returns an error if the message at ledgerID:entryID is not confirmed durable by the internal stats
+func verifyPersistence(ledgerID, entryID int64, stats internalStats) error {
+	if ledgerID < 0 || entryID < 0 {
+		return fmt.Errorf("message id %d:%d is not a persisted position", ledgerID, entryID)
+	}
+	if failedLedgerStates[stats.State] {
+		return fmt.Errorf("managed ledger is in %s state, writes may not meet the write quorum", stats.State)
+	}
+	confirmedLedger, confirmedEntry, err := parsePosition(stats.LastConfirmedEntry)
+	if err != nil {
+		return err
+	}
+	if confirmedLedger < ledgerID || (confirmedLedger == ledgerID && confirmedEntry < entryID) {
+		return fmt.Errorf("last confirmed entry %s is behind the message id %d:%d", stats.LastConfirmedEntry, ledgerID, entryID)
+	}
+	return nil
+}
+
+// TestPersistence verifies and reports the last latency test message is persisted
+func TestPersistence(clusterName string, tokenSupplier func() (string, error), topicCfg TopicCfg, messageID pulsar.MessageID) {
+	component := clusterName + "-persistence"
+	if messageID == nil {
+		log.Errorf("persistence check is skipped on %s, no message id is returned", topicCfg.TopicName)
+		return
+	}
+	baseURL := adminURL(topicCfg)
+	if _, err := url.ParseRequestURI(baseURL); err != nil {
+		log.Errorf("persistence check is skipped, invalid admin url %s error: %v", baseURL, err)
+		return
+	}
+
+	admin := restTopicAdmin{baseURL: baseURL, tokenSupplier: tokenSupplier}
+	stats, err := admin.InternalStats(topicCfg.TopicName)
+	if err == nil {
+		err = verifyPersistence(messageID.LedgerID(), messageID.EntryID(), stats)
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("%s persistence test failed on %s, error: %v", component, topicCfg.TopicName, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "persistence test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	log.Infof("%s persistence test has successfully passed on %s", component, topicCfg.TopicName)
+	ClearIncident(component)
+}
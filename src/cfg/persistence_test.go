@@ -0,0 +1,68 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyPersistence(t *testing.T) {
+	errNil(t, verifyPersistence(12, 5, internalStats{State: "LedgerOpened", LastConfirmedEntry: "12:5"}))
+	errNil(t, verifyPersistence(12, 5, internalStats{State: "LedgerOpened", LastConfirmedEntry: "13:0"}))
+
+	err := verifyPersistence(12, 5, internalStats{State: "LedgerOpened", LastConfirmedEntry: "12:4"})
+	assert(t, err != nil, "expect an error if the last confirmed entry is behind the message")
+
+	err = verifyPersistence(12, 5, internalStats{State: "LedgerOpened", LastConfirmedEntry: "11:9"})
+	assert(t, err != nil, "expect an error if the last confirmed ledger is behind the message")
+
+	err = verifyPersistence(12, 5, internalStats{State: "WriteFailed", LastConfirmedEntry: "12:5"})
+	assert(t, err != nil, "expect an error on a failed managed ledger")
+
+	err = verifyPersistence(-1, -1, internalStats{State: "LedgerOpened", LastConfirmedEntry: "12:5"})
+	assert(t, err != nil, "expect an error on a non persisted message id")
+
+	err = verifyPersistence(12, 5, internalStats{State: "LedgerOpened", LastConfirmedEntry: "12"})
+	assert(t, err != nil, "expect an error on an invalid last confirmed entry")
+}
+
+func TestInternalStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/v2/persistent/tenant/ns/orders/internalStats" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"entriesAddedCounter": 20, "state": "LedgerOpened", "lastConfirmedEntry": "7:19"}`))
+	}))
+	defer server.Close()
+	admin := restTopicAdmin{baseURL: server.URL}
+
+	stats, err := admin.InternalStats("persistent://tenant/ns/orders")
+	errNil(t, err)
+	assert(t, stats.State == "LedgerOpened", "expect LedgerOpened state but got %s", stats.State)
+	assert(t, stats.LastConfirmedEntry == "7:19", "expect 7:19 last confirmed entry but got %s", stats.LastConfirmedEntry)
+
+	_, err = admin.InternalStats("persistent://tenant/ns/unknown")
+	assert(t, err != nil, "expect an error on a missing topic")
+}
@@ -0,0 +1,117 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// probe the binary protocol and the HTTP lookup planes independently
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+const (
+	binaryPlane = "binary"
+	httpPlane   = "http"
+)
+
+// lookupData is the subset of the HTTP lookup response
+type lookupData struct {
+	BrokerURL    string `json:"brokerUrl"`
+	BrokerURLTLS string `json:"brokerUrlTls"`
+}
+
+// LookupTopic looks up the owner broker of the topic over HTTP
+func (a restTopicAdmin) LookupTopic(topicFn string) (string, error) {
+	route, err := util.TopicFnToURL(topicFn)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.do(http.MethodGet, "lookup/v2/topic/"+route)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to lookup topic %s, returns incorrect status code %d", topicFn, resp.StatusCode)
+	}
+
+	var lookup lookupData
+	if err = json.NewDecoder(resp.Body).Decode(&lookup); err != nil {
+		return "", err
+	}
+	broker := util.FirstNonEmptyString(lookup.BrokerURLTLS, lookup.BrokerURL)
+	if broker == "" {
+		return "", fmt.Errorf("no broker is returned by the lookup of topic %s", topicFn)
+	}
+	return broker, nil
+}
+
+// probeBinaryPlane looks up the topic partitions over the binary protocol
+func probeBinaryPlane(topicCfg TopicCfg, tokenSupplier func() (string, error)) error {
+	client, err := GetPulsarClientWithTrustStore(topicCfg.PulsarURL, tokenSupplier, topicCfg.TrustStore)
+	if err != nil {
+		return err
+	}
+	if _, err = client.TopicPartitions(topicCfg.TopicName); err != nil {
+		// the connections of the cached client could be stale
+		if connectionErrorClass(classifyError(err)) {
+			recyclePulsarClient(topicCfg.PulsarURL, client)
+		}
+		return err
+	}
+	return nil
+}
+
+// reportPlane reports the health of the plane as an independent component
+func reportPlane(clusterName, plane string, err error, alertPolicy *AlertPolicyCfg) {
+	component := clusterName + "-" + plane + "-plane"
+	PromPlaneHealth(clusterName, plane, err == nil)
+	if err != nil {
+		errMsg := fmt.Sprintf("cluster %s %s plane topic lookup failed, error: %v", clusterName, plane, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, clusterName, plane+" plane lookup failure", errMsg, alertPolicy)
+		return
+	}
+	log.Infof("cluster %s %s plane topic lookup succeeded", clusterName, plane)
+	ClearIncident(component)
+}
+
+// TestPlanes reports the health of the binary protocol and the HTTP lookup planes of the cluster separately
+func TestPlanes(topicCfg TopicCfg) {
+	pulsarURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
+	if err != nil {
+		panic(err) //panic because this is a showstopper
+	}
+	clusterName := pulsarURL.Hostname()
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	reportPlane(clusterName, binaryPlane, probeBinaryPlane(topicCfg, tokenSupplier), &topicCfg.AlertPolicy)
+
+	_, err = restTopicAdmin{baseURL: adminURL(topicCfg), tokenSupplier: tokenSupplier}.LookupTopic(topicCfg.TopicName)
+	reportPlane(clusterName, httpPlane, err, &topicCfg.AlertPolicy)
+}
@@ -0,0 +1,99 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// planeClient is a fake Pulsar client of the binary plane lookup
+type planeClient struct {
+	pulsar.Client
+	err error
+}
+
+func (c *planeClient) TopicPartitions(topic string) ([]string, error) {
+	return []string{topic}, c.err
+}
+
+func (c *planeClient) Close() {}
+
+func TestPlanesReportedIndependently(t *testing.T) {
+	defer withoutAlertDestinations()()
+	httpUp := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert(t, r.URL.Path == "/lookup/v2/topic/persistent/tenant/ns/planes", "unexpected path %s", r.URL.Path)
+		if !httpUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"brokerUrl": "pulsar://broker-1:6650", "httpUrl": "http://broker-1:8080"}`))
+	}))
+	defer server.Close()
+	topicCfg := TopicCfg{
+		PulsarURL:   "pulsar://planes-test:6650",
+		Token:       "planes-token",
+		AdminURL:    server.URL,
+		TopicName:   "persistent://tenant/ns/planes",
+		AlertPolicy: AlertPolicyCfg{Ceiling: 1},
+	}
+	tracked := func(component string) bool {
+		incidentTrackersLock.RLock()
+		defer incidentTrackersLock.RUnlock()
+		_, ok := incidentTrackers[component]
+		return ok
+	}
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, "planes-test-binary-plane")
+		delete(incidentTrackers, "planes-test-http-plane")
+		incidentTrackersLock.Unlock()
+	}()
+	up := func(plane string) float64 {
+		return testutil.ToFloat64(planeHealth.WithLabelValues("planes-test", plane))
+	}
+	setClient := func(err error) {
+		clientsLock.Lock()
+		clients[topicCfg.PulsarURL] = &planeClient{err: err}
+		clientsLock.Unlock()
+	}
+	defer evictPulsarClient(topicCfg.PulsarURL)
+
+	// the binary plane is broken while the http plane is healthy
+	setClient(errors.New("connection refused"))
+	TestPlanes(topicCfg)
+	assert(t, up(binaryPlane) == 0 && up(httpPlane) == 1, "expect only the binary plane down")
+	assert(t, tracked("planes-test-binary-plane") && !tracked("planes-test-http-plane"), "expect only the binary plane incident")
+
+	// the http plane is broken while the binary plane is healthy
+	setClient(nil)
+	httpUp = false
+	TestPlanes(topicCfg)
+	assert(t, up(binaryPlane) == 1 && up(httpPlane) == 0, "expect only the http plane down")
+	assert(t, tracked("planes-test-http-plane"), "expect the http plane incident")
+}
@@ -0,0 +1,143 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// build the probe topic names under a dedicated tenant and namespace of every cluster, and ensure the namespace exists
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// defaultProbeTopic is the local name of the probe topic if the topic name is not specified
+const defaultProbeTopic = "heartbeat-probe"
+
+// namespaceAdmin verifies and creates the namespaces
+type namespaceAdmin interface {
+	NamespaceExists(tenant, namespace string) (bool, error)
+	CreateNamespace(tenant, namespace string) error
+}
+
+var (
+	// key is the admin url and the tenant/namespace ensured to exist
+	ensuredNamespaces     = make(map[string]bool)
+	ensuredNamespacesLock = &sync.Mutex{}
+)
+
+// NamespaceExists checks the namespace in the list of the tenant's namespaces
+func (a restTopicAdmin) NamespaceExists(tenant, namespace string) (bool, error) {
+	resp, err := a.do(http.MethodGet, "admin/v2/namespaces/"+tenant)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return false, err
+	} else if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to list namespaces of tenant %s, returns incorrect status code %d", tenant, resp.StatusCode)
+	}
+
+	var namespaces []string
+	if err = json.NewDecoder(resp.Body).Decode(&namespaces); err != nil {
+		return false, err
+	}
+	return util.StrContains(namespaces, tenant+"/"+namespace), nil
+}
+
+// CreateNamespace creates the namespace, an existing namespace is not an error
+func (a restTopicAdmin) CreateNamespace(tenant, namespace string) error {
+	resp, err := a.do(http.MethodPut, "admin/v2/namespaces/"+tenant+"/"+namespace)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("failed to create namespace %s/%s, returns incorrect status code %d", tenant, namespace, resp.StatusCode)
+	}
+	return nil
+}
+
+// probeTopicName returns the topic name under the probe tenant and namespace, the local name of the topic name is kept,
+// the topic name is unchanged if the probe tenant or namespace is not specified
+func probeTopicName(topicCfg TopicCfg) string {
+	if topicCfg.ProbeTenant == "" || topicCfg.ProbeNamespace == "" {
+		return topicCfg.TopicName
+	}
+	domain := "persistent"
+	if strings.HasPrefix(topicCfg.TopicName, "non-persistent://") {
+		domain = "non-persistent"
+	}
+	local := topicCfg.TopicName[strings.LastIndex(topicCfg.TopicName, "/")+1:]
+	return fmt.Sprintf("%s://%s/%s/%s", domain, topicCfg.ProbeTenant, topicCfg.ProbeNamespace, util.FirstNonEmptyString(local, defaultProbeTopic))
+}
+
+// setProbeTopics builds the topic names of the topics with the probe tenant and namespace
+func (c *Configuration) setProbeTopics() {
+	for i := range c.PulsarTopicConfig {
+		c.PulsarTopicConfig[i].TopicName = probeTopicName(c.PulsarTopicConfig[i])
+	}
+}
+
+// ensureNamespace verifies the namespace exists, it is created if it does not exist and the creation is required
+func ensureNamespace(admin namespaceAdmin, tenant, namespace string, create bool) error {
+	exists, err := admin.NamespaceExists(tenant, namespace)
+	if err != nil {
+		return err
+	} else if exists {
+		return nil
+	} else if !create {
+		return fmt.Errorf("probe namespace %s/%s does not exist", tenant, namespace)
+	}
+	log.Infof("create probe namespace %s/%s", tenant, namespace)
+	return admin.CreateNamespace(tenant, namespace)
+}
+
+// EnsureProbeNamespace verifies the probe namespace of the topic once on the admin url until it succeeds
+func EnsureProbeNamespace(clusterName string, tokenSupplier func() (string, error), topicCfg TopicCfg) {
+	baseURL := adminURL(topicCfg)
+	key := baseURL + "/" + topicCfg.ProbeTenant + "/" + topicCfg.ProbeNamespace
+	ensuredNamespacesLock.Lock()
+	ensured := ensuredNamespaces[key]
+	ensuredNamespacesLock.Unlock()
+	if ensured {
+		return
+	}
+
+	component := clusterName + "-probe-namespace"
+	admin := restTopicAdmin{baseURL: baseURL, tokenSupplier: tokenSupplier}
+	if err := ensureNamespace(admin, topicCfg.ProbeTenant, topicCfg.ProbeNamespace, topicCfg.CreateProbeNamespace); err != nil {
+		errMsg := fmt.Sprintf("cluster %s probe namespace test failed, error: %v", clusterName, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, clusterName, "probe namespace failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	ensuredNamespacesLock.Lock()
+	ensuredNamespaces[key] = true
+	ensuredNamespacesLock.Unlock()
+	ClearIncident(component)
+}
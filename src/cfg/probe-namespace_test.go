@@ -0,0 +1,118 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type fakeNamespaceAdmin struct {
+	exists  bool
+	err     error
+	created []string
+}
+
+func (a *fakeNamespaceAdmin) NamespaceExists(tenant, namespace string) (bool, error) {
+	return a.exists, a.err
+}
+
+func (a *fakeNamespaceAdmin) CreateNamespace(tenant, namespace string) error {
+	a.created = append(a.created, tenant+"/"+namespace)
+	return nil
+}
+
+func TestProbeTopicName(t *testing.T) {
+	topicCfg := TopicCfg{TopicName: "persistent://production/orders/latency-topic"}
+	assert(t, probeTopicName(topicCfg) == topicCfg.TopicName, "expect the topic name unchanged without the probe namespace")
+
+	topicCfg.ProbeTenant, topicCfg.ProbeNamespace = "heartbeat", "cluster1"
+	name := probeTopicName(topicCfg)
+	assert(t, name == "persistent://heartbeat/cluster1/latency-topic", "expect the topic under the probe namespace but got %s", name)
+
+	topicCfg.TopicName = "non-persistent://production/orders/latency-topic"
+	name = probeTopicName(topicCfg)
+	assert(t, name == "non-persistent://heartbeat/cluster1/latency-topic", "expect the non-persistent domain kept but got %s", name)
+
+	topicCfg.TopicName = "local-probe"
+	name = probeTopicName(topicCfg)
+	assert(t, name == "persistent://heartbeat/cluster1/local-probe", "expect the local name under the probe namespace but got %s", name)
+
+	topicCfg.TopicName = ""
+	name = probeTopicName(topicCfg)
+	assert(t, name == "persistent://heartbeat/cluster1/heartbeat-probe", "expect the default probe topic but got %s", name)
+
+	// the topic name is built once again on every configuration initialization
+	c := Configuration{Name: "probe-namespace", PulsarTopicConfig: []TopicCfg{topicCfg}}
+	c.Init()
+	c.Init()
+	assert(t, c.PulsarTopicConfig[0].TopicName == "persistent://heartbeat/cluster1/heartbeat-probe", "unexpected topic %s", c.PulsarTopicConfig[0].TopicName)
+}
+
+func TestEnsureNamespace(t *testing.T) {
+	admin := &fakeNamespaceAdmin{exists: true}
+	errNil(t, ensureNamespace(admin, "heartbeat", "cluster1", true))
+	assert(t, len(admin.created) == 0, "expect an existing namespace not created")
+
+	admin = &fakeNamespaceAdmin{}
+	assert(t, ensureNamespace(admin, "heartbeat", "cluster1", false) != nil, "expect a missing namespace error without the creation")
+	assert(t, len(admin.created) == 0, "expect the namespace not created without the creation")
+	errNil(t, ensureNamespace(admin, "heartbeat", "cluster1", true))
+	assert(t, len(admin.created) == 1 && admin.created[0] == "heartbeat/cluster1", "expect the namespace created but got %v", admin.created)
+
+	admin = &fakeNamespaceAdmin{err: errors.New("unauthorized")}
+	assert(t, ensureNamespace(admin, "heartbeat", "cluster1", true) != nil, "expect the admin error")
+	assert(t, len(admin.created) == 0, "expect no creation on the admin error")
+}
+
+func TestEnsureProbeNamespace(t *testing.T) {
+	defer withoutAlertDestinations()()
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			w.Write([]byte(`["heartbeat/other"]`))
+		case http.MethodPut:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	topicCfg := TopicCfg{AdminURL: server.URL, Token: "token", ProbeTenant: "heartbeat", ProbeNamespace: "cluster1", CreateProbeNamespace: true}
+	key := server.URL + "/heartbeat/cluster1"
+	defer func() {
+		ensuredNamespacesLock.Lock()
+		delete(ensuredNamespaces, key)
+		ensuredNamespacesLock.Unlock()
+	}()
+
+	tokenSupplier := func() (string, error) { return topicCfg.Token, nil }
+	EnsureProbeNamespace("probe-namespace-cluster", tokenSupplier, topicCfg)
+	assert(t, len(requests) == 2 && requests[0] == "GET /admin/v2/namespaces/heartbeat" && requests[1] == "PUT /admin/v2/namespaces/heartbeat/cluster1",
+		"expect the namespace verified and created but got %v", requests)
+
+	// the namespace is ensured once
+	EnsureProbeNamespace("probe-namespace-cluster", tokenSupplier, topicCfg)
+	assert(t, len(requests) == 2, "expect no more admin request but got %v", requests)
+}
@@ -0,0 +1,123 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// trace the probes with OpenTelemetry spans exported over OTLP
+
+import (
+	"context"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OtelTracingCfg configures the OpenTelemetry tracing of the probes
+type OtelTracingCfg struct {
+	Enabled bool `json:"enabled"`
+	// Endpoint is the host:port of the OTLP HTTP collector, OTEL_EXPORTER_OTLP_ENDPOINT is used if not specified
+	Endpoint string `json:"endpoint"`
+	// Insecure exports the spans over plain HTTP
+	Insecure bool `json:"insecure"`
+	// SampleRatio is the fraction of the probe runs traced, every run is traced if not specified
+	SampleRatio float64 `json:"sampleRatio"`
+}
+
+const tracerName = "github.com/datastax/pulsar-heartbeat"
+
+// tracer creates the probe spans, it is a no-op tracer unless tracing is enabled
+var tracer = trace.NewNoopTracerProvider().Tracer(tracerName)
+
+// InitTracing exports the probe spans to the OTLP collector if tracing is enabled,
+// it returns the function to flush and stop the export
+func InitTracing() func() {
+	tracingCfg := GetConfig().OtelTracing
+	if !tracingCfg.Enabled {
+		return func() {}
+	}
+	opts := []otlptracehttp.Option{}
+	if tracingCfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(tracingCfg.Endpoint))
+	}
+	if tracingCfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		log.Errorf("probe tracing is disabled, failed to create the OTLP exporter, error %v", err)
+		return func() {}
+	}
+	provider := newTracerProvider(tracingCfg, sdktrace.WithBatcher(exporter))
+	log.Infof("trace probes to OTLP endpoint %s with the sample ratio %v", tracingCfg.Endpoint, sampleRatio(tracingCfg))
+	return func() {
+		if err := provider.Shutdown(context.Background()); err != nil {
+			log.Errorf("failed to flush the probe spans, error %v", err)
+		}
+	}
+}
+
+// newTracerProvider creates the tracer provider of the sampling configuration and sets the probe tracer
+func newTracerProvider(tracingCfg OtelTracingCfg, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append(opts,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio(tracingCfg)))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(util.FirstNonEmptyString(GetConfig().Name, "pulsar-heartbeat")))),
+	)
+	provider := sdktrace.NewTracerProvider(opts...)
+	tracer = provider.Tracer(tracerName)
+	return provider
+}
+
+// sampleRatio is the ratio of the traced probes, every probe is traced by default
+func sampleRatio(tracingCfg OtelTracingCfg) float64 {
+	if tracingCfg.SampleRatio <= 0 {
+		return 1
+	}
+	return tracingCfg.SampleRatio
+}
+
+// startProbeSpan starts the root span of a probe run
+func startProbeSpan(probe, name string) (context.Context, trace.Span) {
+	return tracer.Start(context.Background(), "probe "+probe, trace.WithAttributes(attribute.String("probe.name", name)))
+}
+
+// startSpan starts a child span of the probe span in the context, a nil context starts a root span
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records the error on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,117 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// withInMemoryTracing records the probe spans in memory until the returned function restores the tracer
+func withInMemoryTracing(tracingCfg OtelTracingCfg) (*tracetest.InMemoryExporter, func()) {
+	saved := tracer
+	exporter := tracetest.NewInMemoryExporter()
+	newTracerProvider(tracingCfg, sdktrace.WithSyncer(exporter))
+	return exporter, func() { tracer = saved }
+}
+
+// spansByName indexes the ended spans by their names
+func spansByName(exporter *tracetest.InMemoryExporter) map[string]tracetest.SpanStub {
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	return spans
+}
+
+func TestTopicProbeSpans(t *testing.T) {
+	defer withoutAlertDestinations()()
+	exporter, restore := withInMemoryTracing(OtelTracingCfg{Enabled: true})
+	defer restore()
+	topicCfg := TopicCfg{
+		PulsarURL:     "pulsar://tracing-test:6650",
+		TopicName:     "persistent://tenant/ns/tracing-test",
+		NumOfMessages: 2,
+	}
+	newFakePulsarClient(t, topicCfg.PulsarURL, time.Millisecond)
+
+	testTopic(topicCfg, false, true).Wait()
+	spans := spansByName(exporter)
+	root, ok := spans["probe topic"]
+	assert(t, ok, "expect the root probe span")
+	assert(t, !root.Parent.IsValid(), "expect the probe span is the root")
+	pubSub, ok := spans["PubSubLatency"]
+	assert(t, ok, "expect the PubSubLatency span")
+	assert(t, pubSub.Parent.SpanID() == root.SpanContext.SpanID(), "expect PubSubLatency a child of the probe span")
+	for _, name := range []string{"produce", "consume"} {
+		span, ok := spans[name]
+		assert(t, ok, "expect the %s span", name)
+		assert(t, span.Parent.SpanID() == pubSub.SpanContext.SpanID(), "expect the %s span a child of PubSubLatency", name)
+	}
+}
+
+func TestAdminProbeSpans(t *testing.T) {
+	defer withoutAlertDestinations()()
+	exporter, restore := withInMemoryTracing(OtelTracingCfg{Enabled: true})
+	defer restore()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	Config.PulsarAdminConfig.Clusters = []OpsClusterCfg{{Name: "tracing-cluster", URL: server.URL}}
+
+	PulsarTenants()
+	spans := spansByName(exporter)
+	root, ok := spans["probe admin"]
+	assert(t, ok, "expect the root probe span")
+	tenant, ok := spans["PulsarAdminTenant"]
+	assert(t, ok, "expect the PulsarAdminTenant span")
+	assert(t, tenant.Parent.SpanID() == root.SpanContext.SpanID(), "expect PulsarAdminTenant a child of the probe span")
+	assert(t, len(tenant.Events) == 1 && tenant.Events[0].Name == "exception", "expect the failed request recorded on the span")
+}
+
+func TestProbeTracingDisabled(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	Config.OtelTracing = OtelTracingCfg{}
+	InitTracing()()
+
+	_, span := startProbeSpan("topic", "disabled-test")
+	defer span.End()
+	assert(t, !span.IsRecording(), "expect no span recorded if tracing is disabled")
+
+	// a sampled out probe run is not recorded and its children are not either
+	exporter, restore := withInMemoryTracing(OtelTracingCfg{Enabled: true, SampleRatio: 1e-18})
+	defer restore()
+	ctx, root := startProbeSpan("topic", "sampled-out-test")
+	_, child := startSpan(ctx, "PubSubLatency")
+	endSpan(child, nil)
+	root.End()
+	assert(t, !trace.SpanFromContext(ctx).IsRecording(), "expect the probe run sampled out")
+	assert(t, len(exporter.GetSpans()) == 0, "expect no span exported but got %d", len(exporter.GetSpans()))
+}
@@ -0,0 +1,63 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify the message properties are propagated by the function to the output topic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// errPropertiesLost is the error of the properties not propagated to the output topic
+var errPropertiesLost = errors.New("message properties are not propagated")
+
+// propagatedProperties returns the properties set on the input messages, the value identifies the probe
+func propagatedProperties(keys []string, msgPrefix string) map[string]string {
+	if len(keys) == 0 {
+		return nil
+	}
+	properties := make(map[string]string, len(keys))
+	for _, key := range keys {
+		properties[key] = msgPrefix + "-" + key
+	}
+	return properties
+}
+
+// verifyPropagatedProperties returns an error listing the properties missing or altered on the received message
+func verifyPropagatedProperties(expected, received map[string]string) error {
+	lost := []string{}
+	for key, value := range expected {
+		if actual, ok := received[key]; !ok {
+			lost = append(lost, key+" missing")
+		} else if actual != value {
+			lost = append(lost, fmt.Sprintf("%s altered to %q", key, actual))
+		}
+	}
+	if len(lost) == 0 {
+		return nil
+	}
+	sort.Strings(lost)
+	return fmt.Errorf("%w: %s", errPropertiesLost, strings.Join(lost, ", "))
+}
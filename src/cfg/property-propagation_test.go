@@ -0,0 +1,73 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyPropagatedProperties(t *testing.T) {
+	expected := propagatedProperties([]string{"trace-id", "tenant"}, "messageid")
+	assert(t, expected["trace-id"] == "messageid-trace-id", "unexpected property value %s", expected["trace-id"])
+	assert(t, propagatedProperties(nil, "messageid") == nil, "expect no properties if not configured")
+
+	errNil(t, verifyPropagatedProperties(expected, map[string]string{"trace-id": "messageid-trace-id", "tenant": "messageid-tenant", "extra": "kept"}))
+
+	err := verifyPropagatedProperties(expected, map[string]string{"trace-id": "rewritten"})
+	assert(t, errors.Is(err, errPropertiesLost), "expect the properties lost but got %v", err)
+	assert(t, strings.Contains(err.Error(), "tenant missing") && strings.Contains(err.Error(), `trace-id altered to "rewritten"`),
+		"expect the missing and altered properties listed but got %v", err)
+}
+
+func TestPubSubLatencyPropagatedProperties(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:            "pulsar://propagation-test:6650",
+		TopicName:            "persistent://tenant/ns/function-input",
+		OutputTopic:          "persistent://tenant/ns/function-output",
+		PropagatedProperties: []string{"trace-id", "tenant"},
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 2)
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	_, err := PubSubLatency("propagation-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+
+	// the function drops a property, the loss is reported even though the sender is still in flight
+	topicCfg.MaxInFlightMessages = 1
+	lossPayloads, _ := AllMsgPayloads("messageid", []string{"10B"}, 5)
+	for i := 0; i < 5; i++ {
+		client = newFakePulsarClient(t, topicCfg.PulsarURL, 5*time.Millisecond)
+		client.producer.dropProperty = "tenant"
+		_, err = PubSubLatency("propagation-cluster", nil, topicCfg, "messageid", lossPayloads, maxPayloadSize)
+		assert(t, errors.Is(err, errPropertiesLost), "expect the property loss reported but got %v", err)
+	}
+	topicCfg.MaxInFlightMessages = 0
+
+	// the properties are not verified without an output topic
+	topicCfg.OutputTopic = ""
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	client.producer.dropProperty = "tenant"
+	_, err = PubSubLatency("propagation-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+}
@@ -0,0 +1,126 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// wrap the produce and the ack of the pub sub latency test in Pulsar transactions
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// pubSubTransaction has the produce and the ack transactions of a test, a nil transaction is disabled
+type pubSubTransaction struct {
+	produce pulsar.Transaction
+	ack     pulsar.Transaction
+	// commitLatency is the total time to commit both transactions
+	commitLatency time.Duration
+}
+
+// newPubSubTransaction opens the produce and the ack transactions with the timeout if the topic uses transactions
+func newPubSubTransaction(client pulsar.Client, topicCfg TopicCfg, timeout time.Duration) (*pubSubTransaction, error) {
+	if !topicCfg.UseTransaction {
+		return nil, nil
+	}
+	produce, err := client.NewTransaction(timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the produce transaction: %w", err)
+	}
+	ack, err := client.NewTransaction(timeout)
+	if err != nil {
+		produce.Abort(context.Background())
+		return nil, fmt.Errorf("failed to open the ack transaction: %w", err)
+	}
+	return &pubSubTransaction{produce: produce, ack: ack}, nil
+}
+
+// producerTransaction is the transaction of the sent messages, nil if disabled
+func (tx *pubSubTransaction) producerTransaction() pulsar.Transaction {
+	if tx == nil {
+		return nil
+	}
+	return tx.produce
+}
+
+// acknowledge acks the message within the ack transaction if enabled, a failed plain ack is ignored
+// since the message would be redelivered, but a failed transactional ack would fail the commit
+func (tx *pubSubTransaction) acknowledge(consumer pulsar.Consumer, msg pulsar.Message) error {
+	if tx == nil {
+		consumer.Ack(msg)
+		return nil
+	}
+	if err := consumer.AckWithTxn(msg, tx.ack); err != nil {
+		return fmt.Errorf("failed to ack message within the ack transaction: %w", err)
+	}
+	return nil
+}
+
+// commitProduce commits the sent messages, they are only delivered to the consumer after the commit
+func (tx *pubSubTransaction) commitProduce(ctx context.Context) error {
+	if tx == nil {
+		return nil
+	}
+	return tx.commit(ctx, tx.produce, "produce")
+}
+
+// commitAck commits the acks of the received messages
+func (tx *pubSubTransaction) commitAck(ctx context.Context) error {
+	if tx == nil {
+		return nil
+	}
+	return tx.commit(ctx, tx.ack, "ack")
+}
+
+func (tx *pubSubTransaction) commit(ctx context.Context, txn pulsar.Transaction, name string) error {
+	start := time.Now()
+	if err := txn.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit the %s transaction: %w", name, err)
+	}
+	tx.commitLatency += time.Since(start)
+	return nil
+}
+
+// transactionEnabled returns whether any topic of the pulsar url uses transactions,
+// the transaction coordinator client is only enabled on the cached client of such a url
+func transactionEnabled(pulsarURL string) bool {
+	for _, topicCfg := range GetConfig().PulsarTopicConfig {
+		if topicCfg.UseTransaction && topicCfg.PulsarURL == pulsarURL {
+			return true
+		}
+	}
+	return false
+}
+
+// abort aborts the transactions not committed so that the coordinator does not wait for their timeout
+func (tx *pubSubTransaction) abort() {
+	if tx == nil {
+		return
+	}
+	for _, txn := range []pulsar.Transaction{tx.produce, tx.ack} {
+		if txn.GetState() == pulsar.TxnOpen {
+			txn.Abort(context.Background())
+		}
+	}
+}
@@ -34,35 +34,22 @@ import (
 	log "github.com/apex/log"
 	"github.com/datastax/pulsar-heartbeat/src/util"
 	"github.com/hashicorp/go-retryablehttp"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // PulsarAdminTenant probes the tenant endpoint to get a list of tenants
 // returns the number of tenants on the cluster
-func PulsarAdminTenant(clusterURL string, tokenSupplier func() (string, error)) (int, error) {
+func PulsarAdminTenant(clusterName, clusterURL string, tokenSupplier func() (string, error)) (int, error) {
 
 	client := retryablehttp.NewClient()
 	client.RetryWaitMin = 4 * time.Second
 	client.RetryWaitMax = 64 * time.Second
 	client.RetryMax = 2
-	caCertFile := GetConfig().TrustStore
-	if caCertFile != "" {
-		caCert, err := os.ReadFile(caCertFile)
-		if err != nil {
-			return 0, fmt.Errorf("error opening cert file %s, Error: %v", caCertFile, err)
-		}
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(caCert)
-
-		t := &http.Transport{
-			TLSClientConfig: &tls.Config{
-				RootCAs: caCertPool,
-			},
-		}
-		client.HTTPClient = &http.Client{
-			Transport: t,
-		}
+	httpClient, err := adminHTTPClient(30 * time.Second)
+	if err != nil {
+		return 0, err
 	}
-	client.HTTPClient.Timeout = time.Duration(30) * time.Second
+	client.HTTPClient = httpClient
 
 	req, err := retryablehttp.NewRequest(http.MethodGet, clusterURL, nil)
 	if err != nil {
@@ -77,7 +64,9 @@ func PulsarAdminTenant(clusterURL string, tokenSupplier func() (string, error))
 		req.Header.Add("Authorization", "Bearer "+token)
 	}
 
+	start := time.Now()
 	resp, err := client.Do(req)
+	PromAdminRequest("tenants", clusterName, responseStatusCode(resp), time.Since(start))
 	if resp != nil {
 		defer resp.Body.Close()
 	}
@@ -94,6 +83,68 @@ func PulsarAdminTenant(clusterURL string, tokenSupplier func() (string, error))
 	return len(tenants), nil
 }
 
+// adminTLSConfig returns the tls config with the trust store and the client certificate for admin REST API
+// it returns nil if neither is configured
+func adminTLSConfig() (*tls.Config, error) {
+	caCertFile := GetConfig().TrustStore
+	clientCert := GetConfig().ClientCertConfig
+	if caCertFile == "" && clientCert.CertFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if caCertFile != "" {
+		caCert, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("error opening cert file %s, Error: %v", caCertFile, err)
+		}
+		caCertPool := x509.NewCertPool()
+		caCertPool.AppendCertsFromPEM(caCert)
+		tlsConfig.RootCAs = caCertPool
+	}
+	if clientCert.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(clientCert.CertFile, clientCert.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client cert file %s and key file %s, Error: %v", clientCert.CertFile, clientCert.KeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// adminHTTPClient returns a http client for admin REST API with the configured tls
+func adminHTTPClient(timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := adminTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		CheckRedirect: util.PreserveHeaderForRedirect,
+		Timeout:       timeout,
+		Transport:     newHTTPTransport(tlsConfig),
+	}, nil
+}
+
+// PulsarAdminTestThread runs the admin REST API tests of the clusters on the admin interval
+func PulsarAdminTestThread() {
+	runProbes(adminProbes())
+	// the bundle churn is measured between two runs, so it is not a probe of the one-shot mode
+	RunInterval(PulsarBundleChurn, IntervalDuration(GetConfig().PulsarAdminConfig.IntervalSeconds, 120))
+}
+
+// adminProbes are the admin REST API tests of the clusters, every test is a no-op if it is not configured
+func adminProbes() []probe {
+	interval := IntervalDuration(GetConfig().PulsarAdminConfig.IntervalSeconds, 120)
+	return []probe{
+		{name: "pulsar-admin-tenants", interval: interval, run: PulsarTenants},
+		{name: "pulsar-admin-namespace-policies", interval: interval, run: PulsarNamespacePolicies},
+		{name: "pulsar-admin-subscription-consumers", interval: interval, run: PulsarSubscriptionConsumers},
+		{name: "pulsar-admin-topic-message-rates", interval: interval, run: PulsarTopicMessageRates},
+		{name: "pulsar-admin-required-topics", interval: interval, run: PulsarRequiredTopics},
+		{name: "pulsar-admin-dead-letter-topics", interval: interval, run: PulsarDeadLetterTopics},
+	}
+}
+
 // PulsarTenants get a list of tenants on each cluster
 func PulsarTenants() {
 	clusters := GetConfig().PulsarAdminConfig.Clusters
@@ -106,7 +157,11 @@ func PulsarTenants() {
 		}
 		clusterName := adminURL.Hostname()
 		queryURL := util.SingleSlashJoin(cluster.URL, "/admin/v2/tenants")
-		tenantSize, err := PulsarAdminTenant(queryURL, tokenSupplier)
+		ctx, probeSpan := startProbeSpan("admin", cluster.Name)
+		_, span := startSpan(ctx, "PulsarAdminTenant", attribute.String("pulsar.cluster", cluster.Name))
+		tenantSize, err := PulsarAdminTenant(cluster.Name, queryURL, tokenSupplier)
+		endSpan(span, err)
+		probeSpan.End()
 		if err != nil {
 			errMsg := fmt.Sprintf("tenant-test failed on cluster %s error: %v", queryURL, err)
 			log.Errorf(clusterName + "-pulsar-admin " + errMsg)
@@ -114,6 +169,9 @@ func PulsarTenants() {
 		} else {
 			PromGaugeInt(TenantsGaugeOpt(), cluster.Name, tenantSize)
 			ClearIncident(cluster.Name)
+			if GetConfig().PulsarAdminConfig.MetadataLabels {
+				refreshClusterMetadata(cluster, tokenSupplier)
+			}
 			if tenantSize == 0 {
 				log.Errorf("cluster %s pulsar-admin has incorrect number of tenants 0", cluster.Name)
 			} else {
@@ -122,3 +180,11 @@ func PulsarTenants() {
 		}
 	}
 }
+
+// responseStatusCode returns the status code of the http response, or 0 if there is no response
+func responseStatusCode(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
@@ -0,0 +1,166 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// adminRequestRecorded checks the admin request latency metric exists with the labels
+func adminRequestRecorded(t *testing.T, cluster, endpoint, status string) bool {
+	for _, labels := range gatheredLabels(t, "pulsar_admin_request_ms") {
+		if labels["device"] == cluster && labels["endpoint"] == endpoint && labels["status"] == status {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPulsarAdminTenantRequestMetric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`["public","pulsar"]`))
+	}))
+	defer server.Close()
+
+	tenants, err := PulsarAdminTenant("admin-metric-cluster", server.URL+"/admin/v2/tenants", nil)
+	errNil(t, err)
+	assert(t, tenants == 2, "expect 2 tenants but got %d", tenants)
+	assert(t, adminRequestRecorded(t, "admin-metric-cluster", "tenants", "200"), "expect the tenants request latency recorded")
+
+	_, err = GetBrokers(server.URL, "admin-metric-cluster", nil)
+	errNil(t, err)
+	assert(t, adminRequestRecorded(t, "admin-metric-cluster", "brokers", "200"), "expect the brokers request latency recorded")
+
+	PromAdminRequest("brokers", "admin-metric-down-cluster", 0, 0)
+	assert(t, adminRequestRecorded(t, "admin-metric-down-cluster", "brokers", "error"), "expect a failed request recorded with error status")
+}
+
+// writeClientCert generates a self signed client certificate and key files
+func writeClientCert(t *testing.T, dir string) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	errNil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pulsar-heartbeat"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	errNil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	errNil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	errNil(t, err)
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	errNil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	errNil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, certFile, keyFile
+}
+
+func TestAdminClientCert(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certFile, keyFile := writeClientCert(t, dir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/admin/v2/persistent/") {
+			w.Write([]byte(`["persistent://tenant/ns/mtls-topic"]`))
+			return
+		}
+		w.Write([]byte(`["broker-1:8080"]`))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	trustStore := filepath.Join(dir, "ca.crt")
+	errNil(t, os.WriteFile(trustStore, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	trustStoreCfg, clientCertCfg := GetConfig().TrustStore, GetConfig().ClientCertConfig
+	defer func() {
+		GetConfig().TrustStore, GetConfig().ClientCertConfig = trustStoreCfg, clientCertCfg
+	}()
+	tokenSupplier := func() (string, error) { return "token", nil }
+
+	// mtls is rejected without the client cert
+	GetConfig().TrustStore = trustStore
+	GetConfig().ClientCertConfig = TLSClientCertCfg{}
+	_, err := GetBrokers(server.URL, "mtls-cluster", tokenSupplier)
+	assert(t, err != nil, "expect the request without client cert rejected")
+
+	// the client cert is combined with the token
+	GetConfig().ClientCertConfig = TLSClientCertCfg{CertFile: certFile, KeyFile: keyFile}
+	tlsConfig, err := adminTLSConfig()
+	errNil(t, err)
+	assert(t, len(tlsConfig.Certificates) == 1, "expect the transport carries the client cert")
+	brokers, err := GetBrokers(server.URL, "mtls-cluster", tokenSupplier)
+	errNil(t, err)
+	assert(t, len(brokers) == 1, "expect one broker but got %v", brokers)
+
+	// the topic admin of the probes shares the tls of the admin client
+	admin := restTopicAdmin{baseURL: server.URL, tokenSupplier: tokenSupplier}
+	exists, err := admin.TopicExists("tenant", "ns", "persistent://tenant/ns/mtls-topic")
+	errNil(t, err)
+	assert(t, exists, "expect the topic listed over mtls")
+	host := strings.TrimPrefix(server.URL, "https://")
+	assert(t, adminRequestRecorded(t, host, "persistent", "200"), "expect the topic admin request latency recorded")
+
+	GetConfig().ClientCertConfig = TLSClientCertCfg{CertFile: certFile, KeyFile: filepath.Join(dir, "missing.key")}
+	_, err = adminHTTPClient(time.Second)
+	assert(t, err != nil, "expect an error on a missing key file")
+}
+
+func TestAdminEndpoint(t *testing.T) {
+	for route, expected := range map[string]string{
+		"admin/v2/namespaces/tenant/ns/retention":                 "namespaces",
+		"admin/v2/persistent/tenant/ns/topic?force=true":          "persistent",
+		"/admin/v2/brokers/version":                               "brokers",
+		"lookup/v2/topic/persistent/tenant/ns/topic":              "lookup",
+		"admin/v2/persistent/tenant/ns/topic/compaction?x=y&z=/a": "persistent",
+	} {
+		endpoint := adminEndpoint(route)
+		assert(t, endpoint == expected, "expect the endpoint %s of %s but got %s", expected, route, endpoint)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -34,6 +35,7 @@ import (
 	log "github.com/apex/log"
 	"github.com/datastax/pulsar-heartbeat/src/topic"
 	"github.com/datastax/pulsar-heartbeat/src/util"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -43,7 +45,15 @@ const (
 
 var (
 	clients         = make(map[string]pulsar.Client)
-	partitionTopics = make(map[string]*topic.PartitionTopics)
+	clientCreatedAt = make(map[string]time.Time)
+	// clientLastUsedAt is the last time the cached client was handed to a test
+	clientLastUsedAt = make(map[string]time.Time)
+	clientsLock      = &sync.Mutex{}
+	partitionTopics  = make(map[string]*topic.PartitionTopics)
+
+	// clientRetireGrace is how long a client swapped out of the cache stays open for the concurrent tests still using it,
+	// it is longer than the default probe timeout
+	clientRetireGrace = 2 * maxDefaultProbeTimeout
 )
 
 // MsgResult stores the result of message test
@@ -51,44 +61,167 @@ type MsgResult struct {
 	InOrderDelivery bool
 	Latency         time.Duration
 	SentTime        time.Time
+
+	schemaVersion []byte
+	// messageLatencies are the latency of every message of the test, Latency is the average of them
+	messageLatencies []time.Duration
+	// orderDeviation is the first deviation of the received order from the sent order in the strict ordering mode
+	orderDeviation error
+	// messageID is the id of the last message acknowledged by the broker
+	messageID pulsar.MessageID
+	// commitLatency is the time to commit the produce and the ack transactions of a transactional test
+	commitLatency time.Duration
 }
 
 // GetPulsarClient gets the pulsar client object
 // Note: the caller has to Close() the client object
+// a cached client older than the max client age is recycled so that a rotated proxy DNS is resolved again
 func GetPulsarClient(pulsarURL string, tokenSupplier func() (string, error)) (pulsar.Client, error) {
+	return GetPulsarClientWithTrustStore(pulsarURL, tokenSupplier, "")
+}
+
+// newPulsarClient creates a Pulsar client, it is replaced in tests
+var newPulsarClient = pulsar.NewClient
+
+// GetPulsarClientWithTrustStore gets the pulsar client object as GetPulsarClient with the trust store override,
+// the global trust store is used if the override is not specified.
+// the client is cached by the pulsar url so that the trust store only applies when the client is created
+func GetPulsarClientWithTrustStore(pulsarURL string, tokenSupplier func() (string, error), trustStore string) (pulsar.Client, error) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
 	client, ok := clients[pulsarURL]
+	maxAge := time.Duration(GetConfig().MaxClientAgeSeconds) * time.Second
+	if createdAt, tracked := clientCreatedAt[pulsarURL]; ok && tracked && maxAge > 0 && time.Since(createdAt) > maxAge {
+		log.Infof("recycle pulsar client to %s created at %v", pulsarURL, createdAt)
+		retirePulsarClient(client)
+		delete(clients, pulsarURL)
+		delete(clientCreatedAt, pulsarURL)
+		ok = false
+	}
 	if !ok {
-		clientOpt := pulsar.ClientOptions{
-			URL:               pulsarURL,
-			OperationTimeout:  30 * time.Second,
-			ConnectionTimeout: 30 * time.Second,
+		pulsarClient, err := newPulsarClient(pulsarClientOptions(pulsarURL, tokenSupplier, trustStore))
+		if err != nil {
+			return nil, err
 		}
+		clients[pulsarURL] = pulsarClient
+		clientCreatedAt[pulsarURL] = time.Now()
+		clientLastUsedAt[pulsarURL] = time.Now()
+		return pulsarClient, nil
+	}
+	clientLastUsedAt[pulsarURL] = time.Now()
+	return client, nil
+}
 
-		if tokenSupplier != nil {
-			clientOpt.Authentication = pulsar.NewAuthenticationTokenFromSupplier(tokenSupplier)
-		}
+// evictPulsarClient removes the client from the cache so that the next test creates a new client
+// the caller has to Close() the evicted client
+func evictPulsarClient(pulsarURL string) {
+	clientsLock.Lock()
+	delete(clients, pulsarURL)
+	delete(clientCreatedAt, pulsarURL)
+	delete(clientLastUsedAt, pulsarURL)
+	clientsLock.Unlock()
+	recordClientReconnect(pulsarURL, time.Now())
+}
+
+// recyclePulsarClient removes the cached client so that the next test creates a new client,
+// the removed client is retired rather than closed since the concurrent tests of the pulsar url share it.
+// A nil client recycles whichever client is cached. A client already swapped out of the cache by a
+// concurrent test is retired by that test, the newer cached client is kept.
+func recyclePulsarClient(pulsarURL string, client pulsar.Client) {
+	clientsLock.Lock()
+	cached, ok := clients[pulsarURL]
+	swapped := ok && (client == nil || cached == client)
+	if swapped {
+		delete(clients, pulsarURL)
+		delete(clientCreatedAt, pulsarURL)
+		delete(clientLastUsedAt, pulsarURL)
+	}
+	clientsLock.Unlock()
+	if swapped {
+		retirePulsarClient(cached)
+	}
+	recordClientReconnect(pulsarURL, time.Now())
+}
+
+// retirePulsarClient closes a client swapped out of the cache after the retire grace period
+func retirePulsarClient(client pulsar.Client) {
+	time.AfterFunc(clientRetireGrace, client.Close)
+}
 
-		if strings.HasPrefix(pulsarURL, "pulsar+ssl://") {
-			trustStore := GetConfig().TrustStore
-			if trustStore != "" {
-				clientOpt.TLSTrustCertsFilePath = trustStore
-			} else {
-				log.Warn("missing trustStore while pulsar+ssl tls is enabled")
+// evictIdlePulsarClients closes and removes the cached clients unused for longer than the idle duration,
+// a client is created again on the next use. It returns the pulsar urls of the evicted clients.
+func evictIdlePulsarClients(idle time.Duration, now time.Time) []string {
+	idleClients := make(map[string]pulsar.Client)
+	clientsLock.Lock()
+	for pulsarURL, lastUsedAt := range clientLastUsedAt {
+		if now.Sub(lastUsedAt) > idle {
+			if client, ok := clients[pulsarURL]; ok {
+				idleClients[pulsarURL] = client
 			}
+			delete(clients, pulsarURL)
+			delete(clientCreatedAt, pulsarURL)
+			delete(clientLastUsedAt, pulsarURL)
 		}
+	}
+	clientsLock.Unlock()
 
-		pulsarClient, err := pulsar.NewClient(clientOpt)
-		if err != nil {
-			return nil, err
+	evicted := []string{}
+	for pulsarURL, client := range idleClients {
+		log.Infof("close pulsar client to %s idle for longer than %v", pulsarURL, idle)
+		client.Close()
+		evicted = append(evicted, pulsarURL)
+	}
+	return evicted
+}
+
+// MonitorIdlePulsarClients periodically evicts the cached Pulsar clients idle beyond clientIdleSeconds
+func MonitorIdlePulsarClients() {
+	idle := time.Duration(GetConfig().ClientIdleSeconds) * time.Second
+	if idle <= 0 {
+		return
+	}
+	RunInterval(func() { evictIdlePulsarClients(idle, time.Now()) }, idle/2)
+}
+
+// pulsarClientOptions returns the client options, they are only applied when the cached client is created
+func pulsarClientOptions(pulsarURL string, tokenSupplier func() (string, error), trustStore string) pulsar.ClientOptions {
+	clientOpt := pulsar.ClientOptions{
+		URL:                     pulsarURL,
+		OperationTimeout:        30 * time.Second,
+		ConnectionTimeout:       30 * time.Second,
+		MaxConnectionsPerBroker: GetConfig().MaxConnectionsPerBroker,
+		ListenerName:            GetConfig().ListenerName,
+		EnableTransaction:       transactionEnabled(pulsarURL),
+	}
+
+	if tokenSupplier != nil {
+		clientOpt.Authentication = pulsar.NewAuthenticationTokenFromSupplier(tokenSupplier)
+	}
+
+	if strings.HasPrefix(pulsarURL, "pulsar+ssl://") {
+		trustStore = util.FirstNonEmptyString(trustStore, GetConfig().TrustStore)
+		if trustStore != "" {
+			clientOpt.TLSTrustCertsFilePath = trustStore
+		} else {
+			log.Warn("missing trustStore while pulsar+ssl tls is enabled")
 		}
-		clients[pulsarURL] = pulsarClient
-		return pulsarClient, nil
 	}
-	return client, nil
+	return clientOpt
 }
 
 // PubSubLatency the latency including successful produce and consume of a message
-func PubSubLatency(clusterName string, tokenSupplier func() (string, error), uri, topicName, outputTopic, msgPrefix, expectedSuffix string, payloads [][]byte, maxPayloadSize int) (MsgResult, error) {
+// the topic, output topic, expected message suffix, and send options are specified by the topic configuration
+func PubSubLatency(clusterName string, tokenSupplier func() (string, error), topicCfg TopicCfg, msgPrefix string, payloads [][]byte, maxPayloadSize int) (MsgResult, error) {
+	ctx, span := startSpan(topicCfg.traceCtx, "PubSubLatency", attribute.String("pulsar.cluster", clusterName),
+		attribute.String("pulsar.topic", topicCfg.TopicName), attribute.Int("pulsar.messages", len(payloads)))
+	topicCfg.traceCtx = ctx
+	result, err := pubSubLatency(clusterName, tokenSupplier, topicCfg, msgPrefix, payloads, maxPayloadSize)
+	endSpan(span, err)
+	return result, err
+}
+
+func pubSubLatency(clusterName string, tokenSupplier func() (string, error), topicCfg TopicCfg, msgPrefix string, payloads [][]byte, maxPayloadSize int) (MsgResult, error) {
+	uri, topicName, outputTopic, expectedSuffix := topicCfg.PulsarURL, topicCfg.TopicName, topicCfg.OutputTopic, topicCfg.ExpectedMsg
 	client, err := GetPulsarClient(uri, tokenSupplier)
 	if err != nil {
 		return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to get pulsar client to uri '%s': %w", uri, err)
@@ -98,20 +231,27 @@ func PubSubLatency(clusterName string, tokenSupplier func() (string, error), uri
 	// defer client.Close()
 
 	// Use the client to instantiate a producer
+	// the producer pending queue is the same size as the in-flight limit so that sends are not blocked on the queue
+	// the producer name is unique per subscription since the probes with their own subscriptions run concurrently on the topic
+	producerName := probeClientName(topicName)
+	if topicCfg.subscriptionName != "" {
+		producerName += "-" + topicCfg.subscriptionName
+	}
 	producer, err := client.CreateProducer(pulsar.ProducerOptions{
-		Topic: topicName,
+		Topic:              topicName,
+		Name:               producerName,
+		MaxPendingMessages: topicCfg.MaxInFlightMessages,
 	})
 
 	if err != nil {
 		// we guess something could have gone wrong if producer cannot be created
-		client.Close()
-		delete(clients, uri)
+		recyclePulsarClient(uri, client)
 		return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to create producer to topic '%s' on host '%s': %w", topicName, uri, err)
 	}
 
 	defer producer.Close()
 
-	subscriptionName := "latency-measure"
+	subscriptionName := util.FirstNonEmptyString(topicCfg.subscriptionName, "latency-measure")
 
 	// use the same input topic if outputTopic does not exist
 	// Two topic use case could be for Pulsar function test
@@ -119,17 +259,26 @@ func PubSubLatency(clusterName string, tokenSupplier func() (string, error), uri
 	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
 		Topic:                       consumerTopic,
 		SubscriptionName:            subscriptionName,
-		Type:                        pulsar.Exclusive,
+		Name:                        probeClientName(consumerTopic),
+		Type:                        subscriptionType(topicCfg.SubscriptionType),
 		SubscriptionInitialPosition: pulsar.SubscriptionPositionLatest,
+		NackRedeliveryDelay:         nackRedeliveryDelay(topicCfg),
 	})
 
 	if err != nil {
-		defer client.Close() //must defer to allow producer to be closed first
-		delete(clients, uri)
+		// the client is retired after the grace period so the deferred producer close runs first
+		recyclePulsarClient(uri, client)
 		return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to subscribe to topic: %w", err)
 	}
 	defer consumer.Close()
 
+	// a stale backlog on the subscription would be received ahead of the test messages and skew the latency
+	if topicCfg.ResetSubscriptionBeforeTest {
+		if err = consumer.SeekByTime(time.Now()); err != nil {
+			return MsgResult{Latency: failedLatency}, fmt.Errorf("failed to reset subscription %s on topic %s: %w", subscriptionName, consumerTopic, err)
+		}
+	}
+
 	// notify the main thread with the latency to complete the exit
 	completeChan := make(chan MsgResult, 1)
 
@@ -142,67 +291,167 @@ func PubSubLatency(clusterName string, tokenSupplier func() (string, error), uri
 	// Key is payload in string, value is pointer to a MsgResult
 	sentPayloads := make(map[string]*MsgResult, receivedCount)
 	// Use mutex instead of sync.Map in favour of performance and simplicity
-	//  and because no need to protect map iteration to calculate results
 	mapMutex := &sync.Mutex{}
+	var lastMessageID pulsar.MessageID
 
 	receiveTimeout := util.TimeDuration(5+(maxPayloadSize/102400), 10, time.Second)
+	// per message logs are sampled to avoid flooding logs with a large number of messages
+	msgLog := util.NewLogSampler(GetConfig().LogSampleRate)
+	// the properties are only verified on the output topic of a function
+	var properties map[string]string
+	if outputTopic != "" {
+		properties = propagatedProperties(topicCfg.PropagatedProperties, msgPrefix)
+	}
+	// the first message is negatively acknowledged once to verify the redelivery
+	nackCheck := newNackRedelivery(topicCfg, expectedMessage(string(payloads[0]), expectedSuffix))
+	timeout := probeTimeout(topicCfg.ProbeTimeoutSeconds, len(payloads), receiveTimeout)
+	tx, err := newPubSubTransaction(client, topicCfg, timeout)
+	if err != nil {
+		return MsgResult{Latency: failedLatency}, err
+	}
+	defer tx.abort()
+	// the receive loop is bounded as a whole in addition to every receive, the default is the probe timeout
+	receiveDeadline := timeout
+	if topicCfg.ReceiveDeadlineSeconds > 0 {
+		receiveDeadline = time.Duration(topicCfg.ReceiveDeadlineSeconds) * time.Second
+	}
+	// the message indexes in the sent order are compared with the received order in the strict ordering mode
+	var sentOrder, receivedOrder []int
+	if topicCfg.StrictOrdering {
+		for _, payload := range payloads {
+			sentOrder = append(sentOrder, GetMessageID(msgPrefix, expectedMessage(string(payload), expectedSuffix)))
+		}
+	}
+	loopCtx, loopCancel := context.WithTimeout(context.Background(), receiveDeadline)
+	defer loopCancel()
 	go func() {
+		_, consumeSpan := startSpan(topicCfg.traceCtx, "consume", attribute.String("pulsar.topic", consumerTopic))
+		defer consumeSpan.End()
 
 		lastMessageIndex := -1 // to track the message delivery order
+		var schemaVersion []byte
 		for receivedCount > 0 {
-			cCtx, cancel := context.WithTimeout(context.Background(), receiveTimeout)
-			defer cancel()
+			cCtx, cancel := context.WithTimeout(loopCtx, receiveTimeout)
 
-			log.Infof("wait to receive on message count %d", receivedCount)
+			msgLog.Infof("wait to receive on message count %d", receivedCount)
 			msg, err := consumer.Receive(cCtx)
+			cancel()
 			if err != nil {
-				receivedCount = 0 // play safe?
-				errorChan <- fmt.Errorf("consumer Receive() error: %w", err)
-				break
+				received := len(payloads) - receivedCount
+				if nackErr := nackCheck.pending(); nackErr != nil {
+					err = nackErr
+				} else if loopCtx.Err() != nil {
+					err = fmt.Errorf("received %d of %d messages before the receive loop deadline %v: %w", received, len(payloads), receiveDeadline, err)
+				}
+				// the sender may still be running, a failed receive is never aggregated as a result
+				errorChan <- &receiveError{err: err}
+				return
 			}
 			receivedTime := time.Now()
 			receivedStr := string(msg.Payload())
 			currentMsgIndex := GetMessageID(msgPrefix, receivedStr)
 
+			nackState, err := nackCheck.receive(receivedStr, receivedTime)
+			if err != nil {
+				errorChan <- err
+				return
+			} else if nackState == nackRedelivered {
+				// the latency and order are measured on the first delivery
+				receivedCount--
+				if err := tx.acknowledge(consumer, msg); err != nil {
+					errorChan <- err
+					return
+				}
+				continue
+			}
+
+			if currentMsgIndex >= 0 {
+				if err := verifyPayloadEncoding(strings.TrimSuffix(receivedStr, expectedSuffixOf(expectedSuffix)), topicCfg.PayloadEncoding); err != nil {
+					errorChan <- fmt.Errorf("message index %d: %w", currentMsgIndex, err)
+					return
+				}
+			}
+
 			mapMutex.Lock()
 			result, ok := sentPayloads[receivedStr]
 			mapMutex.Unlock()
+			if ok && len(properties) > 0 {
+				if err := verifyPropagatedProperties(properties, msg.Properties()); err != nil {
+					errorChan <- fmt.Errorf("output topic %s message index %d: %w", outputTopic, currentMsgIndex, err)
+					return
+				}
+			}
 			if ok {
-				receivedCount--
+				if nackState != nackFirst {
+					receivedCount--
+				}
+				schemaVersion = msg.SchemaVersion()
+				if topicCfg.StrictOrdering {
+					receivedOrder = append(receivedOrder, currentMsgIndex)
+				}
 				result.Latency = receivedTime.Sub(result.SentTime)
 				if currentMsgIndex > lastMessageIndex {
 					result.InOrderDelivery = true
 					lastMessageIndex = currentMsgIndex
 				}
 			}
-			consumer.Ack(msg)
-			log.Infof("consumer received message index %d payload size %d\n", currentMsgIndex, len(receivedStr))
+			if nackState == nackFirst {
+				consumer.Nack(msg)
+				msgLog.Infof("consumer negatively acknowledged message index %d", currentMsgIndex)
+				continue
+			}
+			if err := tx.acknowledge(consumer, msg); err != nil {
+				errorChan <- err
+				return
+			}
+			msgLog.Infof("consumer received message index %d payload size %d", currentMsgIndex, len(receivedStr))
 		}
 
 		//successful case all message received
 		if receivedCount == 0 {
 			var total time.Duration
 			inOrder := true
+			mapMutex.Lock()
+			latencies := make([]time.Duration, 0, len(sentPayloads))
 			for _, v := range sentPayloads {
 				total += v.Latency
 				inOrder = inOrder && v.InOrderDelivery
+				latencies = append(latencies, v.Latency)
+			}
+			mapMutex.Unlock()
+			var deviation error
+			if topicCfg.StrictOrdering {
+				deviation = orderDeviation(sentOrder, receivedOrder)
+				inOrder = inOrder && deviation == nil
 			}
 
 			// receiverLatency <- total / receivedCount
 			completeChan <- MsgResult{
-				Latency:         time.Duration(int(total/time.Millisecond)/len(payloads)) * time.Millisecond,
-				InOrderDelivery: inOrder,
+				Latency:          time.Duration(int(total/time.Millisecond)/len(payloads)) * time.Millisecond,
+				InOrderDelivery:  inOrder,
+				schemaVersion:    schemaVersion,
+				messageLatencies: latencies,
+				orderDeviation:   deviation,
 			}
 		}
 
 	}()
 
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	inFlight := newInFlightLimiter(topicCfg.MaxInFlightMessages)
+	_, produceSpan := startSpan(topicCfg.traceCtx, "produce", attribute.String("pulsar.topic", topicName))
 	for _, payload := range payloads {
-		ctx := context.Background()
+		if err := inFlight.acquire(ctx); err != nil {
+			endSpan(produceSpan, err)
+			return MsgResult{Latency: failedLatency}, fmt.Errorf("timed out to send messages with %d in-flight limit: %w", topicCfg.MaxInFlightMessages, err)
+		}
 
 		// Create a different message to send asynchronously
 		asyncMsg := pulsar.ProducerMessage{
-			Payload: payload,
+			Payload:     payload,
+			Properties:  properties,
+			Transaction: tx.producerTransaction(),
 		}
 
 		sentTime := time.Now()
@@ -211,65 +460,175 @@ func PubSubLatency(clusterName string, tokenSupplier func() (string, error), uri
 		sentPayloads[expectedMsg] = &MsgResult{SentTime: sentTime}
 		mapMutex.Unlock()
 		// Attempt to send message asynchronously and handle the response
-		producer.SendAsync(ctx, &asyncMsg, func(messageId pulsar.MessageID, msg *pulsar.ProducerMessage, err error) {
+		producer.SendAsync(context.Background(), &asyncMsg, func(messageId pulsar.MessageID, msg *pulsar.ProducerMessage, err error) {
+			inFlight.release()
 			if err != nil {
 				errMsg := fmt.Sprintf("fail to instantiate Pulsar client: %v", err)
-				log.Infof(errMsg)
+				log.Errorf(errMsg)
 				// report error and exit
 				errorChan <- errors.New(errMsg)
+				return
 			}
 
-			log.Infof("successfully published %v", sentTime)
+			mapMutex.Lock()
+			lastMessageID = messageId
+			mapMutex.Unlock()
+			msgLog.Infof("successfully published %v", sentTime)
 		})
 	}
+	produceSpan.End()
+	// the transactional messages are only delivered to the consumer after the commit
+	if err := tx.commitProduce(ctx); err != nil {
+		return MsgResult{Latency: failedLatency}, err
+	}
 
-	ticker := time.NewTicker(time.Duration(5*len(payloads)) * time.Second)
-	defer ticker.Stop()
 	select {
 	case receiverLatency := <-completeChan:
+		if outputTopic != "" && topicCfg.OutputSchema.Type != "" {
+			if err := validateOutputSchema(topicCfg, tokenSupplier, receiverLatency.schemaVersion); err != nil {
+				return MsgResult{Latency: failedLatency}, fmt.Errorf("output topic %s schema validation failed: %w", outputTopic, err)
+			}
+		}
+		if err := tx.commitAck(ctx); err != nil {
+			return MsgResult{Latency: failedLatency}, err
+		}
+		if tx != nil {
+			receiverLatency.commitLatency = tx.commitLatency
+		}
+		mapMutex.Lock()
+		receiverLatency.messageID = lastMessageID
+		mapMutex.Unlock()
 		return receiverLatency, nil
 	case reportedErr := <-errorChan:
 		log.Infof("received error %v", reportedErr)
 		return MsgResult{Latency: failedLatency}, reportedErr
-	case <-ticker.C:
-		return MsgResult{Latency: failedLatency}, errors.New("latency measure not received after timeout")
+	case <-ctx.Done():
+		return MsgResult{Latency: failedLatency}, fmt.Errorf("latency measure not received after timeout %v", timeout)
+	}
+}
+
+// maxDefaultProbeTimeout caps the default probe timeout of a large number of messages
+const maxDefaultProbeTimeout = 60 * time.Second
+
+// probeTimeout returns the configured probe timeout, or 5 seconds per message up to
+// the larger of maxDefaultProbeTimeout and twice the receive timeout
+func probeTimeout(configuredSeconds, numOfMessages int, receiveTimeout time.Duration) time.Duration {
+	if configuredSeconds > 0 {
+		return time.Duration(configuredSeconds) * time.Second
+	}
+	upperBound := maxDefaultProbeTimeout
+	if 2*receiveTimeout > upperBound {
+		upperBound = 2 * receiveTimeout
+	}
+	if timeout := time.Duration(5*numOfMessages) * time.Second; timeout < upperBound {
+		return timeout
+	}
+	return upperBound
+}
+
+// inFlightLimiter bounds the number of in-flight async sends, a nil limiter is unbounded
+type inFlightLimiter chan struct{}
+
+func newInFlightLimiter(limit int) inFlightLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return make(inFlightLimiter, limit)
+}
+
+// acquire blocks until an in-flight slot is available or the context is done
+func (l inFlightLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees an in-flight slot once the send is acknowledged
+func (l inFlightLimiter) release() {
+	if l != nil {
+		<-l
 	}
 }
 
 // TopicLatencyTestThread tests a message delivery in topic and measure the latency.
 func TopicLatencyTestThread() {
+	log.Infof("topic configuration %v", GetConfig().PulsarTopicConfig)
+	runProbes(topicProbes(false))
+}
+
+// topicProbes are the latency test and the configured checks of every topic,
+// the one-shot probes wait for the checks, otherwise the checks are left in the background from the second run on
+func topicProbes(once bool) []probe {
 	cfg := GetConfig()
-	topics := cfg.PulsarTopicConfig
 	testBroker := cfg.BrokersConfig.BrokerTestRequired || cfg.K8sConfig.Enabled
-	log.Infof("topic configuration %v", topics)
-
-	for _, topic := range topics {
-		go func(t TopicCfg) {
-			ticker := time.NewTicker(util.TimeDuration(t.IntervalSeconds, 60, time.Second))
-			defer ticker.Stop()
-			TestTopicLatency(t)
-			for {
-				select {
-				case <-ticker.C:
-					if testBroker {
-						go TestBrokers(t)
-					}
-					TestTopicLatency(t)
+	probes := []probe{}
+	for _, topic := range cfg.PulsarTopicConfig {
+		probes = append(probes, probe{
+			name:     topic.TopicName,
+			interval: IntervalDuration(topic.IntervalSeconds, 60),
+			run: func(t TopicCfg) monitorFunc {
+				if once {
+					return func() { testTopic(t, testBroker, true).Wait() }
 				}
-			}
-		}(topic)
+				ticked := false
+				return func() {
+					testTopic(t, testBroker, ticked)
+					ticked = true
+				}
+			}(topic),
+		})
+	}
+	return probes
+}
+
+// testTopic runs the latency test of the topic, the configured checks are started alongside it if required,
+// the returned wait group is done when the checks complete
+func testTopic(t TopicCfg, testBroker, checks bool) *sync.WaitGroup {
+	ctx, span := startProbeSpan("topic", t.TopicName)
+	defer span.End()
+	t.traceCtx = ctx
+	var wg sync.WaitGroup
+	check := func(required bool, fn func(TopicCfg)) {
+		if !required || !checks {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(t)
+		}()
 	}
+	check(testBroker, func(t TopicCfg) { TestBrokers(t) })
+	check(t.AutoCreateCheck, TestTopicAutoCreation)
+	check(t.CompactionCheck, TestTopicCompaction)
+	check(t.DirectBrokerProbe, TestDirectBrokers)
+	check(t.SeekByTimeCheck, TestSeekByTime)
+	check(t.DedupCheck, TestDedup)
+	check(t.SchemaCheck.Type != "", TestSchemaCheck)
+	check(t.BrokerVersionSkew.Enabled && t.ClusterName != "", TestBrokerVersionSkew)
+	check(t.PlaneProbe && adminURL(t) != "", TestPlanes)
+	TestTopicLatency(t)
+	return &wg
 }
 
 // TestTopicLatency test generic message delivery in topics and the latency
 func TestTopicLatency(topicCfg TopicCfg) {
 	// uri is in the form of pulsar+ssl://fqdn:6651
-	adminURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
+	serviceURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
 	if err != nil {
 		panic(err) //panic because this is a showstopper
 	}
-	clusterName := adminURL.Hostname()
+	clusterName := serviceURL.Hostname()
 	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+	if topicCfg.ProbeTenant != "" && topicCfg.ProbeNamespace != "" && adminURL(topicCfg) != "" {
+		EnsureProbeNamespace(clusterName, tokenSupplier, topicCfg)
+	}
 
 	if topicCfg.NumberOfPartitions < 2 {
 		testTopicLatency(clusterName, tokenSupplier, topicCfg)
@@ -279,32 +638,70 @@ func TestTopicLatency(topicCfg TopicCfg) {
 }
 
 func testTopicLatency(clusterName string, tokenSupplier func() (string, error), topicCfg TopicCfg) {
-	stdVerdict := util.GetStdBucket(clusterName)
+	stdVerdict := util.GetStdBucketWithWarmup(clusterName, GetConfig().StatsConfig.WarmupSamples)
 	expectedLatency := util.TimeDuration(topicCfg.LatencyBudgetMs, latencyBudget, time.Millisecond)
 	prefix := "messageid"
-	payloads, maxPayloadSize := AllMsgPayloads(prefix, topicCfg.PayloadSizes, topicCfg.NumOfMessages)
-	log.Infof("send %d messages to topic %s on cluster %s with latency budget %v, %v, %d",
-		len(payloads), topicCfg.TopicName, topicCfg.PulsarURL, expectedLatency, topicCfg.PayloadSizes, topicCfg.NumOfMessages)
-	result, err := PubSubLatency(clusterName, tokenSupplier, topicCfg.PulsarURL, topicCfg.TopicName, topicCfg.OutputTopic, prefix, topicCfg.ExpectedMsg, payloads, maxPayloadSize)
+	payloadSizes := topicCfg.PayloadSizes
+	if len(topicCfg.PayloadDistribution) > 0 {
+		payloadSizes = SamplePayloadSizes(topicCfg.PayloadDistribution, topicCfg.NumOfMessages)
+	}
+	payloads, maxPayloadSize := AllEncodedMsgPayloads(prefix, payloadSizes, topicCfg.NumOfMessages, topicCfg.PayloadEncoding)
+	// the probe id is the latency histogram exemplar to correlate a latency sample with the probe logs
+	probeID := newProbeID()
+	log.Infof("probe %s send %d messages to topic %s on cluster %s with latency budget %v, %v, %d",
+		probeID, len(payloads), topicCfg.TopicName, topicCfg.PulsarURL, expectedLatency, payloadSizes, topicCfg.NumOfMessages)
+	result, err := pubSubLatencyWithRetries(clusterName, topicCfg.Retries, func() (MsgResult, error) {
+		return PubSubLatency(clusterName, tokenSupplier, topicCfg, prefix, payloads, maxPayloadSize)
+	})
 
 	testName := util.FirstNonEmptyString(topicCfg.Name, pubSubSubsystem)
 	log.Infof("cluster %s has message latency %v", clusterName, result.Latency)
+	statusErr := ""
+	errClass := ""
+	inOrder := result.InOrderDelivery
+	if err == nil && !inOrder && !strictOrdering(subscriptionType(topicCfg.SubscriptionType)) {
+		log.Warnf("cluster %s, %s test Pulsar message received out of order on a %s subscription",
+			clusterName, testName, topicCfg.SubscriptionType)
+		inOrder = true
+	}
+	if err == nil && implausibleLatency(result.Latency, topicCfg.MinPlausibleLatencyMs) {
+		log.Warnf("cluster %s, %s test message latency %v is suspicious below the plausible floor %d ms, messages could be stale or skipped",
+			clusterName, testName, result.Latency, topicCfg.MinPlausibleLatencyMs)
+	}
 	if err != nil {
-		errMsg := fmt.Sprintf("cluster %s, %s latency test Pulsar error: %v", clusterName, testName, err)
+		errClass = classifyError(err)
+		errMsg := fmt.Sprintf("cluster %s, %s latency test Pulsar %s error: %v", clusterName, testName, errClass, err)
+		statusErr = errMsg
 		log.Errorf(errMsg)
-		if ReportIncident(clusterName, clusterName, "persisted latency test failure", errMsg, &topicCfg.AlertPolicy) && isDowntimeReporting(topicCfg) {
-			PromGauge(PubSubDowntimeGaugeOpt(), clusterName, float64(time.Duration(topicCfg.IntervalSeconds)))
+		// every attempt failed, the connections of the cached client could be stale after a proxy failover
+		if connectionErrorClass(errClass) {
+			recyclePulsarClient(topicCfg.PulsarURL, nil)
+		}
+		if tolerateReceiveError(clusterName, topicCfg.ReceiveErrorRate, err, time.Now()) {
+			log.Warnf("cluster %s, %s consumer receive error is not reported below the error rate of %d errors in %v",
+				clusterName, testName, topicCfg.ReceiveErrorRate.MaxErrors, receiveErrorWindow(topicCfg.ReceiveErrorRate))
+		} else if suppressedByClusterDown() {
+			log.Warnf("cluster %s, %s latency test incident is suppressed while the k8s cluster is total down", clusterName, testName)
+		} else if ReportIncident(clusterName, clusterName, errorClassIncidentMsg(errClass), errMsg, &topicCfg.AlertPolicy) && isDowntimeReporting(topicCfg) {
+			reportDowntime(clusterName, topicCfg, IntervalDuration(topicCfg.IntervalSeconds, 60))
 		}
-	} else if !result.InOrderDelivery {
+	} else if !inOrder {
 		errMsg := fmt.Sprintf("cluster %s, %s test Pulsar message received out of order", clusterName, testName)
+		if result.orderDeviation != nil {
+			errMsg = fmt.Sprintf("%s, %v", errMsg, result.orderDeviation)
+		}
+		statusErr = errMsg
 		log.Errorf(errMsg)
 	} else if result.Latency > expectedLatency {
 		stdVerdict.Add(float64(result.Latency.Microseconds()))
 		errMsg := fmt.Sprintf("cluster %s, %s test message latency %v over the budget %v",
 			clusterName, testName, result.Latency, expectedLatency)
+		statusErr = errMsg
 		log.Errorf(errMsg)
-		if ReportIncident(clusterName, clusterName, "persisted latency test failure", errMsg, &topicCfg.AlertPolicy) && isDowntimeReporting(topicCfg) {
-			PromGauge(PubSubDowntimeGaugeOpt(), clusterName, float64(time.Duration(topicCfg.IntervalSeconds)))
+		if suppressedByClusterDown() {
+			log.Warnf("cluster %s, %s latency test incident is suppressed while the k8s cluster is total down", clusterName, testName)
+		} else if ReportLatencyIncident(clusterName, clusterName, "persisted latency test failure", errMsg, result.Latency, expectedLatency, &topicCfg.AlertPolicy) && isDowntimeReporting(topicCfg) {
+			reportDowntime(clusterName, topicCfg, IntervalDuration(topicCfg.IntervalSeconds, 60))
 		}
 	} else if stddev, mean, within6Sigma := stdVerdict.Push(float64(result.Latency.Microseconds())); !within6Sigma && stddev > 0 && mean > 0 {
 		errMsg := fmt.Sprintf("cluster %s, %s test message latency %v μs over six standard deviation %v μs and mean is %v μs",
@@ -320,12 +717,112 @@ func testTopicLatency(clusterName string, tokenSupplier func() (string, error),
 			len(payloads), topicCfg.TopicName, testName, topicCfg.PulsarURL)
 		ClearIncident(clusterName)
 		if isDowntimeReporting(topicCfg) {
-			PromGauge(PubSubDowntimeGaugeOpt(), clusterName, 0) // report gauge no downtime
+			reportDowntime(clusterName, topicCfg, 0) // report gauge no downtime
 		}
 	}
+	if err == nil && topicCfg.UseTransaction {
+		log.Infof("cluster %s, %s test committed the transactions in %v", clusterName, testName, result.commitLatency)
+		PromLatencySum(TransactionCommitLatencyGaugeOpt(), clusterName, result.commitLatency)
+	}
+	if err == nil && topicCfg.VerifyPersistence {
+		TestPersistence(clusterName, tokenSupplier, topicCfg, result.messageID)
+	}
+	if err == nil && topicCfg.BrokerLatencyCheck && adminURL(topicCfg) != "" {
+		TestBrokerLatency(clusterName, tokenSupplier, topicCfg, result.Latency, expectedLatency)
+	}
+	if topicCfg.ReconnectStorm.MaxReconnects > 0 {
+		TestReconnectStorm(clusterName, topicCfg)
+	}
 	if result.Latency < failedLatency {
-		PromLatencySum(GetGaugeType(topicCfg.Name), clusterName, result.Latency)
+		if topicCfg.PerMessageHistogram && len(result.messageLatencies) > 0 {
+			PromMessageLatencies(GetGaugeType(topicCfg.Name), clusterName, result.Latency, result.messageLatencies, probeID)
+		} else {
+			PromLatencySumWithExemplar(GetGaugeType(topicCfg.Name), clusterName, result.Latency, probeID)
+		}
+		RecordLatencyEMA(clusterName, result.Latency)
 	}
+	recordHealthSignal(clusterName, latencySignal, RecordAvailability(clusterName, err == nil && inOrder && result.Latency <= expectedLatency))
+	RecordHealthScore(clusterName)
+	RecordStatus(clusterName, result.Latency, statusErr)
+	RecordResultHistory(clusterName, result.Latency, statusErr)
+	PromPubSubErrorClass(clusterName, errClass)
+}
+
+// implausibleLatency returns whether the latency is below the plausible floor, it is disabled if the floor is not specified
+func implausibleLatency(latency time.Duration, minPlausibleLatencyMs int) bool {
+	return minPlausibleLatencyMs > 0 && latency < time.Duration(minPlausibleLatencyMs)*time.Millisecond
+}
+
+// subscriptionType returns the consumer subscription type, an unknown type is exclusive
+func subscriptionType(name string) pulsar.SubscriptionType {
+	switch strings.ToLower(strings.ReplaceAll(name, "-", "_")) {
+	case "failover":
+		return pulsar.Failover
+	case "shared":
+		return pulsar.Shared
+	case "key_shared", "keyshared":
+		return pulsar.KeyShared
+	default:
+		return pulsar.Exclusive
+	}
+}
+
+// strictOrdering returns whether the subscription type guarantees in order delivery
+func strictOrdering(subType pulsar.SubscriptionType) bool {
+	return subType == pulsar.Exclusive || subType == pulsar.Failover
+}
+
+// newProbeID returns a unique id of a probe cycle
+func newProbeID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 16)
+}
+
+// evalProtocolLatency evaluates a protocol handler latency result against the budget and the standard deviation
+// subsystem is the protocol handler, such as kop or mop, and used as the Prometheus subsystem
+func evalProtocolLatency(name, subsystem string, latencyBudgetMs int, alertPolicy *AlertPolicyCfg, result MsgResult, err error) {
+	expectedLatency := util.TimeDuration(latencyBudgetMs, latencyBudget, time.Millisecond)
+	stdVerdict := util.GetStdBucket(name)
+	title := subsystem + " persisted latency test failure"
+	statusErr := ""
+
+	if err != nil {
+		errMsg := fmt.Sprintf("%s %s latency test error: %v", name, subsystem, err)
+		statusErr = errMsg
+		log.Errorf(errMsg)
+		ReportIncident(name, name, title, errMsg, alertPolicy)
+		PromCounter(ProbeFailureCounterOpt(subsystem), name)
+	} else if result.Latency > expectedLatency {
+		stdVerdict.Add(float64(result.Latency.Microseconds()))
+		errMsg := fmt.Sprintf("%s %s test message latency %v over the budget %v", name, subsystem, result.Latency, expectedLatency)
+		statusErr = errMsg
+		log.Errorf(errMsg)
+		ReportIncident(name, name, title, errMsg, alertPolicy)
+	} else if stddev, mean, within6Sigma := stdVerdict.Push(float64(result.Latency.Microseconds())); !within6Sigma && stddev > 0 && mean > 0 {
+		log.Errorf("%s %s test message latency %v μs over six standard deviation %v μs and mean is %v μs",
+			name, subsystem, result.Latency.Microseconds(), stddev, mean)
+	} else {
+		log.Infof("%s %s pubsub succeeded with latency %v expected latency %v", name, subsystem, result.Latency, expectedLatency)
+		ClearIncident(name)
+	}
+
+	PromLatencySum(GetGaugeType(subsystem), name, result.Latency)
+	RecordAvailability(name, err == nil && result.Latency <= expectedLatency)
+	RecordStatus(name, result.Latency, statusErr)
+}
+
+// pubSubLatencyWithRetries runs the probe and re-runs it up to the number of retries
+// after a failure, the first successful attempt is returned as the result
+func pubSubLatencyWithRetries(clusterName string, retries int, probe func() (MsgResult, error)) (MsgResult, error) {
+	result, err := probe()
+	for attempt := 1; err != nil; attempt++ {
+		PromCounter(PubSubFailedAttemptCounterOpt(), clusterName)
+		if attempt > retries {
+			break
+		}
+		log.Warnf("cluster %s pub sub latency test failed, retry %d of %d, error: %v", clusterName, attempt, retries, err)
+		result, err = probe()
+	}
+	return result, err
 }
 
 func isDowntimeReporting(cfg TopicCfg) bool {
@@ -339,27 +836,63 @@ func expectedMessage(payload, expected string) string {
 	return payload
 }
 
+// expectedSuffixOf returns the suffix appended to the payload by the expected message
+func expectedSuffixOf(expected string) string {
+	if strings.HasPrefix(expected, "$") {
+		return expected[1:]
+	}
+	return ""
+}
+
 func testPartitionTopic(clusterName string, tokenSupplier func() (string, error), cfg TopicCfg) {
 	trustStore := util.FirstNonEmptyString(cfg.TrustStore, GetConfig().TrustStore)
 	testName := "partition-topics-test"
 	component := clusterName + "-" + testName
-	pt, err := getPartition(cfg, tokenSupplier, trustStore)
+	passed, statusErr := false, ""
+	var latency time.Duration
+	defer func() {
+		RecordAvailability(clusterName, passed)
+		RecordStatus(component, latency, statusErr)
+	}()
+	pt, err := getPartition(clusterName, cfg, tokenSupplier, trustStore)
+	if pt != nil && pt.ActualPartitions > 0 {
+		PromPartitionCount(clusterName, cfg.TopicName, cfg.NumberOfPartitions, pt.ActualPartitions)
+	}
+	countComponent := clusterName + "-partition-count"
+	if errors.Is(err, topic.ErrPartitionMismatch) {
+		errMsg := fmt.Sprintf("%s partition topic test, error: %v", countComponent, err)
+		log.Errorf(errMsg)
+		ReportIncident(countComponent, countComponent, "partition count mismatch", errMsg, &cfg.AlertPolicy)
+		if !cfg.TestActualPartitions {
+			statusErr = errMsg
+			return
+		}
+		// test the existing partitions rather than the configured ones
+		actual := *pt
+		actual.NumberOfPartitions = pt.ActualPartitions
+		pt, err = &actual, nil
+	} else if err == nil {
+		ClearIncident(countComponent)
+	}
 	if err != nil {
 		errMsg := fmt.Sprintf("%s failed to create PartitionTopic test object, error: %v", component, err)
+		statusErr = errMsg
 		ReportIncident(component, component, "persisted failure to create partition topic test client", errMsg, &cfg.AlertPolicy)
 		return
 	}
-	pulsarClient, err := GetPulsarClient(cfg.PulsarURL, tokenSupplier)
+	pulsarClient, err := GetPulsarClientWithTrustStore(cfg.PulsarURL, tokenSupplier, trustStore)
 	if err != nil {
 		errMsg := fmt.Sprintf("cluster %s, %s failed create Pulsar Client with error: %v", component, testName, err)
+		statusErr = errMsg
 		log.Errorf(errMsg)
 		ReportIncident(component, component, "partition topic test failure", errMsg, &cfg.AlertPolicy)
 		return
 	}
 
-	latency, err := pt.TestPartitionTopic(pulsarClient)
+	latency, err = pt.TestPartitionTopic(pulsarClient)
 	if err != nil {
 		errMsg := fmt.Sprintf("cluster %s, %s partition topic test failed with Pulsar error: %v", component, testName, err)
+		statusErr = errMsg
 		log.Errorf(errMsg)
 		ReportIncident(component, component, "partition topic test failure", errMsg, &cfg.AlertPolicy)
 		return
@@ -368,24 +901,42 @@ func testPartitionTopic(clusterName string, tokenSupplier func() (string, error)
 	if latency > expectedLatency || latency == 0 {
 		errMsg := fmt.Sprintf("cluster %s, partition topic test message latency %v over the budget %v",
 			component, latency, expectedLatency)
+		statusErr = errMsg
 		log.Errorf(errMsg)
-		ReportIncident(component, component, "partition topic test has over budget latency", errMsg, &cfg.AlertPolicy)
+		ReportLatencyIncident(component, component, "partition topic test has over budget latency", errMsg, latency, expectedLatency, &cfg.AlertPolicy)
 	} else {
 		log.Infof("%d partition topics test successfully passed with latency %v", pt.NumberOfPartitions, latency)
 		ClearIncident(component)
+		passed = true
 	}
 }
 
-func getPartition(cfg TopicCfg, tokenSupplier func() (string, error), trustStore string) (*topic.PartitionTopics, error) {
+func getPartition(clusterName string, cfg TopicCfg, tokenSupplier func() (string, error), trustStore string) (*topic.PartitionTopics, error) {
 	pt, ok := partitionTopics[cfg.TopicName]
 	if !ok {
-		var err error
-		pt, err = topic.NewPartitionTopic(cfg.PulsarURL, tokenSupplier, trustStore, cfg.TopicName, cfg.AdminURL, cfg.NumberOfPartitions)
+		adminClient, err := adminHTTPClient(10 * time.Second)
 		if err != nil {
 			return nil, err
 		}
+		pt, err = topic.NewPartitionTopic(cfg.PulsarURL, tokenSupplier, trustStore, cfg.TopicName, adminURL(cfg), cfg.NumberOfPartitions)
+		if err != nil {
+			return nil, err
+		}
+		pt.MessagesPerPartition = cfg.MessagesPerPartition
+		pt.ClientName = probeClientName(cfg.TopicName)
+		pt.Transport = adminClient.Transport
+		pt.AdminRequestObserver = func(endpoint string, statusCode int, latency time.Duration) {
+			PromAdminRequest(endpoint, clusterName, statusCode, latency)
+		}
 		partitionTopics[cfg.TopicName] = pt
 	}
 
 	return pt, pt.VerifyPartitionTopic()
 }
+
+// probeClientName returns the producer and consumer name to identify the probe on the topic in the broker stats,
+// in the form of <prefix>-<monitor name>-<local topic name>
+func probeClientName(topicName string) string {
+	prefix := util.FirstNonEmptyString(GetConfig().ProbeNamePrefix, "pulsar-heartbeat")
+	return fmt.Sprintf("%s-%s-%s", prefix, GetConfig().Name, topicName[strings.LastIndex(topicName, "/")+1:])
+}
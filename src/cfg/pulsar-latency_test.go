@@ -0,0 +1,747 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type fakeMessage struct {
+	pulsar.Message
+	payload     []byte
+	publishTime time.Time
+	properties  map[string]string
+}
+
+func (m *fakeMessage) Payload() []byte               { return m.payload }
+func (m *fakeMessage) SchemaVersion() []byte         { return nil }
+func (m *fakeMessage) PublishTime() time.Time        { return m.publishTime }
+func (m *fakeMessage) Properties() map[string]string { return m.properties }
+
+type fakeConsumer struct {
+	pulsar.Consumer
+	messages  chan pulsar.Message
+	seekedAt  []time.Time
+	nacked    int
+	nackDelay time.Duration
+	// receiveErr fails every receive
+	receiveErr error
+}
+
+func (c *fakeConsumer) SeekByTime(at time.Time) error {
+	c.seekedAt = append(c.seekedAt, at)
+	return nil
+}
+
+func (c *fakeConsumer) Receive(ctx context.Context) (pulsar.Message, error) {
+	if c.receiveErr != nil {
+		return nil, c.receiveErr
+	}
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *fakeConsumer) Ack(pulsar.Message) error { return nil }
+func (c *fakeConsumer) Close()                   {}
+
+func (c *fakeConsumer) AckWithTxn(msg pulsar.Message, txn pulsar.Transaction) error {
+	txn.(*fakeTransaction).acked++
+	return nil
+}
+
+// fakeTransaction delivers its messages to the consumer on commit
+type fakeTransaction struct {
+	pulsar.Transaction
+	state     pulsar.TxnState
+	acked     int
+	commitErr error
+	committed chan struct{}
+}
+
+func newFakeTransaction() *fakeTransaction {
+	return &fakeTransaction{state: pulsar.TxnOpen, committed: make(chan struct{})}
+}
+
+func (txn *fakeTransaction) Commit(context.Context) error {
+	if txn.commitErr != nil {
+		return txn.commitErr
+	}
+	txn.state = pulsar.TxnCommitted
+	close(txn.committed)
+	return nil
+}
+
+func (txn *fakeTransaction) Abort(context.Context) error {
+	txn.state = pulsar.TxnAborted
+	return nil
+}
+
+func (txn *fakeTransaction) GetState() pulsar.TxnState { return txn.state }
+
+// Nack redelivers the message after the delay, the message is dropped if the delay is negative
+func (c *fakeConsumer) Nack(msg pulsar.Message) {
+	c.nacked++
+	if c.nackDelay < 0 {
+		return
+	}
+	go func() {
+		time.Sleep(c.nackDelay)
+		c.messages <- msg
+	}()
+}
+
+// fakeProducer acknowledges a message after the delay and delivers it to the consumer
+type fakeProducer struct {
+	pulsar.Producer
+	consumer    *fakeConsumer
+	delay       time.Duration
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	// outOfOrder holds back the first message until the next message is delivered
+	outOfOrder bool
+	sent       int
+	delivered  int
+	held       pulsar.Message
+	// stagger delivers every message one delay after the previous message
+	stagger bool
+	// dropProperty removes the property from the delivered message
+	dropProperty string
+}
+
+func (p *fakeProducer) SendAsync(ctx context.Context, msg *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	p.mu.Lock()
+	p.inFlight++
+	if p.inFlight > p.maxInFlight {
+		p.maxInFlight = p.inFlight
+	}
+	seq := p.sent
+	p.sent++
+	p.mu.Unlock()
+	go func() {
+		if p.stagger {
+			time.Sleep(p.delay * time.Duration(seq+1))
+		} else {
+			time.Sleep(p.delay)
+		}
+		if txn, ok := msg.Transaction.(*fakeTransaction); ok {
+			p.mu.Lock()
+			p.inFlight--
+			p.mu.Unlock()
+			callback(nil, msg, nil)
+			<-txn.committed
+			p.consumer.messages <- &fakeMessage{payload: msg.Payload, properties: msg.Properties}
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.inFlight--
+		properties := make(map[string]string, len(msg.Properties))
+		for key, value := range msg.Properties {
+			if key != p.dropProperty {
+				properties[key] = value
+			}
+		}
+		// the first message is already out of order if a later message has been delivered ahead of it
+		if p.outOfOrder && seq == 0 && p.delivered == 0 {
+			p.held = &fakeMessage{payload: msg.Payload, properties: properties}
+		} else {
+			p.delivered++
+			p.consumer.messages <- &fakeMessage{payload: msg.Payload, properties: properties}
+			if p.held != nil {
+				p.consumer.messages <- p.held
+				p.held = nil
+			}
+		}
+		callback(nil, msg, nil)
+	}()
+}
+
+func (p *fakeProducer) Close() {}
+
+type fakePulsarClient struct {
+	pulsar.Client
+	producer        *fakeProducer
+	producerOptions pulsar.ProducerOptions
+	consumerOptions pulsar.ConsumerOptions
+	mu              sync.Mutex
+	closed          bool
+	transactions    []*fakeTransaction
+	// transactionErr fails to open a transaction
+	transactionErr error
+	// commitErr fails to commit the transactions
+	commitErr error
+	// createProducerErr and subscribeErr fail to create the producer and the consumer
+	createProducerErr error
+	subscribeErr      error
+}
+
+func (c *fakePulsarClient) NewTransaction(time.Duration) (pulsar.Transaction, error) {
+	if c.transactionErr != nil {
+		return nil, c.transactionErr
+	}
+	txn := newFakeTransaction()
+	txn.commitErr = c.commitErr
+	c.transactions = append(c.transactions, txn)
+	return txn, nil
+}
+
+func (c *fakePulsarClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+}
+
+func (c *fakePulsarClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *fakePulsarClient) CreateProducer(opts pulsar.ProducerOptions) (pulsar.Producer, error) {
+	c.producerOptions = opts
+	if c.createProducerErr != nil {
+		return nil, c.createProducerErr
+	}
+	return c.producer, nil
+}
+
+func (c *fakePulsarClient) Subscribe(opts pulsar.ConsumerOptions) (pulsar.Consumer, error) {
+	c.consumerOptions = opts
+	if c.subscribeErr != nil {
+		return nil, c.subscribeErr
+	}
+	return c.producer.consumer, nil
+}
+
+// newFakePulsarClient registers a fake client for the pulsar url in the client cache
+func newFakePulsarClient(t *testing.T, pulsarURL string, delay time.Duration) *fakePulsarClient {
+	client := &fakePulsarClient{
+		producer: &fakeProducer{
+			consumer: &fakeConsumer{messages: make(chan pulsar.Message, 1000)},
+			delay:    delay,
+		},
+	}
+	clientsLock.Lock()
+	clients[pulsarURL] = client
+	clientsLock.Unlock()
+	t.Cleanup(func() {
+		evictPulsarClient(pulsarURL)
+	})
+	return client
+}
+
+func TestPubSubLatencyRetries(t *testing.T) {
+	attempts := 0
+	failThenSucceed := func() (MsgResult, error) {
+		attempts++
+		if attempts == 1 {
+			return MsgResult{Latency: failedLatency}, errors.New("consumer Receive() error: context deadline exceeded")
+		}
+		return MsgResult{Latency: 20 * time.Millisecond, InOrderDelivery: true}, nil
+	}
+
+	result, err := pubSubLatencyWithRetries("retry-cluster", 2, failThenSucceed)
+	errNil(t, err)
+	assert(t, attempts == 2, "expect the probe to stop at the first success, attempts %d", attempts)
+	assert(t, result.Latency == 20*time.Millisecond, "expect the successful attempt latency")
+
+	// no retry is the default behaviour
+	attempts = 0
+	_, err = pubSubLatencyWithRetries("retry-cluster", 0, failThenSucceed)
+	assert(t, err != nil, "expect the first failure to be reported without retries")
+	assert(t, attempts == 1, "expect a single attempt")
+
+	alwaysFail := func() (MsgResult, error) {
+		attempts++
+		return MsgResult{Latency: failedLatency}, errors.New("latency measure not received after timeout")
+	}
+	attempts = 0
+	_, err = pubSubLatencyWithRetries("retry-cluster", 3, alwaysFail)
+	assert(t, err != nil, "expect the failure after all retries")
+	assert(t, attempts == 4, "expect one attempt plus 3 retries, attempts %d", attempts)
+}
+
+func TestPubSubLatencyInFlightLimit(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:           "pulsar://inflight-test:6650",
+		TopicName:           "persistent://tenant/ns/inflight-test",
+		MaxInFlightMessages: 3,
+	}
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 5*time.Millisecond)
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 30)
+
+	_, err := PubSubLatency("inflight-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, client.producer.maxInFlight <= 3, "expect in-flight messages never exceed 3 but got %d", client.producer.maxInFlight)
+	assert(t, client.producerOptions.MaxPendingMessages == 3, "expect the producer pending queue sized to the limit")
+
+	// unbounded by default
+	topicCfg.MaxInFlightMessages = 0
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 5*time.Millisecond)
+	_, err = PubSubLatency("inflight-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, client.producer.maxInFlight > 3, "expect unbounded in-flight messages but got %d", client.producer.maxInFlight)
+}
+
+func TestPubSubLatencyPerMessageHistogram(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL: "pulsar://per-message-test:6650",
+		TopicName: "persistent://tenant/ns/per-message-test",
+	}
+	newFakePulsarClient(t, topicCfg.PulsarURL, time.Millisecond)
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 7)
+
+	result, err := PubSubLatency("per-message-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, len(result.messageLatencies) == 7, "expect the latency of 7 messages but got %d", len(result.messageLatencies))
+
+	opt := prometheus.GaugeOpts{Namespace: "pulsar", Subsystem: "per_message_test", Name: "latency_ms", Help: "per message latency test"}
+	PromMessageLatencies(opt, "per-message-cluster", result.Latency, result.messageLatencies, "")
+	families, err := prometheus.DefaultGatherer.Gather()
+	errNil(t, err)
+	var observations, summaries uint64
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			switch family.GetName() {
+			case "pulsar_per_message_test_latency_ms_histogram":
+				observations += metric.GetHistogram().GetSampleCount()
+			case "pulsar_per_message_test_latency_ms_hst":
+				summaries += metric.GetSummary().GetSampleCount()
+			}
+		}
+	}
+	assert(t, observations == 7, "expect 7 histogram observations but got %d", observations)
+	assert(t, summaries == 1, "expect the average observed once in the summary but got %d", summaries)
+}
+
+func TestPubSubLatencyResetSubscription(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL: "pulsar://reset-subscription-test:6650",
+		TopicName: "persistent://tenant/ns/reset-subscription-test",
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 2)
+
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	_, err := PubSubLatency("reset-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, 0 == len(client.producer.consumer.seekedAt), "expect no subscription reset by default")
+
+	topicCfg.ResetSubscriptionBeforeTest = true
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	start := time.Now()
+	_, err = PubSubLatency("reset-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, 1 == len(client.producer.consumer.seekedAt), "expect the subscription reset once but got %d", len(client.producer.consumer.seekedAt))
+	assert(t, !client.producer.consumer.seekedAt[0].Before(start), "expect the subscription reset to latest")
+	assert(t, "latency-measure" == client.consumerOptions.SubscriptionName, "expect the latency-measure subscription")
+}
+
+func TestPulsarClientOptions(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+
+	Config.MaxConnectionsPerBroker = 0
+	opts := pulsarClientOptions("pulsar://localhost:6650", nil, "")
+	assert(t, 0 == opts.MaxConnectionsPerBroker, "expect the client default connection pool size")
+	assert(t, nil == opts.Authentication, "expect no authentication without a token supplier")
+	assert(t, "" == opts.ListenerName, "expect the broker default listener")
+
+	Config.MaxConnectionsPerBroker = 8
+	Config.ListenerName = "internal"
+	opts = pulsarClientOptions("pulsar://localhost:6650", func() (string, error) { return "token", nil }, "")
+	assert(t, 8 == opts.MaxConnectionsPerBroker, "expect 8 connections per broker but got %d", opts.MaxConnectionsPerBroker)
+	assert(t, "pulsar://localhost:6650" == opts.URL, "expect the client url")
+	assert(t, nil != opts.Authentication, "expect token authentication")
+	assert(t, "internal" == opts.ListenerName, "expect the internal listener but got %s", opts.ListenerName)
+	assert(t, !opts.EnableTransaction, "expect no transaction coordinator client without a transactional topic")
+
+	Config.PulsarTopicConfig = []TopicCfg{{PulsarURL: "pulsar://localhost:6650", UseTransaction: true}}
+	opts = pulsarClientOptions("pulsar://localhost:6650", nil, "")
+	assert(t, opts.EnableTransaction, "expect the transaction coordinator client of a transactional topic")
+	opts = pulsarClientOptions("pulsar://other:6650", nil, "")
+	assert(t, !opts.EnableTransaction, "expect no transaction coordinator client of another pulsar url")
+}
+
+func TestPubSubLatencyTransaction(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL: "pulsar://transaction-test:6650",
+		TopicName: "persistent://tenant/ns/transaction-test",
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 1)
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+
+	_, err := PubSubLatency("transaction-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, "persistent://tenant/ns/transaction-test" == client.producerOptions.Topic, "expect the non-transactional producer by default")
+
+	assert(t, 0 == len(client.transactions), "expect no transaction by default")
+
+	topicCfg.UseTransaction = true
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	payloads, maxPayloadSize = AllMsgPayloads("messageid", []string{"10B"}, 3)
+	result, err := PubSubLatency("transaction-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, result.Latency < failedLatency, "expect the transactional latency but got %v", result.Latency)
+	assert(t, 2 == len(client.transactions), "expect the produce and the ack transactions but got %d", len(client.transactions))
+	for _, txn := range client.transactions {
+		assert(t, pulsar.TxnCommitted == txn.GetState(), "expect both transactions committed")
+	}
+	assert(t, 3 == client.transactions[1].acked, "expect 3 messages acked in the ack transaction but got %d", client.transactions[1].acked)
+
+	// the failure of the transaction coordinator fails the test
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	client.transactionErr = errors.New("transaction coordinator not found")
+	result, err = PubSubLatency("transaction-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	assert(t, err != nil && strings.Contains(err.Error(), "failed to open the produce transaction"), "expect the open error but got %v", err)
+	assert(t, failedLatency == result.Latency, "expect the failed latency")
+
+	// the transactions not committed are aborted if the produce commit fails
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	client.commitErr = errors.New("transaction timeout")
+	result, err = PubSubLatency("transaction-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	assert(t, err != nil && strings.Contains(err.Error(), "failed to commit the produce transaction"), "expect the commit error but got %v", err)
+	assert(t, failedLatency == result.Latency, "expect the failed latency")
+	assert(t, pulsar.TxnAborted == client.transactions[1].GetState(), "expect the ack transaction aborted")
+}
+
+func TestPubSubLatencyPayloadEncoding(t *testing.T) {
+	for _, encoding := range []string{"base64", "hex"} {
+		topicCfg := TopicCfg{
+			PulsarURL:       "pulsar://encoding-test:6650",
+			TopicName:       "persistent://tenant/ns/encoding-test",
+			PayloadEncoding: encoding,
+		}
+		payloads, maxPayloadSize := AllEncodedMsgPayloads("messageid", []string{"100B"}, 2, encoding)
+		newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+		_, err := PubSubLatency("encoding-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+		errNil(t, err)
+
+		// the altered payload delivered to an encoding test is not preserved in the encoding,
+		// the failure is reported even though the sender is still in flight
+		payloads = append([][]byte{[]byte("messageid-0-altered body!")}, payloads...)
+		topicCfg.MaxInFlightMessages = 1
+		for i := 0; i < 5; i++ {
+			newFakePulsarClient(t, topicCfg.PulsarURL, 5*time.Millisecond)
+			_, err = PubSubLatency("encoding-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+			assert(t, err != nil && strings.Contains(err.Error(), encoding), "expect the %s encoding not preserved but got %v", encoding, err)
+		}
+		evictPulsarClient(topicCfg.PulsarURL)
+	}
+}
+
+func TestPulsarClientRecycle(t *testing.T) {
+	saved, savedGrace := Config, clientRetireGrace
+	defer func() { Config, clientRetireGrace = saved, savedGrace }()
+	clientRetireGrace = 50 * time.Millisecond
+	pulsarURL := "pulsar://recycle-test:6650"
+
+	// a client is never aged without the max client age
+	Config.MaxClientAgeSeconds = 0
+	fake := newFakePulsarClient(t, pulsarURL, 0)
+	clientCreatedAt[pulsarURL] = time.Now().Add(-time.Hour)
+	client, err := GetPulsarClient(pulsarURL, nil)
+	errNil(t, err)
+	assert(t, client == fake && !fake.isClosed(), "expect the cached client")
+
+	Config.MaxClientAgeSeconds = 60
+	clientCreatedAt[pulsarURL] = time.Now().Add(-30 * time.Second)
+	client, err = GetPulsarClient(pulsarURL, nil)
+	errNil(t, err)
+	assert(t, client == fake && !fake.isClosed(), "expect the cached client within the max age")
+
+	clientCreatedAt[pulsarURL] = time.Now().Add(-61 * time.Second)
+	client, err = GetPulsarClient(pulsarURL, nil)
+	errNil(t, err)
+	defer client.Close()
+	assert(t, client != fake, "expect a new client after the max age")
+	assert(t, !fake.isClosed(), "expect the aged client open for the concurrent tests")
+	time.Sleep(100 * time.Millisecond)
+	assert(t, fake.isClosed(), "expect the aged client closed after the retire grace period")
+	assert(t, time.Since(clientCreatedAt[pulsarURL]) < time.Minute, "expect the new client creation time tracked")
+
+	// a failed test recycles the cached client
+	fake = newFakePulsarClient(t, pulsarURL, 0)
+	recyclePulsarClient(pulsarURL, nil)
+	_, ok := clients[pulsarURL]
+	assert(t, !ok, "expect the recycled client removed from the cache")
+	assert(t, !fake.isClosed(), "expect the recycled client open for the concurrent tests")
+	time.Sleep(100 * time.Millisecond)
+	assert(t, fake.isClosed(), "expect the recycled client closed after the retire grace period")
+}
+
+func TestPulsarClientRetiredOnCreateFailure(t *testing.T) {
+	savedGrace := clientRetireGrace
+	defer func() { clientRetireGrace = savedGrace }()
+	clientRetireGrace = 50 * time.Millisecond
+	topicCfg := TopicCfg{
+		PulsarURL:     "pulsar://create-failure-test:6650",
+		TopicName:     "persistent://tenant/ns/create-failure-test",
+		PayloadSizes:  []string{"10B"},
+		NumOfMessages: 1,
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", topicCfg.PayloadSizes, topicCfg.NumOfMessages)
+
+	for _, failure := range []string{"producer", "subscribe"} {
+		fake := newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+		if failure == "producer" {
+			fake.createProducerErr = errors.New("producer creation timed out")
+		} else {
+			fake.subscribeErr = errors.New("subscribe timed out")
+		}
+		_, err := pubSubLatency("create-failure-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+		assert(t, err != nil, "expect the %s failure", failure)
+
+		clientsLock.Lock()
+		_, cached := clients[topicCfg.PulsarURL]
+		clientsLock.Unlock()
+		assert(t, !cached, "expect the client removed from the cache on the %s failure", failure)
+		assert(t, !fake.isClosed(), "expect the client open for the concurrent tests on the %s failure", failure)
+		time.Sleep(100 * time.Millisecond)
+		assert(t, fake.isClosed(), "expect the client closed after the retire grace period on the %s failure", failure)
+	}
+}
+
+func TestApplicationErrorKeepsPulsarClient(t *testing.T) {
+	defer withoutAlertDestinations()()
+	topicCfg := TopicCfg{
+		PulsarURL:            "pulsar://application-error-test:6650",
+		TopicName:            "persistent://tenant/ns/application-error-input",
+		OutputTopic:          "persistent://tenant/ns/application-error-output",
+		PropagatedProperties: []string{"trace-id"},
+		PayloadSizes:         []string{"10B"},
+		NumOfMessages:        2,
+		AlertPolicy:          AlertPolicyCfg{Ceiling: 1},
+	}
+	clusterName := "application-error-cluster"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, clusterName)
+		incidentTrackersLock.Unlock()
+		componentStatusesLock.Lock()
+		delete(componentStatuses, clusterName)
+		componentStatusesLock.Unlock()
+	}()
+	// the function drops the propagated property
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	client.producer.dropProperty = "trace-id"
+
+	testTopicLatency(clusterName, nil, topicCfg)
+	clientsLock.Lock()
+	cached := clients[topicCfg.PulsarURL]
+	clientsLock.Unlock()
+	assert(t, cached == client && !client.isClosed(), "expect the shared client kept on an application level failure")
+}
+
+func TestEvictIdlePulsarClients(t *testing.T) {
+	idleURL, activeURL := "pulsar://idle-test:6650", "pulsar://active-test:6650"
+	idle := newFakePulsarClient(t, idleURL, 0)
+	active := newFakePulsarClient(t, activeURL, 0)
+	for _, pulsarURL := range []string{idleURL, activeURL} {
+		_, err := GetPulsarClient(pulsarURL, nil)
+		errNil(t, err)
+	}
+	now := time.Now()
+	clientsLock.Lock()
+	clientLastUsedAt[idleURL] = now.Add(-11 * time.Minute)
+	clientLastUsedAt[activeURL] = now.Add(-time.Minute)
+	clientsLock.Unlock()
+
+	evicted := evictIdlePulsarClients(10*time.Minute, now)
+	assert(t, len(evicted) == 1 && evicted[0] == idleURL, "expect the idle client evicted but got %v", evicted)
+	assert(t, idle.isClosed(), "expect the idle client closed")
+	assert(t, !active.isClosed(), "expect the active client kept")
+	clientsLock.Lock()
+	_, cached := clients[idleURL]
+	_, tracked := clientLastUsedAt[idleURL]
+	clientsLock.Unlock()
+	assert(t, !cached && !tracked, "expect the idle client removed from the cache")
+
+	// the client is created again on the next use
+	recreated := &fakePulsarClient{}
+	saved := newPulsarClient
+	newPulsarClient = func(pulsar.ClientOptions) (pulsar.Client, error) { return recreated, nil }
+	defer func() { newPulsarClient = saved }()
+	client, err := GetPulsarClient(idleURL, nil)
+	errNil(t, err)
+	assert(t, client == recreated, "expect a new client after the idle eviction")
+}
+
+func TestSharedSubscriptionOutOfOrder(t *testing.T) {
+	defer withoutAlertDestinations()()
+	topicCfg := TopicCfg{
+		PulsarURL:        "pulsar://shared-order-test:6650",
+		TopicName:        "persistent://tenant/ns/shared-order-test",
+		PayloadSizes:     []string{"10B"},
+		NumOfMessages:    5,
+		SubscriptionType: "shared",
+		AlertPolicy:      AlertPolicyCfg{Ceiling: 1},
+	}
+	clusterName := "shared-order-cluster"
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, clusterName)
+		incidentTrackersLock.Unlock()
+		componentStatusesLock.Lock()
+		delete(componentStatuses, clusterName)
+		componentStatusesLock.Unlock()
+	}()
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, time.Millisecond)
+	client.producer.outOfOrder = true
+
+	testTopicLatency(clusterName, nil, topicCfg)
+	assert(t, client.consumerOptions.Type == pulsar.Shared, "expect a shared subscription")
+	incidentTrackersLock.RLock()
+	_, tracked := incidentTrackers[clusterName]
+	incidentTrackersLock.RUnlock()
+	assert(t, !tracked, "expect no incident for out of order delivery on a shared subscription")
+	componentStatusesLock.RLock()
+	status := componentStatuses[clusterName]
+	componentStatusesLock.RUnlock()
+	assert(t, status.Success, "expect the shared subscription test succeeded but got %s", status.LastError)
+
+	// the order is strictly checked on an exclusive subscription
+	topicCfg.SubscriptionType = ""
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, time.Millisecond)
+	client.producer.outOfOrder = true
+	testTopicLatency(clusterName, nil, topicCfg)
+	assert(t, client.consumerOptions.Type == pulsar.Exclusive, "expect the default exclusive subscription")
+	componentStatusesLock.RLock()
+	status = componentStatuses[clusterName]
+	componentStatusesLock.RUnlock()
+	assert(t, !status.Success, "expect the out of order failure on an exclusive subscription")
+}
+
+func TestSubscriptionType(t *testing.T) {
+	assert(t, subscriptionType("") == pulsar.Exclusive, "expect exclusive by default")
+	assert(t, subscriptionType("Failover") == pulsar.Failover, "expect failover")
+	assert(t, subscriptionType("key-shared") == pulsar.KeyShared, "expect key shared")
+	assert(t, strictOrdering(pulsar.Failover), "expect strict ordering on failover")
+	assert(t, !strictOrdering(pulsar.KeyShared), "expect no strict ordering on key shared")
+}
+
+func TestProbeClientNames(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	Config.Name = "name-test"
+	topicCfg := TopicCfg{
+		PulsarURL: "pulsar://client-name-test:6650",
+		TopicName: "persistent://tenant/ns/client-name-test",
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 1)
+
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	_, err := PubSubLatency("client-name-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, "pulsar-heartbeat-name-test-client-name-test" == client.producerOptions.Name, "expect the default producer name but got %s", client.producerOptions.Name)
+	assert(t, "pulsar-heartbeat-name-test-client-name-test" == client.consumerOptions.Name, "expect the default consumer name but got %s", client.consumerOptions.Name)
+
+	Config.ProbeNamePrefix = "probe"
+	topicCfg.subscriptionName = "latency-measure-broker-1"
+	client = newFakePulsarClient(t, topicCfg.PulsarURL, 0)
+	_, err = PubSubLatency("client-name-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	errNil(t, err)
+	assert(t, "probe-name-test-client-name-test-latency-measure-broker-1" == client.producerOptions.Name, "expect the producer name per subscription but got %s", client.producerOptions.Name)
+	assert(t, "probe-name-test-client-name-test" == client.consumerOptions.Name, "expect the prefixed consumer name but got %s", client.consumerOptions.Name)
+}
+
+func TestImplausibleLatency(t *testing.T) {
+	assert(t, !implausibleLatency(0, 0), "expect no floor by default")
+	assert(t, implausibleLatency(200*time.Microsecond, 1), "expect a sub floor latency flagged")
+	assert(t, !implausibleLatency(time.Millisecond, 1), "expect the latency at the floor plausible")
+	assert(t, !implausibleLatency(30*time.Millisecond, 1), "expect a realistic latency plausible")
+}
+
+func TestPubSubLatencyProbeTimeout(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:           "pulsar://probe-timeout-test:6650",
+		TopicName:           "persistent://tenant/ns/probe-timeout-test",
+		ProbeTimeoutSeconds: 1,
+	}
+	newFakePulsarClient(t, topicCfg.PulsarURL, 3*time.Second)
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 2)
+
+	start := time.Now()
+	result, err := PubSubLatency("probe-timeout-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	elapsed := time.Since(start)
+	assert(t, err != nil, "expect the probe timed out")
+	assert(t, result.Latency == failedLatency, "expect the failed latency")
+	assert(t, elapsed >= time.Second && elapsed < 2*time.Second, "expect the probe returned at the 1 second bound but took %v", elapsed)
+}
+
+func TestPubSubLatencyReceiveErrorWhileSending(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:           "pulsar://receive-error-test:6650",
+		TopicName:           "persistent://tenant/ns/receive-error-test",
+		MaxInFlightMessages: 1,
+	}
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 10)
+
+	// the receive fails while the sender is still in flight, the failure is never reported as a result
+	for i := 0; i < 10; i++ {
+		client := newFakePulsarClient(t, topicCfg.PulsarURL, 5*time.Millisecond)
+		client.producer.consumer.receiveErr = errors.New("consumer closed")
+		result, err := PubSubLatency("receive-error-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+		assert(t, err != nil && strings.Contains(err.Error(), "consumer closed"), "expect the receive error but got %v", err)
+		assert(t, failedLatency == result.Latency, "expect the failed latency")
+	}
+}
+
+func TestProbeTimeout(t *testing.T) {
+	receiveTimeout := 5 * time.Second
+	assert(t, 30*time.Second == probeTimeout(30, 100, receiveTimeout), "expect the configured timeout")
+	assert(t, 10*time.Second == probeTimeout(0, 2, receiveTimeout), "expect 5 seconds per message")
+	assert(t, maxDefaultProbeTimeout == probeTimeout(0, 1000, receiveTimeout), "expect the default capped")
+	assert(t, 80*time.Second == probeTimeout(0, 1000, 40*time.Second), "expect twice the receive timeout of large payloads")
+}
+
+func TestPubSubLatencyReceiveDeadline(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:              "pulsar://receive-deadline-test:6650",
+		TopicName:              "persistent://tenant/ns/receive-deadline-test",
+		ProbeTimeoutSeconds:    10,
+		ReceiveDeadlineSeconds: 1,
+	}
+	// every receive is within the receive timeout but all the messages take 2 seconds
+	client := newFakePulsarClient(t, topicCfg.PulsarURL, 400*time.Millisecond)
+	client.producer.stagger = true
+	payloads, maxPayloadSize := AllMsgPayloads("messageid", []string{"10B"}, 5)
+
+	start := time.Now()
+	_, err := PubSubLatency("receive-deadline-cluster", nil, topicCfg, "messageid", payloads, maxPayloadSize)
+	elapsed := time.Since(start)
+	assert(t, err != nil, "expect the receive loop deadline error")
+	assert(t, strings.Contains(err.Error(), "received 2 of 5 messages before the receive loop deadline 1s"), "expect the partial receive counts but got %v", err)
+	assert(t, elapsed < 1500*time.Millisecond, "expect the probe returned at the receive loop deadline but took %v", elapsed)
+}
@@ -0,0 +1,80 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// report consumer receive errors only when they are sustained over a sliding window
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// receiveError is a consumer receive failure of the pub sub test
+type receiveError struct {
+	err error
+}
+
+func (e *receiveError) Error() string { return "consumer Receive() error: " + e.err.Error() }
+func (e *receiveError) Unwrap() error { return e.err }
+
+var (
+	// key is the cluster name, value is the time of the receive errors within the window
+	receiveErrors     = make(map[string][]time.Time)
+	receiveErrorsLock = &sync.Mutex{}
+)
+
+// receiveErrorWindow returns the sliding window of the receive error rate
+func receiveErrorWindow(rate ReceiveErrorRateCfg) time.Duration {
+	return util.TimeDuration(rate.WindowSeconds, 600, time.Second)
+}
+
+// countReceiveErrors adds the receive error at the time and returns the errors within the window
+func countReceiveErrors(errorTimes []time.Time, now time.Time, window time.Duration) []time.Time {
+	kept := errorTimes[:0]
+	for _, t := range errorTimes {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	return append(kept, now)
+}
+
+// tolerateReceiveError counts a consumer receive error of the cluster, it returns true if the error is not to be reported
+// because the errors within the window do not exceed the maximum, every error is reported if the maximum is not specified
+func tolerateReceiveError(cluster string, rate ReceiveErrorRateCfg, err error, now time.Time) bool {
+	var recvErr *receiveError
+	if !errors.As(err, &recvErr) {
+		return false
+	}
+	PromCounter(ReceiveErrorCounterOpt(), cluster)
+	if rate.MaxErrors <= 0 {
+		return false
+	}
+
+	receiveErrorsLock.Lock()
+	defer receiveErrorsLock.Unlock()
+	receiveErrors[cluster] = countReceiveErrors(receiveErrors[cluster], now, receiveErrorWindow(rate))
+	return len(receiveErrors[cluster]) <= rate.MaxErrors
+}
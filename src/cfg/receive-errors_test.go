@@ -0,0 +1,56 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestReceiveErrorRate(t *testing.T) {
+	cluster := "receive-error-cluster"
+	defer func() {
+		receiveErrorsLock.Lock()
+		delete(receiveErrors, cluster)
+		receiveErrorsLock.Unlock()
+	}()
+	rate := ReceiveErrorRateCfg{MaxErrors: 2, WindowSeconds: 60}
+	recvErr := fmt.Errorf("attempt failed: %w", &receiveError{err: errors.New("connection closed")})
+	now := time.Now()
+
+	assert(t, tolerateReceiveError(cluster, rate, recvErr, now), "expect the first receive error tolerated")
+	assert(t, tolerateReceiveError(cluster, rate, recvErr, now.Add(10*time.Second)), "expect the second receive error tolerated")
+	assert(t, !tolerateReceiveError(cluster, rate, recvErr, now.Add(20*time.Second)), "expect the sustained receive errors reported")
+
+	// the errors out of the window are no longer counted
+	assert(t, tolerateReceiveError(cluster, rate, recvErr, now.Add(95*time.Second)), "expect an isolated receive error tolerated")
+
+	assert(t, !tolerateReceiveError(cluster, rate, errors.New("producer send error"), now), "expect other errors reported")
+	assert(t, !tolerateReceiveError(cluster+"-default", ReceiveErrorRateCfg{}, recvErr, now), "expect every receive error reported by default")
+
+	counter := testutil.ToFloat64(counters["pulsar-pubsub-receive_error_total"].WithLabelValues(cluster))
+	assert(t, counter == 4, "expect 4 receive errors counted but got %v", counter)
+}
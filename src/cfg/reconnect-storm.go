@@ -0,0 +1,85 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// alert on the cached Pulsar clients re-created repeatedly after failures, an unstable link even if the probes succeed on retry
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// reconnectRetention bounds the reconnect history of every pulsar url regardless of the configured window
+const reconnectRetention = 24 * time.Hour
+
+var (
+	// key is the pulsar url, the reconnect times are ordered with the oldest first
+	clientReconnects = make(map[string][]time.Time)
+	reconnectsLock   = &sync.Mutex{}
+)
+
+// recordClientReconnect records the cached client of the pulsar url is dropped on a failure,
+// the next test has to connect again with a new client
+func recordClientReconnect(pulsarURL string, now time.Time) {
+	PromCounter(ClientReconnectCounterOpt(), clusterHost(pulsarURL))
+	reconnectsLock.Lock()
+	defer reconnectsLock.Unlock()
+	times := append(clientReconnects[pulsarURL], now)
+	for len(times) > 0 && now.Sub(times[0]) > reconnectRetention {
+		times = times[1:]
+	}
+	clientReconnects[pulsarURL] = times
+}
+
+// reconnectStorm returns the number of reconnects within the window and whether it exceeds the maximum
+func reconnectStorm(times []time.Time, maxReconnects int, window time.Duration, now time.Time) (int, bool) {
+	count := 0
+	for _, t := range times {
+		if now.Sub(t) <= window {
+			count++
+		}
+	}
+	return count, count > maxReconnects
+}
+
+// TestReconnectStorm reports an incident when the client of the topic reconnects more than the maximum within the window
+func TestReconnectStorm(clusterName string, topicCfg TopicCfg) {
+	stormCfg := topicCfg.ReconnectStorm
+	window := util.TimeDuration(stormCfg.WindowSeconds, 600, time.Second)
+	reconnectsLock.Lock()
+	count, storm := reconnectStorm(clientReconnects[topicCfg.PulsarURL], stormCfg.MaxReconnects, window, time.Now())
+	reconnectsLock.Unlock()
+
+	component := clusterName + "-reconnect-storm"
+	if storm {
+		errMsg := fmt.Sprintf("cluster %s, pulsar client to %s reconnected %d times over the maximum %d within %v",
+			clusterName, topicCfg.PulsarURL, count, stormCfg.MaxReconnects, window)
+		log.Errorf(errMsg)
+		ReportIncident(component, clusterName, "pulsar client reconnect storm", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	ClearIncident(component)
+}
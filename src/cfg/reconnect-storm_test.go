@@ -0,0 +1,63 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectStormDecision(t *testing.T) {
+	now := time.Now()
+	times := []time.Time{now.Add(-20 * time.Minute), now.Add(-5 * time.Minute), now.Add(-2 * time.Minute), now.Add(-time.Second)}
+
+	count, storm := reconnectStorm(times, 3, 10*time.Minute, now)
+	assert(t, count == 3 && !storm, "expect 3 reconnects within the maximum but got %d, %v", count, storm)
+	count, storm = reconnectStorm(times, 2, 10*time.Minute, now)
+	assert(t, count == 3 && storm, "expect 3 reconnects over the maximum but got %d, %v", count, storm)
+	count, storm = reconnectStorm(times, 3, 30*time.Minute, now)
+	assert(t, count == 4 && storm, "expect 4 reconnects within the wider window but got %d, %v", count, storm)
+	count, storm = reconnectStorm(nil, 0, 10*time.Minute, now)
+	assert(t, count == 0 && !storm, "expect no storm without reconnects")
+}
+
+func TestRecordClientReconnect(t *testing.T) {
+	pulsarURL := "pulsar://reconnect-storm:6650"
+	defer func() {
+		reconnectsLock.Lock()
+		delete(clientReconnects, pulsarURL)
+		reconnectsLock.Unlock()
+	}()
+
+	now := time.Now()
+	recordClientReconnect(pulsarURL, now.Add(-25*time.Hour))
+	recordClientReconnect(pulsarURL, now.Add(-time.Minute))
+	// a recycled client is a reconnect
+	recyclePulsarClient(pulsarURL, nil)
+
+	reconnectsLock.Lock()
+	times := clientReconnects[pulsarURL]
+	reconnectsLock.Unlock()
+	assert(t, len(times) == 2, "expect the reconnects older than the retention dropped but got %v", times)
+	_, storm := reconnectStorm(times, 1, 10*time.Minute, time.Now())
+	assert(t, storm, "expect a reconnect storm over the maximum")
+}
@@ -0,0 +1,178 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// aggregate the failures of a region's components into a single region incident
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// defaultRegionFailureThreshold pages the region when a majority of its components fail
+const defaultRegionFailureThreshold = 0.5
+
+var (
+	// key is the region, value is the number of configured clusters in the region
+	regionSizes = make(map[string]int)
+	// key is the region, value is the failing components of every failing cluster
+	regionFailures = make(map[string]map[string]map[string]bool)
+	// the set of regions with an open region incident
+	degradedRegions = make(map[string]bool)
+	// key is the component, value is the region and the cluster of the component
+	componentRegions = make(map[string]regionMember)
+	regionsLock      = &sync.Mutex{}
+)
+
+// regionMember is the region and the cluster host of a failing component
+type regionMember struct {
+	region  string
+	cluster string
+}
+
+// setRegions assigns the region to the alert policies and counts the clusters of every region by the cluster host,
+// the topics of the same cluster count as one member of the region
+func (c *Configuration) setRegions() {
+	clusters := make(map[string]map[string]bool)
+	addCluster := func(region, rawURL string) {
+		if region == "" {
+			return
+		}
+		if _, ok := clusters[region]; !ok {
+			clusters[region] = make(map[string]bool)
+		}
+		clusters[region][clusterHost(rawURL)] = true
+	}
+	for i := range c.PulsarTopicConfig {
+		c.PulsarTopicConfig[i].AlertPolicy.region = c.PulsarTopicConfig[i].Region
+		addCluster(c.PulsarTopicConfig[i].Region, c.PulsarTopicConfig[i].PulsarURL)
+	}
+	for i := range c.PulsarAdminConfig.Clusters {
+		c.PulsarAdminConfig.Clusters[i].AlertPolicy.region = c.PulsarAdminConfig.Clusters[i].Region
+		addCluster(c.PulsarAdminConfig.Clusters[i].Region, c.PulsarAdminConfig.Clusters[i].URL)
+	}
+	sizes := make(map[string]int)
+	for region, hosts := range clusters {
+		sizes[region] = len(hosts)
+	}
+
+	regionsLock.Lock()
+	defer regionsLock.Unlock()
+	regionSizes = sizes
+}
+
+// regionComponent is the component name of the region incident
+func regionComponent(region string) string {
+	return "region-" + region
+}
+
+// regionFailureThreshold is the fraction of the failing clusters over which a region is degraded
+func regionFailureThreshold() float64 {
+	if threshold := GetConfig().RegionFailureThreshold; threshold > 0 {
+		return threshold
+	}
+	return defaultRegionFailureThreshold
+}
+
+// trackRegionFailure marks the component of the cluster failing in the region
+// it returns the number of failing clusters, the number of clusters in the region, whether the failing clusters exceed
+// the threshold, and whether the region incident is open
+func trackRegionFailure(region, cluster, component string) (failing, size int, overThreshold, degraded bool) {
+	regionsLock.Lock()
+	defer regionsLock.Unlock()
+	componentRegions[component] = regionMember{region: region, cluster: cluster}
+	if _, ok := regionFailures[region]; !ok {
+		regionFailures[region] = make(map[string]map[string]bool)
+	}
+	if _, ok := regionFailures[region][cluster]; !ok {
+		regionFailures[region][cluster] = make(map[string]bool)
+	}
+	regionFailures[region][cluster][component] = true
+
+	failing, size = len(regionFailures[region]), regionSizes[region]
+	if size < failing {
+		size = failing
+	}
+	return failing, size, float64(failing)/float64(size) > regionFailureThreshold(), degradedRegions[region]
+}
+
+// markRegionDegraded opens the region incident, it returns false if the incident is already open
+func markRegionDegraded(region string) bool {
+	regionsLock.Lock()
+	defer regionsLock.Unlock()
+	if degradedRegions[region] {
+		return false
+	}
+	degradedRegions[region] = true
+	return true
+}
+
+// clearRegionFailure marks the component recovered, it returns the region if the failing clusters of the region
+// have just dropped to the threshold
+func clearRegionFailure(component string) (string, bool) {
+	regionsLock.Lock()
+	defer regionsLock.Unlock()
+	member, ok := componentRegions[component]
+	if !ok {
+		return "", false
+	}
+	delete(componentRegions, component)
+	region, size := member.region, regionSizes[member.region]
+	overThreshold := func() bool {
+		return size > 0 && float64(len(regionFailures[region]))/float64(size) > regionFailureThreshold()
+	}
+	wasOver := overThreshold()
+	delete(regionFailures[region][member.cluster], component)
+	if len(regionFailures[region][member.cluster]) == 0 {
+		delete(regionFailures[region], member.cluster)
+	}
+
+	if (degradedRegions[region] || wasOver) && !overThreshold() {
+		delete(degradedRegions, region)
+		return region, true
+	}
+	return "", false
+}
+
+// reportRegionIncident evaluates the alert policy of the region incident while the failing clusters of the region exceed
+// the threshold, a single region incident is created once the policy is met.
+// It returns whether the region incident is created and whether the region is degraded,
+// the individual incident is suppressed in a degraded region
+func reportRegionIncident(region, component, desc string, eval *AlertPolicyCfg) (bool, bool) {
+	cluster := util.FirstNonEmptyString(eval.cluster, component)
+	failing, size, overThreshold, degraded := trackRegionFailure(region, cluster, component)
+	if degraded {
+		return false, true
+	}
+	if !overThreshold {
+		return false, false
+	}
+	name := regionComponent(region)
+	msg := fmt.Sprintf("region %s degraded with %d of %d clusters failed", region, failing, size)
+	if eval.Ceiling > 0 && trackIncident(name, msg, desc, eval) && markRegionDegraded(region) {
+		CreateIncident(name, name, msg, desc, "P2")
+		return true, true
+	}
+	return false, false
+}
@@ -0,0 +1,142 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+)
+
+func TestRegionIncident(t *testing.T) {
+	defer withoutAlertDestinations()()
+	regionsLock.Lock()
+	regionSizes["test-region"] = 3
+	regionsLock.Unlock()
+	defer func() {
+		incidentTrackersLock.Lock()
+		for _, component := range []string{"region-cluster1", "region-cluster2", "region-cluster3"} {
+			delete(incidentTrackers, component)
+		}
+		incidentTrackersLock.Unlock()
+		regionsLock.Lock()
+		delete(regionSizes, "test-region")
+		regionsLock.Unlock()
+	}()
+	policy := AlertPolicyCfg{Ceiling: 1, region: "test-region"}
+	tracked := func(component string) bool {
+		incidentTrackersLock.RLock()
+		defer incidentTrackersLock.RUnlock()
+		_, ok := incidentTrackers[component]
+		return ok
+	}
+
+	// a minority of failures pages the individual component
+	assert(t, ReportIncident("region-cluster1", "region-cluster1", "latency test failure", "desc", &policy), "expect an individual incident")
+	assert(t, tracked("region-cluster1"), "expect the individual incident tracked")
+
+	// a majority of failures creates a single region incident
+	assert(t, ReportIncident("region-cluster2", "region-cluster2", "latency test failure", "desc", &policy), "expect the region incident")
+	assert(t, !tracked("region-cluster2"), "expect the individual incident suppressed")
+	assert(t, !ReportIncident("region-cluster3", "region-cluster3", "latency test failure", "desc", &policy), "expect no more incident in a degraded region")
+	assert(t, !tracked("region-cluster3"), "expect the individual incident suppressed")
+	assert(t, !ReportIncident("region-cluster2", "region-cluster2", "latency test failure", "desc", &policy), "expect a single region incident")
+
+	ClearIncident("region-cluster2")
+	regionsLock.Lock()
+	assert(t, degradedRegions["test-region"], "expect the region degraded with 2 of 3 failures")
+	regionsLock.Unlock()
+	ClearIncident("region-cluster3")
+	regionsLock.Lock()
+	assert(t, !degradedRegions["test-region"], "expect the region recovered with 1 of 3 failures")
+	regionsLock.Unlock()
+	ClearIncident("region-cluster1")
+}
+
+func TestSetRegions(t *testing.T) {
+	saved := regionSizes
+	defer func() { regionSizes = saved }()
+	c := Configuration{
+		PulsarTopicConfig: []TopicCfg{
+			{Region: "us-east", PulsarURL: "pulsar+ssl://east1.example.com:6651"},
+			{Region: "us-east", PulsarURL: "pulsar+ssl://east2.example.com:6651"},
+			{},
+			// the topics of the same cluster count once
+			{Region: "us-east", PulsarURL: "pulsar+ssl://east1.example.com:6651"},
+		},
+		PulsarAdminConfig: PulsarAdminRESTCfg{Clusters: []OpsClusterCfg{
+			{Region: "us-west", URL: "https://west1.example.com"},
+			{Region: "us-east", URL: "https://east2.example.com"},
+		}},
+	}
+	c.setRegions()
+	assert(t, "us-east" == c.PulsarTopicConfig[0].AlertPolicy.region, "expect the region assigned to the alert policy")
+	assert(t, "" == c.PulsarTopicConfig[2].AlertPolicy.region, "expect no region")
+	assert(t, "us-west" == c.PulsarAdminConfig.Clusters[0].AlertPolicy.region, "expect the region assigned to the cluster alert policy")
+	assert(t, 2 == regionSizes["us-east"] && 1 == regionSizes["us-west"], "unexpected region sizes %v", regionSizes)
+}
+
+func TestRegionIncidentAlertPolicy(t *testing.T) {
+	defer withoutAlertDestinations()()
+	region := "policy-region"
+	components := []string{"policy-region-topic1", "policy-region-topic2", "policy-region-topic3"}
+	regionsLock.Lock()
+	regionSizes[region] = 2
+	regionsLock.Unlock()
+	defer func() {
+		for _, component := range components {
+			ClearIncident(component)
+		}
+		incidentTrackersLock.Lock()
+		for _, component := range append(components, regionComponent(region)) {
+			delete(incidentTrackers, component)
+		}
+		incidentTrackersLock.Unlock()
+		regionsLock.Lock()
+		delete(regionSizes, region)
+		regionsLock.Unlock()
+	}()
+	cluster1 := AlertPolicyCfg{Ceiling: 2, region: region, cluster: "cluster1.example.com"}
+	cluster2 := AlertPolicyCfg{Ceiling: 2, region: region, cluster: "cluster2.example.com"}
+	degraded := func() bool {
+		regionsLock.Lock()
+		defer regionsLock.Unlock()
+		return degradedRegions[region]
+	}
+
+	// the failing topics of one cluster are a single failing cluster of the region
+	ReportIncident(components[0], components[0], "latency test failure", "desc", &cluster1)
+	ReportIncident(components[1], components[1], "latency test failure", "desc", &cluster1)
+	assert(t, !degraded(), "expect the region not degraded with 1 of 2 clusters failed")
+
+	// the region is paged once the failures over the threshold meet the alert policy
+	ReportIncident(components[2], components[2], "latency test failure", "desc", &cluster2)
+	assert(t, !degraded(), "expect the region not paged on the first failure over the threshold")
+	ReportIncident(components[2], components[2], "latency test failure", "desc", &cluster2)
+	assert(t, degraded(), "expect the region paged by the alert policy")
+
+	// the region recovers once the failing clusters drop to the threshold
+	ClearIncident(components[2])
+	assert(t, !degraded(), "expect the region recovered with 1 of 2 clusters failed")
+	incidentTrackersLock.RLock()
+	_, tracked := incidentTrackers[regionComponent(region)]
+	incidentTrackersLock.RUnlock()
+	assert(t, !tracked, "expect the region tracker cleared on the recovery")
+}
@@ -0,0 +1,115 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// detect required topics accidentally deleted
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// missingTopics returns the required topics absent in the listed topics, a partitioned topic exists if any of its partitions is listed
+func missingTopics(required, listed []string) []string {
+	exists := make(map[string]bool, len(listed))
+	for _, topicFn := range listed {
+		exists[topicFn] = true
+		if i := strings.LastIndex(topicFn, "-partition-"); i > 0 {
+			exists[topicFn[:i]] = true
+		}
+	}
+	missing := []string{}
+	for _, topicFn := range required {
+		if !exists[topicFn] {
+			missing = append(missing, topicFn)
+		}
+	}
+	return missing
+}
+
+// listRequiredNamespaces lists the topics of every namespace of the required topics
+func listRequiredNamespaces(admin restTopicAdmin, required []string) ([]string, error) {
+	listed := []string{}
+	namespaces := make(map[string]bool)
+	for _, topicFn := range required {
+		isPersistent, tenant, namespace, _, err := util.TokenizeTopicFullName(topicFn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid required topic %s: %w", topicFn, err)
+		}
+		domain := "persistent"
+		if !isPersistent {
+			domain = "non-persistent"
+		}
+		key := domain + "/" + tenant + "/" + namespace
+		if namespaces[key] {
+			continue
+		}
+		namespaces[key] = true
+		topics, err := admin.ListTopics(domain, tenant, namespace)
+		if err != nil {
+			return nil, err
+		}
+		listed = append(listed, topics...)
+	}
+	return listed, nil
+}
+
+// PulsarRequiredTopics verifies the required topics exist on each cluster
+func PulsarRequiredTopics() {
+	adminCfg := GetConfig().PulsarAdminConfig
+	if len(adminCfg.RequiredTopics) == 0 {
+		return
+	}
+	tokenSupplier := util.TokenSupplierWithOverride(adminCfg.Token, GetConfig().TokenSupplier())
+
+	for _, cluster := range adminCfg.Clusters {
+		adminURL, err := url.ParseRequestURI(cluster.URL)
+		if err != nil {
+			panic(err) //panic because this is a showstopper
+		}
+		component := cluster.Name + "-required-topics"
+		listed, err := listRequiredNamespaces(restTopicAdmin{baseURL: cluster.URL, tokenSupplier: tokenSupplier}, adminCfg.RequiredTopics)
+		if err != nil {
+			errMsg := fmt.Sprintf("cluster %s required topics test failed, error: %v", cluster.Name, err)
+			log.Errorf(errMsg)
+			ReportIncident(component, adminURL.Hostname(), "required topics test failure", errMsg, &cluster.AlertPolicy)
+			continue
+		}
+
+		missing := missingTopics(adminCfg.RequiredTopics, listed)
+		PromGaugeInt(MissingTopicsGaugeOpt(), cluster.Name, len(missing))
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			errMsg := fmt.Sprintf("cluster %s is missing required topics: %s", cluster.Name, strings.Join(missing, ", "))
+			log.Errorf(errMsg)
+			ReportIncident(component, adminURL.Hostname(), "required topics are missing", errMsg, &cluster.AlertPolicy)
+		} else {
+			log.Infof("cluster %s has all %d required topics", cluster.Name, len(adminCfg.RequiredTopics))
+			ClearIncident(component)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMissingTopics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/v2/persistent/tenant/ns":
+			w.Write([]byte(`["persistent://tenant/ns/orders","persistent://tenant/ns/events-partition-0","persistent://tenant/ns/events-partition-1"]`))
+		case "/admin/v2/non-persistent/tenant/ns":
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	required := []string{
+		"persistent://tenant/ns/orders",
+		"persistent://tenant/ns/events",
+		"persistent://tenant/ns/payments",
+		"non-persistent://tenant/ns/presence",
+	}
+	listed, err := listRequiredNamespaces(restTopicAdmin{baseURL: server.URL}, required)
+	errNil(t, err)
+	assert(t, 3 == len(listed), "expect 3 listed topics but got %d", len(listed))
+
+	missing := missingTopics(required, listed)
+	assert(t, 2 == len(missing), "expect 2 missing topics but got %v", missing)
+	assert(t, "persistent://tenant/ns/payments" == missing[0], "expect the deleted topic missing but got %s", missing[0])
+	assert(t, "non-persistent://tenant/ns/presence" == missing[1], "expect the non persistent topic missing but got %s", missing[1])
+
+	_, err = listRequiredNamespaces(restTopicAdmin{baseURL: server.URL}, []string{"persistent://other/ns/orders"})
+	assert(t, err != nil, "expect an error listing a missing namespace")
+}
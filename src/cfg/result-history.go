@@ -0,0 +1,106 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// keep the latest latency test results of every cluster in a bounded ring buffer
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultStatusHistorySize is the number of the latest results kept for every cluster
+const defaultStatusHistorySize = 20
+
+// ProbeResult is the result of a latency test
+type ProbeResult struct {
+	Timestamp time.Time `json:"timestamp"`
+	LatencyMs int64     `json:"latencyMs"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// resultHistory is a ring buffer of the latest results
+type resultHistory struct {
+	results []ProbeResult
+	// next is the index of the oldest result to be overwritten once the buffer is full
+	next int
+}
+
+// add appends the result and overwrites the oldest one beyond the size
+func (h *resultHistory) add(result ProbeResult, size int) {
+	if len(h.results) > size {
+		// the history size is reduced, keep the latest results
+		h.results, h.next = h.list()[len(h.results)-size:], 0
+	}
+	if len(h.results) < size {
+		h.results = append(h.results, result)
+		return
+	}
+	h.results[h.next] = result
+	h.next = (h.next + 1) % size
+}
+
+// list returns the results with the oldest first
+func (h *resultHistory) list() []ProbeResult {
+	results := make([]ProbeResult, 0, len(h.results))
+	results = append(results, h.results[h.next:]...)
+	return append(results, h.results[:h.next]...)
+}
+
+var (
+	// key is the cluster name
+	histories     = make(map[string]*resultHistory)
+	historiesLock = &sync.Mutex{}
+)
+
+// RecordResultHistory records the latency test result of the cluster, an empty error message indicates success
+func RecordResultHistory(cluster string, latency time.Duration, errMsg string) {
+	size := GetConfig().StatusHistorySize
+	if size <= 0 {
+		size = defaultStatusHistorySize
+	}
+	historiesLock.Lock()
+	defer historiesLock.Unlock()
+	history, ok := histories[cluster]
+	if !ok {
+		history = &resultHistory{}
+		histories[cluster] = history
+	}
+	history.add(ProbeResult{
+		Timestamp: time.Now(),
+		LatencyMs: latency.Milliseconds(),
+		Success:   errMsg == "",
+		Error:     errMsg,
+	}, size)
+}
+
+// resultHistories returns a copy of the result history of every cluster
+func resultHistories() map[string][]ProbeResult {
+	historiesLock.Lock()
+	defer historiesLock.Unlock()
+	copied := make(map[string][]ProbeResult, len(histories))
+	for cluster, history := range histories {
+		copied[cluster] = history.list()
+	}
+	return copied
+}
@@ -0,0 +1,69 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestResultHistory(t *testing.T) {
+	history := &resultHistory{}
+	for i := 0; i < 5; i++ {
+		history.add(ProbeResult{LatencyMs: int64(i)}, 3)
+	}
+	results := history.list()
+	assert(t, len(results) == 3, "expect the history capped at 3 but got %d", len(results))
+	for i, result := range results {
+		assert(t, result.LatencyMs == int64(i+2), "expect the latest results with the oldest first but got %v", results)
+	}
+
+	// a reduced size keeps the latest results
+	history.add(ProbeResult{LatencyMs: 5}, 2)
+	results = history.list()
+	assert(t, len(results) == 2, "expect the history capped at 2 but got %d", len(results))
+	assert(t, results[0].LatencyMs == 4 && results[1].LatencyMs == 5, "expect the latest results but got %v", results)
+}
+
+func TestRecordResultHistory(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	defer func() {
+		historiesLock.Lock()
+		delete(histories, "history-cluster")
+		historiesLock.Unlock()
+	}()
+
+	Config.StatusHistorySize = 4
+	for i := 0; i < 6; i++ {
+		errMsg := ""
+		if i%2 == 1 {
+			errMsg = "latency test error " + strconv.Itoa(i)
+		}
+		RecordResultHistory("history-cluster", time.Duration(i)*time.Millisecond, errMsg)
+	}
+	results := GetStatusReport().History["history-cluster"]
+	assert(t, len(results) == 4, "expect 4 results but got %d", len(results))
+	assert(t, results[0].LatencyMs == 2 && results[0].Success, "expect the oldest kept result first but got %v", results[0])
+	assert(t, results[3].LatencyMs == 5 && !results[3].Success && results[3].Error == "latency test error 5", "expect the latest failure last but got %v", results[3])
+}
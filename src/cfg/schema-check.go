@@ -0,0 +1,124 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify the schema registration and compatibility by publishing with a declared schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// schemaSendTimeout is the timeout to publish the schema check message
+const schemaSendTimeout = 10 * time.Second
+
+// newCheckSchema creates the Pulsar schema of the schema check
+func newCheckSchema(schemaCfg SchemaCheckCfg) (pulsar.Schema, error) {
+	switch strings.ToUpper(schemaCfg.Type) {
+	case "JSON":
+		return pulsar.NewJSONSchemaWithValidation(schemaCfg.Definition, nil)
+	case "AVRO":
+		return pulsar.NewAvroSchemaWithValidation(schemaCfg.Definition, nil)
+	default:
+		return nil, fmt.Errorf("unsupported schema type %s, only AVRO and JSON are supported", schemaCfg.Type)
+	}
+}
+
+// schemaProducerOptions returns the options of the producer registering the declared schema
+func schemaProducerOptions(topicCfg TopicCfg) (pulsar.ProducerOptions, error) {
+	schema, err := newCheckSchema(topicCfg.SchemaCheck)
+	if err != nil {
+		return pulsar.ProducerOptions{}, err
+	}
+	return pulsar.ProducerOptions{
+		Topic:  util.FirstNonEmptyString(topicCfg.SchemaCheck.TopicName, topicCfg.TopicName+"-schema"),
+		Name:   probeClientName(topicCfg.TopicName) + "-schema",
+		Schema: schema,
+	}, nil
+}
+
+// schemaMessage decodes the json payload as the message value to be encoded by the schema
+func schemaMessage(payload string) (*pulsar.ProducerMessage, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(util.FirstNonEmptyString(payload, "{}")), &value); err != nil {
+		return nil, fmt.Errorf("invalid schema check payload: %w", err)
+	}
+	return &pulsar.ProducerMessage{Value: value}, nil
+}
+
+// TestSchemaCheck evaluates and reports the schema registration of the topic
+func TestSchemaCheck(topicCfg TopicCfg) {
+	pulsarURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
+	if err != nil {
+		log.Errorf("schema check is skipped, invalid pulsar url %s error: %v", topicCfg.PulsarURL, err)
+		return
+	}
+	component := pulsarURL.Hostname() + "-schema"
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	if err := schemaCheck(topicCfg, tokenSupplier); err != nil {
+		errMsg := fmt.Sprintf("%s schema test failed on %s, error: %v", component, topicCfg.TopicName, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "schema test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	log.Infof("%s schema test has successfully passed on %s", component, topicCfg.TopicName)
+	ClearIncident(component)
+}
+
+// schemaCheck creates a producer with the declared schema, which the broker registers or verifies the compatibility,
+// and publishes a message encoded by the schema
+func schemaCheck(topicCfg TopicCfg, tokenSupplier func() (string, error)) error {
+	opts, err := schemaProducerOptions(topicCfg)
+	if err != nil {
+		return err
+	}
+	msg, err := schemaMessage(topicCfg.SchemaCheck.Payload)
+	if err != nil {
+		return err
+	}
+	client, err := GetPulsarClient(topicCfg.PulsarURL, tokenSupplier)
+	if err != nil {
+		return fmt.Errorf("failed to create Pulsar client: %w", err)
+	}
+
+	producer, err := client.CreateProducer(opts)
+	if err != nil {
+		return fmt.Errorf("schema registration failed on topic %s: %w", opts.Topic, err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), schemaSendTimeout)
+	defer cancel()
+	if _, err := producer.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to publish the schema message on topic %s: %w", opts.Topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+const checkSchemaDef = `{"type":"record","name":"Heartbeat","namespace":"test","fields":[{"name":"id","type":"string"}]}`
+
+// schemaProducer records the messages published with the schema
+type schemaProducer struct {
+	pulsar.Producer
+	sent []*pulsar.ProducerMessage
+}
+
+func (p *schemaProducer) Send(_ context.Context, msg *pulsar.ProducerMessage) (pulsar.MessageID, error) {
+	p.sent = append(p.sent, msg)
+	return nil, nil
+}
+
+func (p *schemaProducer) Close() {}
+
+// schemaClient rejects the schema with the error or creates the schema producer
+type schemaClient struct {
+	pulsar.Client
+	opts     pulsar.ProducerOptions
+	producer *schemaProducer
+	err      error
+}
+
+func (c *schemaClient) CreateProducer(opts pulsar.ProducerOptions) (pulsar.Producer, error) {
+	c.opts = opts
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.producer, nil
+}
+
+func TestSchemaProducerOptions(t *testing.T) {
+	topicCfg := TopicCfg{TopicName: "persistent://tenant/ns/schema-test", SchemaCheck: SchemaCheckCfg{Type: "avro", Definition: checkSchemaDef}}
+	opts, err := schemaProducerOptions(topicCfg)
+	errNil(t, err)
+	assert(t, opts.Topic == "persistent://tenant/ns/schema-test-schema", "expect the default schema topic but got %s", opts.Topic)
+	assert(t, opts.Schema.GetSchemaInfo().Type == pulsar.AVRO, "expect the avro schema")
+
+	topicCfg.SchemaCheck = SchemaCheckCfg{Type: "JSON", Definition: checkSchemaDef, TopicName: "persistent://tenant/ns/json"}
+	opts, err = schemaProducerOptions(topicCfg)
+	errNil(t, err)
+	assert(t, opts.Topic == "persistent://tenant/ns/json", "expect the configured schema topic but got %s", opts.Topic)
+	assert(t, opts.Schema.GetSchemaInfo().Type == pulsar.JSON, "expect the json schema")
+
+	topicCfg.SchemaCheck = SchemaCheckCfg{Type: "PROTOBUF", Definition: checkSchemaDef}
+	_, err = schemaProducerOptions(topicCfg)
+	assert(t, err != nil, "expect an error on an unsupported schema type")
+
+	topicCfg.SchemaCheck = SchemaCheckCfg{Type: "AVRO", Definition: `{"type":"record"`}
+	_, err = schemaProducerOptions(topicCfg)
+	assert(t, err != nil, "expect an error on an invalid schema definition")
+}
+
+func TestSchemaCheckPublish(t *testing.T) {
+	topicCfg := TopicCfg{
+		PulsarURL:   "pulsar://schema-test:6650",
+		TopicName:   "persistent://tenant/ns/schema-test",
+		SchemaCheck: SchemaCheckCfg{Type: "AVRO", Definition: checkSchemaDef, Payload: `{"id":"heartbeat"}`},
+	}
+	client := &schemaClient{producer: &schemaProducer{}}
+	clientsLock.Lock()
+	clients[topicCfg.PulsarURL] = client
+	clientsLock.Unlock()
+	defer evictPulsarClient(topicCfg.PulsarURL)
+
+	errNil(t, schemaCheck(topicCfg, nil))
+	assert(t, client.opts.Schema != nil, "expect the producer created with the schema")
+	assert(t, len(client.producer.sent) == 1, "expect a message published but got %d", len(client.producer.sent))
+	_, err := client.opts.Schema.Encode(client.producer.sent[0].Value)
+	errNil(t, err)
+
+	client.err = errors.New("incompatible schema")
+	err = schemaCheck(topicCfg, nil)
+	assert(t, err != nil && errors.Unwrap(err) == client.err, "expect the schema registration error but got %v", err)
+
+	topicCfg.SchemaCheck.Payload = "{"
+	assert(t, schemaCheck(topicCfg, nil) != nil, "expect an error on an invalid payload")
+}
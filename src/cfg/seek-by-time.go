@@ -0,0 +1,123 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify a reader seeking by timestamp returns the message published after the timestamp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// seekByTimeMargin is how far before the publish the reader seeks to tolerate the clock skew between the client and the broker
+const seekByTimeMargin = time.Second
+
+// seekReader is the reader capability required by the seek by time check
+type seekReader interface {
+	SeekByTime(time.Time) error
+	HasNext() bool
+	Next(context.Context) (pulsar.Message, error)
+}
+
+// verifySeekByTime seeks the reader to the time and reads until the expected payload is returned
+func verifySeekByTime(reader seekReader, seekTime time.Time, expected string, timeout time.Duration) error {
+	if err := reader.SeekByTime(seekTime); err != nil {
+		return fmt.Errorf("failed to seek to %v: %w", seekTime, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	read := 0
+	for reader.HasNext() {
+		msg, err := reader.Next(ctx)
+		if err != nil {
+			return fmt.Errorf("the expected message is not read after %d messages: %w", read, err)
+		}
+		read++
+		if msg.PublishTime().Before(seekTime) {
+			return fmt.Errorf("read a message published at %v before the seek time %v", msg.PublishTime(), seekTime)
+		}
+		if string(msg.Payload()) == expected {
+			return nil
+		}
+	}
+	return fmt.Errorf("the expected message is not returned after seeking to %v, read %d messages", seekTime, read)
+}
+
+// TestSeekByTime evaluates and reports the reader seek by time
+func TestSeekByTime(topicCfg TopicCfg) {
+	pulsarURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
+	if err != nil {
+		log.Errorf("seek by time check is skipped, invalid pulsar url %s error: %v", topicCfg.PulsarURL, err)
+		return
+	}
+	component := pulsarURL.Hostname() + "-seek-by-time"
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	if err := seekByTime(topicCfg, tokenSupplier); err != nil {
+		errMsg := fmt.Sprintf("%s seek by time test failed on %s, error: %v", component, topicCfg.TopicName, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "seek by time test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	log.Infof("%s seek by time test has successfully passed on %s", component, topicCfg.TopicName)
+	ClearIncident(component)
+}
+
+// seekByTime publishes a message and verifies a reader seeking to just before the publish reads it back
+func seekByTime(topicCfg TopicCfg, tokenSupplier func() (string, error)) error {
+	client, err := GetPulsarClient(topicCfg.PulsarURL, tokenSupplier)
+	if err != nil {
+		return fmt.Errorf("failed to create Pulsar client: %w", err)
+	}
+	producer, err := client.CreateProducer(pulsar.ProducerOptions{
+		Topic: topicCfg.TopicName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create producer: %w", err)
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	seekTime := time.Now().Add(-seekByTimeMargin)
+	payload := fmt.Sprintf("heartbeat-seek-%d", time.Now().UnixNano())
+	if _, err = producer.Send(ctx, &pulsar.ProducerMessage{Payload: []byte(payload)}); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	reader, err := client.CreateReader(pulsar.ReaderOptions{
+		Topic:          topicCfg.TopicName,
+		StartMessageID: pulsar.LatestMessageID(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create reader: %w", err)
+	}
+	defer reader.Close()
+	return verifySeekByTime(reader, seekTime, payload, 30*time.Second)
+}
@@ -0,0 +1,86 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// fakeSeekReader returns the messages published at or after the seek time
+type fakeSeekReader struct {
+	messages []pulsar.Message
+	seekErr  error
+	// ignoreSeek returns every message regardless of the seek time
+	ignoreSeek bool
+	pending    []pulsar.Message
+}
+
+func (r *fakeSeekReader) SeekByTime(at time.Time) error {
+	if r.seekErr != nil {
+		return r.seekErr
+	}
+	r.pending = nil
+	for _, msg := range r.messages {
+		if r.ignoreSeek || !msg.PublishTime().Before(at) {
+			r.pending = append(r.pending, msg)
+		}
+	}
+	return nil
+}
+
+func (r *fakeSeekReader) HasNext() bool { return len(r.pending) > 0 }
+
+func (r *fakeSeekReader) Next(ctx context.Context) (pulsar.Message, error) {
+	if len(r.pending) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	msg := r.pending[0]
+	r.pending = r.pending[1:]
+	return msg, nil
+}
+
+func TestVerifySeekByTime(t *testing.T) {
+	now := time.Now()
+	messages := []pulsar.Message{
+		&fakeMessage{payload: []byte("old"), publishTime: now.Add(-time.Hour)},
+		&fakeMessage{payload: []byte("other"), publishTime: now.Add(-time.Millisecond)},
+		&fakeMessage{payload: []byte("expected"), publishTime: now},
+	}
+	seekTime := now.Add(-seekByTimeMargin)
+
+	errNil(t, verifySeekByTime(&fakeSeekReader{messages: messages}, seekTime, "expected", time.Second))
+
+	err := verifySeekByTime(&fakeSeekReader{messages: messages[:2]}, seekTime, "expected", time.Second)
+	assert(t, err != nil, "expect an error if the expected message is not returned")
+
+	err = verifySeekByTime(&fakeSeekReader{messages: messages, ignoreSeek: true}, seekTime, "expected", time.Second)
+	assert(t, err != nil, "expect an error on a message published before the seek time")
+
+	err = verifySeekByTime(&fakeSeekReader{messages: messages, seekErr: errors.New("not supported")}, seekTime, "expected", time.Second)
+	assert(t, err != nil, "expect the seek error")
+}
@@ -34,10 +34,68 @@ import (
 
 // SlackMessage is the message struct to be posted for Slack
 type SlackMessage struct {
-	Channel   string `json:"channel"`
-	Text      string `json:"text"`
-	Username  string `json:"username"`
-	IconEmogi string `json:"icon_emogi"`
+	Channel     string            `json:"channel"`
+	Text        string            `json:"text"`
+	Username    string            `json:"username"`
+	IconEmogi   string            `json:"icon_emogi"`
+	Attachments []SlackAttachment `json:"attachments,omitempty"`
+}
+
+// SlackAttachment is a color coded Slack attachment, the fallback is the plain text shown by the clients without attachment support
+type SlackAttachment struct {
+	Color    string `json:"color"`
+	Text     string `json:"text"`
+	Fallback string `json:"fallback"`
+}
+
+// SlackSeverity is the severity of a Slack alert to determine the attachment color and the mention
+type SlackSeverity string
+
+const (
+	// SeverityCritical is an incident of the critical priorities, it has the critical mention
+	SeverityCritical SlackSeverity = "critical"
+	// SeverityIncident is an incident reported or escalated
+	SeverityIncident SlackSeverity = "incident"
+	// SeverityWarning is any other alert
+	SeverityWarning SlackSeverity = "warning"
+	// SeverityRecovery is an incident cleared
+	SeverityRecovery SlackSeverity = "recovery"
+)
+
+// defaultSlackColors are the attachment colors of every severity
+var defaultSlackColors = map[SlackSeverity]string{
+	SeverityCritical: "danger",
+	SeverityIncident: "danger",
+	SeverityWarning:  "warning",
+	SeverityRecovery: "good",
+}
+
+// incidentSeverity returns whether the incident priority is critical, the default critical priority is P1
+func (s SlackCfg) incidentSeverity(priority string) SlackSeverity {
+	critical := s.CriticalPriorities
+	if len(critical) == 0 {
+		critical = []string{"P1"}
+	}
+	if util.StrContains(critical, priority) {
+		return SeverityCritical
+	}
+	return SeverityIncident
+}
+
+// slackMessage returns the plain text message, or the color coded attachment of the severity if attachments are enabled,
+// the critical mention is the text of the attachment message
+func (s SlackCfg) slackMessage(msg string, severity SlackSeverity) SlackMessage {
+	if !s.Attachments {
+		return SlackMessage{Text: msg}
+	}
+	color := util.FirstNonEmptyString(s.Colors[string(severity)], defaultSlackColors[severity])
+	slackMsg := SlackMessage{
+		Attachments: []SlackAttachment{{Color: color, Text: msg, Fallback: msg}},
+	}
+	if severity == SeverityCritical {
+		slackMsg.Text = s.CriticalMention
+	}
+	return slackMsg
 }
 
 // AlertVerbosity contains attributes required to calculate whether verbose alert is required or not
@@ -84,13 +142,17 @@ func VerboseAlert(component, message string, silenceWindow time.Duration) {
 
 // Alert alerts to slack, email, text.
 func Alert(msg string) {
+	AlertWithSeverity(msg, SeverityWarning)
+}
+
+// AlertWithSeverity alerts to slack with the attachment color and the mention of the severity
+func AlertWithSeverity(msg string, severity SlackSeverity) {
 	log.Errorf("Alert %s", msg)
-	if GetConfig().SlackConfig.AlertURL == "" {
+	slackCfg := GetConfig().SlackConfig
+	if slackCfg.AlertURL == "" || !GetConfig().alertsEnabled() {
 		return
 	}
-	err := SendSlackNotification(GetConfig().SlackConfig.AlertURL, SlackMessage{
-		Text: msg,
-	})
+	err := SendSlackNotification(slackCfg.AlertURL, slackCfg.slackMessage(msg, severity))
 	if err != nil {
 		log.Errorf("slack error %v", err)
 	}
@@ -107,7 +169,7 @@ func SendSlackNotification(webhookURL string, msg SlackMessage) error {
 
 	req.Header.Add("Content-Type", "application/json")
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := newHTTPClient(10 * time.Second)
 	resp, err := client.Do(req)
 	if resp != nil {
 		defer resp.Body.Close()
@@ -0,0 +1,69 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSlackSeverityMessage(t *testing.T) {
+	slackCfg := SlackCfg{}
+	msg := slackCfg.slackMessage("cluster down", SeverityCritical)
+	assert(t, msg.Text == "cluster down" && len(msg.Attachments) == 0, "expect the plain text without attachments but got %v", msg)
+
+	slackCfg = SlackCfg{Attachments: true, CriticalMention: "<!here>", Colors: map[string]string{"warning": "#ffcc00"}}
+	assert(t, slackCfg.incidentSeverity("P1") == SeverityCritical, "expect P1 critical by default")
+	assert(t, slackCfg.incidentSeverity("P2") == SeverityIncident, "expect P2 not critical by default")
+	msg = slackCfg.slackMessage("cluster down", SeverityIncident)
+	assert(t, msg.Text == "" && msg.Attachments[0].Color == "danger", "expect a red incident without the mention but got %v", msg)
+	msg = slackCfg.slackMessage("slow cluster", SeverityWarning)
+	assert(t, msg.Attachments[0].Color == "#ffcc00", "expect the overridden warning color but got %v", msg)
+	msg = slackCfg.slackMessage("cluster recovered", SeverityRecovery)
+	assert(t, msg.Attachments[0].Color == "good", "expect a green recovery but got %v", msg)
+}
+
+func TestSlackCriticalAlert(t *testing.T) {
+	defer withoutAlertDestinations()()
+	var received SlackMessage
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errNil(t, json.NewDecoder(r.Body).Decode(&received))
+		w.Write([]byte("ok"))
+	}))
+	defer slack.Close()
+	Config.SlackConfig = SlackCfg{
+		AlertURL:           slack.URL,
+		Attachments:        true,
+		CriticalMention:    "<!subteam^S0123>",
+		CriticalPriorities: []string{"P1", "P2"},
+	}
+
+	CreateIncident("slack-critical-component", "", "cluster down", "latency test error", "P2")
+	assert(t, received.Text == "<!subteam^S0123>", "expect the critical mention but got %s", received.Text)
+	assert(t, len(received.Attachments) == 1 && received.Attachments[0].Color == "danger", "expect a red attachment but got %v", received.Attachments)
+	attachment := received.Attachments[0]
+	assert(t, strings.Contains(attachment.Text, "slack-critical-component") && attachment.Fallback == attachment.Text,
+		"expect the alert text with the plain text fallback but got %v", attachment)
+}
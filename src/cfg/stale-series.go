@@ -0,0 +1,106 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// delete the metric series of the devices removed from the configuration on reload
+
+import (
+	"github.com/apex/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// configuredDevices returns the device label values of the components in the configuration
+func configuredDevices(c Configuration) map[string]bool {
+	devices := make(map[string]bool)
+	add := func(names ...string) {
+		for _, name := range names {
+			if name != "" {
+				devices[name] = true
+			}
+		}
+	}
+	add(c.Name)
+	for _, t := range c.PulsarTopicConfig {
+		add(t.Name, t.ClusterName)
+		if t.PulsarURL != "" {
+			add(clusterHost(t.PulsarURL))
+		}
+	}
+	for _, cluster := range c.PulsarAdminConfig.Clusters {
+		add(cluster.Name)
+	}
+	for _, site := range c.SitesConfig.Sites {
+		add(site.Name)
+	}
+	for _, ws := range c.WebSocketConfig {
+		add(ws.Name, ws.Cluster)
+	}
+	for _, k := range c.KafkaConfig {
+		add(k.Name)
+	}
+	for _, m := range c.MqttConfig {
+		add(m.Name)
+	}
+	for _, f := range c.FailoverDrillConfig {
+		add(f.Name)
+	}
+	return devices
+}
+
+// deleteRemovedSeries deletes the series of the devices no longer configured
+func deleteRemovedSeries(previous, current map[string]bool) {
+	for device := range previous {
+		if !current[device] {
+			log.Infof("delete metric series of the removed device %s", device)
+			deleteDeviceSeries(device)
+		}
+	}
+}
+
+// deleteDeviceSeries deletes every series labeled by the device
+func deleteDeviceSeries(device string) {
+	metricsLock.Lock()
+	for _, gauge := range metrics {
+		gauge.DeleteLabelValues(device)
+	}
+	for _, summary := range summaries {
+		summary.DeleteLabelValues(device)
+	}
+	for _, histogram := range histograms {
+		histogram.DeleteLabelValues(device)
+	}
+	for _, counter := range counters {
+		counter.DeleteLabelValues(device)
+	}
+	metricsLock.Unlock()
+
+	labels := prometheus.Labels{"device": device}
+	for _, vec := range []*prometheus.GaugeVec{
+		adminRequestLatency, monitorComponents, pubSubErrorClass, clusterInfo, subscriptionConsumerCount,
+		directBrokerLatency, topicMsgRateIn, siteResponseBytes, partitionCount, dlqBacklog,
+		planeHealth,
+	} {
+		if vec != nil {
+			vec.DeletePartialMatch(labels)
+		}
+	}
+}
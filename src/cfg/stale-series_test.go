@@ -0,0 +1,87 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func hasDeviceSeries(t *testing.T, name, device string) bool {
+	for _, labels := range gatheredLabels(t, name) {
+		if labels["device"] == device {
+			return true
+		}
+	}
+	return false
+}
+
+func TestReloadDeletesRemovedSeries(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	Config = Configuration{}
+
+	configFile := filepath.Join(t.TempDir(), "removed-series.yml")
+	sample := `
+name: removed-series-test
+pulsarTopicConfig:
+  - pulsarUrl: pulsar://kept.example.com:6650
+    topicName: persistent://tenant/ns/kept
+  - pulsarUrl: pulsar://removed.example.com:6650
+    topicName: persistent://tenant/ns/removed
+`
+	errNil(t, os.WriteFile(configFile, []byte(sample), 0600))
+	ReadConfigFile(configFile)
+
+	for _, cluster := range []string{"kept.example.com", "removed.example.com"} {
+		PromCounter(HeartbeatCounterOpt(), cluster)
+		PromTopicMsgRateIn(cluster, "persistent://tenant/ns/topic", 10)
+	}
+	assert(t, hasDeviceSeries(t, "pulsar_monitor_counter", "removed.example.com"), "expect the removed cluster gauge before reload")
+
+	sample = `
+name: removed-series-test
+pulsarTopicConfig:
+  - pulsarUrl: pulsar://kept.example.com:6650
+    topicName: persistent://tenant/ns/kept
+`
+	errNil(t, os.WriteFile(configFile, []byte(sample), 0600))
+	ReadConfigFile(configFile)
+
+	for _, name := range []string{"pulsar_monitor_counter", "pulsar_topic_msg_rate_in"} {
+		assert(t, !hasDeviceSeries(t, name, "removed.example.com"), "expect the removed cluster series of %s deleted", name)
+		assert(t, hasDeviceSeries(t, name, "kept.example.com"), "expect the kept cluster series of %s", name)
+	}
+
+	// the series are retained if configured
+	PromCounter(HeartbeatCounterOpt(), "removed.example.com")
+	sample = `
+name: removed-series-test
+prometheusConfig:
+  retainRemovedSeries: true
+pulsarTopicConfig: []
+`
+	errNil(t, os.WriteFile(configFile, []byte(sample), 0600))
+	ReadConfigFile(configFile)
+	assert(t, hasDeviceSeries(t, "pulsar_monitor_counter", "kept.example.com"), "expect the series retained")
+}
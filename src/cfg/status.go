@@ -0,0 +1,153 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// a read-only json status view of the last test result of every monitored component
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// ComponentStatus is the last test result of a monitored component
+type ComponentStatus struct {
+	Component   string     `json:"component"`
+	Success     bool       `json:"success"`
+	LatencyMs   int64      `json:"latencyMs"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastRun     time.Time  `json:"lastRun"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// IncidentPriority is the priority of the open incident, empty if there is no open incident
+	IncidentPriority string `json:"incidentPriority,omitempty"`
+}
+
+// StatusReport is the response of the status endpoint
+type StatusReport struct {
+	Name          string            `json:"name"`
+	GeneratedAt   time.Time         `json:"generatedAt"`
+	Components    []ComponentStatus `json:"components"`
+	OpenIncidents []string          `json:"openIncidents"`
+	// History is the latest latency test results of every cluster, the oldest first
+	History map[string][]ProbeResult `json:"history"`
+}
+
+var (
+	// key is the component name
+	componentStatuses     = make(map[string]ComponentStatus)
+	componentStatusesLock = &sync.RWMutex{}
+)
+
+// RecordStatus records the last test result of a component, an empty error message indicates success
+func RecordStatus(component string, latency time.Duration, errMsg string) {
+	componentStatusesLock.Lock()
+	defer componentStatusesLock.Unlock()
+	status := componentStatuses[component]
+	status.Component = component
+	status.Success = errMsg == ""
+	status.LatencyMs = latency.Milliseconds()
+	status.LastError = errMsg
+	status.LastRun = time.Now()
+	if status.Success {
+		lastSuccess := status.LastRun
+		status.LastSuccess = &lastSuccess
+	}
+	componentStatuses[component] = status
+}
+
+// openIncidents returns the priority of open incidents by component
+func openIncidents() map[string]string {
+	open := make(map[string]string)
+	incidentsLock.RLock()
+	for component := range incidents {
+		open[component] = "P2"
+	}
+	incidentsLock.RUnlock()
+
+	incidentTrackersLock.RLock()
+	for component, tracker := range incidentTrackers {
+		if tracker.Priority != "" {
+			open[component] = tracker.Priority
+		}
+	}
+	incidentTrackersLock.RUnlock()
+	return open
+}
+
+// GetStatusReport assembles the status report from the component statuses and the incident trackers
+func GetStatusReport() StatusReport {
+	open := openIncidents()
+	report := StatusReport{
+		Name:          GetConfig().Name,
+		GeneratedAt:   time.Now(),
+		Components:    []ComponentStatus{},
+		OpenIncidents: []string{},
+		History:       resultHistories(),
+	}
+
+	componentStatusesLock.RLock()
+	for component, status := range componentStatuses {
+		status.IncidentPriority = open[component]
+		report.Components = append(report.Components, status)
+	}
+	componentStatusesLock.RUnlock()
+
+	for component := range open {
+		report.OpenIncidents = append(report.OpenIncidents, component)
+	}
+	sort.Slice(report.Components, func(i, j int) bool { return report.Components[i].Component < report.Components[j].Component })
+	sort.Strings(report.OpenIncidents)
+	return report
+}
+
+// StatusHandler serves the status report, a bearer token is required if the token is specified
+func StatusHandler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authorizedGet(w, r, token) {
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(GetStatusReport()); err != nil {
+			log.Errorf("failed to write status report %v", err)
+		}
+	}
+}
+
+// authorizedGet verifies the request is a GET with the bearer token if the token is specified, it writes the error response otherwise
+func authorizedGet(w http.ResponseWriter, r *http.Request, token string) bool {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusHandler(t *testing.T) {
+	RecordStatus("status-ok", 20*time.Millisecond, "")
+	RecordStatus("status-failed", 10*time.Millisecond, "")
+	RecordStatus("status-failed", 0, "latency test error")
+	incidentTrackersLock.Lock()
+	incidentTrackers["status-failed"] = &IncidentAlertPolicy{Priority: "P3", Alerts: make(map[time.Time]bool)}
+	incidentTrackersLock.Unlock()
+	defer func() {
+		incidentTrackersLock.Lock()
+		delete(incidentTrackers, "status-failed")
+		incidentTrackersLock.Unlock()
+		componentStatusesLock.Lock()
+		delete(componentStatuses, "status-ok")
+		delete(componentStatuses, "status-failed")
+		componentStatusesLock.Unlock()
+	}()
+
+	rec := httptest.NewRecorder()
+	StatusHandler("")(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert(t, http.StatusOK == rec.Code, "expect status code 200 but got %d", rec.Code)
+	assert(t, "application/json" == rec.Header().Get("Content-Type"), "expect json content type")
+
+	report := StatusReport{}
+	errNil(t, json.NewDecoder(rec.Body).Decode(&report))
+	statuses := make(map[string]ComponentStatus)
+	for _, status := range report.Components {
+		statuses[status.Component] = status
+	}
+
+	ok := statuses["status-ok"]
+	assert(t, ok.Success && 20 == ok.LatencyMs && "" == ok.LastError, "unexpected status %v", ok)
+	assert(t, ok.LastSuccess != nil && !ok.LastRun.IsZero(), "expect the last success time")
+	assert(t, "" == ok.IncidentPriority, "expect no open incident")
+
+	failed := statuses["status-failed"]
+	assert(t, !failed.Success && "latency test error" == failed.LastError, "unexpected status %v", failed)
+	assert(t, failed.LastSuccess != nil && failed.LastSuccess.Before(failed.LastRun), "expect the last success time kept after a failure")
+	assert(t, "P3" == failed.IncidentPriority, "expect the open incident priority but got %s", failed.IncidentPriority)
+	found := false
+	for _, component := range report.OpenIncidents {
+		found = found || component == "status-failed"
+	}
+	assert(t, found, "expect status-failed in open incidents %v", report.OpenIncidents)
+
+	rec = httptest.NewRecorder()
+	StatusHandler("")(rec, httptest.NewRequest(http.MethodPost, "/status", nil))
+	assert(t, http.StatusMethodNotAllowed == rec.Code, "expect status code 405 but got %d", rec.Code)
+}
+
+func TestStatusHandlerAuth(t *testing.T) {
+	handler := StatusHandler("secret")
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert(t, http.StatusUnauthorized == rec.Code, "expect status code 401 without a token but got %d", rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	assert(t, http.StatusUnauthorized == rec.Code, "expect status code 401 with a wrong token but got %d", rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/status", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	assert(t, http.StatusOK == rec.Code, "expect status code 200 with the token but got %d", rec.Code)
+}
@@ -0,0 +1,133 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// detect a critical subscription losing its consumers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// subscriptionStats is the subset of the topic stats of a subscription
+type subscriptionStats struct {
+	Consumers  []json.RawMessage `json:"consumers"`
+	MsgBacklog int64             `json:"msgBacklog"`
+}
+
+// topicStats is the subset of the topic stats
+type topicStats struct {
+	MsgRateIn    float64 `json:"msgRateIn"`
+	MsgInCounter int64   `json:"msgInCounter"`
+	// PublishLatency and StorageWriteLatency are the broker reported latency in milliseconds, they are 0 if not reported
+	PublishLatency      float64                      `json:"publishLatency"`
+	StorageWriteLatency float64                      `json:"storageWriteLatency"`
+	Subscriptions       map[string]subscriptionStats `json:"subscriptions"`
+}
+
+// TopicStats gets the topic stats
+func (a restTopicAdmin) TopicStats(topicFn string) (topicStats, error) {
+	route, err := util.TopicFnToURL(topicFn)
+	if err != nil {
+		return topicStats{}, err
+	}
+	resp, err := a.do(http.MethodGet, "admin/v2/"+route+"/stats")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return topicStats{}, err
+	} else if resp.StatusCode != http.StatusOK {
+		return topicStats{}, fmt.Errorf("failed to get the stats of topic %s, returns incorrect status code %d", topicFn, resp.StatusCode)
+	}
+
+	var stats topicStats
+	if err = json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return topicStats{}, err
+	}
+	return stats, nil
+}
+
+// subscriptionConsumers returns the number of consumers of the subscription, or an error if the subscription does not exist
+func subscriptionConsumers(stats topicStats, subscription string) (int, error) {
+	sub, ok := stats.Subscriptions[subscription]
+	if !ok {
+		return 0, fmt.Errorf("subscription %s does not exist", subscription)
+	}
+	return len(sub.Consumers), nil
+}
+
+// evalSubscriptionConsumers returns an error if the subscription has fewer consumers than the minimum
+func evalSubscriptionConsumers(adminURL string, subCfg SubscriptionCfg, tokenSupplier func() (string, error)) (int, error) {
+	admin := restTopicAdmin{baseURL: adminURL, tokenSupplier: tokenSupplier}
+	stats, err := admin.TopicStats(subCfg.TopicName)
+	if err != nil {
+		return 0, err
+	}
+	consumers, err := subscriptionConsumers(stats, subCfg.Subscription)
+	if err != nil {
+		return 0, err
+	}
+	minConsumers := subCfg.MinConsumers
+	if minConsumers <= 0 {
+		minConsumers = 1
+	}
+	if consumers < minConsumers {
+		return consumers, fmt.Errorf("subscription %s on topic %s has %d consumers below the minimum %d",
+			subCfg.Subscription, subCfg.TopicName, consumers, minConsumers)
+	}
+	return consumers, nil
+}
+
+// PulsarSubscriptionConsumers verifies the configured subscriptions have the minimum number of consumers on each cluster
+func PulsarSubscriptionConsumers() {
+	adminCfg := GetConfig().PulsarAdminConfig
+	if len(adminCfg.Subscriptions) == 0 {
+		return
+	}
+	tokenSupplier := util.TokenSupplierWithOverride(adminCfg.Token, GetConfig().TokenSupplier())
+
+	for _, cluster := range adminCfg.Clusters {
+		adminURL, err := url.ParseRequestURI(cluster.URL)
+		if err != nil {
+			panic(err) //panic because this is a showstopper
+		}
+		for _, subCfg := range adminCfg.Subscriptions {
+			component := cluster.Name + "-" + subCfg.Subscription + "-consumers"
+			consumers, err := evalSubscriptionConsumers(cluster.URL, subCfg, tokenSupplier)
+			PromSubscriptionConsumers(cluster.Name, subCfg.TopicName, subCfg.Subscription, consumers)
+			if err != nil {
+				errMsg := fmt.Sprintf("cluster %s subscription consumer test failed, error: %v", cluster.Name, err)
+				log.Errorf(errMsg)
+				ReportIncident(component, adminURL.Hostname(), "subscription lost consumers", errMsg, &cluster.AlertPolicy)
+			} else {
+				log.Infof("cluster %s subscription %s has %d consumers", cluster.Name, subCfg.Subscription, consumers)
+				ClearIncident(component)
+			}
+		}
+	}
+}
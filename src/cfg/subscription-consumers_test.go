@@ -0,0 +1,77 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const sampleTopicStats = `{
+  "msgRateIn": 0.0,
+  "subscriptions": {
+    "billing": {
+      "msgBacklog": 12,
+      "type": "Shared",
+      "consumers": [
+        {"consumerName": "billing-1", "msgRateOut": 1.5},
+        {"consumerName": "billing-2", "msgRateOut": 0.5}
+      ]
+    },
+    "audit": {
+      "msgBacklog": 250,
+      "type": "Exclusive",
+      "consumers": []
+    }
+  }
+}`
+
+func TestSubscriptionConsumers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/v2/persistent/tenant/ns/orders/stats" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(sampleTopicStats))
+	}))
+	defer server.Close()
+	topicFn := "persistent://tenant/ns/orders"
+
+	consumers, err := evalSubscriptionConsumers(server.URL, SubscriptionCfg{TopicName: topicFn, Subscription: "billing"}, nil)
+	errNil(t, err)
+	assert(t, consumers == 2, "expect 2 consumers but got %d", consumers)
+
+	consumers, err = evalSubscriptionConsumers(server.URL, SubscriptionCfg{TopicName: topicFn, Subscription: "billing", MinConsumers: 3}, nil)
+	assert(t, err != nil, "expect an error below the minimum consumers")
+	assert(t, consumers == 2, "expect 2 consumers but got %d", consumers)
+
+	consumers, err = evalSubscriptionConsumers(server.URL, SubscriptionCfg{TopicName: topicFn, Subscription: "audit"}, nil)
+	assert(t, err != nil, "expect an error on a subscription without consumers")
+	assert(t, consumers == 0, "expect no consumer but got %d", consumers)
+
+	_, err = evalSubscriptionConsumers(server.URL, SubscriptionCfg{TopicName: topicFn, Subscription: "missing"}, nil)
+	assert(t, err != nil, "expect an error on a missing subscription")
+
+	_, err = evalSubscriptionConsumers(server.URL, SubscriptionCfg{TopicName: "persistent://tenant/ns/unknown", Subscription: "billing"}, nil)
+	assert(t, err != nil, "expect an error on a missing topic")
+}
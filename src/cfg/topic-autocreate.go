@@ -0,0 +1,221 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify the broker auto creates a topic on the first produce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// topicAdmin is the admin capability required by the topic auto creation check
+type topicAdmin interface {
+	TopicExists(tenant, namespace, topicFn string) (bool, error)
+	DeleteTopic(tenant, namespace, topic string) error
+}
+
+// restTopicAdmin is the topicAdmin over the Pulsar admin REST API
+type restTopicAdmin struct {
+	baseURL       string
+	tokenSupplier func() (string, error)
+}
+
+func (a restTopicAdmin) do(method, route string) (*http.Response, error) {
+	req, err := http.NewRequest(method, util.SingleSlashJoin(a.baseURL, route), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("user-agent", "pulsar-heartbeat")
+	if a.tokenSupplier != nil {
+		token, err := a.tokenSupplier()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Add("Authorization", "Bearer "+token)
+	}
+	client, err := adminHTTPClient(10 * time.Second)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	PromAdminRequest(adminEndpoint(route), req.URL.Host, responseStatusCode(resp), time.Since(start))
+	return resp, err
+}
+
+// adminEndpoint is the resource of the admin route as the endpoint label of the admin request metric,
+// i.e. namespaces of admin/v2/namespaces/tenant/namespace
+func adminEndpoint(route string) string {
+	route = strings.TrimPrefix(strings.SplitN(route, "?", 2)[0], "/")
+	resource := strings.TrimPrefix(route, "admin/v2/")
+	if resource == route {
+		// the lookup and other routes outside of the admin v2 api are labeled by their first segment
+		return strings.SplitN(route, "/", 2)[0]
+	}
+	return strings.SplitN(resource, "/", 2)[0]
+}
+
+// TopicExists checks the topic in the list of the namespace's topics
+func (a restTopicAdmin) TopicExists(tenant, namespace, topicFn string) (bool, error) {
+	topics, err := a.ListTopics("persistent", tenant, namespace)
+	if err != nil {
+		return false, err
+	}
+	return util.StrContains(topics, topicFn), nil
+}
+
+// ListTopics lists the topic full names of the namespace in the domain, either persistent or non-persistent
+// a partitioned topic is listed by its partitions
+func (a restTopicAdmin) ListTopics(domain, tenant, namespace string) ([]string, error) {
+	resp, err := a.do(http.MethodGet, "admin/v2/"+domain+"/"+tenant+"/"+namespace)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	} else if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list topics in %s/%s, returns incorrect status code %d", tenant, namespace, resp.StatusCode)
+	}
+
+	var topics []string
+	if err = json.NewDecoder(resp.Body).Decode(&topics); err != nil {
+		return nil, err
+	}
+	return topics, nil
+}
+
+// DeleteTopic force deletes the topic, a topic that does not exist is considered deleted
+func (a restTopicAdmin) DeleteTopic(tenant, namespace, topic string) error {
+	resp, err := a.do(http.MethodDelete, "admin/v2/persistent/"+tenant+"/"+namespace+"/"+url.PathEscape(topic)+"?force=true")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete topic %s, returns incorrect status code %d", topic, resp.StatusCode)
+	}
+	return nil
+}
+
+// verifyTopicAutoCreation produces to a topic that does not exist, confirms the topic is created, then deletes it,
+// the topic is deleted on every path since the producer could have created it even if the produce or the verification fails
+func verifyTopicAutoCreation(tenant, namespace, topic string, produce func(topicFn string) error, admin topicAdmin) (err error) {
+	topicFn := "persistent://" + tenant + "/" + namespace + "/" + topic
+	defer func() {
+		if deleteErr := admin.DeleteTopic(tenant, namespace, topic); deleteErr != nil {
+			if err == nil {
+				err = fmt.Errorf("failed to clean up auto created topic %s: %w", topicFn, deleteErr)
+			} else {
+				log.Errorf("failed to clean up topic %s of the failed auto creation test, error: %v", topicFn, deleteErr)
+			}
+		}
+	}()
+	if err := produce(topicFn); err != nil {
+		return fmt.Errorf("failed to produce to a new topic %s, topic auto creation could be disabled: %w", topicFn, err)
+	}
+
+	exists, err := admin.TopicExists(tenant, namespace, topicFn)
+	if err != nil {
+		return fmt.Errorf("failed to verify topic %s creation: %w", topicFn, err)
+	}
+	if !exists {
+		return fmt.Errorf("topic %s was not auto created after produce", topicFn)
+	}
+	return nil
+}
+
+// produceOnce creates a producer and sends a single message before closing the producer
+func produceOnce(client pulsar.Client, topicFn string) error {
+	producer, err := client.CreateProducer(pulsar.ProducerOptions{
+		Topic: topicFn,
+	})
+	if err != nil {
+		return err
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = producer.Send(ctx, &pulsar.ProducerMessage{
+		Payload: []byte(fmt.Sprintf("topic auto creation test message %v", time.Now())),
+	})
+	return err
+}
+
+// TestTopicAutoCreation evaluates and reports the topic auto creation under the configured namespace
+func TestTopicAutoCreation(topicCfg TopicCfg) {
+	tenant, namespace, err := autoCreateNamespace(topicCfg)
+	if err != nil {
+		log.Errorf("topic auto creation check is skipped, error: %v", err)
+		return
+	}
+	pulsarURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
+	if err != nil {
+		log.Errorf("topic auto creation check is skipped, invalid pulsar url %s error: %v", topicCfg.PulsarURL, err)
+		return
+	}
+	component := pulsarURL.Hostname() + "-topic-autocreate"
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	client, err := GetPulsarClient(topicCfg.PulsarURL, tokenSupplier)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s failed to create Pulsar client, error: %v", component, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "topic auto creation test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+
+	admin := restTopicAdmin{baseURL: adminURL(topicCfg), tokenSupplier: tokenSupplier}
+	topic := fmt.Sprintf("heartbeat-autocreate-%d", time.Now().UnixNano())
+	err = verifyTopicAutoCreation(tenant, namespace, topic, func(topicFn string) error {
+		return produceOnce(client, topicFn)
+	}, admin)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s topic auto creation test failed, error: %v", component, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "topic auto creation test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	log.Infof("%s topic auto creation test has successfully passed under %s/%s", component, tenant, namespace)
+	ClearIncident(component)
+}
+
+// autoCreateNamespace returns the configured namespace, or the namespace of the probe topic
+func autoCreateNamespace(topicCfg TopicCfg) (string, string, error) {
+	if topicCfg.AutoCreateNamespace != "" {
+		_, tenant, namespace, _, err := util.TokenizeTopicFullName("persistent://" + topicCfg.AutoCreateNamespace)
+		return tenant, namespace, err
+	}
+	_, tenant, namespace, _, err := util.TokenizeTopicFullName(topicCfg.TopicName)
+	return tenant, namespace, err
+}
@@ -0,0 +1,116 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeTopicAdmin struct {
+	topics    map[string]bool
+	calls     []string
+	deleted   []string
+	existsErr error
+	deleteErr error
+}
+
+func (a *fakeTopicAdmin) TopicExists(tenant, namespace, topicFn string) (bool, error) {
+	a.calls = append(a.calls, "exists")
+	return a.topics[topicFn], a.existsErr
+}
+
+func (a *fakeTopicAdmin) DeleteTopic(tenant, namespace, topic string) error {
+	a.calls = append(a.calls, "delete")
+	a.deleted = append(a.deleted, topic)
+	return a.deleteErr
+}
+
+func TestVerifyTopicAutoCreation(t *testing.T) {
+	admin := &fakeTopicAdmin{topics: map[string]bool{}}
+	autoCreate := func(topicFn string) error {
+		admin.calls = append(admin.calls, "produce")
+		admin.topics[topicFn] = true
+		return nil
+	}
+
+	err := verifyTopicAutoCreation("tenant", "ns", "new-topic", autoCreate, admin)
+	errNil(t, err)
+	assert(t, len(admin.calls) == 3, "expect produce, verify, and delete calls %v", admin.calls)
+	assert(t, admin.calls[0] == "produce" && admin.calls[1] == "exists" && admin.calls[2] == "delete", "unexpected sequence %v", admin.calls)
+	assert(t, admin.deleted[0] == "new-topic", "expect the new topic to be deleted")
+
+	// auto creation disabled, the topic does not exist after produce
+	admin = &fakeTopicAdmin{topics: map[string]bool{}}
+	err = verifyTopicAutoCreation("tenant", "ns", "new-topic", func(string) error { return nil }, admin)
+	assert(t, err != nil, "expect missing topic error")
+	assert(t, len(admin.deleted) == 1, "expect the delete attempted in case the topic listing lags")
+
+	// the producer could have created the topic before the send fails
+	admin = &fakeTopicAdmin{topics: map[string]bool{}}
+	err = verifyTopicAutoCreation("tenant", "ns", "new-topic", func(string) error {
+		return errors.New("server error: TopicNotFound")
+	}, admin)
+	assert(t, err != nil, "expect produce error")
+	assert(t, len(admin.calls) == 1 && admin.calls[0] == "delete", "expect only the clean up call %v", admin.calls)
+
+	// the topic is deleted if the verification fails
+	admin = &fakeTopicAdmin{topics: map[string]bool{}, existsErr: errors.New("admin unavailable")}
+	err = verifyTopicAutoCreation("tenant", "ns", "new-topic", autoCreate, admin)
+	assert(t, err != nil && strings.Contains(err.Error(), "failed to verify"), "expect the verification error but got %v", err)
+	assert(t, len(admin.deleted) == 1, "expect the topic deleted after the failed verification")
+
+	// a failed clean up fails the test, but does not mask an earlier failure
+	admin = &fakeTopicAdmin{topics: map[string]bool{}, deleteErr: errors.New("forbidden")}
+	err = verifyTopicAutoCreation("tenant", "ns", "new-topic", autoCreate, admin)
+	assert(t, err != nil && strings.Contains(err.Error(), "failed to clean up"), "expect the clean up error but got %v", err)
+	admin = &fakeTopicAdmin{topics: map[string]bool{}, deleteErr: errors.New("forbidden")}
+	err = verifyTopicAutoCreation("tenant", "ns", "new-topic", func(string) error { return nil }, admin)
+	assert(t, err != nil && strings.Contains(err.Error(), "was not auto created"), "expect the missing topic error but got %v", err)
+}
+
+func TestDeleteTopicNotFound(t *testing.T) {
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert(t, r.Method == http.MethodDelete, "expect delete but got %s", r.Method)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	admin := restTopicAdmin{baseURL: server.URL}
+
+	errNil(t, admin.DeleteTopic("tenant", "ns", "missing-topic"))
+	status = http.StatusForbidden
+	assert(t, admin.DeleteTopic("tenant", "ns", "forbidden-topic") != nil, "expect the forbidden delete to fail")
+}
+
+func TestAutoCreateNamespace(t *testing.T) {
+	tenant, ns, err := autoCreateNamespace(TopicCfg{TopicName: "persistent://tenant1/ns1/topic"})
+	errNil(t, err)
+	assert(t, tenant == "tenant1" && ns == "ns1", "expect the namespace of the topic")
+
+	tenant, ns, err = autoCreateNamespace(TopicCfg{TopicName: "persistent://tenant1/ns1/topic", AutoCreateNamespace: "tenant2/ns2"})
+	errNil(t, err)
+	assert(t, tenant == "tenant2" && ns == "ns2", "expect the configured namespace")
+}
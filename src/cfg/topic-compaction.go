@@ -0,0 +1,272 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// verify a compacted read only returns the latest value of every key
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+const (
+	compactionKeys     = 3
+	compactionVersions = 3
+)
+
+// compactionAdmin is the admin capability required by the topic compaction check
+type compactionAdmin interface {
+	Compact(tenant, namespace, topic string) error
+	CompactionStatus(tenant, namespace, topic string) (string, error)
+}
+
+// compactionStatusResponse is the compaction status returned by the admin REST API
+type compactionStatusResponse struct {
+	Status    string `json:"status"`
+	LastError string `json:"lastError"`
+}
+
+// Compact triggers the topic compaction
+func (a restTopicAdmin) Compact(tenant, namespace, topic string) error {
+	resp, err := a.do(http.MethodPut, "admin/v2/persistent/"+tenant+"/"+namespace+"/"+url.PathEscape(topic)+"/compaction")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	} else if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to trigger compaction on topic %s, returns incorrect status code %d", topic, resp.StatusCode)
+	}
+	return nil
+}
+
+// CompactionStatus returns the status of the last compaction, one of NOT_RUN, RUNNING, SUCCESS, and ERROR
+func (a restTopicAdmin) CompactionStatus(tenant, namespace, topic string) (string, error) {
+	resp, err := a.do(http.MethodGet, "admin/v2/persistent/"+tenant+"/"+namespace+"/"+url.PathEscape(topic)+"/compaction")
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return "", err
+	} else if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get compaction status of topic %s, returns incorrect status code %d", topic, resp.StatusCode)
+	}
+
+	status := compactionStatusResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return "", err
+	}
+	if status.Status == "ERROR" {
+		return status.Status, fmt.Errorf("compaction on topic %s failed: %s", topic, status.LastError)
+	}
+	return status.Status, nil
+}
+
+// keyedValue is a message key and value
+type keyedValue struct {
+	key   string
+	value string
+}
+
+// compactionMessages returns several versions of every key in produce order and the latest value of every key
+// the keys are unique to the run so that the values of the previous runs are not evaluated
+func compactionMessages(runID string, keys, versions int) ([]keyedValue, map[string]string) {
+	messages := []keyedValue{}
+	latest := make(map[string]string, keys)
+	for v := 0; v < versions; v++ {
+		for k := 0; k < keys; k++ {
+			msg := keyedValue{
+				key:   fmt.Sprintf("%s-key-%d", runID, k),
+				value: fmt.Sprintf("%s-key-%d-version-%d", runID, k, v),
+			}
+			messages = append(messages, msg)
+			latest[msg.key] = msg.value
+		}
+	}
+	return messages, latest
+}
+
+// verifyLatestValues asserts the compacted read returns exactly the latest value of every expected key
+// values of other keys are ignored
+func verifyLatestValues(expected map[string]string, received []keyedValue) error {
+	counts := make(map[string]int, len(expected))
+	for _, msg := range received {
+		latest, ok := expected[msg.key]
+		if !ok {
+			continue
+		}
+		counts[msg.key]++
+		if msg.value != latest {
+			return fmt.Errorf("key %s returns stale value %s, expected the latest value %s", msg.key, msg.value, latest)
+		}
+	}
+
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if counts[key] == 0 {
+			return fmt.Errorf("key %s is missing from the compacted read", key)
+		} else if counts[key] > 1 {
+			return fmt.Errorf("key %s returns %d values, expected only the latest value", key, counts[key])
+		}
+	}
+	return nil
+}
+
+// verifyTopicCompaction produces versioned keyed messages, compacts the topic, and verifies the compacted read
+func verifyTopicCompaction(tenant, namespace, topic, runID string, produce func([]keyedValue) error,
+	admin compactionAdmin, readCompacted func() ([]keyedValue, error), timeout time.Duration) error {
+	messages, latest := compactionMessages(runID, compactionKeys, compactionVersions)
+	if err := produce(messages); err != nil {
+		return fmt.Errorf("failed to produce keyed messages: %w", err)
+	}
+
+	if err := admin.Compact(tenant, namespace, topic); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		status, err := admin.CompactionStatus(tenant, namespace, topic)
+		if err != nil {
+			return err
+		}
+		if status == "SUCCESS" {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("compaction on topic %s has not completed after %v, status %s", topic, timeout, status)
+		}
+		time.Sleep(time.Second)
+	}
+
+	received, err := readCompacted()
+	if err != nil {
+		return fmt.Errorf("failed to read the compacted topic: %w", err)
+	}
+	return verifyLatestValues(latest, received)
+}
+
+// produceKeyedMessages sends the keyed messages in order
+func produceKeyedMessages(client pulsar.Client, topicFn string, messages []keyedValue) error {
+	producer, err := client.CreateProducer(pulsar.ProducerOptions{
+		Topic: topicFn,
+	})
+	if err != nil {
+		return err
+	}
+	defer producer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	for _, msg := range messages {
+		if _, err = producer.Send(ctx, &pulsar.ProducerMessage{
+			Key:     msg.key,
+			Payload: []byte(msg.value),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCompactedMessages reads the compacted topic from the earliest message
+func readCompactedMessages(client pulsar.Client, topicFn string) ([]keyedValue, error) {
+	reader, err := client.CreateReader(pulsar.ReaderOptions{
+		Topic:          topicFn,
+		StartMessageID: pulsar.EarliestMessageID(),
+		ReadCompacted:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	received := []keyedValue{}
+	for reader.HasNext() {
+		msg, err := reader.Next(ctx)
+		if err != nil {
+			return received, err
+		}
+		received = append(received, keyedValue{key: msg.Key(), value: string(msg.Payload())})
+	}
+	return received, nil
+}
+
+// compactionTopicName returns the configured compaction topic, or the probe topic with a compaction suffix
+func compactionTopicName(topicCfg TopicCfg) string {
+	return util.FirstNonEmptyString(topicCfg.CompactionTopic, topicCfg.TopicName+"-compaction")
+}
+
+// TestTopicCompaction evaluates and reports the topic compaction
+func TestTopicCompaction(topicCfg TopicCfg) {
+	topicFn := compactionTopicName(topicCfg)
+	isPersistent, tenant, namespace, topic, err := util.TokenizeTopicFullName(topicFn)
+	if err != nil || !isPersistent {
+		log.Errorf("topic compaction check is skipped, invalid persistent topic %s error: %v", topicFn, err)
+		return
+	}
+	pulsarURL, err := url.ParseRequestURI(topicCfg.PulsarURL)
+	if err != nil {
+		log.Errorf("topic compaction check is skipped, invalid pulsar url %s error: %v", topicCfg.PulsarURL, err)
+		return
+	}
+	component := pulsarURL.Hostname() + "-topic-compaction"
+	tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+
+	client, err := GetPulsarClient(topicCfg.PulsarURL, tokenSupplier)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s failed to create Pulsar client, error: %v", component, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "topic compaction test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+
+	admin := restTopicAdmin{baseURL: adminURL(topicCfg), tokenSupplier: tokenSupplier}
+	runID := fmt.Sprintf("heartbeat-%d", time.Now().UnixNano())
+	err = verifyTopicCompaction(tenant, namespace, topic, runID, func(messages []keyedValue) error {
+		return produceKeyedMessages(client, topicFn, messages)
+	}, admin, func() ([]keyedValue, error) {
+		return readCompactedMessages(client, topicFn)
+	}, 60*time.Second)
+	if err != nil {
+		errMsg := fmt.Sprintf("%s topic compaction test failed on %s, error: %v", component, topicFn, err)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "topic compaction test failure", errMsg, &topicCfg.AlertPolicy)
+		return
+	}
+	log.Infof("%s topic compaction test has successfully passed on %s", component, topicFn)
+	ClearIncident(component)
+}
@@ -0,0 +1,122 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeCompactionAdmin struct {
+	statuses  []string
+	compacted bool
+}
+
+func (a *fakeCompactionAdmin) Compact(tenant, namespace, topic string) error {
+	a.compacted = true
+	return nil
+}
+
+func (a *fakeCompactionAdmin) CompactionStatus(tenant, namespace, topic string) (string, error) {
+	status := a.statuses[0]
+	if len(a.statuses) > 1 {
+		a.statuses = a.statuses[1:]
+	}
+	return status, nil
+}
+
+// compact returns the latest value of every key in the order of the key's first message
+func compact(messages []keyedValue) []keyedValue {
+	compacted := []keyedValue{}
+	index := make(map[string]int)
+	for _, msg := range messages {
+		if i, ok := index[msg.key]; ok {
+			compacted[i] = msg
+			continue
+		}
+		index[msg.key] = len(compacted)
+		compacted = append(compacted, msg)
+	}
+	return compacted
+}
+
+func TestVerifyLatestValues(t *testing.T) {
+	messages, latest := compactionMessages("run1", 2, 3)
+	assert(t, 6 == len(messages), "expect 6 messages but got %d", len(messages))
+	assert(t, 2 == len(latest), "expect 2 keys but got %d", len(latest))
+	assert(t, "run1-key-1-version-2" == latest["run1-key-1"], "expect the last version as the latest value")
+
+	errNil(t, verifyLatestValues(latest, compact(messages)))
+
+	// values of the previous runs are ignored
+	previous, _ := compactionMessages("run0", 2, 3)
+	errNil(t, verifyLatestValues(latest, append(compact(previous), compact(messages)...)))
+
+	// an uncompacted read returns every version
+	err := verifyLatestValues(latest, messages)
+	assert(t, err != nil && strings.Contains(err.Error(), "stale value"), "expect stale value error but got %v", err)
+
+	err = verifyLatestValues(latest, append(compact(messages), keyedValue{key: "run1-key-0", value: latest["run1-key-0"]}))
+	assert(t, err != nil && strings.Contains(err.Error(), "returns 2 values"), "expect duplicated value error but got %v", err)
+
+	err = verifyLatestValues(latest, compact(messages)[:1])
+	assert(t, err != nil && strings.Contains(err.Error(), "run1-key-1 is missing"), "expect missing key error but got %v", err)
+}
+
+func TestVerifyTopicCompaction(t *testing.T) {
+	produced := []keyedValue{}
+	produce := func(messages []keyedValue) error {
+		produced = append(produced, messages...)
+		return nil
+	}
+	read := func() ([]keyedValue, error) { return compact(produced), nil }
+
+	admin := &fakeCompactionAdmin{statuses: []string{"RUNNING", "SUCCESS"}}
+	errNil(t, verifyTopicCompaction("tenant", "ns", "topic", "run1", produce, admin, read, 5*time.Second))
+	assert(t, admin.compacted, "expect compaction triggered")
+	assert(t, compactionKeys*compactionVersions == len(produced), "expect every version produced")
+
+	// the read is not compacted
+	admin = &fakeCompactionAdmin{statuses: []string{"SUCCESS"}}
+	err := verifyTopicCompaction("tenant", "ns", "topic", "run2", produce, admin, func() ([]keyedValue, error) {
+		return produced, nil
+	}, time.Second)
+	assert(t, err != nil, "expect stale value error")
+
+	admin = &fakeCompactionAdmin{statuses: []string{"RUNNING"}}
+	err = verifyTopicCompaction("tenant", "ns", "topic", "run3", produce, admin, read, 0)
+	assert(t, err != nil && strings.Contains(err.Error(), "has not completed"), "expect compaction timeout error but got %v", err)
+
+	err = verifyTopicCompaction("tenant", "ns", "topic", "run4", func([]keyedValue) error {
+		return errors.New("producer error")
+	}, admin, read, time.Second)
+	assert(t, err != nil && strings.Contains(err.Error(), "failed to produce"), "expect produce error but got %v", err)
+}
+
+func TestCompactionTopicName(t *testing.T) {
+	topicFn := compactionTopicName(TopicCfg{TopicName: "persistent://tenant/ns/topic"})
+	assert(t, "persistent://tenant/ns/topic-compaction" == topicFn, "unexpected default compaction topic %s", topicFn)
+	topicFn = compactionTopicName(TopicCfg{TopicName: "persistent://tenant/ns/topic", CompactionTopic: "persistent://tenant/ns/compacted"})
+	assert(t, "persistent://tenant/ns/compacted" == topicFn, "unexpected compaction topic %s", topicFn)
+}
@@ -0,0 +1,100 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// expand the topic groups into the individual topic probes of pulsarTopicConfig
+
+import (
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// TopicGroupCfg is a cluster entry probing multiple topics, the settings of the embedded TopicCfg are shared by every topic
+type TopicGroupCfg struct {
+	TopicCfg
+	Topics []TopicProbe `json:"topics"`
+}
+
+// TopicProbe is a topic of the group, the specified fields override the shared settings of the group
+type TopicProbe struct {
+	Name               string          `json:"name"`
+	TopicName          string          `json:"topicName"`
+	OutputTopic        string          `json:"outputTopic"`
+	NumberOfPartitions int             `json:"numberOfPartitions"`
+	LatencyBudgetMs    int             `json:"latencyBudgetMs"`
+	IntervalSeconds    int             `json:"intervalSeconds"`
+	ExpectedMsg        string          `json:"expectedMsg"`
+	PayloadSizes       []string        `json:"payloadSizes"`
+	NumOfMessages      int             `json:"numberOfMessages"`
+	AlertPolicy        *AlertPolicyCfg `json:"AlertPolicy"`
+}
+
+// topicCfg returns the probe settings layered over the shared settings of the group
+func (g TopicGroupCfg) topicCfg(p TopicProbe) TopicCfg {
+	t := g.TopicCfg
+	t.Name = util.FirstNonEmptyString(p.Name, t.Name)
+	t.TopicName = util.FirstNonEmptyString(p.TopicName, t.TopicName)
+	t.OutputTopic = util.FirstNonEmptyString(p.OutputTopic, t.OutputTopic)
+	t.ExpectedMsg = util.FirstNonEmptyString(p.ExpectedMsg, t.ExpectedMsg)
+	if p.NumberOfPartitions > 0 {
+		t.NumberOfPartitions = p.NumberOfPartitions
+	}
+	if p.LatencyBudgetMs > 0 {
+		t.LatencyBudgetMs = p.LatencyBudgetMs
+	}
+	if p.IntervalSeconds > 0 {
+		t.IntervalSeconds = p.IntervalSeconds
+	}
+	if p.NumOfMessages > 0 {
+		t.NumOfMessages = p.NumOfMessages
+	}
+	if len(p.PayloadSizes) > 0 {
+		t.PayloadSizes = p.PayloadSizes
+	}
+	if p.AlertPolicy != nil {
+		t.AlertPolicy = *p.AlertPolicy
+	}
+	t.expanded = true
+	return t
+}
+
+// flatTopics returns the topics configured in pulsarTopicConfig without the ones expanded from the topic groups
+func flatTopics(topics []TopicCfg) []TopicCfg {
+	var flat []TopicCfg
+	for _, t := range topics {
+		if !t.expanded {
+			flat = append(flat, t)
+		}
+	}
+	return flat
+}
+
+// expandTopicGroups appends a topic probe of every topic of the groups to pulsarTopicConfig,
+// the topics expanded by a previous call are replaced
+func (c *Configuration) expandTopicGroups() {
+	topics := flatTopics(c.PulsarTopicConfig)
+	for _, group := range c.PulsarTopicGroups {
+		for _, p := range group.Topics {
+			topics = append(topics, group.topicCfg(p))
+		}
+	}
+	c.PulsarTopicConfig = topics
+}
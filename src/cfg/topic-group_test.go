@@ -0,0 +1,107 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+const flatTopicsYaml = `
+name: flat
+pulsarTopicConfig:
+  - name: orders
+    pulsarUrl: pulsar+ssl://group.example.com:6651
+    adminUrl: https://group.example.com:8443
+    latencyBudgetMs: 360
+    intervalSeconds: 60
+    payloadSizes: [ 15B ]
+    topicName: persistent://tenant/ns/orders
+    AlertPolicy:
+      Ceiling: 10
+  - name: orders
+    pulsarUrl: pulsar+ssl://group.example.com:6651
+    adminUrl: https://group.example.com:8443
+    latencyBudgetMs: 1000
+    intervalSeconds: 60
+    payloadSizes: [ 15B ]
+    topicName: persistent://tenant/ns/payments
+    numberOfPartitions: 3
+    AlertPolicy:
+      Ceiling: 2
+  - name: standalone
+    pulsarUrl: pulsar://standalone:6650
+    topicName: persistent://public/default/standalone
+`
+
+const nestedTopicsYaml = `
+name: nested
+pulsarTopicConfig:
+  - name: standalone
+    pulsarUrl: pulsar://standalone:6650
+    topicName: persistent://public/default/standalone
+pulsarTopicGroups:
+  - name: orders
+    pulsarUrl: pulsar+ssl://group.example.com:6651
+    adminUrl: https://group.example.com:8443
+    latencyBudgetMs: 360
+    intervalSeconds: 60
+    payloadSizes: [ 15B ]
+    AlertPolicy:
+      Ceiling: 10
+    topics:
+      - topicName: persistent://tenant/ns/orders
+      - topicName: persistent://tenant/ns/payments
+        latencyBudgetMs: 1000
+        numberOfPartitions: 3
+        AlertPolicy:
+          Ceiling: 2
+`
+
+// probeSet is the topic configs keyed by the topic name without the expanded flag
+func probeSet(topics []TopicCfg) map[string]TopicCfg {
+	set := make(map[string]TopicCfg)
+	for _, t := range topics {
+		t.expanded = false
+		set[t.TopicName] = t
+	}
+	return set
+}
+
+func TestExpandTopicGroups(t *testing.T) {
+	flat, nested := Configuration{}, Configuration{}
+	errNil(t, yaml.Unmarshal([]byte(flatTopicsYaml), &flat))
+	errNil(t, yaml.Unmarshal([]byte(nestedTopicsYaml), &nested))
+	flat.Init()
+	nested.Init()
+
+	assert(t, len(nested.PulsarTopicConfig) == 3, "expect 3 topic probes but got %d", len(nested.PulsarTopicConfig))
+	assert(t, reflect.DeepEqual(probeSet(flat.PulsarTopicConfig), probeSet(nested.PulsarTopicConfig)),
+		"expect the nested form expanded to the flat form but got %v", nested.PulsarTopicConfig)
+
+	// an initialization again does not duplicate the expanded topics
+	nested.Init()
+	assert(t, len(nested.PulsarTopicConfig) == 3, "expect 3 topic probes but got %d", len(nested.PulsarTopicConfig))
+	assert(t, len(flatTopics(nested.PulsarTopicConfig)) == 1, "expect 1 flat topic but got %d", len(flatTopics(nested.PulsarTopicConfig)))
+}
@@ -0,0 +1,124 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// detect a topic gone quiet when its publish rate stays below the minimum
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// rateSample is the topic publish rate at the time
+type rateSample struct {
+	at   time.Time
+	rate float64
+}
+
+// rateWindow keeps the publish rate samples in the window
+type rateWindow struct {
+	window  time.Duration
+	samples []rateSample
+}
+
+// add records the rate and returns whether the rate has stayed below the minimum for the whole window
+func (w *rateWindow) add(at time.Time, rate, minRate float64) bool {
+	w.samples = append(w.samples, rateSample{at: at, rate: rate})
+	windowStart := at.Add(-w.window)
+	// keep the latest sample at or before the window start to know the window is covered
+	i := 0
+	for i+1 < len(w.samples) && !w.samples[i+1].at.After(windowStart) {
+		i++
+	}
+	w.samples = w.samples[i:]
+	if w.samples[0].at.After(windowStart) {
+		return false
+	}
+	for _, sample := range w.samples {
+		if sample.rate >= minRate {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	// key is the cluster name and the topic
+	rateWindows     = make(map[string]*rateWindow)
+	rateWindowsLock = &sync.Mutex{}
+)
+
+// topicRateBelowMinimum records the rate of the topic and returns whether it has stayed below the minimum in the window
+func topicRateBelowMinimum(clusterName string, rateCfg MessageRateCfg, at time.Time, rate float64) bool {
+	window := util.TimeDuration(rateCfg.WindowSeconds, 300, time.Second)
+	key := clusterName + "|" + rateCfg.TopicName
+	rateWindowsLock.Lock()
+	defer rateWindowsLock.Unlock()
+	w, ok := rateWindows[key]
+	if !ok || w.window != window {
+		w = &rateWindow{window: window}
+		rateWindows[key] = w
+	}
+	return w.add(at, rate, rateCfg.MinRateIn)
+}
+
+// PulsarTopicMessageRates verifies the configured topics keep the minimum publish rate on each cluster
+func PulsarTopicMessageRates() {
+	adminCfg := GetConfig().PulsarAdminConfig
+	if len(adminCfg.MessageRates) == 0 {
+		return
+	}
+	tokenSupplier := util.TokenSupplierWithOverride(adminCfg.Token, GetConfig().TokenSupplier())
+
+	for _, cluster := range adminCfg.Clusters {
+		adminURL, err := url.ParseRequestURI(cluster.URL)
+		if err != nil {
+			panic(err) //panic because this is a showstopper
+		}
+		admin := restTopicAdmin{baseURL: cluster.URL, tokenSupplier: tokenSupplier}
+		for _, rateCfg := range adminCfg.MessageRates {
+			component := cluster.Name + "-" + rateCfg.TopicName + "-msg-rate"
+			stats, err := admin.TopicStats(rateCfg.TopicName)
+			if err != nil {
+				errMsg := fmt.Sprintf("cluster %s topic message rate test failed, error: %v", cluster.Name, err)
+				log.Errorf(errMsg)
+				ReportIncident(component, adminURL.Hostname(), "topic message rate test failure", errMsg, &cluster.AlertPolicy)
+				continue
+			}
+			PromTopicMsgRateIn(cluster.Name, rateCfg.TopicName, stats.MsgRateIn)
+			if topicRateBelowMinimum(cluster.Name, rateCfg, time.Now(), stats.MsgRateIn) {
+				errMsg := fmt.Sprintf("cluster %s topic %s msgRateIn %.2f has stayed below the minimum %.2f for %v", cluster.Name,
+					rateCfg.TopicName, stats.MsgRateIn, rateCfg.MinRateIn, util.TimeDuration(rateCfg.WindowSeconds, 300, time.Second))
+				log.Errorf(errMsg)
+				ReportIncident(component, adminURL.Hostname(), "topic has gone quiet", errMsg, &cluster.AlertPolicy)
+			} else {
+				log.Infof("cluster %s topic %s msgRateIn is %.2f", cluster.Name, rateCfg.TopicName, stats.MsgRateIn)
+				ClearIncident(component)
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTopicStatsMsgRateIn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"msgRateIn": 12.5, "msgThroughputIn": 1024.0, "subscriptions": {}}`))
+	}))
+	defer server.Close()
+
+	stats, err := restTopicAdmin{baseURL: server.URL}.TopicStats("persistent://tenant/ns/orders")
+	errNil(t, err)
+	assert(t, 12.5 == stats.MsgRateIn, "expect msgRateIn 12.5 but got %f", stats.MsgRateIn)
+	assert(t, 0 == len(stats.Subscriptions), "expect no subscription")
+}
+
+func TestTopicRateBelowMinimum(t *testing.T) {
+	rateCfg := MessageRateCfg{TopicName: "persistent://tenant/ns/quiet", MinRateIn: 1, WindowSeconds: 60}
+	clusterName := "msg-rate-cluster"
+	defer func() {
+		rateWindowsLock.Lock()
+		delete(rateWindows, clusterName+"|"+rateCfg.TopicName)
+		rateWindowsLock.Unlock()
+	}()
+	start := time.Now()
+
+	assert(t, !topicRateBelowMinimum(clusterName, rateCfg, start, 0.1), "expect no alert before the window is covered")
+	assert(t, !topicRateBelowMinimum(clusterName, rateCfg, start.Add(30*time.Second), 0), "expect no alert before the window is covered")
+	assert(t, topicRateBelowMinimum(clusterName, rateCfg, start.Add(60*time.Second), 0), "expect an alert after the rate stayed below the minimum for the window")
+	assert(t, !topicRateBelowMinimum(clusterName, rateCfg, start.Add(90*time.Second), 5), "expect no alert once the rate recovers")
+	assert(t, !topicRateBelowMinimum(clusterName, rateCfg, start.Add(120*time.Second), 0), "expect no alert with a recovered rate in the window")
+	assert(t, !topicRateBelowMinimum(clusterName, rateCfg, start.Add(149*time.Second), 0), "expect no alert with the recovered rate at the window start")
+	assert(t, topicRateBelowMinimum(clusterName, rateCfg, start.Add(180*time.Second), 0), "expect an alert after the recovered rate rolled out of the window")
+}
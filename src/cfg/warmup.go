@@ -0,0 +1,74 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// warm up the Pulsar client connections at startup
+
+import (
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+)
+
+// warmUpTopics returns the first topic configuration of every pulsar url
+func warmUpTopics(topics []TopicCfg) []TopicCfg {
+	seen := make(map[string]bool)
+	warmUp := []TopicCfg{}
+	for _, topicCfg := range topics {
+		if topicCfg.PulsarURL == "" || seen[topicCfg.PulsarURL] {
+			continue
+		}
+		seen[topicCfg.PulsarURL] = true
+		warmUp = append(warmUp, topicCfg)
+	}
+	return warmUp
+}
+
+// WarmUpPulsarClients creates the cached Pulsar client and a throwaway producer of every cluster in parallel,
+// it returns when all clusters are warmed up, a warm up failure is only logged
+func WarmUpPulsarClients() {
+	var wg sync.WaitGroup
+	for _, topicCfg := range warmUpTopics(GetConfig().PulsarTopicConfig) {
+		wg.Add(1)
+		go func(topicCfg TopicCfg) {
+			defer wg.Done()
+			tokenSupplier := util.TokenSupplierWithOverride(topicCfg.Token, GetConfig().TokenSupplier())
+			client, err := GetPulsarClientWithTrustStore(topicCfg.PulsarURL, tokenSupplier, topicCfg.TrustStore)
+			if err != nil {
+				log.Errorf("warm up failed to create pulsar client to %s, error: %v", topicCfg.PulsarURL, err)
+				return
+			}
+			producer, err := client.CreateProducer(pulsar.ProducerOptions{
+				Topic: topicCfg.TopicName,
+			})
+			if err != nil {
+				log.Errorf("warm up failed to create producer to topic %s on %s, error: %v", topicCfg.TopicName, topicCfg.PulsarURL, err)
+				return
+			}
+			producer.Close()
+			log.Infof("warmed up pulsar client to %s", topicCfg.PulsarURL)
+		}(topicCfg)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,63 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+func TestWarmUpPulsarClients(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	defer func(create func(pulsar.ClientOptions) (pulsar.Client, error)) { newPulsarClient = create }(newPulsarClient)
+	var mu sync.Mutex
+	created := map[string]*fakePulsarClient{}
+	newPulsarClient = func(opts pulsar.ClientOptions) (pulsar.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		client := &fakePulsarClient{producer: &fakeProducer{}}
+		created[opts.URL] = client
+		return client, nil
+	}
+	Config.PulsarTopicConfig = []TopicCfg{
+		{PulsarURL: "pulsar://warmup-cluster-1:6650", TopicName: "persistent://tenant/ns/warmup-1"},
+		{PulsarURL: "pulsar://warmup-cluster-1:6650", TopicName: "persistent://tenant/ns/warmup-2"},
+		{PulsarURL: "pulsar://warmup-cluster-2:6650", TopicName: "persistent://tenant/ns/warmup-3"},
+	}
+	defer func() {
+		evictPulsarClient("pulsar://warmup-cluster-1:6650")
+		evictPulsarClient("pulsar://warmup-cluster-2:6650")
+	}()
+
+	WarmUpPulsarClients()
+	assert(t, len(created) == 2, "expect a client per cluster but got %d", len(created))
+	first := created["pulsar://warmup-cluster-1:6650"]
+	assert(t, first != nil && first.producerOptions.Topic == "persistent://tenant/ns/warmup-1", "expect a producer on the first topic of the cluster")
+	assert(t, created["pulsar://warmup-cluster-2:6650"].producerOptions.Topic == "persistent://tenant/ns/warmup-3", "expect a producer on the second cluster")
+
+	client, err := GetPulsarClient("pulsar://warmup-cluster-2:6650", nil)
+	errNil(t, err)
+	assert(t, client == created["pulsar://warmup-cluster-2:6650"], "expect the warmed up client cached for the probes")
+}
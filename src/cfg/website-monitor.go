@@ -22,8 +22,14 @@
 package cfg
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"math/rand"
+	"mime"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/antonmedv/expr"
@@ -32,13 +38,68 @@ import (
 	"github.com/hashicorp/go-retryablehttp"
 )
 
-func monitorSite(site SiteCfg) error {
-
-	client := retryablehttp.NewClient()
+// siteHTTPClient returns the retryable client with the site's retry backoff and trust store
+func siteHTTPClient(site SiteCfg) (*retryablehttp.Client, error) {
+	client := newRetryableClient()
 	client.HTTPClient.Timeout = time.Duration(site.ResponseSeconds) * time.Second
-	client.RetryWaitMin = 4 * time.Second
-	client.RetryWaitMax = 64 * time.Second
+	client.RetryWaitMin = util.TimeDuration(site.RetryWaitMinMs, 4000, time.Millisecond)
+	client.RetryWaitMax = util.TimeDuration(site.RetryWaitMaxMs, 64000, time.Millisecond)
 	client.RetryMax = site.Retries
+	if site.RetryJitter {
+		client.Backoff = jitterBackoff
+	}
+	if site.TrustStore != "" {
+		caCert, err := os.ReadFile(site.TrustStore)
+		if err != nil {
+			return nil, fmt.Errorf("error opening cert file %s, Error: %v", site.TrustStore, err)
+		}
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificate is found in the cert file %s", site.TrustStore)
+		}
+		transport := client.HTTPClient.Transport.(*http.Transport)
+		transport.TLSClientConfig = &tls.Config{RootCAs: caCertPool}
+	}
+	return client, nil
+}
+
+// jitterBackoff randomizes the exponential backoff between the min wait and the exponential wait,
+// the Retry-After of a rate limited or unavailable response is honored as is
+func jitterBackoff(min, max time.Duration, attemptNum int, resp *http.Response) time.Duration {
+	wait := retryablehttp.DefaultBackoff(min, max, attemptNum, resp)
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		return wait
+	}
+	if wait <= min {
+		return wait
+	}
+	return min + time.Duration(rand.Int63n(int64(wait-min)))
+}
+
+// maxSiteResponseBytes is the limit of the response body read to measure the size
+const maxSiteResponseBytes = 10 << 20
+
+// responseSize reads and discards the body up to the limit, it returns the number of bytes read
+func responseSize(body io.Reader, limit int64) (int64, error) {
+	return io.Copy(io.Discard, io.LimitReader(body, limit))
+}
+
+// mediaType returns the media type of the content type without the parameters, such as charset
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return "unknown"
+	}
+	if media, _, err := mime.ParseMediaType(contentType); err == nil {
+		return media
+	}
+	return "invalid"
+}
+
+func monitorSite(site SiteCfg) error {
+	client, err := siteHTTPClient(site)
+	if err != nil {
+		return err
+	}
 
 	req, err := retryablehttp.NewRequest(http.MethodGet, site.URL, nil)
 	if err != nil {
@@ -58,6 +119,13 @@ func monitorSite(site SiteCfg) error {
 		return err
 	}
 	PromLatencySum(SiteLatencyGaugeOpt(), site.Name, time.Since(sentTime))
+	if site.ResponseMetrics {
+		size, err := responseSize(resp.Body, maxSiteResponseBytes)
+		if err != nil {
+			return fmt.Errorf("failed to read the response body: %w", err)
+		}
+		PromSiteResponseBytes(site.Name, mediaType(resp.Header.Get("Content-Type")), size)
+	}
 
 	if site.StatusCode > 0 && resp.StatusCode != site.StatusCode {
 		return fmt.Errorf("response statusCode %d does not match the expected code %d", resp.StatusCode, site.StatusCode)
@@ -88,34 +156,37 @@ func monitorSite(site SiteCfg) error {
 }
 
 func mon(site SiteCfg) {
+	start := time.Now()
 	err := monitorSite(site)
 	if err != nil {
 		errMsg := fmt.Sprintf("url monitoring %s error: %v", site.URL, err)
 		title := fmt.Sprintf("persisted %s endpoint failure", site.Name)
 		log.Errorf(errMsg)
 		ReportIncident(site.Name, site.Name, title, errMsg, &site.AlertPolicy)
+		RecordStatus(site.Name, time.Since(start), errMsg)
 	} else {
 		ClearIncident(site.Name)
+		RecordStatus(site.Name, time.Since(start), "")
 	}
 }
 
 // MonitorSites monitors a list of sites
 func MonitorSites() {
-	sites := GetConfig().SitesConfig.Sites
+	runProbes(siteProbes())
+}
 
-	for _, site := range sites {
+// siteProbes are the website monitors
+func siteProbes() []probe {
+	probes := []probe{}
+	for _, site := range GetConfig().SitesConfig.Sites {
 		log.Infof("monitor and evaluate url %s", site.URL)
-		go func(s SiteCfg) {
-			interval := util.TimeDuration(s.IntervalSeconds, 120, time.Second)
-			ticker := time.NewTicker(interval)
-			defer ticker.Stop()
-			mon(s)
-			for {
-				select {
-				case <-ticker.C:
-					mon(s)
-				}
-			}
-		}(site)
+		probes = append(probes, probe{
+			name:     site.Name,
+			interval: IntervalDuration(site.IntervalSeconds, 120),
+			run: func(s SiteCfg) monitorFunc {
+				return func() { mon(s) }
+			}(site),
+		})
 	}
+	return probes
 }
@@ -0,0 +1,118 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSiteRetryBackoff(t *testing.T) {
+	client, err := siteHTTPClient(SiteCfg{Retries: 2})
+	errNil(t, err)
+	assert(t, 4*time.Second == client.RetryWaitMin, "expect the default min wait but got %v", client.RetryWaitMin)
+	assert(t, 64*time.Second == client.RetryWaitMax, "expect the default max wait but got %v", client.RetryWaitMax)
+	assert(t, 2 == client.RetryMax, "expect 2 retries")
+	assert(t, 8*time.Second == client.Backoff(client.RetryWaitMin, client.RetryWaitMax, 1, nil), "expect the exponential backoff without jitter")
+
+	client, err = siteHTTPClient(SiteCfg{RetryWaitMinMs: 100, RetryWaitMaxMs: 800, RetryJitter: true})
+	errNil(t, err)
+	assert(t, 100*time.Millisecond == client.RetryWaitMin, "expect the configured min wait but got %v", client.RetryWaitMin)
+	assert(t, 800*time.Millisecond == client.RetryWaitMax, "expect the configured max wait but got %v", client.RetryWaitMax)
+	for attempt := 0; attempt < 10; attempt++ {
+		wait := client.Backoff(client.RetryWaitMin, client.RetryWaitMax, attempt, nil)
+		assert(t, wait >= client.RetryWaitMin && wait <= client.RetryWaitMax, "expect the jittered wait %v within the bounds", wait)
+	}
+
+	// the Retry-After is not jittered
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"2"}}}
+	assert(t, 2*time.Second == jitterBackoff(100*time.Millisecond, 800*time.Millisecond, 1, resp), "expect the Retry-After honored")
+}
+
+func TestMonitorSiteRetryBackoff(t *testing.T) {
+	requests := []time.Time{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	site := SiteCfg{URL: server.URL, Name: "backoff-site", ResponseSeconds: 5, Retries: 2, StatusCode: http.StatusOK,
+		RetryWaitMinMs: 10, RetryWaitMaxMs: 20}
+	start := time.Now()
+	assert(t, monitorSite(site) != nil, "expect the site failure after retries")
+	assert(t, 3 == len(requests), "expect 3 requests but got %d", len(requests))
+	assert(t, time.Since(start) < 2*time.Second, "expect the configured backoff instead of the default 4 seconds")
+}
+
+func TestSiteTrustStore(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	site := SiteCfg{URL: server.URL, Name: "private-ca-site", ResponseSeconds: 5, StatusCode: http.StatusOK}
+
+	// the system roots do not trust the test server
+	client, err := siteHTTPClient(site)
+	errNil(t, err)
+	assert(t, client.HTTPClient.Transport.(*http.Transport).TLSClientConfig == nil, "expect the system roots by default")
+	assert(t, monitorSite(site) != nil, "expect the site signed by a private CA rejected")
+
+	site.TrustStore = filepath.Join(t.TempDir(), "ca.crt")
+	errNil(t, os.WriteFile(site.TrustStore, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	client, err = siteHTTPClient(site)
+	errNil(t, err)
+	tlsConfig := client.HTTPClient.Transport.(*http.Transport).TLSClientConfig
+	assert(t, tlsConfig != nil && tlsConfig.RootCAs != nil, "expect the custom CA applied to the transport")
+	errNil(t, monitorSite(site))
+
+	site.TrustStore = filepath.Join(t.TempDir(), "missing.crt")
+	_, err = siteHTTPClient(site)
+	assert(t, err != nil, "expect an error on a missing CA file")
+}
+
+func TestSiteResponseMetrics(t *testing.T) {
+	body := strings.Repeat("a", 1234)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	site := SiteCfg{URL: server.URL, Name: "response-size-site", ResponseSeconds: 5, StatusCode: http.StatusOK, ResponseMetrics: true}
+	errNil(t, monitorSite(site))
+	size := testutil.ToFloat64(siteResponseBytes.WithLabelValues(site.Name, "text/html"))
+	assert(t, size == 1234, "expect the response size 1234 bytes but got %f", size)
+
+	read, err := responseSize(strings.NewReader(body), 100)
+	errNil(t, err)
+	assert(t, read == 100, "expect the body read up to the limit but got %d", read)
+	assert(t, mediaType("") == "unknown", "expect an unknown media type without the content type")
+}
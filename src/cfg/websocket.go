@@ -218,16 +218,20 @@ func TestWsLatency(config WsConfig) {
 	stdVerdict := util.GetStdBucket(config.Cluster)
 
 	result, err := WsLatencyTest(config.ProducerURL, config.ConsumerURL, tokenSupplier)
+	statusErr := ""
 	if err != nil {
 		errMsg := fmt.Sprintf("cluster %s, %s websocket latency test Pulsar error: %v", config.Cluster, config.Name, err)
+		statusErr = errMsg
 		log.Errorf(errMsg)
 		ReportIncident(config.Name, config.Cluster, "websocket persisted latency test failure", errMsg, &config.AlertPolicy)
+		PromCounter(ProbeFailureCounterOpt(websocketSubsystem), config.Cluster)
 	} else if result.Latency > expectedLatency {
 		stdVerdict.Add(float64(result.Latency.Milliseconds()))
 		errMsg := fmt.Sprintf("cluster %s, %s websocket test message latency %v over the budget %v",
 			config.Cluster, config.Name, result.Latency, expectedLatency)
+		statusErr = errMsg
 		log.Errorf(errMsg)
-		ReportIncident(config.Name, config.Cluster, "websocket persisted latency test failure", errMsg, &config.AlertPolicy)
+		ReportLatencyIncident(config.Name, config.Cluster, "websocket persisted latency test failure", errMsg, result.Latency, expectedLatency, &config.AlertPolicy)
 	} else if stddev, mean, within3Sigma := stdVerdict.Push(float64(result.Latency.Milliseconds())); !within3Sigma {
 		errMsg := fmt.Sprintf("cluster %s, websocket test message latency %v over three standard deviation %v ms and mean is %v ms",
 			config.Cluster, result.Latency, stddev, mean)
@@ -239,24 +243,26 @@ func TestWsLatency(config WsConfig) {
 	}
 
 	PromLatencySum(GetGaugeType(websocketSubsystem), config.Cluster, result.Latency)
+	RecordStatus(config.Name, result.Latency, statusErr)
 }
 
 // WebSocketTopicLatencyTestThread tests a message websocket delivery in topic and measure the latency.
 func WebSocketTopicLatencyTestThread() {
-	configs := GetConfig().WebSocketConfig
+	runProbes(webSocketProbes())
+}
 
-	for _, cfg := range configs {
+// webSocketProbes are the websocket latency tests
+func webSocketProbes() []probe {
+	probes := []probe{}
+	for _, cfg := range GetConfig().WebSocketConfig {
 		cfg.reconcileConfig()
-		go func(t WsConfig) {
-			ticker := time.NewTicker(util.TimeDuration(t.IntervalSeconds, 60, time.Second))
-			defer ticker.Stop()
-			TestWsLatency(t)
-			for {
-				select {
-				case <-ticker.C:
-					TestWsLatency(t)
-				}
-			}
-		}(cfg)
+		probes = append(probes, probe{
+			name:     cfg.Name,
+			interval: IntervalDuration(cfg.IntervalSeconds, 60),
+			run: func(t WsConfig) monitorFunc {
+				return func() { TestWsLatency(t) }
+			}(cfg),
+		})
 	}
+	return probes
 }
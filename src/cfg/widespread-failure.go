@@ -0,0 +1,135 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// raise a single incident when a large fraction of all the configured clusters fail at once
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// widespreadFailureComponent is the component name of the widespread failure incident
+const widespreadFailureComponent = "widespread-failure"
+
+var (
+	// the set of configured clusters
+	monitoredClusters = make(map[string]bool)
+	// key is the cluster, value is the set of failing components
+	clusterFailures = make(map[string]map[string]bool)
+	// key is the component, value is the cluster
+	componentClusters = make(map[string]string)
+	// whether the widespread failure incident is open
+	widespreadFailure = false
+	clustersLock      = &sync.Mutex{}
+)
+
+// clusterHost identifies a cluster by the host name of the url
+func clusterHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return rawURL
+}
+
+// setClusters assigns the cluster to the alert policies and records the set of configured clusters
+func (c *Configuration) setClusters() {
+	clusters := make(map[string]bool)
+	for i := range c.PulsarTopicConfig {
+		if c.PulsarTopicConfig[i].PulsarURL != "" {
+			cluster := clusterHost(c.PulsarTopicConfig[i].PulsarURL)
+			c.PulsarTopicConfig[i].AlertPolicy.cluster = cluster
+			clusters[cluster] = true
+		}
+	}
+	for i := range c.PulsarAdminConfig.Clusters {
+		if c.PulsarAdminConfig.Clusters[i].URL != "" {
+			cluster := clusterHost(c.PulsarAdminConfig.Clusters[i].URL)
+			c.PulsarAdminConfig.Clusters[i].AlertPolicy.cluster = cluster
+			clusters[cluster] = true
+		}
+	}
+
+	clustersLock.Lock()
+	defer clustersLock.Unlock()
+	monitoredClusters = clusters
+}
+
+// isWidespread returns whether the failing clusters exceed the threshold fraction of all the clusters
+func isWidespread(failing, total int, threshold float64) bool {
+	return threshold > 0 && total > 0 && float64(failing)/float64(total) > threshold
+}
+
+// trackClusterFailure marks the component failing in the cluster
+// it returns the number of failing clusters, the number of clusters, and whether the failure has just become widespread
+func trackClusterFailure(cluster, component string) (failing, total int, newlyWidespread bool) {
+	clustersLock.Lock()
+	defer clustersLock.Unlock()
+	componentClusters[component] = cluster
+	if _, ok := clusterFailures[cluster]; !ok {
+		clusterFailures[cluster] = make(map[string]bool)
+	}
+	clusterFailures[cluster][component] = true
+
+	failing, total = len(clusterFailures), len(monitoredClusters)
+	if total < failing {
+		total = failing
+	}
+	if isWidespread(failing, total, GetConfig().WidespreadFailureThreshold) {
+		newlyWidespread = !widespreadFailure
+		widespreadFailure = true
+	}
+	return failing, total, newlyWidespread
+}
+
+// clearWidespreadFailure marks the component recovered, it returns true if the widespread failure has just recovered
+func clearWidespreadFailure(component string) bool {
+	clustersLock.Lock()
+	defer clustersLock.Unlock()
+	cluster, ok := componentClusters[component]
+	if !ok {
+		return false
+	}
+	delete(componentClusters, component)
+	delete(clusterFailures[cluster], component)
+	if len(clusterFailures[cluster]) == 0 {
+		delete(clusterFailures, cluster)
+	}
+
+	if widespreadFailure && !isWidespread(len(clusterFailures), len(monitoredClusters), GetConfig().WidespreadFailureThreshold) {
+		widespreadFailure = false
+		return true
+	}
+	return false
+}
+
+// reportWidespreadFailure creates the widespread failure incident when the failure has just become widespread
+// the individual cluster incidents are still reported
+func reportWidespreadFailure(cluster, component, desc string) bool {
+	failing, total, newlyWidespread := trackClusterFailure(cluster, component)
+	if newlyWidespread {
+		msg := fmt.Sprintf("widespread failure with %d of %d clusters failed, the monitor or a shared infrastructure may be at fault", failing, total)
+		CreateIncident(widespreadFailureComponent, widespreadFailureComponent, msg, desc, "P1")
+	}
+	return newlyWidespread
+}
@@ -0,0 +1,70 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"testing"
+)
+
+func TestWidespreadFailure(t *testing.T) {
+	defer withoutAlertDestinations()()
+	saved := monitoredClusters
+	defer func() {
+		clustersLock.Lock()
+		monitoredClusters = saved
+		clustersLock.Unlock()
+	}()
+	c := Configuration{
+		PulsarTopicConfig: []TopicCfg{
+			{PulsarURL: "pulsar+ssl://cluster1.example.com:6651"},
+			{PulsarURL: "pulsar+ssl://cluster1.example.com:6651"},
+			{PulsarURL: "pulsar+ssl://cluster2.example.com:6651"},
+		},
+		PulsarAdminConfig: PulsarAdminRESTCfg{Clusters: []OpsClusterCfg{{URL: "https://cluster3.example.com"}, {URL: "https://cluster4.example.com"}}},
+	}
+	c.setClusters()
+	assert(t, len(monitoredClusters) == 4, "expect 4 clusters but got %d", len(monitoredClusters))
+	assert(t, c.PulsarTopicConfig[0].AlertPolicy.cluster == "cluster1.example.com", "expect cluster1.example.com but got %s", c.PulsarTopicConfig[0].AlertPolicy.cluster)
+	assert(t, c.PulsarAdminConfig.Clusters[0].AlertPolicy.cluster == "cluster3.example.com", "expect cluster3.example.com but got %s", c.PulsarAdminConfig.Clusters[0].AlertPolicy.cluster)
+
+	Config.WidespreadFailureThreshold = 0.5
+	assert(t, !reportWidespreadFailure("cluster1.example.com", "widespread-cluster1-latency", "desc"), "expect no widespread failure with 1 of 4 clusters failed")
+	assert(t, !reportWidespreadFailure("cluster1.example.com", "widespread-cluster1-admin", "desc"), "expect the components of one cluster counted once")
+	assert(t, !reportWidespreadFailure("cluster2.example.com", "widespread-cluster2-latency", "desc"), "expect no widespread failure at the threshold")
+	assert(t, reportWidespreadFailure("cluster3.example.com", "widespread-cluster3-admin", "desc"), "expect the widespread failure above the threshold")
+	assert(t, !reportWidespreadFailure("cluster4.example.com", "widespread-cluster4-admin", "desc"), "expect a single widespread failure incident")
+
+	assert(t, !clearWidespreadFailure("widespread-cluster4-admin"), "expect the widespread failure with 3 of 4 clusters failed")
+	assert(t, !clearWidespreadFailure("widespread-cluster1-latency"), "expect cluster1 still failing on the other component")
+	assert(t, clearWidespreadFailure("widespread-cluster3-admin"), "expect the widespread failure recovered with 2 of 4 clusters failed")
+	clearWidespreadFailure("widespread-cluster2-latency")
+	clearWidespreadFailure("widespread-cluster1-admin")
+	assert(t, len(clusterFailures) == 0, "expect no failing cluster but got %d", len(clusterFailures))
+
+	Config.WidespreadFailureThreshold = 0
+	for _, component := range []string{"widespread-cluster1-latency", "widespread-cluster2-latency", "widespread-cluster3-admin"} {
+		assert(t, !reportWidespreadFailure(clusterHost("https://"+component), component, "desc"), "expect the widespread failure disabled")
+	}
+	for _, component := range []string{"widespread-cluster1-latency", "widespread-cluster2-latency", "widespread-cluster3-admin"} {
+		clearWidespreadFailure(component)
+	}
+}
@@ -0,0 +1,124 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+// monitor zookeeper latency with a lightweight read
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/datastax/pulsar-heartbeat/src/util"
+	"github.com/datastax/pulsar-heartbeat/src/zookeeper"
+)
+
+// zkClient is the ZooKeeper read capability required by the latency probe
+type zkClient interface {
+	Exists(path string) (bool, error)
+	Close() error
+}
+
+var connectZookeeper = func(server string, timeout time.Duration) (zkClient, error) {
+	return zookeeper.Connect(server, timeout)
+}
+
+// ZookeeperLatency connects to a zookeeper server and measures the latency of an exists read on the root znode
+func ZookeeperLatency(server string, timeout time.Duration) (time.Duration, error) {
+	sentTime := time.Now()
+	client, err := connectZookeeper(server, timeout)
+	if err != nil {
+		return failedLatency, fmt.Errorf("failed to connect to zookeeper %s: %w", server, err)
+	}
+	defer client.Close()
+
+	exists, err := client.Exists("/")
+	if err != nil {
+		return failedLatency, fmt.Errorf("zookeeper %s exists read error: %w", server, err)
+	} else if !exists {
+		return failedLatency, fmt.Errorf("zookeeper %s returns no root znode", server)
+	}
+	return time.Since(sentTime), nil
+}
+
+// evalZookeeperLatency evaluates each zookeeper server's latency and returns a summary of failures
+func evalZookeeperLatency(latencies map[string]time.Duration, errs map[string]error, budget time.Duration) string {
+	failures := []string{}
+	for server, err := range errs {
+		failures = append(failures, err.Error())
+		delete(latencies, server)
+	}
+	for server, latency := range latencies {
+		if latency > budget {
+			failures = append(failures, fmt.Sprintf("zookeeper %s latency %v over the budget %v", server, latency, budget))
+		}
+	}
+	return strings.Join(failures, "; ")
+}
+
+// TestZookeeperLatency tests and reports each zookeeper server in the ensemble
+func TestZookeeperLatency() {
+	zkCfg := GetConfig().ZookeeperConfig
+	component := GetConfig().Name + "-zookeeper"
+	budget := util.TimeDuration(zkCfg.LatencyBudgetMs, 1000, time.Millisecond)
+	timeout := util.TimeDuration(zkCfg.TimeoutSeconds, 10, time.Second)
+
+	latencies := make(map[string]time.Duration)
+	errs := make(map[string]error)
+	for _, server := range zkCfg.Hosts {
+		latency, err := ZookeeperLatency(server, timeout)
+		if err != nil {
+			errs[server] = err
+			continue
+		}
+		latencies[server] = latency
+		PromLatencySum(GetGaugeType(k8sZookeeperSubsystem), server, latency)
+	}
+
+	if failures := evalZookeeperLatency(latencies, errs, budget); failures != "" {
+		errMsg := fmt.Sprintf("%s test failed: %s", component, failures)
+		log.Errorf(errMsg)
+		ReportIncident(component, component, "zookeeper latency test failure", errMsg, &zkCfg.AlertPolicy)
+		return
+	}
+	log.Infof("%s latency test has successfully passed on %v", component, zkCfg.Hosts)
+	ClearIncident(component)
+}
+
+// MonitorZookeeperLatency starts the zookeeper latency monitoring thread
+func MonitorZookeeperLatency() {
+	runProbes(zookeeperProbes())
+}
+
+// zookeeperProbes is the zookeeper latency probe if the zookeeper hosts are configured
+func zookeeperProbes() []probe {
+	zkCfg := GetConfig().ZookeeperConfig
+	if len(zkCfg.Hosts) == 0 {
+		return nil
+	}
+	return []probe{{
+		name:     GetConfig().Name + "-zookeeper",
+		interval: IntervalDuration(zkCfg.IntervalSeconds, 60),
+		run:      TestZookeeperLatency,
+	}}
+}
@@ -0,0 +1,85 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package cfg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeZkClient struct {
+	delay  time.Duration
+	exists bool
+	err    error
+}
+
+func (c fakeZkClient) Exists(path string) (bool, error) {
+	time.Sleep(c.delay)
+	return c.exists, c.err
+}
+
+func (c fakeZkClient) Close() error {
+	return nil
+}
+
+func TestZookeeperLatencyProbe(t *testing.T) {
+	defer func(connect func(string, time.Duration) (zkClient, error)) {
+		connectZookeeper = connect
+	}(connectZookeeper)
+
+	servers := map[string]zkClient{
+		"zk-0:2181": fakeZkClient{exists: true},
+		"zk-1:2181": fakeZkClient{exists: true, delay: 30 * time.Millisecond},
+		"zk-2:2181": fakeZkClient{err: errors.New("connection loss")},
+	}
+	connectZookeeper = func(server string, timeout time.Duration) (zkClient, error) {
+		if client, ok := servers[server]; ok {
+			return client, nil
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	latencies := make(map[string]time.Duration)
+	errs := make(map[string]error)
+	for _, server := range []string{"zk-0:2181", "zk-1:2181", "zk-2:2181", "zk-3:2181"} {
+		latency, err := ZookeeperLatency(server, time.Second)
+		if err != nil {
+			assert(t, latency == failedLatency, "expect failed latency on error")
+			errs[server] = err
+			continue
+		}
+		latencies[server] = latency
+	}
+	assert(t, len(latencies) == 2, "expect two successful servers")
+	assert(t, len(errs) == 2, "expect exists read error and connect error")
+
+	failures := evalZookeeperLatency(latencies, errs, 20*time.Millisecond)
+	assert(t, strings.Contains(failures, "zk-1:2181 latency"), "expect over budget server reported, %s", failures)
+	assert(t, strings.Contains(failures, "connection loss"), "expect exists error reported, %s", failures)
+	assert(t, strings.Contains(failures, "connection refused"), "expect connect error reported, %s", failures)
+	assert(t, !strings.Contains(failures, "zk-0"), "expect the healthy server not reported, %s", failures)
+
+	assert(t, evalZookeeperLatency(map[string]time.Duration{"zk-0:2181": time.Millisecond}, map[string]error{}, time.Second) == "",
+		"expect no failure within the budget")
+}
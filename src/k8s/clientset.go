@@ -97,6 +97,18 @@ type Client struct {
 	Broker           Deployment
 	Proxy            Deployment
 	FunctionWorker   StatefulSet
+	ExpectedReplicas ExpectedReplicas
+}
+
+// ExpectedReplicas is the minimum expected replicas of each component,
+// it takes precedence over the replicas in the k8s spec when it is greater
+// so that a component scaled down to 0 is still evaluated as offline
+type ExpectedReplicas struct {
+	Zookeeper      int32 `json:"zookeeper"`
+	Bookkeeper     int32 `json:"bookkeeper"`
+	Broker         int32 `json:"broker"`
+	Proxy          int32 `json:"proxy"`
+	FunctionWorker int32 `json:"functionWorker"`
 }
 
 // ClusterStatus is the health status of the cluster and its components
@@ -106,7 +118,9 @@ type ClusterStatus struct {
 	BrokerOfflineInstances     int
 	BrokerStsOfflineInstances  int
 	ProxyOfflineInstances      int
-	Status                     ClusterStatusCode
+	// FunctionWorkerOfflineInstances does not affect the cluster status beyond PartialReady since the data plane is intact
+	FunctionWorkerOfflineInstances int
+	Status                         ClusterStatusCode
 }
 
 // Deployment is the k8s deployment
@@ -123,8 +137,8 @@ type StatefulSet struct {
 	Instances int32
 }
 
-// GetK8sClient gets k8s clientset
-func GetK8sClient(pulsarNamespace string) (*Client, error) {
+// restConfig returns the in-cluster config, or the kubeconfig of the home directory outside of the k8s cluster
+func restConfig(pulsarNamespace string) (*rest.Config, error) {
 	var config *rest.Config
 
 	if home := homedir.HomeDir(); home != "" {
@@ -143,6 +157,24 @@ func GetK8sClient(pulsarNamespace string) (*Client, error) {
 			}
 		}
 	}
+	return config, nil
+}
+
+// GetClientset gets the k8s clientset without evaluating the Pulsar cluster
+func GetClientset(namespace string) (kubernetes.Interface, error) {
+	config, err := restConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// GetK8sClient gets k8s clientset
+func GetK8sClient(pulsarNamespace string) (*Client, error) {
+	config, err := restConfig(pulsarNamespace)
+	if err != nil {
+		return nil, err
+	}
 
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -231,9 +263,44 @@ func (c *Client) UpdateReplicas(namespace string) error {
 		c.Bookkeeper.Replicas = 0
 	}
 
+	fw, err := c.getStatefulSets(namespace, FunctionWorkerDeployment)
+	if err != nil {
+		return err
+	}
+	if len(fw.Items) > 0 {
+		c.FunctionWorker.Replicas = *(fw.Items[0]).Spec.Replicas
+	} else {
+		c.FunctionWorker.Replicas = 0
+	}
+
+	c.applyExpectedReplicas()
 	return nil
 }
 
+// applyExpectedReplicas raises the spec derived replicas to the configured minimum expected replicas
+func (c *Client) applyExpectedReplicas() {
+	c.Zookeeper.Replicas = maxInt32(c.Zookeeper.Replicas, c.ExpectedReplicas.Zookeeper)
+	c.Bookkeeper.Replicas = maxInt32(c.Bookkeeper.Replicas, c.ExpectedReplicas.Bookkeeper)
+	c.Proxy.Replicas = maxInt32(c.Proxy.Replicas, c.ExpectedReplicas.Proxy)
+	c.FunctionWorker.Replicas = maxInt32(c.FunctionWorker.Replicas, c.ExpectedReplicas.FunctionWorker)
+
+	// broker can be either a deployment or a statefulset
+	if c.Broker.Replicas+c.BrokerSts.Replicas < c.ExpectedReplicas.Broker {
+		if c.BrokerSts.Replicas > 0 {
+			c.BrokerSts.Replicas = c.ExpectedReplicas.Broker
+		} else {
+			c.Broker.Replicas = c.ExpectedReplicas.Broker
+		}
+	}
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // WatchPods watches the running pods vs intended replicas
 func (c *Client) WatchPods(namespace string) error {
 
@@ -279,6 +346,14 @@ func (c *Client) WatchPods(namespace string) error {
 			return err
 		}
 	}
+
+	if c.FunctionWorker.Replicas > 0 {
+		if counts, err := c.runningPodCounts(namespace, FunctionWorkerDeployment); err == nil {
+			c.FunctionWorker.Instances = int32(counts)
+		} else {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -286,12 +361,13 @@ func (c *Client) WatchPods(namespace string) error {
 func (c *Client) EvalHealth() (string, ClusterStatus) {
 	health := ""
 	status := ClusterStatus{
-		ZookeeperOfflineInstances:  int(c.Zookeeper.Replicas - c.Zookeeper.Instances),
-		BookkeeperOfflineInstances: int(c.Bookkeeper.Replicas - c.Bookkeeper.Instances),
-		BrokerOfflineInstances:     int(c.Broker.Replicas - c.Broker.Instances),
-		BrokerStsOfflineInstances:  int(c.BrokerSts.Replicas - c.BrokerSts.Instances),
-		ProxyOfflineInstances:      int(c.Proxy.Replicas - c.Proxy.Instances),
-		Status:                     OK,
+		ZookeeperOfflineInstances:      int(c.Zookeeper.Replicas - c.Zookeeper.Instances),
+		BookkeeperOfflineInstances:     int(c.Bookkeeper.Replicas - c.Bookkeeper.Instances),
+		BrokerOfflineInstances:         int(c.Broker.Replicas - c.Broker.Instances),
+		BrokerStsOfflineInstances:      int(c.BrokerSts.Replicas - c.BrokerSts.Instances),
+		ProxyOfflineInstances:          int(c.Proxy.Replicas - c.Proxy.Instances),
+		FunctionWorkerOfflineInstances: int(c.FunctionWorker.Replicas - c.FunctionWorker.Instances),
+		Status:                         OK,
 	}
 	if c.Zookeeper.Instances < 2 {
 		health = fmt.Sprintf("\nCluster error - zookeeper is running %d instances out of %d replicas", c.Zookeeper.Instances, c.Zookeeper.Replicas)
@@ -332,6 +408,14 @@ func (c *Client) EvalHealth() (string, ClusterStatus) {
 		health = health + fmt.Sprintf("\nCluster warning - proxy is running %d instances out of %d", c.Proxy.Instances, c.Proxy.Replicas)
 		status.Status = updateStatus(status.Status, PartialReady)
 	}
+
+	if c.FunctionWorker.Replicas > 0 && c.FunctionWorker.Instances == 0 {
+		health = health + fmt.Sprintf("\nCluster error - function worker has no running instances out of %d replicas", c.FunctionWorker.Replicas)
+		status.Status = updateStatus(status.Status, PartialReady)
+	} else if c.FunctionWorker.Replicas > 0 && c.FunctionWorker.Instances < c.FunctionWorker.Replicas {
+		health = health + fmt.Sprintf("\nCluster warning - function worker is running %d instances out of %d", c.FunctionWorker.Instances, c.FunctionWorker.Replicas)
+		status.Status = updateStatus(status.Status, PartialReady)
+	}
 	c.Status = status.Status
 	return health, status
 }
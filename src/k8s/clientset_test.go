@@ -0,0 +1,115 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package k8s
+
+import (
+	"strings"
+	"testing"
+)
+
+// healthyClient returns a client with all components running on their spec replicas
+func healthyClient() Client {
+	return Client{
+		Zookeeper:  StatefulSet{Name: ZookeeperSts, Replicas: 3, Instances: 3},
+		Bookkeeper: StatefulSet{Name: BookkeeperSts, Replicas: 3, Instances: 3},
+		Broker:     Deployment{Name: BrokerDeployment, Replicas: 3, Instances: 3},
+		Proxy:      Deployment{Name: ProxyDeployment, Replicas: 3, Instances: 3},
+	}
+}
+
+func TestExpectedReplicasOverride(t *testing.T) {
+	client := healthyClient()
+	// proxy is accidentally scaled to 0
+	client.Proxy = Deployment{Name: ProxyDeployment, Replicas: 0, Instances: 0}
+	client.applyExpectedReplicas()
+	if _, status := client.EvalHealth(); status.Status != OK {
+		t.Fatalf("expect the spec derived replicas report OK status but got %s", ClusterStatusCodeString(status.Status))
+	}
+
+	client.ExpectedReplicas = ExpectedReplicas{Proxy: 3}
+	client.applyExpectedReplicas()
+	if client.Proxy.Replicas != 3 {
+		t.Fatalf("expect proxy replicas raised to 3 but got %d", client.Proxy.Replicas)
+	}
+	desc, status := client.EvalHealth()
+	if status.Status != TotalDown {
+		t.Fatalf("expect a TotalDown status to fire an incident but got %s", ClusterStatusCodeString(status.Status))
+	}
+	if status.ProxyOfflineInstances != 3 {
+		t.Fatalf("expect 3 offline proxy instances but got %d, %s", status.ProxyOfflineInstances, desc)
+	}
+
+	// the spec replicas take precedence when it is greater than the expected replicas
+	client = healthyClient()
+	client.ExpectedReplicas = ExpectedReplicas{Zookeeper: 1, Broker: 1}
+	client.applyExpectedReplicas()
+	if client.Zookeeper.Replicas != 3 || client.Broker.Replicas != 3 {
+		t.Fatalf("expect the spec replicas unchanged")
+	}
+
+	// broker scaled to 0 as a statefulset
+	client = healthyClient()
+	client.Broker = Deployment{Name: BrokerDeployment}
+	client.BrokerSts = StatefulSet{Name: BrokerSts}
+	client.ExpectedReplicas = ExpectedReplicas{Broker: 3}
+	client.applyExpectedReplicas()
+	if _, status := client.EvalHealth(); status.Status != TotalDown {
+		t.Fatalf("expect no running broker to be TotalDown")
+	}
+}
+
+func TestFunctionWorkerHealth(t *testing.T) {
+	// function workers are not evaluated without replicas
+	client := healthyClient()
+	if _, status := client.EvalHealth(); status.Status != OK || status.FunctionWorkerOfflineInstances != 0 {
+		t.Fatalf("expect OK status without function workers but got %s", ClusterStatusCodeString(status.Status))
+	}
+
+	client.FunctionWorker = StatefulSet{Name: FunctionWorkerDeployment, Replicas: 2, Instances: 2}
+	if _, status := client.EvalHealth(); status.Status != OK {
+		t.Fatalf("expect OK status with all function workers running but got %s", ClusterStatusCodeString(status.Status))
+	}
+
+	client.FunctionWorker.Instances = 1
+	desc, status := client.EvalHealth()
+	if status.Status != PartialReady || status.FunctionWorkerOfflineInstances != 1 {
+		t.Fatalf("expect PartialReady with 1 offline function worker but got %s, %s", ClusterStatusCodeString(status.Status), desc)
+	}
+
+	// the data plane is intact while all function workers are down
+	client.FunctionWorker.Instances = 0
+	desc, status = client.EvalHealth()
+	if status.Status != PartialReady || status.FunctionWorkerOfflineInstances != 2 {
+		t.Fatalf("expect PartialReady with 2 offline function workers but got %s, %s", ClusterStatusCodeString(status.Status), desc)
+	}
+	if !strings.Contains(desc, "function worker has no running instances") {
+		t.Fatalf("expect the function worker error in the description but got %s", desc)
+	}
+
+	// the expected replicas detect the function workers scaled to 0
+	client.FunctionWorker = StatefulSet{Name: FunctionWorkerDeployment}
+	client.ExpectedReplicas = ExpectedReplicas{FunctionWorker: 2}
+	client.applyExpectedReplicas()
+	if _, status := client.EvalHealth(); status.FunctionWorkerOfflineInstances != 2 {
+		t.Fatalf("expect 2 offline function workers but got %d", status.FunctionWorkerOfflineInstances)
+	}
+}
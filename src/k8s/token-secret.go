@@ -0,0 +1,69 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package k8s
+
+// read the Pulsar token from a k8s secret
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/apex/log"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretTokenSupplier returns a token supplier reading the key of the secret,
+// the token is cached and read again from the secret after the refresh interval.
+// the cached token is returned if the secret cannot be read after the refresh interval
+func SecretTokenSupplier(clientset kubernetes.Interface, namespace, name, key string, refresh time.Duration) func() (string, error) {
+	var mu sync.Mutex
+	token := ""
+	var readAt time.Time
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if token != "" && time.Since(readAt) < refresh {
+			return token, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, meta_v1.GetOptions{})
+		if err == nil {
+			value, ok := secret.Data[key]
+			if ok && len(value) > 0 {
+				token, readAt = strings.TrimSuffix(string(value), "\n"), time.Now()
+				return token, nil
+			}
+			err = fmt.Errorf("key %s is not found in the secret", key)
+		}
+		if token != "" {
+			log.Warnf("failed to refresh the token from secret %s/%s, the cached token is used, error: %v", namespace, name, err)
+			return token, nil
+		}
+		return "", fmt.Errorf("failed to read the token from secret %s/%s: %w", namespace, name, err)
+	}
+}
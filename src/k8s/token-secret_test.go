@@ -0,0 +1,86 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package k8s
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestSecretTokenSupplier(t *testing.T) {
+	token, requests := "token-1\n", 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/pulsar/secrets/heartbeat-token" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		requests++
+		if token == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"kind":"Secret","apiVersion":"v1","metadata":{"name":"heartbeat-token","namespace":"pulsar"},"data":{"token":"%s"}}`,
+			base64.StdEncoding.EncodeToString([]byte(token)))
+	}))
+	defer server.Close()
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	supplier := SecretTokenSupplier(clientset, "pulsar", "heartbeat-token", "token", 50*time.Millisecond)
+	if value, err := supplier(); err != nil || value != "token-1" {
+		t.Fatalf("expect token-1 read from the secret but got %s, error %v", value, err)
+	}
+	token = "token-2"
+	if value, _ := supplier(); value != "token-1" || requests != 1 {
+		t.Fatalf("expect the cached token-1 within the refresh interval but got %s after %d requests", value, requests)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if value, _ := supplier(); value != "token-2" {
+		t.Fatalf("expect the refreshed token-2 but got %s", value)
+	}
+
+	// the cached token is used when the secret cannot be read
+	token = ""
+	time.Sleep(60 * time.Millisecond)
+	if value, err := supplier(); err != nil || value != "token-2" {
+		t.Fatalf("expect the cached token-2 but got %s, error %v", value, err)
+	}
+
+	token = "token-3"
+	if _, err := SecretTokenSupplier(clientset, "pulsar", "heartbeat-token", "missing", time.Minute)(); err == nil {
+		t.Fatalf("expect an error without a cached token")
+	}
+	if _, err := SecretTokenSupplier(clientset, "pulsar", "missing-secret", "token", time.Minute)(); err == nil {
+		t.Fatalf("expect an error on a missing secret")
+	}
+}
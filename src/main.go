@@ -27,17 +27,16 @@ import (
 	"net/http"
 	"os"
 	"runtime"
-	"time"
 
 	"github.com/apex/log"
 	"github.com/datastax/pulsar-heartbeat/src/cfg"
 	"github.com/datastax/pulsar-heartbeat/src/util"
 	"github.com/google/gops/agent"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
 	cfgFile = flag.String("config", "../config/runtime.yml", "config file for monitoring")
+	once    = flag.Bool("once", false, "run every configured probe once and exit, the exit code is 1 if any probe fails")
 )
 
 func main() {
@@ -56,20 +55,48 @@ func main() {
 	cfg.ReadConfigFile(effectiveCfgFile)
 
 	config := cfg.GetConfig()
+	stopTracing := cfg.InitTracing()
+
+	if *once {
+		if config.WarmupOnStart {
+			cfg.WarmUpPulsarClients()
+		}
+		exitCode := cfg.RunOnce()
+		// flush the probe spans before the exit
+		stopTracing()
+		os.Exit(exitCode)
+	}
 
 	cfg.MonitorK8sPulsarCluster()
-	cfg.RunInterval(cfg.PulsarTenants, util.TimeDuration(config.PulsarAdminConfig.IntervalSeconds, 120, time.Second))
-	cfg.RunInterval(cfg.StartHeartBeat, util.TimeDuration(config.OpsGenieConfig.IntervalSeconds, 240, time.Second))
-	cfg.RunInterval(cfg.UptimeHeartBeat, 30*time.Second) // fixed 30 seconds for heartbeat
+	cfg.MonitorBookkeeperLedgers()
+	cfg.MonitorZookeeperLatency()
+	cfg.PulsarAdminTestThread()
+	cfg.RunInterval(cfg.StartHeartBeat, cfg.IntervalDuration(config.OpsGenieConfig.IntervalSeconds, 240))
+	cfg.RunInterval(cfg.UptimeHeartBeat, cfg.UptimeHeartbeatInterval)
+	cfg.MonitorHeartbeatWatchdog()
+	cfg.MonitorIdlePulsarClients()
 	cfg.MonitorSites()
+	if config.WarmupOnStart {
+		cfg.WarmUpPulsarClients()
+	}
 	cfg.TopicLatencyTestThread()
 	cfg.WebSocketTopicLatencyTestThread()
+	cfg.KafkaLatencyTestThread()
+	cfg.MqttLatencyTestThread()
+	cfg.FailoverDrillThread()
 	cfg.PushToPrometheusProxyThread()
 
 	if config.PrometheusConfig.ExposeMetrics {
 		log.Infof("serving metrics on port %s", config.PrometheusConfig.Port)
-		http.Handle("/metrics", promhttp.Handler())
-		http.ListenAndServe(util.FirstNonEmptyString(config.PrometheusConfig.Port, ":8089"), nil)
+		http.Handle("/metrics", cfg.MetricsHandler())
+		http.Handle("/status", cfg.StatusHandler(config.PrometheusConfig.StatusToken))
+		http.Handle("/incidents", cfg.IncidentsHandler(config.PrometheusConfig.StatusToken))
+		if err := cfg.ServeMetrics(util.FirstNonEmptyString(config.PrometheusConfig.Port, ":8089"), nil); err != nil {
+			if !config.PrometheusConfig.WarnOnBindError {
+				log.Fatalf("%v", err)
+			}
+			log.Errorf("metrics are not exposed, %v", err)
+		}
 	}
 	exit := make(chan *struct{})
 	for {
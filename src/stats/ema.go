@@ -0,0 +1,56 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package stats
+
+// defaultSmoothingFactor is the weight of the latest sample if the smoothing factor is out of (0, 1]
+const defaultSmoothingFactor = 0.2
+
+// EMA is the exponential moving average of the samples
+type EMA struct {
+	alpha   float64
+	value   float64
+	samples int
+}
+
+// NewEMA creates an exponential moving average with the smoothing factor as the weight of the latest sample
+func NewEMA(smoothingFactor float64) *EMA {
+	if smoothingFactor <= 0 || smoothingFactor > 1 {
+		smoothingFactor = defaultSmoothingFactor
+	}
+	return &EMA{alpha: smoothingFactor}
+}
+
+// Push a sample into the average, the first sample is the initial average, returns the average
+func (e *EMA) Push(sample float64) float64 {
+	if e.samples == 0 {
+		e.value = sample
+	} else {
+		e.value = e.alpha*sample + (1-e.alpha)*e.value
+	}
+	e.samples++
+	return e.value
+}
+
+// Value returns the average, it is 0 if there is no sample
+func (e *EMA) Value() float64 {
+	return e.value
+}
@@ -0,0 +1,47 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEMA(t *testing.T) {
+	ema := NewEMA(0.5)
+	if ema.Value() != 0 {
+		t.Fatalf("expect 0 without sample but got %f", ema.Value())
+	}
+	expected := []float64{100, 60, 40, 70, 85}
+	for i, sample := range []float64{100, 20, 20, 100, 100} {
+		if v := ema.Push(sample); math.Abs(v-expected[i]) > 1e-9 {
+			t.Fatalf("expect average %f after sample %d but got %f", expected[i], i, v)
+		}
+	}
+
+	// an out of range smoothing factor uses the default
+	ema = NewEMA(0)
+	ema.Push(100)
+	if v := ema.Push(200); math.Abs(v-120) > 1e-9 {
+		t.Fatalf("expect average 120 with the default smoothing factor but got %f", v)
+	}
+}
@@ -0,0 +1,64 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package stats
+
+// RollingRatio is the ratio of successful outcomes over a bounded window of the latest outcomes
+type RollingRatio struct {
+	outcomes  []bool
+	next      int
+	successes int
+}
+
+// NewRollingRatio creates a rolling ratio over a window of the size
+func NewRollingRatio(size int) *RollingRatio {
+	if size < 1 {
+		size = 1
+	}
+	return &RollingRatio{
+		outcomes: make([]bool, 0, size),
+	}
+}
+
+// Push an outcome into the window, the oldest outcome is evicted when the window is full, returns the ratio
+func (r *RollingRatio) Push(success bool) float64 {
+	if len(r.outcomes) < cap(r.outcomes) {
+		r.outcomes = append(r.outcomes, success)
+	} else {
+		if r.outcomes[r.next] {
+			r.successes--
+		}
+		r.outcomes[r.next] = success
+		r.next = (r.next + 1) % len(r.outcomes)
+	}
+	if success {
+		r.successes++
+	}
+	return r.Ratio()
+}
+
+// Ratio returns the ratio of successful outcomes in the window, it is 1 if there is no outcome
+func (r *RollingRatio) Ratio() float64 {
+	if len(r.outcomes) == 0 {
+		return 1
+	}
+	return float64(r.successes) / float64(len(r.outcomes))
+}
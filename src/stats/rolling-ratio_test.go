@@ -0,0 +1,56 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package stats
+
+import (
+	"testing"
+)
+
+func TestRollingRatio(t *testing.T) {
+	ratio := NewRollingRatio(4)
+	if ratio.Ratio() != 1 {
+		t.Fatalf("expect ratio 1 without outcome but got %f", ratio.Ratio())
+	}
+	ratio.Push(true)
+	if r := ratio.Push(false); r != 0.5 {
+		t.Fatalf("expect ratio 0.5 but got %f", r)
+	}
+	ratio.Push(true)
+	if r := ratio.Push(true); r != 0.75 {
+		t.Fatalf("expect ratio 0.75 but got %f", r)
+	}
+
+	// the window is full, the oldest successful outcome is evicted
+	if r := ratio.Push(false); r != 0.5 {
+		t.Fatalf("expect ratio 0.5 after eviction but got %f", r)
+	}
+	// the failed outcome is evicted
+	if r := ratio.Push(true); r != 0.75 {
+		t.Fatalf("expect ratio 0.75 after eviction but got %f", r)
+	}
+	for i := 0; i < 4; i++ {
+		ratio.Push(false)
+	}
+	if ratio.Ratio() != 0 {
+		t.Fatalf("expect ratio 0 but got %f", ratio.Ratio())
+	}
+}
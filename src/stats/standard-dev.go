@@ -31,6 +31,9 @@ type StandardDeviation struct {
 	Mean    float64
 	Buckets []float64
 	Std     float64 // σ
+	// WarmupSamples is the number of the first pushed samples excluded from the model
+	WarmupSamples int
+	skipped       int
 }
 
 // NewStandardDeviation creates a new standard dev object
@@ -40,9 +43,22 @@ func NewStandardDeviation(name string) StandardDeviation {
 	}
 }
 
+// NewStandardDeviationWithWarmup creates a new standard dev object that skips the first warmup samples
+func NewStandardDeviationWithWarmup(name string, warmupSamples int) StandardDeviation {
+	return StandardDeviation{
+		Name:          name,
+		WarmupSamples: warmupSamples,
+	}
+}
+
 // Push a float64 to calculate standard deviation and returns σ and whether the number is over 6σ in positive right side of bell curve
 // 6σ is at odd of every three weeks
+// a warmup sample is not evaluated and always within 6σ
 func (sd *StandardDeviation) Push(num float64) (std, mean float64, within6Sigma bool) {
+	if sd.skipped < sd.WarmupSamples {
+		sd.skipped++
+		return sd.Std, sd.Mean, true
+	}
 	sd.Buckets = append(sd.Buckets, num)
 	sd.Sum += num
 	counter := len(sd.Buckets)
@@ -65,3 +65,26 @@ func Test0StandardDev(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestWarmupStandardDev(t *testing.T) {
+	std := NewStandardDeviationWithWarmup("Test", 3)
+	// cold start samples
+	for i := 0; i < 3; i++ {
+		if _, _, within6Std := std.Push(5000); !within6Std {
+			t.Fatalf("expect warmup sample %d within 6σ", i)
+		}
+	}
+	if len(std.Buckets) != 0 {
+		t.Fatalf("expect warmup samples excluded from the buckets but got %d", len(std.Buckets))
+	}
+
+	for i := 0; i < 20; i++ {
+		std.Push(float64(10 + i%3))
+	}
+	if std.Mean > 12 {
+		t.Fatalf("expect the mean not skewed by warmup samples but got %f", std.Mean)
+	}
+	if len(std.Buckets) != 20 {
+		t.Fatalf("expect 20 samples in the buckets after warmup but got %d", len(std.Buckets))
+	}
+}
@@ -39,6 +39,12 @@ import (
 
 // partition topics can be used to test availabilities of all PartitionTopic
 
+// partitionKeyPrefix is the message key prefix followed by the partition index
+const partitionKeyPrefix = "partitionkey"
+
+// ErrPartitionMismatch is returned when the partitioned topic has a different number of partitions than expected
+var ErrPartitionMismatch = errors.New("partition count mismatch")
+
 // PartitionTopics data struct is the persistent partition topic name and number of partitions it has
 type PartitionTopics struct {
 	NumberOfPartitions int
@@ -50,7 +56,31 @@ type PartitionTopics struct {
 	PartitionTopicName string
 	TopicFullname      string
 	BaseAdminURL       string
-	log                *log.Entry
+	// ReceiveTimeout is the timeout for all partition consumers to receive the message, default 60 seconds
+	ReceiveTimeout time.Duration
+	// MessagesPerPartition is the number of keyed messages sent to each partition to verify ordering, default 1
+	MessagesPerPartition int
+	// AdminRequestObserver is called with the latency and status code of every admin request, optional
+	AdminRequestObserver func(endpoint string, statusCode int, latency time.Duration)
+	// ClientName is the producer and consumer name to identify the test in the broker stats, optional
+	ClientName string
+	// ActualPartitions is the number of partitions of the existing topic queried by VerifyPartitionTopic
+	ActualPartitions int
+	// Transport is the round tripper of the admin requests, http.DefaultTransport is used if not specified
+	Transport http.RoundTripper
+	log       *log.Entry
+}
+
+// observeAdminRequest reports the admin request to the observer, the status code is 0 if no response is received
+func (pt *PartitionTopics) observeAdminRequest(endpoint string, response *http.Response, start time.Time) {
+	if pt.AdminRequestObserver == nil {
+		return
+	}
+	statusCode := 0
+	if response != nil {
+		statusCode = response.StatusCode
+	}
+	pt.AdminRequestObserver(endpoint, statusCode, time.Since(start))
 }
 
 // NewPartitionTopic creates a PartitionTopic test object
@@ -93,9 +123,12 @@ func (pt *PartitionTopics) GetPartitionTopic() (bool, error) {
 		request.Header.Add("Authorization", "Bearer "+token)
 	}
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: pt.Transport,
 	}
+	start := time.Now()
 	response, err := client.Do(request)
+	pt.observeAdminRequest("get-partitioned-topics", response, start)
 	if response != nil {
 		defer response.Body.Close()
 	}
@@ -145,9 +178,12 @@ func (pt *PartitionTopics) CreatePartitionTopic() error {
 		request.Header.Add("Authorization", "Bearer "+token)
 	}
 	client := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: pt.Transport,
 	}
+	start := time.Now()
 	response, err := client.Do(request)
+	pt.observeAdminRequest("create-partitioned-topic", response, start)
 	if response != nil {
 		defer response.Body.Close()
 	}
@@ -165,8 +201,59 @@ func (pt *PartitionTopics) CreatePartitionTopic() error {
 	return nil
 }
 
+// GetPartitionCount gets the number of partitions of the partitioned topic
+func (pt *PartitionTopics) GetPartitionCount() (int, error) {
+	url := pt.BaseAdminURL + "/admin/v2/persistent/" + pt.Tenant + "/" + pt.Namespace + "/" + pt.PartitionTopicName + "/partitions"
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if pt.TokenSupplier != nil {
+		token, err := pt.TokenSupplier()
+		if err != nil {
+			return 0, err
+		}
+		request.Header.Add("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: pt.Transport,
+	}
+	start := time.Now()
+	response, err := client.Do(request)
+	pt.observeAdminRequest("get-partitions", response, start)
+	if response != nil {
+		defer response.Body.Close()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET partitions %s response status code %d", url, response.StatusCode)
+	}
+
+	var metadata struct {
+		Partitions int `json:"partitions"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&metadata); err != nil {
+		return 0, err
+	}
+	return metadata.Partitions, nil
+}
+
+// partitionMismatch returns ErrPartitionMismatch if the actual number of partitions differs from the expected
+func partitionMismatch(topicFn string, expected, actual int) error {
+	if expected == actual {
+		return nil
+	}
+	return fmt.Errorf("%w: partitioned topic %s has %d partitions, expected %d", ErrPartitionMismatch, topicFn, actual, expected)
+}
+
 // VerifyPartitionTopic verifies existence of the partition topic
-// it creates one if it's missing
+// it creates one if it's missing, or returns ErrPartitionMismatch if the existing topic has a different number of partitions
 func (pt *PartitionTopics) VerifyPartitionTopic() error {
 	created, err := pt.GetPartitionTopic()
 	if err != nil {
@@ -174,33 +261,57 @@ func (pt *PartitionTopics) VerifyPartitionTopic() error {
 	}
 	if created {
 		pt.log.Infof("partitioned topic %s already exists", pt.TopicFullname)
-		return nil
+		actual, err := pt.GetPartitionCount()
+		if err != nil {
+			return err
+		}
+		pt.ActualPartitions = actual
+		return partitionMismatch(pt.TopicFullname, pt.NumberOfPartitions, actual)
 	}
 
-	return pt.CreatePartitionTopic()
+	if err := pt.CreatePartitionTopic(); err != nil {
+		return err
+	}
+	pt.ActualPartitions = pt.NumberOfPartitions
+	return nil
 }
 
 // TestPartitionTopic sends multiple messages and to be verified by multiple consumers
 func (pt *PartitionTopics) TestPartitionTopic(client pulsar.Client) (time.Duration, error) {
 
+	messagesPerPartition := pt.MessagesPerPartition
+	if messagesPerPartition < 1 {
+		messagesPerPartition = 1
+	}
 	// notify the main thread with the latency to complete the exit of all consumers
-	completeChan := make(chan *util.ConsumerResult, pt.NumberOfPartitions)
+	// the buffer accommodates a result from each consumer and a send error from each message
+	completeChan := make(chan *util.ConsumerResult, pt.NumberOfPartitions*(messagesPerPartition+1))
+	// consumers are cancelled and closed when the test returns including the timeout path
+	ctx, cancel := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
 	defer func() {
-		wg.Wait() // only close channel after the consumers processed messages or timedout
+		cancel()
+		wg.Wait() // only close channel after all consumers are closed
 		close(completeChan)
 	}()
 
 	partitionTopicSuffix := "-partition-"
 	// prepare the message
 	message := fmt.Sprintf("partition topic test message %v", time.Now())
-	receiveTimeout := 60 * time.Second
+	receiveTimeout := pt.ReceiveTimeout
+	if receiveTimeout <= 0 {
+		receiveTimeout = 60 * time.Second
+	}
 
 	pt.log.Infof("create a topic producer %s", pt.TopicFullname)
-	// create a pulsar producer
+	// create a pulsar producer, the message key is routed to the partition so that every partition receives messages
 	producer, err := client.CreateProducer(pulsar.ProducerOptions{
 		Topic:           pt.TopicFullname,
+		Name:            pt.ClientName,
 		DisableBatching: true,
+		MessageRouter: func(msg *pulsar.ProducerMessage, tm pulsar.TopicMetadata) int {
+			return partitionOfKey(msg.Key, int(tm.NumPartitions()))
+		},
 	})
 	if err != nil {
 		return 0, err
@@ -211,40 +322,26 @@ func (pt *PartitionTopics) TestPartitionTopic(client pulsar.Client) (time.Durati
 	}()
 
 	// start multiple consumers and listens to individual partition topics
+	messages := make([][]string, pt.NumberOfPartitions)
 	for i := 0; i < pt.NumberOfPartitions; i++ {
 		topicName := pt.TopicFullname + partitionTopicSuffix + strconv.Itoa(i)
-		pt.log.Infof("subscribe to partition topic %s wait on message %s", topicName, message)
-		go util.VerifyMessageByPulsarConsumer(client, topicName, message, receiveTimeout, &wg, completeChan)
+		messages[i] = partitionMessages(message, i, messagesPerPartition)
+		pt.log.Infof("subscribe to partition topic %s wait on %d messages %s", topicName, messagesPerPartition, message)
+		wg.Add(1)
+		go util.VerifyMessagesByPulsarConsumer(ctx, client, topicName, pt.ClientName, messages[i], receiveTimeout, &wg, completeChan)
 	}
 
-	// producer sends multiple messages
+	// producer sends multiple messages in order to each partition
 	start := time.Now()
-	for i := 0; i < pt.NumberOfPartitions; i++ {
-		ctx := context.Background()
-
-		// Create a different message to send asynchronously
-		msg := pulsar.ProducerMessage{
-			Payload: []byte(message),
-			Key:     "partitionkey" + strconv.Itoa(i),
+	for seq := 0; seq < messagesPerPartition; seq++ {
+		for i := 0; i < pt.NumberOfPartitions; i++ {
+			pt.sendAsync(ctx, producer, partitionKeyPrefix+strconv.Itoa(i), messages[i][seq], completeChan)
 		}
-
-		// Attempt to send message asynchronously and handle the response
-		producer.SendAsync(ctx, &msg, func(messageId pulsar.MessageID, msg *pulsar.ProducerMessage, err error) {
-			if err != nil {
-				log.Errorf("failed to send message over partition topic , error: %v", err)
-				errMsg := fmt.Sprintf("fail to instantiate Pulsar client: %v", err)
-				// report error and exit
-				completeChan <- &util.ConsumerResult{
-					Err: errors.New(errMsg),
-				}
-			} else {
-				log.Infof("successfully published message on topic %s ", pt.TopicFullname)
-			}
-		})
 	}
 
 	receivedCounter := 0
 	successfulCounter := 0
+	failures := []string{}
 	ticker := time.NewTicker(receiveTimeout)
 	defer ticker.Stop()
 	for receivedCounter < pt.NumberOfPartitions {
@@ -254,6 +351,7 @@ func (pt *PartitionTopics) TestPartitionTopic(client pulsar.Client) (time.Durati
 			log.Infof(" received counter %d", receivedCounter)
 			if signal.Err != nil {
 				log.Errorf("topic %s receive error: %v", pt.TopicFullname, signal.Err)
+				failures = append(failures, signal.Err.Error())
 			} else if signal.InOrderDelivery {
 				successfulCounter++
 				log.Infof("successfully received counter %d", successfulCounter)
@@ -264,9 +362,49 @@ func (pt *PartitionTopics) TestPartitionTopic(client pulsar.Client) (time.Durati
 				return time.Since(start), nil
 			}
 		case <-ticker.C:
-			return 0, fmt.Errorf("received %d msg with %d successful delivery but timed out to receive all %d messages",
-				receivedCounter, successfulCounter, pt.NumberOfPartitions)
+			return 0, fmt.Errorf("received %d msg with %d successful delivery but timed out to receive all %d messages %s",
+				receivedCounter, successfulCounter, pt.NumberOfPartitions, strings.Join(failures, "; "))
+		}
+	}
+	return 0, fmt.Errorf("received %d out of %d messages %s", successfulCounter, pt.NumberOfPartitions, strings.Join(failures, "; "))
+}
+
+// sendAsync sends the keyed message and reports the send error to the complete channel
+func (pt *PartitionTopics) sendAsync(ctx context.Context, producer pulsar.Producer, key, message string, completeChan chan *util.ConsumerResult) {
+	msg := pulsar.ProducerMessage{
+		Payload: []byte(message),
+		Key:     key,
+	}
+
+	// Attempt to send message asynchronously and handle the response
+	producer.SendAsync(ctx, &msg, func(messageId pulsar.MessageID, msg *pulsar.ProducerMessage, err error) {
+		if err != nil {
+			log.Errorf("failed to send message over partition topic , error: %v", err)
+			errMsg := fmt.Sprintf("fail to instantiate Pulsar client: %v", err)
+			// report error and exit
+			completeChan <- &util.ConsumerResult{
+				Err: errors.New(errMsg),
+			}
+		} else {
+			log.Infof("successfully published message on topic %s ", pt.TopicFullname)
 		}
+	})
+}
+
+// partitionMessages returns the ordered messages expected on the partition
+func partitionMessages(message string, partition, numOfMessages int) []string {
+	messages := make([]string, numOfMessages)
+	for seq := range messages {
+		messages[seq] = fmt.Sprintf("%s partition %d seq %d", message, partition, seq)
+	}
+	return messages
+}
+
+// partitionOfKey routes the partition key to the partition index, or the first partition for other keys
+func partitionOfKey(key string, numOfPartitions int) int {
+	partition, err := strconv.Atoi(strings.TrimPrefix(key, partitionKeyPrefix))
+	if err != nil || partition < 0 || numOfPartitions < 1 {
+		return 0
 	}
-	return 0, fmt.Errorf("received %d out of %d messages", successfulCounter, pt.NumberOfPartitions)
+	return partition % numOfPartitions
 }
@@ -0,0 +1,256 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package topic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/apex/log"
+)
+
+// stubConsumer receives the messages routed to its partition until the context is done
+type stubConsumer struct {
+	pulsar.Consumer
+	client   *stubClient
+	messages chan pulsar.Message
+}
+
+func (c *stubConsumer) Receive(ctx context.Context) (pulsar.Message, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *stubConsumer) Ack(pulsar.Message) error { return nil }
+
+func (c *stubConsumer) Close() {
+	c.client.mu.Lock()
+	defer c.client.mu.Unlock()
+	c.client.closed++
+}
+
+type stubMessage struct {
+	pulsar.Message
+	payload []byte
+}
+
+func (m *stubMessage) Payload() []byte { return m.payload }
+
+type stubTopicMetadata struct {
+	partitions uint32
+}
+
+func (m stubTopicMetadata) NumPartitions() uint32 { return m.partitions }
+
+// stubProducer acknowledges every message and delivers it to the routed partition if delivery is enabled
+type stubProducer struct {
+	pulsar.Producer
+	client *stubClient
+	router func(*pulsar.ProducerMessage, pulsar.TopicMetadata) int
+}
+
+func (p *stubProducer) SendAsync(ctx context.Context, msg *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	if p.client.deliver {
+		partition := p.router(msg, stubTopicMetadata{partitions: uint32(len(p.client.partitions))})
+		p.client.deliverTo(partition, msg.Payload)
+	}
+	callback(nil, msg, nil)
+}
+
+func (p *stubProducer) Name() string  { return "stub-producer" }
+func (p *stubProducer) Topic() string { return "stub-topic" }
+func (p *stubProducer) Close()        {}
+
+type stubClient struct {
+	pulsar.Client
+	mu         sync.Mutex
+	subscribed int
+	closed     int
+	deliver    bool
+	// partitions holds the messages of each partition, a partition in reversed delivers messages in reverse order
+	partitions [][][]byte
+	reversed   map[int]bool
+}
+
+func newStubClient(numOfPartitions int, deliver bool) *stubClient {
+	return &stubClient{
+		deliver:    deliver,
+		partitions: make([][][]byte, numOfPartitions),
+		reversed:   map[int]bool{},
+	}
+}
+
+func (c *stubClient) deliverTo(partition int, payload []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.partitions[partition] = append(c.partitions[partition], payload)
+}
+
+func (c *stubClient) CreateProducer(opts pulsar.ProducerOptions) (pulsar.Producer, error) {
+	return &stubProducer{client: c, router: opts.MessageRouter}, nil
+}
+
+func (c *stubClient) Subscribe(opts pulsar.ConsumerOptions) (pulsar.Consumer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed++
+	consumer := &stubConsumer{client: c, messages: make(chan pulsar.Message, 100)}
+	if c.deliver {
+		parts := strings.Split(opts.Topic, "-partition-")
+		partition, _ := strconv.Atoi(parts[len(parts)-1])
+		go consumer.stream(partition)
+	}
+	return consumer, nil
+}
+
+// stream delivers the partition messages once all messages are sent
+func (c *stubConsumer) stream(partition int) {
+	time.Sleep(50 * time.Millisecond)
+	c.client.mu.Lock()
+	payloads := append([][]byte{}, c.client.partitions[partition]...)
+	reversed := c.client.reversed[partition]
+	c.client.mu.Unlock()
+	for i := range payloads {
+		if reversed {
+			i = len(payloads) - 1 - i
+		}
+		c.messages <- &stubMessage{payload: payloads[i]}
+	}
+}
+
+func newStubPartitionTopics(numOfPartitions, messagesPerPartition int) *PartitionTopics {
+	return &PartitionTopics{
+		NumberOfPartitions:   numOfPartitions,
+		Tenant:               "tenant",
+		Namespace:            "ns",
+		PartitionTopicName:   "partition-topic",
+		TopicFullname:        "persistent://tenant/ns/partition-topic",
+		ReceiveTimeout:       2 * time.Second,
+		MessagesPerPartition: messagesPerPartition,
+		log:                  log.WithFields(log.Fields{"app": "partition topic test"}),
+	}
+}
+
+func TestPartitionConsumersClosedOnTimeout(t *testing.T) {
+	pt := newStubPartitionTopics(4, 1)
+	pt.ReceiveTimeout = 200 * time.Millisecond
+	client := newStubClient(4, false)
+
+	_, err := pt.TestPartitionTopic(client)
+	if err == nil {
+		t.Fatal("expect a timeout error when no message is received")
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if client.subscribed != 4 {
+		t.Fatalf("expect 4 consumers subscribed but got %d", client.subscribed)
+	}
+	if client.closed != client.subscribed {
+		t.Fatalf("expect all %d consumers closed but got %d", client.subscribed, client.closed)
+	}
+}
+
+func TestPartitionTopicOrdering(t *testing.T) {
+	client := newStubClient(3, true)
+	if _, err := newStubPartitionTopics(3, 5).TestPartitionTopic(client); err != nil {
+		t.Fatalf("expect in order delivery on every partition but got error %v", err)
+	}
+	for i, payloads := range client.partitions {
+		if len(payloads) != 5 {
+			t.Fatalf("expect 5 messages routed to partition %d but got %d", i, len(payloads))
+		}
+	}
+
+	client = newStubClient(3, true)
+	client.reversed[1] = true
+	_, err := newStubPartitionTopics(3, 5).TestPartitionTopic(client)
+	if err == nil {
+		t.Fatal("expect an ordering violation error")
+	}
+	if !strings.Contains(err.Error(), "partition-topic-partition-1 violated ordering") {
+		t.Fatalf("expect partition 1 reported for ordering violation but got %v", err)
+	}
+	if client.closed != 3 {
+		t.Fatalf("expect all 3 consumers closed but got %d", client.closed)
+	}
+}
+
+func TestPartitionOfKey(t *testing.T) {
+	if partitionOfKey(partitionKeyPrefix+"2", 4) != 2 {
+		t.Fatal("expect the key routed to partition 2")
+	}
+	if partitionOfKey(partitionKeyPrefix+"5", 4) != 1 {
+		t.Fatal("expect the key over the number of partitions wrapped around")
+	}
+	if partitionOfKey("other", 4) != 0 {
+		t.Fatal("expect other keys routed to the first partition")
+	}
+}
+
+func TestPartitionCountMismatch(t *testing.T) {
+	if err := partitionMismatch("persistent://tenant/ns/topic", 3, 3); err != nil {
+		t.Fatalf("expect no mismatch but got %v", err)
+	}
+	if err := partitionMismatch("persistent://tenant/ns/topic", 3, 2); !errors.Is(err, ErrPartitionMismatch) {
+		t.Fatalf("expect a mismatch on fewer partitions but got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/v2/persistent/tenant/ns/partitioned":
+			w.Write([]byte(`["persistent://tenant/ns/topic"]`))
+		case "/admin/v2/persistent/tenant/ns/topic/partitions":
+			w.Write([]byte(`{"partitions": 2}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	pt, err := NewPartitionTopic("pulsar://localhost:6650", nil, "", "persistent://tenant/ns/topic", server.URL, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pt.VerifyPartitionTopic(); !errors.Is(err, ErrPartitionMismatch) {
+		t.Fatalf("expect a mismatch with the existing topic but got %v", err)
+	}
+	if pt.ActualPartitions != 2 {
+		t.Fatalf("expect 2 actual partitions but got %d", pt.ActualPartitions)
+	}
+
+	pt.NumberOfPartitions = 2
+	if err := pt.VerifyPartitionTopic(); err != nil {
+		t.Fatalf("expect no mismatch but got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package util
+
+import (
+	"sync/atomic"
+
+	"github.com/apex/log"
+)
+
+// LogSampler emits one in every N repetitive info logs, the rest are emitted at debug level
+// all logs are emitted at debug level if the rate is less than 1
+type LogSampler struct {
+	rate  uint64
+	count uint64
+}
+
+// NewLogSampler creates a LogSampler with the sample rate
+func NewLogSampler(rate int) *LogSampler {
+	if rate < 1 {
+		rate = 0
+	}
+	return &LogSampler{rate: uint64(rate)}
+}
+
+// Infof logs at info level if the log is sampled, otherwise at debug level
+func (s *LogSampler) Infof(msg string, v ...interface{}) {
+	n := atomic.AddUint64(&s.count, 1)
+	if s.rate > 0 && (n-1)%s.rate == 0 {
+		log.Infof(msg, v...)
+		return
+	}
+	log.Debugf(msg, v...)
+}
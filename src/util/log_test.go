@@ -0,0 +1,79 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package util
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+)
+
+func withMemoryLogger(level log.Level, fn func()) *memory.Handler {
+	handler := memory.New()
+	defaultLogger := log.Log
+	log.Log = &log.Logger{Handler: handler, Level: level}
+	defer func() {
+		log.Log = defaultLogger
+	}()
+	fn()
+	return handler
+}
+
+func TestLogSampler(t *testing.T) {
+	// at info level, per message logs are suppressed without sampling
+	handler := withMemoryLogger(log.InfoLevel, func() {
+		sampler := NewLogSampler(0)
+		for i := 0; i < 10; i++ {
+			sampler.Infof("message %d", i)
+		}
+		log.Errorf("error log")
+	})
+	if len(handler.Entries) != 1 || handler.Entries[0].Level != log.ErrorLevel {
+		t.Fatalf("expect only the error log emitted but got %d entries", len(handler.Entries))
+	}
+
+	// one in every 3 logs is sampled at info level
+	handler = withMemoryLogger(log.InfoLevel, func() {
+		sampler := NewLogSampler(3)
+		for i := 0; i < 10; i++ {
+			sampler.Infof("message %d", i)
+		}
+	})
+	if len(handler.Entries) != 4 {
+		t.Fatalf("expect 4 sampled logs but got %d", len(handler.Entries))
+	}
+	if handler.Entries[1].Message != "message 3" {
+		t.Fatalf("expect the 4th log sampled but got %s", handler.Entries[1].Message)
+	}
+
+	// all logs are emitted at debug level
+	handler = withMemoryLogger(log.DebugLevel, func() {
+		sampler := NewLogSampler(0)
+		for i := 0; i < 10; i++ {
+			sampler.Infof("message %d", i)
+		}
+	})
+	if len(handler.Entries) != 10 {
+		t.Fatalf("expect all 10 logs at debug level but got %d", len(handler.Entries))
+	}
+}
@@ -41,50 +41,91 @@ type ConsumerResult struct {
 }
 
 // VerifyMessageByPulsarConsumer instantiates a Pulsar consumer and verifies an expected message
-func VerifyMessageByPulsarConsumer(client pulsar.Client, topicName, expectedMessage string, receiveTimeout time.Duration, wg *sync.WaitGroup, completeChan chan *ConsumerResult) error {
+// the consumer is closed when the message is received, the receive timeout expires, or the context is cancelled
+// the caller must add to the wait group before calling this function
+func VerifyMessageByPulsarConsumer(ctx context.Context, client pulsar.Client, topicName, consumerName, expectedMessage string, receiveTimeout time.Duration, wg *sync.WaitGroup, completeChan chan *ConsumerResult) error {
+	return VerifyMessagesByPulsarConsumer(ctx, client, topicName, consumerName, []string{expectedMessage}, receiveTimeout, wg, completeChan)
+}
+
+// VerifyMessagesByPulsarConsumer instantiates a Pulsar consumer and verifies a list of expected messages are received in order
+// the consumer is closed when all messages are received, the receive timeout expires, or the context is cancelled
+// the caller must add to the wait group before calling this function
+func VerifyMessagesByPulsarConsumer(ctx context.Context, client pulsar.Client, topicName, consumerName string, expectedMessages []string, receiveTimeout time.Duration, wg *sync.WaitGroup, completeChan chan *ConsumerResult) error {
+	defer wg.Done()
+	// the result is abandoned if the caller has already stopped waiting
+	report := func(result *ConsumerResult) {
+		select {
+		case completeChan <- result:
+		case <-ctx.Done():
+		}
+	}
+
 	topicParts := strings.Split(topicName, "/")
 	subscriptionName := "partition-sub" + topicParts[len(topicParts)-1]
 	consumer, err := client.Subscribe(pulsar.ConsumerOptions{
 		Topic:                       topicName,
 		SubscriptionName:            subscriptionName,
+		Name:                        consumerName,
 		Type:                        pulsar.Exclusive,
 		ReceiverQueueSize:           1,
 		SubscriptionInitialPosition: pulsar.SubscriptionPositionEarliest,
 	})
 	if err != nil {
 		log.Errorf("failed to created partition topic consumer, error: %v", err)
+		report(&ConsumerResult{
+			Err: fmt.Errorf("failed to create consumer on %s, error: %v", topicName, err),
+		})
 		return err
 	}
-	wg.Add(1)
-	defer func() {
-		wg.Done()
-		consumer.Close()
-	}()
+	defer consumer.Close()
 
-	receivedCount := 0
-	start := time.Now()
-	for time.Since(start) <= receiveTimeout {
-		cCtx, cancel := context.WithTimeout(context.Background(), receiveTimeout)
-		defer cancel()
+	// key is the expected message, value is the expected order
+	expectedIndex := make(map[string]int, len(expectedMessages))
+	for i, m := range expectedMessages {
+		expectedIndex[m] = i
+	}
+	received := make(map[string]bool, len(expectedMessages))
+	lastIndex := -1
+	inOrder := true
 
+	cCtx, cancel := context.WithTimeout(ctx, receiveTimeout)
+	defer cancel()
+	receivedCount := 0
+	for len(received) < len(expectedMessages) {
 		log.Infof("%s wait to receive on message count %d", topicName, receivedCount)
 		receivedCount++
 		msg, err := consumer.Receive(cCtx)
 		if err != nil {
-			completeChan <- &ConsumerResult{
+			report(&ConsumerResult{
 				Err: fmt.Errorf("consumer Receive() error: %v", err),
-			}
-			break
+			})
+			return nil
 		}
 		consumer.Ack(msg)
-		if expectedMessage == string(msg.Payload()) {
-			log.Infof("expected message received by %s", topicName)
-			completeChan <- &ConsumerResult{
-				InOrderDelivery: true,
-				Timestamp:       time.Now(),
-			}
-			return nil
+		payload := string(msg.Payload())
+		index, ok := expectedIndex[payload]
+		if !ok || received[payload] {
+			continue
+		}
+		received[payload] = true
+		if index < lastIndex {
+			log.Errorf("%s received message %d after message %d out of order", topicName, index, lastIndex)
+			inOrder = false
+		} else {
+			lastIndex = index
 		}
 	}
+
+	log.Infof("all %d expected messages received by %s", len(expectedMessages), topicName)
+	if !inOrder {
+		report(&ConsumerResult{
+			Err: fmt.Errorf("%s violated ordering of %d messages", topicName, len(expectedMessages)),
+		})
+		return nil
+	}
+	report(&ConsumerResult{
+		InOrderDelivery: true,
+		Timestamp:       time.Now(),
+	})
 	return nil
 }
@@ -250,9 +250,14 @@ func StrToInt(str string, defaultNum int) int {
 
 // GetStdBucket gets the standard deviation bucket
 func GetStdBucket(key string) *stats.StandardDeviation {
+	return GetStdBucketWithWarmup(key, 0)
+}
+
+// GetStdBucketWithWarmup gets the standard deviation bucket, the warmup samples only apply to a new bucket
+func GetStdBucketWithWarmup(key string, warmupSamples int) *stats.StandardDeviation {
 	stdVerdict, ok := standardDeviationStore[key]
 	if !ok {
-		std := stats.NewStandardDeviation(key)
+		std := stats.NewStandardDeviationWithWarmup(key, warmupSamples)
 		standardDeviationStore[key] = &std
 		return &std
 	}
@@ -0,0 +1,166 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package zookeeper
+
+// a minimal ZooKeeper client that only supports session connect and the exists read,
+// enough to measure a round trip to a ZooKeeper server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	opExists       = 3
+	opCloseSession = -11
+
+	// ZooKeeper error code for no node
+	errNoNode = -101
+)
+
+// Client is a ZooKeeper session over a single server connection
+type Client struct {
+	conn    net.Conn
+	timeout time.Duration
+	xid     int32
+}
+
+// Connect establishes a ZooKeeper session to a server in the form of host:port
+func Connect(server string, timeout time.Duration) (*Client, error) {
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		conn:    conn,
+		timeout: timeout,
+	}
+	if err := c.connect(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) connect() error {
+	// protocolVersion, lastZxidSeen, timeOut, sessionId, passwd
+	req := make([]byte, 0, 44)
+	req = appendInt32(req, 0)
+	req = appendInt64(req, 0)
+	req = appendInt32(req, int32(c.timeout/time.Millisecond))
+	req = appendInt64(req, 0)
+	req = appendInt32(req, 16)
+	req = append(req, make([]byte, 16)...)
+	if err := c.write(req); err != nil {
+		return err
+	}
+
+	resp, err := c.read()
+	if err != nil {
+		return err
+	}
+	// protocolVersion, timeOut, sessionId
+	if len(resp) < 16 {
+		return fmt.Errorf("short zookeeper connect response of %d bytes", len(resp))
+	}
+	if timeout := int32(binary.BigEndian.Uint32(resp[4:8])); timeout <= 0 {
+		return fmt.Errorf("zookeeper session is rejected or expired")
+	}
+	return nil
+}
+
+// Exists returns whether the znode exists
+func (c *Client) Exists(path string) (bool, error) {
+	c.xid++
+	req := appendInt32(nil, c.xid)
+	req = appendInt32(req, opExists)
+	req = appendInt32(req, int32(len(path)))
+	req = append(req, path...)
+	req = append(req, 0) // no watch
+	if err := c.write(req); err != nil {
+		return false, err
+	}
+
+	for {
+		resp, err := c.read()
+		if err != nil {
+			return false, err
+		}
+		// xid, zxid, err
+		if len(resp) < 16 {
+			return false, fmt.Errorf("short zookeeper reply of %d bytes", len(resp))
+		}
+		if int32(binary.BigEndian.Uint32(resp[0:4])) != c.xid {
+			// skip watch events and pings
+			continue
+		}
+		switch code := int32(binary.BigEndian.Uint32(resp[12:16])); code {
+		case 0:
+			return true, nil
+		case errNoNode:
+			return false, nil
+		default:
+			return false, fmt.Errorf("zookeeper exists %s returns error code %d", path, code)
+		}
+	}
+}
+
+// Close closes the session and the connection
+func (c *Client) Close() error {
+	c.xid++
+	req := appendInt32(nil, c.xid)
+	req = appendInt32(req, opCloseSession)
+	c.write(req)
+	return c.conn.Close()
+}
+
+func (c *Client) write(buf []byte) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(append(appendInt32(nil, int32(len(buf))), buf...))
+	return err
+}
+
+func (c *Client) read() ([]byte, error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header)
+	if length > 1024*1024 {
+		return nil, fmt.Errorf("zookeeper packet length %d over the limit", length)
+	}
+	buf := make([]byte, length)
+	_, err := io.ReadFull(c.conn, buf)
+	return buf, err
+}
+
+func appendInt32(buf []byte, v int32) []byte {
+	return append(buf, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendInt64(buf []byte, v int64) []byte {
+	return appendInt32(appendInt32(buf, int32(v>>32)), int32(v))
+}
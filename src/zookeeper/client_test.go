@@ -0,0 +1,102 @@
+//
+//  Copyright (c) 2020-2021 Datastax, Inc.
+//
+//  Licensed to the Apache Software Foundation (ASF) under one
+//  or more contributor license agreements.  See the NOTICE file
+//  distributed with this work for additional information
+//  regarding copyright ownership.  The ASF licenses this file
+//  to you under the Apache License, Version 2.0 (the
+//  "License"); you may not use this file except in compliance
+//  with the License.  You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+//  Unless required by applicable law or agreed to in writing,
+//  software distributed under the License is distributed on an
+//  "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+//  KIND, either express or implied.  See the License for the
+//  specific language governing permissions and limitations
+//  under the License.
+//
+
+package zookeeper
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts a session and replies to exists requests, only the root znode exists
+func fakeServer(t *testing.T, listener net.Listener) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	readPacket := func() []byte {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return nil
+		}
+		buf := make([]byte, binary.BigEndian.Uint32(header))
+		io.ReadFull(conn, buf)
+		return buf
+	}
+	writePacket := func(buf []byte) {
+		conn.Write(append(appendInt32(nil, int32(len(buf))), buf...))
+	}
+
+	readPacket()
+	// protocolVersion, timeOut, sessionId, passwd
+	resp := appendInt32(nil, 0)
+	resp = appendInt32(resp, 6000)
+	resp = appendInt64(resp, 1)
+	resp = appendInt32(resp, 16)
+	writePacket(append(resp, make([]byte, 16)...))
+
+	for {
+		req := readPacket()
+		if req == nil {
+			return
+		}
+		xid := int32(binary.BigEndian.Uint32(req[0:4]))
+		op := int32(binary.BigEndian.Uint32(req[4:8]))
+		reply := appendInt64(appendInt32(nil, xid), 100)
+		if op == opCloseSession {
+			writePacket(appendInt32(reply, 0))
+			return
+		}
+		pathLen := binary.BigEndian.Uint32(req[8:12])
+		if string(req[12:12+pathLen]) == "/" {
+			writePacket(append(appendInt32(reply, 0), make([]byte, 68)...))
+		} else {
+			writePacket(appendInt32(reply, errNoNode))
+		}
+	}
+}
+
+func TestExists(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go fakeServer(t, listener)
+
+	client, err := Connect(listener.Addr().String(), 2*time.Second)
+	if err != nil {
+		t.Fatalf("connect error %v", err)
+	}
+	defer client.Close()
+
+	if exists, err := client.Exists("/"); err != nil || !exists {
+		t.Fatalf("expect root znode exists, error %v", err)
+	}
+	if exists, err := client.Exists("/missing"); err != nil || exists {
+		t.Fatalf("expect missing znode does not exist, error %v", err)
+	}
+}